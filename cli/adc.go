@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
@@ -24,6 +27,8 @@ var (
 	outputType        string
 	outputDir         string
 	filesListFile     string
+	keepComments      bool
+	attributes        = attributeFlag{}
 	
 	// Parallel processing & limits flags
 	maxWorkers        int
@@ -37,6 +42,9 @@ var (
 	dryRun            bool
 	validateOnly      bool
 	preserveStructure bool
+
+	// Collects the cross references of the batch for the link report
+	linkChecker *lib.LinkChecker
 )
 
 type Config struct {
@@ -46,6 +54,8 @@ type Config struct {
 	XSLFile       *string `json:"xslFile"`
 	OutputType    *string `json:"outputType"`
 	OutputDir     *string `json:"outputDir"`
+	KeepComments  *bool   `json:"keepComments"`
+	Attributes    map[string]string `json:"attributes"`
 	
 	// New batch processing fields
 	InputFolders        []string        `json:"inputFolders"`
@@ -73,6 +83,9 @@ func main() {
 	flag.StringVar(&outputDir, "out-dir", "", "Output directory (default: same as input file)")
 	flag.StringVar(&outputDir, "d", "", "Output directory (shorthand for --out-dir)")
 	flag.StringVar(&filesListFile, "files", "", "Path to file containing list of files to process")
+	flag.BoolVar(&keepComments, "keep-comments", false, "Keep // and //// comments in the output as comment elements")
+	flag.Var(attributes, "attribute", "Set a document attribute: name=value, name (empty value), name! (unset) or name=value@ (document can override); repeatable")
+	flag.Var(attributes, "a", "Set a document attribute (shorthand for --attribute)")
 
 	// New flags
 	flag.IntVar(&maxWorkers, "workers", runtime.GOMAXPROCS(0), "Maximum concurrent workers")
@@ -344,6 +357,7 @@ func main() {
 	}
 
 	// Process files using parallel processor
+	linkChecker = lib.NewLinkChecker()
 	results := lib.ProcessFilesParallel(files, func(file string) error {
 		if validateOnly {
 			// Validation only works for AsciiDoc files
@@ -357,12 +371,7 @@ func main() {
 				f.Close()
 				return nil
 			}
-			f, err := os.Open(file)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-			return lib.Validate(f)
+			return validateFile(file, logger)
 		}
 		return processFile(file, xsltPath, outputType, logger)
 	}, batchConfig, limits, func(current, total int, file string, err error) {
@@ -406,6 +415,21 @@ func main() {
 	fmt.Printf("\n\nProcessing complete in %v\n", results.Duration)
 	fmt.Printf("Success: %d\n", results.SuccessCount)
 	fmt.Printf("Errors:  %d\n", results.ErrorCount)
+
+	// Cross references that don't resolve are reported but don't fail the run
+	if broken := linkChecker.BrokenLinks(); len(broken) > 0 {
+		fmt.Printf("\nBroken links: %d\n", len(broken))
+		for _, link := range broken {
+			logger.Warn(nil, "Broken link",
+				"file", link.File,
+				"line", link.Xref.Start.Line,
+				"column", link.Xref.Start.Column,
+				"target", link.Xref.Target,
+				"message", link.Message,
+			)
+			fmt.Printf(" - %s\n", link)
+		}
+	}
 	
 	if results.ErrorCount > 0 {
 		logger.Warn(nil, "Processing completed with errors",
@@ -417,6 +441,13 @@ func main() {
 				"file", err.File,
 				"error", err.Error.Error(),
 			)
+			var validationErr *lib.ValidationError
+			if errors.As(err.Error, &validationErr) {
+				for _, d := range validationErr.Diagnostics {
+					fmt.Printf(" - %s:%s\n", err.File, d)
+				}
+				continue
+			}
 			fmt.Printf(" - %s: %v\n", err.File, err.Error)
 		}
 		os.Exit(1)
@@ -425,6 +456,97 @@ func main() {
 	logger.Infof("All files processed successfully")
 }
 
+// validateFile validates an AsciiDoc file. Warnings are logged; error
+// diagnostics are returned as a *lib.ValidationError.
+func validateFile(file string, logger *lib.Logger) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	diagnostics, err := lib.ValidateWithOptions(f, parseOptions(file, lib.DefaultOutFileSuffix))
+	if err != nil {
+		return err
+	}
+
+	var errs []lib.Diagnostic
+	for _, d := range diagnostics {
+		if d.Severity == lib.SeverityError {
+			errs = append(errs, d)
+			continue
+		}
+		logger.Warn(nil, "Validation warning",
+			"file", file,
+			"line", d.Start.Line,
+			"column", d.Start.Column,
+			"code", d.Code,
+			"message", d.Message,
+		)
+	}
+	if len(errs) > 0 {
+		return &lib.ValidationError{Diagnostics: errs}
+	}
+	return nil
+}
+
+// attributeFlag collects the document attributes given with -a
+type attributeFlag map[string]string
+
+func (a attributeFlag) String() string {
+	var pairs []string
+	for name, value := range a {
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set adds an attribute given as name=value or name
+func (a attributeFlag) Set(s string) error {
+	name, value, _ := strings.Cut(s, "=")
+	if name == "" {
+		return fmt.Errorf("attribute name missing in %q", s)
+	}
+	a[name] = value
+	return nil
+}
+
+// parseOptions returns the options for parsing file, whose inter-document
+// cross references link to files ending in suffix unless the document sets
+// outfilesuffix. The document is added to the link checker if there is one.
+// docdate and doctime come from the file's modification time.
+func parseOptions(file, suffix string) lib.ParseOptions {
+	attrs := map[string]string{"outfilesuffix": suffix + "@"}
+	for name, value := range attributes {
+		attrs[name] = value
+	}
+	opts := lib.ParseOptions{
+		DocumentName: file,
+		Attributes:   attrs,
+		LinkChecker:  linkChecker,
+		KeepComments: keepComments,
+	}
+	if info, err := os.Stat(file); err == nil {
+		opts.ModTime = info.ModTime()
+	}
+	return opts
+}
+
+// convertToHTML converts AsciiDoc content to a standalone HTML or XHTML document
+func convertToHTML(content []byte, xhtml, usePicoCSS bool, picoCSSPath string, opts lib.ParseOptions) (string, error) {
+	result, err := lib.Convert(bytes.NewReader(content), lib.ConvertOptions{
+		UsePicoCSS:   usePicoCSS,
+		Standalone:   true,
+		XHTML:        xhtml,
+		PicoCSSPath:  picoCSSPath,
+		ParseOptions: opts,
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.HTML, nil
+}
+
 // isMarkdownFile checks if a file is a markdown file based on its extension
 func isMarkdownFile(filename string) bool {
 	lower := strings.ToLower(filename)
@@ -468,9 +590,20 @@ func processFile(adocFile, xsltPath, outputType string, logger *lib.Logger) erro
 		picoCDNPath = "https://cdn.jsdelivr.net/npm/@picocss/pico@2.1.1/css/pico.min.css"
 	}
 
+	// Inter-document cross references point at the files this run writes
+	suffix := "." + outputType
+	if outputType == "xml" && !noXSL && xsltPath != "" {
+		suffix = ".html"
+	}
+	opts := parseOptions(adocFile, suffix)
+
 	switch outputType {
 	case "xml":
-		output, err = lib.ConvertToXML(strings.NewReader(string(adocContent)))
+		var doc *lib.Node
+		doc, err = lib.ParseWithOptions(context.Background(), bytes.NewReader(adocContent), opts)
+		if err == nil {
+			output = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + lib.ToXML(doc)
+		}
 		if err != nil {
 			if logger != nil {
 				logger.Error(nil, "XML conversion failed",
@@ -482,7 +615,7 @@ func processFile(adocFile, xsltPath, outputType string, logger *lib.Logger) erro
 		}
 		extension = ".xml"
 	case "html":
-		output, err = lib.ConvertToHTML(strings.NewReader(string(adocContent)), false, usePicoCSS, picoCDNPath, "")
+		output, err = convertToHTML(adocContent, false, usePicoCSS, picoCDNPath, opts)
 		if err != nil {
 			if logger != nil {
 				logger.Error(nil, "HTML conversion failed",
@@ -494,7 +627,7 @@ func processFile(adocFile, xsltPath, outputType string, logger *lib.Logger) erro
 		}
 		extension = ".html"
 	case "xhtml":
-		output, err = lib.ConvertToHTML(strings.NewReader(string(adocContent)), true, usePicoCSS, picoCDNPath, "")
+		output, err = convertToHTML(adocContent, true, usePicoCSS, picoCDNPath, opts)
 		if err != nil {
 			if logger != nil {
 				logger.Error(nil, "XHTML conversion failed",
@@ -778,6 +911,15 @@ func loadConfig() Config {
 	if config.PreserveStructure != nil && !isSet("preserve-structure") {
 		preserveStructure = *config.PreserveStructure
 	}
+	if config.KeepComments != nil && !isSet("keep-comments") {
+		keepComments = *config.KeepComments
+	}
+	// Attributes from the command line take precedence
+	for name, value := range config.Attributes {
+		if _, ok := attributes[name]; !ok {
+			attributes[name] = value
+		}
+	}
 	
 	return config
 }
@@ -523,3 +523,30 @@ func TestProcessFile_AllOutputTypes(t *testing.T) {
 		os.Remove(expectedFile)
 	}
 }
+
+func TestValidateFile(t *testing.T) {
+	logger := createTestLogger(t)
+	defer logger.Close()
+
+	tempDir := t.TempDir()
+
+	validFile := filepath.Join(tempDir, "valid.adoc")
+	os.WriteFile(validFile, []byte("= Valid\n\nSee <<missing>>."), 0644)
+	if err := validateFile(validFile, logger); err != nil {
+		t.Errorf("Expected warnings only to pass validation, got: %v", err)
+	}
+
+	invalidFile := filepath.Join(tempDir, "invalid.adoc")
+	os.WriteFile(invalidFile, []byte("= Invalid\n\n====\nNo closing delimiter"), 0644)
+	err := validateFile(invalidFile, logger)
+	if err == nil {
+		t.Fatal("Expected validation error for unclosed block")
+	}
+	validationErr, ok := err.(*lib.ValidationError)
+	if !ok {
+		t.Fatalf("Expected *lib.ValidationError, got %T", err)
+	}
+	if len(validationErr.Diagnostics) != 1 || validationErr.Diagnostics[0].Start.Line != 3 {
+		t.Errorf("Unexpected diagnostics: %v", validationErr.Diagnostics)
+	}
+}
@@ -1,13 +1,23 @@
 package lib
 
 import (
+	"context"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var componentMacroRegex = regexp.MustCompile(`^component::\w+\[.*\]$`)
 
+// imageLineRegex matches a line holding nothing but an image macro, which is
+// a block image even when written image:target[] rather than image::
+var imageLineRegex = regexp.MustCompile(`^image::?[^\s\[\]]+\[[^\]]*\]$`)
+
+// blockTitleRegex matches a block title line such as .Example
+var blockTitleRegex = regexp.MustCompile(`^\.[^ \t.].*$`)
+
 // Parse parses AsciiDoc content from a reader and returns a Document Node
 func Parse(reader io.Reader) (*Node, error) {
 	content, err := io.ReadAll(reader)
@@ -16,17 +26,61 @@ func Parse(reader io.Reader) (*Node, error) {
 	}
 
 	parser := newParser(string(content))
-	return parser.parse()
+	return parser.parse(context.Background())
+}
+
+// ParseOptions configures ParseWithOptions
+type ParseOptions struct {
+	IncludeResolver IncludeResolver // Loads include:: targets; includes are left unresolved if nil
+	MaxIncludeDepth int             // Maximum include nesting depth (0 = DefaultMaxIncludeDepth)
+	DocumentName    string          // Name of the document being parsed, passed to the resolver as the parent of its includes
+	Attributes      map[string]string // Attributes defined before the document header, e.g. from the command line
+	LinkChecker     *LinkChecker      // Records the document's IDs and cross references under DocumentName if set
+	KeepComments    bool              // Keep // and //// comments as Comment nodes instead of dropping them
+	ModTime         time.Time         // Last modification of the document, for docdate and doctime (zero = time of parsing)
+	MaxNestingDepth int               // Maximum nesting of sections, blocks and lists (0 = DefaultMaxNestingDepth)
+	MaxLineLength   int               // Maximum length of a line in bytes (0 = DefaultMaxLineLength)
+	MaxNodes        int               // Maximum number of nodes in the tree (0 = DefaultMaxNodes)
+	MaxInlineDepth  int               // Maximum nesting of inline formatting (0 = DefaultMaxInlineDepth)
+	MaxAttributeExpansion int         // Maximum bytes attribute references add to the document (0 = DefaultMaxAttributeExpansion)
+}
+
+// ParseWithOptions parses AsciiDoc content from a reader using the given
+// options. Parsing stops early if the content goes past one of the limits in
+// opts or ctx is done: the tree parsed up to then is returned along with a
+// *LimitError saying why.
+func ParseWithOptions(ctx context.Context, reader io.Reader, opts ParseOptions) (*Node, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := newParser(string(content))
+	parser.opts = opts
+	return parser.parse(ctx)
 }
 
 // parser is a text-based AsciiDoc parser
 type parser struct {
 	lines      []string
 	lineNum    int
+	lineOffset int // Number of source lines preceding lines[0] (non-zero for sub-parsers)
 	doc        *Node
 	header     *Node
-	attributes map[string]string
+	attributes map[string]string // Attributes in scope at the current line (shared with sub-parsers)
+	locked     map[string]bool   // Attributes from ParseOptions.Attributes that entries can't change
 	anchors    map[string]*Node // Registry for anchors
+	diagnostics *[]Diagnostic   // Problems found while parsing (shared with sub-parsers)
+	opts       ParseOptions
+	origins    []sourceLine // Source of each preprocessed line, indexed by lineOffset+idx (nil without includes)
+	verbatim   bool         // Comment syntax is plain text here, as in verse blocks
+	sections   *sectionNumbering // Section numbers given so far (shared with sub-parsers)
+	callouts   *calloutState     // Callout markers awaiting their list (shared with sub-parsers)
+	indexTerms int               // Index terms given an ID so far
+	idSuffixes map[string]int    // Next suffix to try for each generated section ID (shared with sub-parsers)
+	limits     *parseLimits      // Limits and context of the parse (shared with sub-parsers)
+	blocks     *blockTable       // Where delimited blocks close (shared with sub-parsers)
+	lineRunes  map[int]*runeIndex // Rune counts through long lines, for posAt
 }
 
 func newParser(content string) *parser {
@@ -35,33 +89,57 @@ func newParser(content string) *parser {
 		lines:      lines,
 		lineNum:    0,
 		attributes: make(map[string]string),
+		locked:     make(map[string]bool),
 		anchors:    make(map[string]*Node),
+		idSuffixes: make(map[string]int),
+		diagnostics: &[]Diagnostic{},
+		sections:   &sectionNumbering{},
+		callouts:   &calloutState{},
+		limits:     newParseLimits(context.Background(), ParseOptions{}),
 	}
 }
 
-// newSubParser creates a sub-parser with inherited attributes
-func (p *parser) newSubParser(content string) *parser {
+// newSubParser creates a sub-parser sharing the parent's attributes, so
+// that attribute entries within a block apply after it too.
+// startLine is the index in p.lines of the first line of content, so that
+// nodes built by the sub-parser report positions in the original source.
+func (p *parser) newSubParser(content string, startLine int) *parser {
 	subParser := newParser(content)
-	// Inherit attributes from parent
-	subParser.attributes = make(map[string]string)
-	for k, v := range p.attributes {
-		subParser.attributes[k] = v
-	}
+	subParser.lineOffset = p.lineOffset + startLine
+	subParser.attributes = p.attributes
+	subParser.locked = p.locked
 	subParser.doc = p.doc // Share document for attributes
+	subParser.anchors = p.anchors
+	subParser.idSuffixes = p.idSuffixes
+	subParser.diagnostics = p.diagnostics
+	subParser.opts = p.opts
+	subParser.origins = p.origins
+	subParser.sections = p.sections
+	subParser.callouts = p.callouts
+	subParser.limits = p.limits
+	subParser.blocks = p.blocks
 	return subParser
 }
 
-func (p *parser) parse() (*Node, error) {
-	p.doc = NewDocumentNode()
-	p.doc.SetAttribute("doctype", "article") // Default
+func (p *parser) parse(ctx context.Context) (*Node, error) {
+	p.limits = newParseLimits(ctx, p.opts)
+	p.begin()
+
+	// Evaluate conditionals and expand includes before anything else looks at the lines
+	stopped := p.preprocess()
+	if long := p.truncateLongLines(); long != nil {
+		stopped = long
+	}
 
 	// Parse header and attributes
 	p.parseHeader()
+	toc := tocPlacement(p.attributes)
 
 	// Parse preamble (content before first section)
 	// Only parse preamble if there's actually a section later
 	hasSection := false
 	for i := p.lineNum; i < len(p.lines); i++ {
+		i = p.skipCommentBlock(i)
 		line := strings.TrimSpace(p.lines[i])
 		// Check for section markers (== or ===) but NOT ==== (example block)
 		if strings.HasPrefix(line, "==") && !strings.HasPrefix(line, "====") {
@@ -80,9 +158,44 @@ func (p *parser) parse() (*Node, error) {
 	// Parse content (sections and remaining blocks)
 	p.parseContent(p.doc, nil)
 
+	// Link cross references now that every anchor is known
+	p.resolveXrefs(p.doc)
+	p.generateIndex(p.doc)
+	p.generateTOC(p.doc, toc)
+
+	p.setSpan(p.doc, 0, len(p.lines)-1)
+	if p.opts.LinkChecker != nil {
+		p.opts.LinkChecker.Add(p.opts.DocumentName, p.doc)
+	}
+	// The context may be done by now without any loop having noticed
+	if p.halted() {
+		return p.doc, p.limits.err
+	}
+	if stopped != nil {
+		return p.doc, stopped
+	}
 	return p.doc, nil
 }
 
+// begin creates the document and sets the attributes in effect before its
+// header
+func (p *parser) begin() {
+	p.doc = NewDocumentNode()
+	p.doc.SetAttribute("doctype", "article") // Default
+	p.setEnvironmentAttributes()
+
+	// Attributes from the API or command line, locked unless soft set
+	for k, v := range p.opts.Attributes {
+		entry, soft := parseLockedAttribute(k, v)
+		if !entry.unset {
+			p.attributes[entry.name] = entry.value
+		}
+		if !soft {
+			p.locked[entry.name] = true
+		}
+	}
+}
+
 // parsePreamble parses content before the first section
 func (p *parser) parsePreamble() *Node {
 	preamble := NewParagraphNode()
@@ -91,6 +204,7 @@ func (p *parser) parsePreamble() *Node {
 	// Look ahead to find first section
 	firstSectionLine := -1
 	for i := p.lineNum; i < len(p.lines); i++ {
+		i = p.skipCommentBlock(i)
 		line := strings.TrimSpace(p.lines[i])
 		if strings.HasPrefix(line, "=") && !strings.HasPrefix(line, "==") {
 			// This is document title, skip
@@ -116,9 +230,10 @@ func (p *parser) parsePreamble() *Node {
 		// Parse preamble content
 		subLines := p.lines[tempLineNum:firstSectionLine]
 		subContent := strings.Join(subLines, "\n")
-		subParser := p.newSubParser(subContent)
+		subParser := p.newSubParser(subContent, tempLineNum)
 		subParser.lineNum = 0
 		subParser.parseContent(preamble, nil)
+		p.setSpan(preamble, tempLineNum, firstSectionLine-1)
 		
 		// Restore line number
 		p.lineNum = firstSectionLine
@@ -138,6 +253,14 @@ func (p *parser) parseHeader() {
 	for p.lineNum < len(p.lines) {
 		line := strings.TrimSpace(p.lines[p.lineNum])
 
+		// Comments may appear anywhere in the header
+		if isLineComment(line) || isCommentBlockDelimiter(line) {
+			if comment := p.parseComment(); comment != nil {
+				p.doc.AddChild(comment)
+			}
+			continue
+		}
+
 		// Document title
 		if strings.HasPrefix(line, "=") && !strings.HasPrefix(line, "==") {
 			hasHeader = true
@@ -145,38 +268,23 @@ func (p *parser) parseHeader() {
 			p.doc.SetAttribute("title", titleText)
 			
 			p.lineNum++
-			continue
-		}
 
-		// Attributes
-		if strings.HasPrefix(line, ":") {
-			parts := strings.SplitN(line[1:], ":", 2)
-			if len(parts) == 2 {
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-				p.attributes[key] = value
-
-				hasHeader = true
-
-				switch key {
-				case "author":
-					p.doc.SetAttribute("author", value)
-				case "email":
-					p.doc.SetAttribute("email", value)
-				case "revnumber":
-					p.doc.SetAttribute("revnumber", value)
-				case "revdate":
-					p.doc.SetAttribute("revdate", value)
-				case "revremark":
-					p.doc.SetAttribute("revremark", value)
-				case "doctype":
-					p.doc.SetAttribute("doctype", value)
-				default:
-					// Store custom attributes with : prefix to distinguish from standard ones
-					p.doc.SetAttribute(":"+key, value)
+			// The author line, then the revision line, directly under the title
+			if p.isHeaderTextLine(p.lineNum) {
+				p.setAuthors(parseAuthorLine(p.lines[p.lineNum]))
+				p.lineNum++
+				if p.isHeaderTextLine(p.lineNum) {
+					p.setRevision(parseRevisionLine(p.lines[p.lineNum]))
+					p.lineNum++
 				}
 			}
-			p.lineNum++
+			continue
+		}
+
+		// Attribute entries
+		if looksLikeAttributeEntry(line) {
+			hasHeader = true
+			p.parseAttributeEntry()
 			continue
 		}
 
@@ -190,20 +298,40 @@ func (p *parser) parseHeader() {
 			continue
 		}
 
-		// If we hit content, stop parsing header
-		if !strings.HasPrefix(line, "=") && line != "" {
-			break
-		}
+		// Anything else (including a section title or block delimiter) is content
+		break
+	}
 
-		p.lineNum++
+	// An author given by the author and email attribute entries rather than an author line
+	if author := p.doc.GetAttribute("author"); author != "" && p.doc.GetAttribute("firstname") == "" {
+		parsed := parseAuthor(author)
+		if email := p.doc.GetAttribute("email"); email != "" {
+			parsed.Email = email
+		}
+		p.setAuthors([]Author{parsed})
 	}
 }
 
 
 // parseContent parses content items, optionally stopping at sections at or above maxLevel
 // If maxLevel is nil, it will continue until end of document
+// It stops early, keeping what it has added, once the parse reaches a limit.
 func (p *parser) parseContent(parent *Node, maxLevel *int) {
+	if !p.enter() {
+		return
+	}
+	defer p.leave()
+	counted := len(parent.Children)
+	defer func() { p.countNodes(p.lineNum, len(parent.Children)-counted) }()
+
 	for p.lineNum < len(p.lines) {
+		// Count the block added last time round
+		p.countNodes(p.lineNum, len(parent.Children)-counted)
+		counted = len(parent.Children)
+		if p.halted() {
+			return
+		}
+
 		line := p.lines[p.lineNum]
 		trimmed := strings.TrimSpace(line)
 
@@ -212,162 +340,52 @@ func (p *parser) parseContent(parent *Node, maxLevel *int) {
 			continue
 		}
 
-		// Skip standalone attribute lines that precede block delimiters
-		// This prevents them from being parsed as paragraphs
-		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
-			if p.lineNum+1 < len(p.lines) {
-				nextLine := strings.TrimSpace(p.lines[p.lineNum+1])
-				if nextLine == "--" || 
-					strings.HasPrefix(nextLine, "====") || 
-					strings.HasPrefix(nextLine, "****") || 
-					strings.HasPrefix(nextLine, "____") ||
-					strings.HasPrefix(nextLine, "++++") {
-					// This is an attribute for the next block, skip it
-					// The block parser will look back and consume it
-					p.lineNum++
-					continue
-				}
-			}
-		}
-
-		// Code block
-		if strings.HasPrefix(trimmed, "----") || strings.HasPrefix(trimmed, "```") {
-			// Check if previous line is an attribute line and skip it
-			if p.lineNum > 0 {
-				prevLine := strings.TrimSpace(p.lines[p.lineNum-1])
-				if strings.HasPrefix(prevLine, "[") && strings.HasSuffix(prevLine, "]") {
-					// This is an attribute line for the code block, it will be consumed by parseCodeBlock
-					// We don't need to do anything here as parseCodeBlock looks backwards
-				}
-			}
-			codeBlock := p.parseCodeBlock()
-			if codeBlock != nil {
-				parent.AddChild(codeBlock)
+		// Line comment or comment block
+		if !p.verbatim && (isLineComment(trimmed) || isCommentBlockDelimiter(trimmed)) {
+			if comment := p.parseComment(); comment != nil {
+				parent.AddChild(comment)
 			}
 			continue
 		}
-		
-		// Skip attribute lines that are immediately before code blocks
-		// Check if this line is an attribute and next line is a code block delimiter
-		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
-			if p.lineNum+1 < len(p.lines) {
-				nextLine := strings.TrimSpace(p.lines[p.lineNum+1])
-				if strings.HasPrefix(nextLine, "----") || strings.HasPrefix(nextLine, "```") {
-					// This is an attribute for a code block, skip it (parseCodeBlock will handle it)
-					p.lineNum++
-					continue
-				}
-			}
-		}
 
-		// Passthrough block
-		if strings.HasPrefix(trimmed, "++++") {
-			passthroughBlock := p.parsePassthroughBlock()
-			if passthroughBlock != nil {
-				parent.AddChild(passthroughBlock)
-			}
+		// Attribute entry: applies from here on
+		if !p.verbatim && looksLikeAttributeEntry(trimmed) {
+			p.parseAttributeEntry()
 			continue
 		}
 
-		// Literal block
-		if strings.HasPrefix(trimmed, "....") {
-			literalBlock := p.parseLiteralBlock()
-			if literalBlock != nil {
-				parent.AddChild(literalBlock)
-			}
+		// Block attribute line: the block that follows looks back for it
+		if isBlockAttributeLine(trimmed) {
+			p.checkBlockAttributes(p.lineNum)
+			p.lineNum++
 			continue
 		}
-		
-		// Skip attribute lines that are immediately before literal/passthrough blocks
-		// Check if this line is an attribute and next line is a block delimiter
-		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
-			if p.lineNum+1 < len(p.lines) {
-				nextLine := strings.TrimSpace(p.lines[p.lineNum+1])
-				if strings.HasPrefix(nextLine, "....") || strings.HasPrefix(nextLine, "++++") {
-					// This is an attribute for a literal/passthrough block, skip it (block parser will handle it)
-					p.lineNum++
-					continue
-				}
-			}
-		}
 
-		// Example block (check before sections to avoid matching ==== as a section)
-		if strings.HasPrefix(trimmed, "====") {
-			example := p.parseExampleBlock()
-			if example != nil {
-				parent.AddChild(example)
+		// Delimited blocks, checked before sections so that ==== isn't taken
+		// for a title. Comment blocks in verbatim content are text.
+		if opensDelimitedBlock(trimmed) {
+			if block := p.parseDelimitedBlock(); block != nil {
+				parent.AddChild(block)
 			}
 			continue
 		}
 
 		// Check if we hit a section at same or higher level (if maxLevel is set)
+		// A discrete heading doesn't end the section it is in
 		if strings.HasPrefix(trimmed, "=") {
-			if maxLevel != nil {
-				level := 0
-				line := trimmed
-				for strings.HasPrefix(line, "=") {
-					level++
-					line = line[1:]
-				}
-				// Convert to section level (subtract 1)
-				sectionLevel := level - 1
-				if sectionLevel <= *maxLevel {
-					break
-				}
+			if maxLevel != nil && p.headingLevel(trimmed) <= *maxLevel && !p.isDiscreteHeading(p.lineNum) {
+				break
 			}
 			// Parse section
-			section := p.parseSection()
+			section := p.parseSection(parent)
 			if section != nil {
 				parent.AddChild(section)
 			}
 			continue
 		}
 
-		// Sidebar
-		if strings.HasPrefix(trimmed, "****") {
-			sidebar := p.parseSidebar()
-			if sidebar != nil {
-				parent.AddChild(sidebar)
-			}
-			continue
-		}
-
-		// Verse block (check before quote to handle [verse]____)
-		if strings.HasPrefix(trimmed, "____") {
-			// Check if previous line has [verse] attribute
-			isVerse := false
-			if p.lineNum > 0 {
-				prevLine := strings.TrimSpace(p.lines[p.lineNum-1])
-				if prevLine == "[verse]" || strings.HasPrefix(prevLine, "[verse,") {
-					isVerse = true
-				}
-			}
-			if isVerse {
-				verse := p.parseVerseBlock()
-				if verse != nil {
-					parent.AddChild(verse)
-				}
-				continue
-			}
-			// Otherwise, it's a regular quote
-			quote := p.parseQuote()
-			if quote != nil {
-				parent.AddChild(quote)
-			}
-			continue
-		}
-
-		// Open block
-		if trimmed == "--" {
-			openBlock := p.parseOpenBlock()
-			if openBlock != nil {
-				parent.AddChild(openBlock)
-			}
-			continue
-		}
-
 		// Table
-		if strings.HasPrefix(trimmed, "|===") {
+		if isTableDelimiter(trimmed) {
 			table := p.parseTable()
 			if table != nil {
 				parent.AddChild(table)
@@ -375,6 +393,17 @@ func (p *parser) parseContent(parent *Node, maxLevel *int) {
 			continue
 		}
 
+		// Escaped include directive: \include::target[] is literal text
+		if strings.HasPrefix(trimmed, `\include::`) {
+			text := trimmed[1:]
+			para := NewParagraphNode()
+			p.setSpan(para, p.lineNum, p.lineNum)
+			p.parseInlineContent(para, text, newInlineSource(text, p.contentPos(p.lineNum, text)))
+			parent.AddChild(para)
+			p.lineNum++
+			continue
+		}
+
 		// Block macros: include::, toc::, video::, audio::, etc.
 		if strings.Contains(trimmed, "::") && strings.HasSuffix(trimmed, "]") {
 			// Check for standard block macros
@@ -415,13 +444,14 @@ func (p *parser) parseContent(parent *Node, maxLevel *int) {
 			continue
 		}
 
-		// List (but check if it's actually a title for a block)
+		// Block title: the block that follows looks back for it
+		if blockTitleRegex.MatchString(trimmed) {
+			p.lineNum++
+			continue
+		}
+
+		// List
 		if p.isListItem(trimmed) {
-			if p.isBlockTitle(trimmed) {
-				// This is a block title, skip this line and continue
-				p.lineNum++
-				continue
-			}
 			list := p.parseList()
 			if list != nil {
 				parent.AddChild(list)
@@ -439,7 +469,7 @@ func (p *parser) parseContent(parent *Node, maxLevel *int) {
 		}
 
 		// Image
-		if strings.HasPrefix(trimmed, "image::") || strings.HasPrefix(trimmed, "image:") {
+		if strings.HasPrefix(trimmed, "image::") || imageLineRegex.MatchString(trimmed) {
 			image := p.parseImage()
 			if image != nil {
 				parent.AddChild(image)
@@ -447,13 +477,17 @@ func (p *parser) parseContent(parent *Node, maxLevel *int) {
 			continue
 		}
 
-		// Block anchor: [[anchor-id]] or [#anchor-id]
+		// Block anchor: [[anchor-id]], [[anchor-id,reftext]] or [#anchor-id]
 		if strings.HasPrefix(trimmed, "[[") && strings.HasSuffix(trimmed, "]]") {
-			anchorID := strings.TrimPrefix(strings.TrimSuffix(trimmed, "]]"), "[[")
+			anchorID, reftext := splitAnchor(strings.TrimPrefix(strings.TrimSuffix(trimmed, "]]"), "[["))
 			anchor := NewBlockMacroNode("anchor")
 			anchor.SetAttribute("id", anchorID)
+			if reftext != "" {
+				anchor.SetAttribute("reftext", reftext)
+			}
+			p.setSpan(anchor, p.lineNum, p.lineNum)
 			parent.AddChild(anchor)
-			p.anchors[anchorID] = anchor
+			p.registerAnchor(anchorID, anchor, anchor.Start, anchor.End)
 			p.lineNum++
 			continue
 		}
@@ -461,6 +495,7 @@ func (p *parser) parseContent(parent *Node, maxLevel *int) {
 		// Thematic break
 		if trimmed == "'''" {
 			tb := NewThematicBreakNode()
+			p.setSpan(tb, p.lineNum, p.lineNum)
 			parent.AddChild(tb)
 			p.lineNum++
 			continue
@@ -469,6 +504,7 @@ func (p *parser) parseContent(parent *Node, maxLevel *int) {
 		// Page break
 		if trimmed == "<<<" {
 			pb := NewPageBreakNode()
+			p.setSpan(pb, p.lineNum, p.lineNum)
 			parent.AddChild(pb)
 			p.lineNum++
 			continue
@@ -485,101 +521,77 @@ func (p *parser) parseContent(parent *Node, maxLevel *int) {
 	}
 }
 
-func (p *parser) parseSection() *Node {
+// parseSection parses the section whose title is on the current line, with
+// its content, or a discrete heading, which has no content
+func (p *parser) parseSection(parent *Node) *Node {
+	start := p.lineNum
 	line := strings.TrimSpace(p.lines[p.lineNum])
-	level := 0
-	marker := ""
-	for strings.HasPrefix(line, "=") {
-		level++
-		marker += "="
-		line = line[1:]
-	}
+	marker := line[:len(line)-len(strings.TrimLeft(line, "="))]
 
 	// In AsciiDoc, = is document title, == is level 1 section, === is level 2, etc.
-	// So we subtract 1 from the count
-	sectionLevel := level - 1
-
-	// Check for section ID: [id] or [#id] before the title
-	var sectionID string
-	if p.lineNum > 0 {
-		prevLine := strings.TrimSpace(p.lines[p.lineNum-1])
-		if strings.HasPrefix(prevLine, "[") && strings.HasSuffix(prevLine, "]") {
-			// Could be [id] or [#id] or [.role] or [id.role]
-			idContent := prevLine[1 : len(prevLine)-1]
-			if strings.HasPrefix(idContent, "#") {
-				sectionID = strings.TrimPrefix(idContent, "#")
-			} else if !strings.HasPrefix(idContent, ".") {
-				// If it starts with a dot, it's a role, not an ID
-				// Otherwise, it could be an ID
-				parts := strings.Split(idContent, ".")
-				if len(parts) > 0 {
-					sectionID = parts[0]
-				}
-			}
-		}
-	}
+	// So we subtract 1 from the count, then apply any leveloffset
+	sectionLevel := p.headingLevel(line)
 
-	titleText := strings.TrimSpace(line)
+	titleText := strings.TrimSpace(line[len(marker):])
 	section := NewSectionNode(sectionLevel)
 	section.SetAttribute("title", titleText)
 	section.SetAttribute("marker", marker)
-	
-	if sectionID != "" {
-		section.SetAttribute("id", sectionID)
-		// Register anchor for cross-references
-		p.anchors[sectionID] = section
-		// Also register with underscore prefix for section references
-		p.anchors["_"+sectionID] = section
+
+	// Attribute line before the title, such as [#id.role] or [appendix]
+	attrs := p.applyBlockAttributes(section, p.lineNum)
+	p.styleSection(section, parent, sectionLevel, attrs.Style)
+	sectionID := attrs.ID
+	if sectionID == "" {
+		sectionID = p.adoptSectionAnchor(parent, section, p.lineNum)
 	}
-	
-	// Generate automatic ID from title if no ID specified
 	if sectionID == "" {
-		autoID := p.generateSectionID(titleText)
-		section.SetAttribute("id", autoID)
-		p.anchors[autoID] = section
-		p.anchors["_"+autoID] = section
+		// Generate an ID from the title unless sectids is unset
+		sectionID = p.generateSectionID(titleText)
+		if sectionID != "" {
+			section.SetAttribute("id", sectionID)
+			p.anchors[sectionID] = section
+		}
+	}
+	if sectionID != "" && p.anchors["_"+sectionID] == nil {
+		// Also register with underscore prefix for section references
+		p.anchors["_"+sectionID] = section
 	}
 	
 	// Add title as text content (converters will handle rendering)
-	section.AddChild(NewTextNode(titleText))
+	titleNode := NewTextNode(titleText)
+	p.setTextSpan(titleNode, start, titleText)
+	section.AddChild(titleNode)
 
 	p.lineNum++
+	if section.GetAttribute("discrete") != "" {
+		p.setSpan(section, start, start)
+		return section
+	}
 
 	// Parse section content, stopping at sections at same or higher level
 	p.parseContent(section, &sectionLevel)
 
+	p.setSpan(section, start, p.lineNum-1)
 	return section
 }
 
-// generateSectionID creates an ID from a section title
-func (p *parser) generateSectionID(title string) string {
-	// Convert to lowercase, replace spaces with underscores, remove special chars
-	id := strings.ToLower(title)
-	id = strings.ReplaceAll(id, " ", "_")
-	// Remove special characters, keep only alphanumeric and underscores
-	var result strings.Builder
-	for _, r := range id {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
-			result.WriteRune(r)
-		}
-	}
-	return result.String()
-}
-
-// getAllAttributes returns all attributes including built-in ones
+// getAllAttributes returns the attributes in scope at the current line,
+// including built-in ones such as the title
 func (p *parser) getAllAttributes() map[string]string {
 	attrs := make(map[string]string)
 	
-	// Copy document attributes
+	// Document attributes set by the parser rather than entries
 	for k, v := range p.doc.Attributes {
-		attrs[k] = v
+		if !strings.HasPrefix(k, ":") {
+			attrs[k] = v
+		}
 	}
 	
-	// Copy parser attributes (may override document attributes)
+	// Attributes set so far (may override document attributes)
 	for k, v := range p.attributes {
 		attrs[k] = v
 		// Also add with : prefix for custom attributes
-		if k != "author" && k != "email" && k != "revnumber" && k != "revdate" && k != "revremark" && k != "doctype" && k != "title" {
+		if !documentAttributes[k] && k != "title" {
 			attrs[":"+k] = v
 		}
 	}
@@ -588,7 +600,14 @@ func (p *parser) getAllAttributes() map[string]string {
 }
 
 func (p *parser) parseParagraph() *Node {
+	// A style such as [source] or [quote] makes the paragraph another block
+	if block := p.parseStyledParagraph(); block != nil {
+		return block
+	}
+
 	var lines []string
+	var lineIdx []int // Index in p.lines of each entry in lines
+	attrs := p.getAllAttributes()
 
 	for p.lineNum < len(p.lines) {
 		line := strings.TrimSpace(p.lines[p.lineNum])
@@ -602,11 +621,7 @@ func (p *parser) parseParagraph() *Node {
 		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && len(lines) == 0 {
 			if p.lineNum+1 < len(p.lines) {
 				nextLine := strings.TrimSpace(p.lines[p.lineNum+1])
-				if strings.HasPrefix(nextLine, "====") || 
-					strings.HasPrefix(nextLine, "****") || 
-					strings.HasPrefix(nextLine, "____") ||
-					strings.HasPrefix(nextLine, "++++") ||
-					nextLine == "--" {
+				if opensDelimitedBlock(nextLine) {
 					// This is an attribute for the next block, not paragraph content
 					// Don't consume it, return nil to skip paragraph creation
 					return nil
@@ -614,42 +629,32 @@ func (p *parser) parseParagraph() *Node {
 			}
 		}
 
-		// Check for attribute assignment in body: :attr-name: value
-		if strings.HasPrefix(line, ":") && strings.Contains(line, ":") {
-			parts := strings.SplitN(line[1:], ":", 2)
-			if len(parts) == 2 {
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-				p.attributes[key] = value
-				// Also set on document for built-in attributes
-				if key == "author" || key == "email" || key == "revnumber" || key == "revdate" || key == "revremark" || key == "doctype" {
-					p.doc.SetAttribute(key, value)
-				} else {
-					p.doc.SetAttribute(":"+key, value)
-				}
-				p.lineNum++
-				continue
-			}
+		// An attribute entry ends the paragraph; parseContent applies it
+		if looksLikeAttributeEntry(line) && !p.verbatim {
+			break
+		}
+
+		// Line comments within a paragraph are dropped
+		if isLineComment(line) && !p.verbatim {
+			p.lineNum++
+			continue
 		}
 
 		// Stop at block delimiters
 		if strings.HasPrefix(line, "=") ||
-			strings.HasPrefix(line, "++++") ||
-			strings.HasPrefix(line, "----") ||
-			strings.HasPrefix(line, "....") ||
-			strings.HasPrefix(line, "====") ||
-			strings.HasPrefix(line, "****") ||
-			strings.HasPrefix(line, "____") ||
-			strings.HasPrefix(line, "|===") ||
+			(isCommentBlockDelimiter(line) && !p.verbatim) ||
+			opensDelimitedBlock(line) ||
+			isTableDelimiter(line) ||
 			componentMacroRegex.MatchString(line) ||
 			strings.HasPrefix(line, "image::") ||
-			strings.HasPrefix(line, "image:") ||
+			imageLineRegex.MatchString(line) ||
 			p.isListItem(line) ||
 			p.isAdmonition(line) {
 			break
 		}
 
 		lines = append(lines, line)
+		lineIdx = append(lineIdx, p.lineNum)
 		p.lineNum++
 	}
 
@@ -657,354 +662,158 @@ func (p *parser) parseParagraph() *Node {
 		return nil
 	}
 
-	text := strings.Join(lines, " ")
-	// Substitute attributes in text
-	text = SubstituteAttributes(text, p.getAllAttributes())
+	// An admonition style such as [NOTE] turns the paragraph into the
+	// content of an admonition, which takes its attributes
 	para := NewParagraphNode()
-	p.parseInlineContent(para, text)
-	return para
-}
-
-func (p *parser) parseCodeBlock() *Node {
-	// Parse attributes from previous line(s) if present
-	var language, title, role string
-	// Look back for title and attributes (skip empty lines)
-	for i := p.lineNum - 1; i >= 0 && i >= p.lineNum-3; i-- {
-		prevLine := strings.TrimSpace(p.lines[i])
-		if prevLine == "" {
-			continue
-		}
-		if strings.HasPrefix(prevLine, "[") && strings.HasSuffix(prevLine, "]") {
-			attrs := prevLine[1 : len(prevLine)-1]
-			parts := strings.Split(attrs, ",")
-			// Format is [source,language] or [language] or [mermaid] or [role="mermaid"]
-			// Check for mermaid role first
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if part == "mermaid" {
-					role = "mermaid"
-				} else if strings.HasPrefix(part, "role=") {
-					// Handle role="mermaid" or role='mermaid'
-					roleValue := strings.TrimSpace(strings.TrimPrefix(part, "role="))
-					roleValue = strings.Trim(roleValue, "\"'")
-					if roleValue == "mermaid" {
-						role = "mermaid"
-					}
-				}
-			}
-			if len(parts) > 1 {
-				// [source,go] format
-				language = strings.TrimSpace(parts[1])
-			} else if len(parts) > 0 {
-				// [go] format or [mermaid]
-				firstPart := strings.TrimSpace(parts[0])
-				if firstPart != "source" && firstPart != "mermaid" {
-					language = firstPart
-				}
+	p.setSpan(para, lineIdx[0], lineIdx[len(lineIdx)-1])
+	block := para
+	if attrs, _ := p.blockAttributes(lineIdx[0]); admonitionStyles[attrs.Style] {
+		block = NewAdmonitionNode()
+		block.SetAttribute("type", strings.ToLower(attrs.Style))
+		block.Start, block.End = para.Start, para.End
+		block.AddChild(para)
+	}
+	p.applyBlockAttributes(block, lineIdx[0])
+	subs := p.blockSubs(block, normalSubs)
+	hardbreaks := p.hardBreaks(block)
+
+	// Substitute attributes line by line so inline positions can be mapped
+	// back to the source. Lines are joined by a space, or by a line break
+	// where post replacements may turn it into a hard line break.
+	src := &inlineSource{}
+	for i, line := range lines {
+		if subs&subAttributes != 0 {
+			text, drop := p.substituteAttributes(lineIdx[i], line, attrs)
+			if drop {
+				continue
 			}
+			line = text
 		}
-		if strings.HasPrefix(prevLine, ".") {
-			title = strings.TrimPrefix(prevLine, ".")
-			break // Title found, stop looking
+		if len(src.segments) > 0 {
+			if subs&subPostReplacements != 0 && (hardbreaks || strings.HasSuffix(src.text(), " +")) {
+				src.buf.WriteString("\n")
+			} else {
+				src.buf.WriteString(" ")
+			}
 		}
+		src.addSegment(line, p.contentPos(lineIdx[i], strings.TrimSpace(p.lines[lineIdx[i]])))
 	}
-
-	p.lineNum++ // Skip opening delimiter
-
-	var content []string
-	for p.lineNum < len(p.lines) {
-		line := p.lines[p.lineNum]
-		if strings.TrimSpace(line) == "----" || strings.TrimSpace(line) == "```" {
-			p.lineNum++
-			break
-		}
-		content = append(content, line)
-		p.lineNum++
+	if len(src.segments) == 0 {
+		return nil
 	}
+	p.parseInlineSubs(para, src.text(), src, subs, hardbreaks)
+	return block
+}
 
+func (p *parser) buildCodeBlock(start, contentStart int, content []string) *Node {
 	codeBlock := NewCodeBlockNode()
-	if role != "" {
-		codeBlock.SetAttribute("role", role)
+	// Format is [source,language] or [language]; [mermaid] and
+	// [source,mermaid] mark a diagram like [.mermaid] does
+	attrs := p.applyBlockAttributes(codeBlock, start)
+	for _, part := range attrs.Positional {
+		if part == "mermaid" {
+			codeBlock.SetAttribute("role", "mermaid")
+		}
+	}
+	language := attrs.Named["language"]
+	if len(attrs.Positional) > 1 {
+		language = attrs.At(1)
+	} else if attrs.Style != "source" && attrs.Style != "listing" && attrs.Style != "mermaid" {
+		language = attrs.Style
 	}
 	if language != "" {
 		codeBlock.SetAttribute("language", language)
 	}
-	if title != "" {
-		codeBlock.SetAttribute("title", title)
-	}
-	codeBlock.AddChild(NewTextNode(strings.Join(content, "\n")))
+	p.captionBlock(codeBlock)
+	content = applyListingOptions(codeBlock, attrs, content)
+	p.setSpan(codeBlock, start, p.lineNum-1)
+	p.addCode(codeBlock, content, contentStart, p.blockSubs(codeBlock, verbatimSubs))
 	return codeBlock
 }
 
-func (p *parser) parseLiteralBlock() *Node {
-	// Parse attributes from previous line(s) if present
-	var role string
-	// Look back for attributes (skip empty lines)
-	for i := p.lineNum - 1; i >= 0 && i >= p.lineNum-3; i-- {
-		prevLine := strings.TrimSpace(p.lines[i])
-		if prevLine == "" {
-			continue
-		}
-		if strings.HasPrefix(prevLine, "[") && strings.HasSuffix(prevLine, "]") {
-			attrs := prevLine[1 : len(prevLine)-1]
-			parts := strings.Split(attrs, ",")
-			// Check for mermaid role
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if part == "mermaid" {
-					role = "mermaid"
-					break
-				} else if strings.HasPrefix(part, "role=") {
-					// Handle role="mermaid" or role='mermaid'
-					roleValue := strings.TrimSpace(strings.TrimPrefix(part, "role="))
-					roleValue = strings.Trim(roleValue, "\"'")
-					if roleValue == "mermaid" {
-						role = "mermaid"
-						break
-					}
-				}
-			}
-			break
-		}
-		if strings.HasPrefix(prevLine, ".") {
-			break // Title found, stop looking
-		}
-	}
-
-	p.lineNum++ // Skip opening
-	var content []string
-	for p.lineNum < len(p.lines) {
-		line := p.lines[p.lineNum]
-		if strings.TrimSpace(line) == "...." {
-			p.lineNum++
-			break
-		}
-		content = append(content, line)
-		p.lineNum++
-	}
+func (p *parser) buildLiteralBlock(start, contentStart int, content []string) *Node {
 	literalBlock := NewLiteralBlockNode()
-	if role != "" {
-		literalBlock.SetAttribute("role", role)
+	// [mermaid] marks a diagram like [.mermaid] does
+	if attrs := p.applyBlockAttributes(literalBlock, start); attrs.Style == "mermaid" {
+		literalBlock.SetAttribute("role", "mermaid")
 	}
-	literalBlock.AddChild(NewTextNode(strings.Join(content, "\n")))
+	p.setSpan(literalBlock, start, p.lineNum-1)
+	p.addCode(literalBlock, content, contentStart, p.blockSubs(literalBlock, verbatimSubs))
 	return literalBlock
 }
 
-func (p *parser) parseExampleBlock() *Node {
-	// Check for title and attributes on previous non-empty line(s)
-	var title string
-	attrs := make(map[string]string)
-	
-	for i := p.lineNum - 1; i >= 0 && i >= p.lineNum-3; i-- {
-		prevLine := strings.TrimSpace(p.lines[i])
-		if prevLine == "" {
-			continue
-		}
-		// Block title starts with . but not ..
-		if strings.HasPrefix(prevLine, ".") && !strings.HasPrefix(prevLine, "..") {
-			title = strings.TrimPrefix(prevLine, ".")
-		}
-		// Role/ID attributes in brackets
-		if strings.HasPrefix(prevLine, "[") && strings.HasSuffix(prevLine, "]") {
-			attrContent := prevLine[1 : len(prevLine)-1]
-			parts := strings.Split(attrContent, ",")
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if strings.HasPrefix(part, ".") {
-					attrs["role"] = strings.TrimPrefix(part, ".")
-				} else if strings.HasPrefix(part, "#") {
-					attrs["id"] = strings.TrimPrefix(part, "#")
-				}
-			}
-		}
-	}
-
-	p.lineNum++ // Skip opening
-
-	var contentLines []string
-	for p.lineNum < len(p.lines) {
-		line := strings.TrimSpace(p.lines[p.lineNum])
-		if line == "====" {
-			p.lineNum++
-			break
-		}
-		contentLines = append(contentLines, p.lines[p.lineNum])
-		p.lineNum++
-	}
-
-	// Re-parse content within the example block
-	// For simplicity in this refactor, we can create a new parser or recursively call parseContent logic
-	// But we need to pass the lines. 
-	// Let's just use a sub-parser approach for simplicity since we have the lines extracted.
-	
+func (p *parser) buildExampleBlock(start, contentStart int, contentLines []string) *Node {
+	// Re-parse content within the example block with a sub-parser
 	subContent := strings.Join(contentLines, "\n")
-	subParser := p.newSubParser(subContent)
-	example := NewExampleNode()
-	if title != "" {
-		example.SetAttribute("title", title)
-	}
-	for k, v := range attrs {
-		example.SetAttribute(k, v)
-	}
-	subParser.parseContent(example, nil)
+	subParser := p.newSubParser(subContent, contentStart)
+	// An admonition style such as [NOTE] makes it an admonition block
+	block := NewExampleNode()
+	if attrs, _ := p.blockAttributes(start); admonitionStyles[attrs.Style] {
+		block = NewAdmonitionNode()
+		block.SetAttribute("type", strings.ToLower(attrs.Style))
+	}
+	p.applyBlockAttributes(block, start)
+	p.captionBlock(block)
+	subParser.parseContent(block, nil)
+	p.setSpan(block, start, p.lineNum-1)
 	
-	return example
+	return block
 }
 
-func (p *parser) parseSidebar() *Node {
-	p.lineNum++ // Skip opening
-	var title string
-	var contentLines []string
-	
-	// Check for title in previous lines logic is tricky if we already advanced. 
-	// Assuming title was checked before or is inside.
-	// Actually the previous parser checked lines *before* the block start.
-	// Let's check if there was a title before.
-	for i := p.lineNum - 2; i >= 0; i-- {
-		prevLine := strings.TrimSpace(p.lines[i])
-		if prevLine == "" {
-			continue
-		}
-		if strings.HasPrefix(prevLine, ".") {
-			title = strings.TrimPrefix(prevLine, ".")
-		}
-		break
-	}
-
-	for p.lineNum < len(p.lines) {
-		line := strings.TrimSpace(p.lines[p.lineNum])
-		if line == "****" {
-			p.lineNum++
-			break
-		}
-		contentLines = append(contentLines, p.lines[p.lineNum])
-		p.lineNum++
-	}
-
+func (p *parser) buildSidebar(start, contentStart int, contentLines []string) *Node {
 	sidebar := NewSidebarNode()
-	if title != "" {
-		sidebar.SetAttribute("title", title)
-	}
+	p.applyBlockAttributes(sidebar, start)
 	
 	subContent := strings.Join(contentLines, "\n")
-	subParser := p.newSubParser(subContent)
+	subParser := p.newSubParser(subContent, contentStart)
 	subParser.parseContent(sidebar, nil)
+	p.setSpan(sidebar, start, p.lineNum-1)
 	
 	return sidebar
 }
 
-func (p *parser) parseQuote() *Node {
-	p.lineNum++ // Skip opening
-	var contentLines []string
-	
-	var attribution, citation string
-	// Check for attribution line at bottom usually? 
-	// AsciiDoc quotes: 
-	// [quote, attribution, citation]
-	// ____
-	// content
-	// ____
-	
-	// Check for attributes block above
-	for i := p.lineNum - 2; i >= 0 && i >= p.lineNum-4; i-- {
-		prevLine := strings.TrimSpace(p.lines[i])
-		if prevLine == "" {
-			continue
-		}
-		if strings.HasPrefix(prevLine, "[") && strings.HasSuffix(prevLine, "]") {
-			attrs := prevLine[1 : len(prevLine)-1]
-			parts := strings.Split(attrs, ",")
-			// quote, attribution, citation
-			if len(parts) > 1 {
-				attribution = strings.TrimSpace(parts[1])
-			}
-			if len(parts) > 2 {
-				citation = strings.TrimSpace(parts[2])
-			}
-		}
-		break
-	}
-
-	for p.lineNum < len(p.lines) {
-		line := strings.TrimSpace(p.lines[p.lineNum])
-		if line == "____" {
-			p.lineNum++
-			break
-		}
-		contentLines = append(contentLines, p.lines[p.lineNum])
-		p.lineNum++
-	}
-
+func (p *parser) buildQuote(start, contentStart int, contentLines []string) *Node {
 	quote := NewQuoteNode()
-	if attribution != "" {
+	// [quote, attribution, citation]
+	attrs := p.applyBlockAttributes(quote, start)
+	if attribution := attrs.At(1); attribution != "" {
 		quote.SetAttribute("attribution", attribution)
 	}
-	if citation != "" {
+	if citation := attrs.At(2); citation != "" {
 		quote.SetAttribute("citation", citation)
 	}
 
 	subContent := strings.Join(contentLines, "\n")
-	subParser := p.newSubParser(subContent)
+	subParser := p.newSubParser(subContent, contentStart)
 	subParser.parseContent(quote, nil)
+	p.setSpan(quote, start, p.lineNum-1)
 
 	return quote
 }
 
-func (p *parser) parseVerseBlock() *Node {
-	// Check for [verse] attribute on previous line
-	var title, attribution string
-	if p.lineNum > 0 {
-		prevLine := strings.TrimSpace(p.lines[p.lineNum-1])
-		if strings.HasPrefix(prevLine, "[verse") {
-			// Parse attributes if present
-			if strings.Contains(prevLine, ",") {
-				parts := strings.SplitN(prevLine[1:len(prevLine)-1], ",", 2)
-				if len(parts) > 1 {
-					attribution = strings.TrimSpace(parts[1])
-				}
-			}
-		}
-		// Check for title before that
-		if p.lineNum > 1 {
-			titleLine := strings.TrimSpace(p.lines[p.lineNum-2])
-			if strings.HasPrefix(titleLine, ".") {
-				title = strings.TrimPrefix(titleLine, ".")
-			}
-		}
-	}
-
-	p.lineNum++ // Skip opening
-	var contentLines []string
-
-	for p.lineNum < len(p.lines) {
-		line := strings.TrimSpace(p.lines[p.lineNum])
-		if line == "____" {
-			p.lineNum++
-			break
-		}
-		contentLines = append(contentLines, p.lines[p.lineNum])
-		p.lineNum++
-	}
-
+func (p *parser) buildVerseBlock(start, contentStart int, contentLines []string) *Node {
 	verse := NewVerseBlockNode()
-	if title != "" {
-		verse.SetAttribute("title", title)
-	}
-	if attribution != "" {
+	// [verse, attribution, citation]
+	attrs := p.applyBlockAttributes(verse, start)
+	if attribution := attrs.At(1); attribution != "" {
 		verse.SetAttribute("attribution", attribution)
 	}
+	if citation := attrs.At(2); citation != "" {
+		verse.SetAttribute("citation", citation)
+	}
 
 	// Verse blocks preserve line breaks, so we parse content but preserve structure
 	subContent := strings.Join(contentLines, "\n")
-	subParser := p.newSubParser(subContent)
+	subParser := p.newSubParser(subContent, contentStart)
+	subParser.verbatim = true
 	subParser.parseContent(verse, nil)
 
 	// Check for attribution after closing delimiter
 	if p.lineNum < len(p.lines) {
 		line := strings.TrimSpace(p.lines[p.lineNum])
-		if strings.HasPrefix(line, "--") {
-			attribution = strings.TrimPrefix(line, "--")
-			attribution = strings.TrimSpace(attribution)
+		// A bare -- opens an open block instead
+		if strings.HasPrefix(line, "-- ") {
+			attribution := strings.TrimSpace(strings.TrimPrefix(line, "--"))
 			if attribution != "" {
 				verse.SetAttribute("attribution", attribution)
 			}
@@ -1012,286 +821,144 @@ func (p *parser) parseVerseBlock() *Node {
 		}
 	}
 
+	p.setSpan(verse, start, p.lineNum-1)
 	return verse
 }
 
-func (p *parser) parseOpenBlock() *Node {
-	// Parse attributes from previous line(s)
-	attrs := make(map[string]string)
-	if p.lineNum > 0 {
-		prevLine := strings.TrimSpace(p.lines[p.lineNum-1])
-		if strings.HasPrefix(prevLine, "[") && strings.HasSuffix(prevLine, "]") {
-			attrContent := prevLine[1 : len(prevLine)-1]
-			parts := strings.Split(attrContent, ",")
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if strings.Contains(part, "=") {
-					kv := strings.SplitN(part, "=", 2)
-					key := strings.TrimSpace(kv[0])
-					val := strings.TrimSpace(kv[1])
-					if len(val) >= 2 && ((val[0] == '"' && val[len(val)-1] == '"') || (val[0] == '\'' && val[len(val)-1] == '\'')) {
-						val = val[1 : len(val)-1]
-					}
-					attrs[key] = val
-				} else if strings.HasPrefix(part, "#") {
-					attrs["id"] = strings.TrimPrefix(part, "#")
-				} else if strings.HasPrefix(part, ".") {
-					attrs["role"] = strings.TrimPrefix(part, ".")
-				}
-			}
-		}
-	}
-
-	p.lineNum++ // Skip opening
-	var contentLines []string
-
-	for p.lineNum < len(p.lines) {
-		line := strings.TrimSpace(p.lines[p.lineNum])
-		if line == "--" {
-			p.lineNum++
-			break
-		}
-		contentLines = append(contentLines, p.lines[p.lineNum])
-		p.lineNum++
-	}
-
+// buildOpenBlock builds an open block. A style that doesn't make it another
+// kind of block, such as [abstract], is kept as its style attribute.
+func (p *parser) buildOpenBlock(start, contentStart int, contentLines []string) *Node {
 	openBlock := NewOpenBlockNode()
-	for k, v := range attrs {
-		openBlock.SetAttribute(k, v)
+	if attrs := p.applyBlockAttributes(openBlock, start); attrs.Style != "" && attrs.Style != "open" {
+		openBlock.SetAttribute("style", attrs.Style)
 	}
 
 	subContent := strings.Join(contentLines, "\n")
-	subParser := p.newSubParser(subContent)
+	subParser := p.newSubParser(subContent, contentStart)
 	subParser.parseContent(openBlock, nil)
+	p.setSpan(openBlock, start, p.lineNum-1)
 
 	return openBlock
 }
 
-func (p *parser) parsePassthroughBlock() *Node {
-	p.lineNum++ // Skip opening ++++
-	var contentLines []string
-	
-	for p.lineNum < len(p.lines) {
-		line := p.lines[p.lineNum]
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "++++") {
-			p.lineNum++ // Skip closing ++++
-			break
-		}
-		contentLines = append(contentLines, line)
-		p.lineNum++
-	}
-	
+// buildPassthroughBlock builds a passthrough block, or with stem set a stem
+// block, whose math is kept as written. A stem block's style records its
+// notation; [stem] uses the one named by the stem document attribute.
+func (p *parser) buildPassthroughBlock(start int, contentLines []string, stem bool) *Node {
 	// Join with newlines to preserve formatting
 	content := strings.Join(contentLines, "\n")
 	passthrough := NewPassthroughBlockNode(content)
+	attrs := p.applyBlockAttributes(passthrough, start)
+	if stem {
+		passthrough.SetAttribute("style", p.stemNotation(attrs.Style))
+	}
+	p.setSpan(passthrough, start, p.lineNum-1)
+	passthrough.Content = p.substitutePassthrough(start, content, p.blockSubs(passthrough, 0))
 	return passthrough
 }
 
-func (p *parser) parseTable() *Node {
-	// Parse table attributes
-	tableAttrs := make(map[string]string)
-	
-	// Look back for attributes [cols="...", options="..."]
-	for i := p.lineNum - 1; i >= 0; i-- {
-		prevLine := strings.TrimSpace(p.lines[i])
-		if prevLine == "" {
-			continue
-		}
-		if strings.HasPrefix(prevLine, "[") && strings.HasSuffix(prevLine, "]") {
-			// Parse attributes: key=val, key=val or just val
-			content := prevLine[1 : len(prevLine)-1]
-			
-			// Simple parser for key="val" or key=val
-			// Note: This is a basic implementation and might need a proper scanner for complex attributes
-			parts := strings.Split(content, ",")
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if strings.Contains(part, "=") {
-					// Support modern HTML attributes: keys can contain :, @, ., _, -
-					// Values can contain @, $, {, }, (, ) and other special chars
-					kv := strings.SplitN(part, "=", 2)
-					key := strings.TrimSpace(kv[0])
-					val := strings.TrimSpace(kv[1])
-					// Remove quotes only from the outer edges (handles both "value" and 'value')
-					if len(val) >= 2 && ((val[0] == '"' && val[len(val)-1] == '"') || (val[0] == '\'' && val[len(val)-1] == '\'')) {
-						val = val[1 : len(val)-1]
-					}
-					tableAttrs[key] = val
-				} else {
-					// Positional or boolean attributes
-					// Currently just storing as is if needed, or specific handling
-					// For now, handle 'header', 'footer' which are often in options
-					if part == "header" || part == "footer" {
-						// Append to options
-						if opts, ok := tableAttrs["options"]; ok {
-							tableAttrs["options"] = opts + "," + part
-						} else {
-							tableAttrs["options"] = part
-						}
-					}
-				}
-			}
-			break // Attributes found
-		}
-		// If we hit a title (starts with .) continue looking before it
-		if strings.HasPrefix(prevLine, ".") {
-			continue
-		}
-		break // Hit something else
-	}
-
-	p.lineNum++ // Skip opening
-	table := NewTableNode()
-	
-	// Apply attributes
-	for k, v := range tableAttrs {
-		table.SetAttribute(k, v)
-	}
-
-	var rows []*Node
-	
-	for p.lineNum < len(p.lines) {
-		line := strings.TrimSpace(p.lines[p.lineNum])
-		if line == "|===" {
-			p.lineNum++
-			break
-		}
+// List item markers. Labeled list delimiters must be followed by a space or
+// the end of the line, so block macros like include::x[] don't match.
+var (
+	unorderedItemRegex = regexp.MustCompile(`^(\*{1,5}|-)[ \t]+(.*)$`)
+	orderedItemRegex   = regexp.MustCompile(`^(\.{1,5}|\d+\.|[a-zA-Z]\.|[ivxIVX]+\))[ \t]+(.*)$`)
+	labeledItemRegex   = regexp.MustCompile(`^(\S.*?)(:{2,4}|;;)(?:[ \t]+(.*))?$`)
+	checklistItemRegex = regexp.MustCompile(`^\[([ xX*])\][ \t]+(.*)$`)
+)
 
-		if strings.HasPrefix(line, "|") {
-			cells := strings.Split(line, "|")
-			row := NewTableRowNode()
-			
-			// Check if this is a header row (first non-empty row or explicitly marked)
-			isHeaderRow := len(rows) == 0
-			if opts, ok := tableAttrs["options"]; ok && strings.Contains(opts, "header") {
-				// Header rows are explicitly marked
-				isHeaderRow = len(rows) == 0 || (len(rows) == 1 && strings.Contains(tableAttrs["options"], "header"))
-			}
-			if isHeaderRow {
-				row.SetAttribute("role", "header")
-			}
+// listMarker describes the marker at the start of a list item line
+type listMarker struct {
+	key     string // Identifies the list the item belongs to, e.g. "**", "1." or ":::"
+	style   string // unordered, ordered, labeled or callout
+	term    string // Term of a labeled list item
+	callout string // Number of a callout list item, or . to number it in order
+	text    string // Text following the marker
+}
 
-			for i := 1; i < len(cells); i++ {
-				cellText := strings.TrimSpace(cells[i])
-				
-				// Parse cell attributes and alignment: [.class]#text# or [align=left]#text# or |^text| or |vtext|
-				var cellAttrs map[string]string
-				var alignment string
-				var actualText string
-				
-				// Check for alignment markers: ^ (top), v (bottom), or default (middle)
-				if strings.HasPrefix(cellText, "^") {
-					alignment = "top"
-					actualText = cellText[1:]
-				} else if strings.HasPrefix(cellText, "v") {
-					alignment = "bottom"
-					actualText = cellText[1:]
-				} else {
-					actualText = cellText
-				}
-				
-				// Check for cell attributes: [.class]#text# or [colspan=2]#text#
-				if strings.Contains(actualText, "#") && strings.Contains(actualText, "[") {
-					attrStart := strings.Index(actualText, "[")
-					attrEnd := strings.Index(actualText[attrStart:], "]")
-					if attrEnd > 0 {
-						attrEnd += attrStart
-						attrStr := actualText[attrStart+1 : attrEnd]
-						contentStart := strings.Index(actualText[attrEnd:], "#")
-						contentEnd := strings.LastIndex(actualText, "#")
-						if contentStart >= 0 && contentEnd > attrEnd {
-							contentStart += attrEnd
-							cellAttrs = make(map[string]string)
-							// Parse attributes
-							if strings.HasPrefix(attrStr, ".") {
-								cellAttrs["role"] = strings.TrimPrefix(attrStr, ".")
-							} else if strings.HasPrefix(attrStr, "#") {
-								cellAttrs["id"] = strings.TrimPrefix(attrStr, "#")
-							} else if strings.Contains(attrStr, "=") {
-								// Key-value attributes like colspan=2
-								parts := strings.Split(attrStr, ",")
-								for _, part := range parts {
-									part = strings.TrimSpace(part)
-									if strings.Contains(part, "=") {
-										kv := strings.SplitN(part, "=", 2)
-										key := strings.TrimSpace(kv[0])
-										val := strings.TrimSpace(kv[1])
-										if len(val) >= 2 && ((val[0] == '"' && val[len(val)-1] == '"') || (val[0] == '\'' && val[len(val)-1] == '\'')) {
-											val = val[1 : len(val)-1]
-										}
-										cellAttrs[key] = val
-									}
-								}
-							} else {
-								// Could be id.role format
-								parts := strings.Split(attrStr, ".")
-								if len(parts) > 0 {
-									cellAttrs["id"] = parts[0]
-								}
-								if len(parts) > 1 {
-									cellAttrs["role"] = strings.Join(parts[1:], ".")
-								}
-							}
-							// Extract content between # markers
-							actualText = actualText[contentStart+1:contentEnd]
-						}
-					}
-				}
-				
-				// Check for horizontal alignment in cell text: left, center, right
-				// AsciiDoc uses <, ^, > for left, center, right alignment
-				if strings.HasPrefix(actualText, "<") {
-					alignment = "left"
-					actualText = actualText[1:]
-				} else if strings.HasPrefix(actualText, "^") {
-					if alignment == "" {
-						alignment = "center"
-					}
-					actualText = actualText[1:]
-				} else if strings.HasPrefix(actualText, ">") {
-					alignment = "right"
-					actualText = actualText[1:]
-				}
-				
-				// Substitute attributes in cell text
-				actualText = SubstituteAttributes(actualText, p.getAllAttributes())
-				cell := NewTableCellNode()
-				
-				// Set cell attributes
-				if alignment != "" {
-					cell.SetAttribute("align", alignment)
-				}
-				if cellAttrs != nil {
-					for k, v := range cellAttrs {
-						cell.SetAttribute(k, v)
-					}
-				}
-				
-				p.parseInlineContent(cell, actualText)
-				row.AddChild(cell)
+// parseListMarker parses the list item marker at the start of line
+func parseListMarker(line string) (listMarker, bool) {
+	line = strings.TrimSpace(line)
+	if m := unorderedItemRegex.FindStringSubmatch(line); m != nil {
+		return listMarker{key: m[1], style: "unordered", text: m[2]}, true
+	}
+	if m := orderedItemRegex.FindStringSubmatch(line); m != nil {
+		key := m[1]
+		switch {
+		case strings.HasPrefix(key, "."):
+		case key[0] >= '0' && key[0] <= '9':
+			key = "1."
+		case strings.HasSuffix(key, ")"):
+			if strings.ToLower(key) == key {
+				key = "i)"
+			} else {
+				key = "I)"
 			}
-
-			rows = append(rows, row)
+		case key[0] >= 'a' && key[0] <= 'z':
+			key = "a."
+		default:
+			key = "A."
 		}
-		p.lineNum++
+		return listMarker{key: key, style: "ordered", text: m[2]}, true
 	}
-
-	// Append all rows
-	for _, row := range rows {
-		table.AddChild(row)
+	if m := calloutItemRegex.FindStringSubmatch(line); m != nil {
+		return listMarker{key: "<>", style: "callout", callout: m[1], text: m[2]}, true
 	}
-
-	return table
+	if strings.HasPrefix(line, "//") {
+		return listMarker{}, false
+	}
+	if m := labeledItemRegex.FindStringSubmatch(line); m != nil {
+		return listMarker{key: m[2], style: "labeled", term: m[1], text: m[3]}, true
+	}
+	return listMarker{}, false
 }
 
+// parseList parses a list starting at the current line, including any lists
+// nested in its items
 func (p *parser) parseList() *Node {
+	start := p.lineNum
+	list := p.parseListLevel(nil)
+	p.applyListAttributes(list, start)
+	if list.GetAttribute("style") == "callout" {
+		p.linkCallouts(list)
+	}
+	return list
+}
+
+// parseListLevel parses the items of one list. A list is a run of items with
+// the same marker; an item with a new marker starts a list nested in the
+// previous item, and an item whose marker belongs to one of the enclosing
+// lists (ancestors) ends this one.
+func (p *parser) parseListLevel(ancestors []string) *Node {
+	start := p.lineNum
 	var items []*Node
+	var itemStarts []int // Index of the first line of each item
 	style := "unordered"
+	key := ""
+	checklist := false
+	if !p.enter() {
+		return p.finishList(items, itemStarts, style, checklist, start)
+	}
+	defer p.leave()
 
-	for p.lineNum < len(p.lines) {
+	for p.lineNum < len(p.lines) && !p.halted() {
 		line := strings.TrimSpace(p.lines[p.lineNum])
 
+		// Blank lines may separate items; the list ends at anything else
+		if line == "" {
+			next := p.lineNum
+			for next < len(p.lines) && strings.TrimSpace(p.lines[next]) == "" {
+				next++
+			}
+			if next >= len(p.lines) {
+				break
+			}
+			if _, ok := parseListMarker(p.lines[next]); !ok {
+				break
+			}
+			p.lineNum = next
+			continue
+		}
+
 		// Check for list continuation: + on separate line
 		if line == "+" {
 			// This is a continuation marker - the next content should be added to the last item
@@ -1303,29 +970,24 @@ func (p *parser) parseList() *Node {
 				// We'll handle this by parsing content into the last item
 				if p.lineNum < len(p.lines) {
 					nextLine := strings.TrimSpace(p.lines[p.lineNum])
-					// Check what type of block follows
-					if strings.HasPrefix(nextLine, "----") || strings.HasPrefix(nextLine, "```") {
-						codeBlock := p.parseCodeBlock()
-						if codeBlock != nil {
-							lastItem.AddChild(codeBlock)
+					// Skip a block attribute line; the block parser looks back for it
+					if strings.HasPrefix(nextLine, "[") && strings.HasSuffix(nextLine, "]") && p.lineNum+1 < len(p.lines) {
+						if following := strings.TrimSpace(p.lines[p.lineNum+1]); opensDelimitedBlock(following) || isTableDelimiter(following) {
+							p.lineNum++
+							nextLine = following
 						}
-						continue
-					} else if strings.HasPrefix(nextLine, "|===") {
+					}
+					// Check what type of block follows
+					if isTableDelimiter(nextLine) {
 						table := p.parseTable()
 						if table != nil {
 							lastItem.AddChild(table)
 						}
 						continue
-					} else if strings.HasPrefix(nextLine, "====") {
-						example := p.parseExampleBlock()
-						if example != nil {
-							lastItem.AddChild(example)
-						}
-						continue
-					} else if strings.HasPrefix(nextLine, "....") {
-						literalBlock := p.parseLiteralBlock()
-						if literalBlock != nil {
-							lastItem.AddChild(literalBlock)
+					} else if opensDelimitedBlock(nextLine) {
+						block := p.parseDelimitedBlock()
+						if block != nil {
+							lastItem.AddChild(block)
 						}
 						continue
 					} else {
@@ -1341,74 +1003,167 @@ func (p *parser) parseList() *Node {
 			continue
 		}
 
-		// Check for callout list: <1>, <2>, etc.
-		if strings.HasPrefix(line, "<") && strings.HasSuffix(line, ">") {
-			calloutNum := strings.Trim(line, "<>")
-			item := NewListItemNode()
-			item.SetAttribute("callout", calloutNum)
-			// Callout lists don't have content, just the number
-			items = append(items, item)
-			p.lineNum++
-			continue
-		}
-
-		if !p.isListItem(line) {
+		marker, ok := parseListMarker(line)
+		if !ok {
 			break
 		}
 
-		// Determine style and marker
-		if strings.HasPrefix(line, ".") {
-			style = "ordered"
-		} else if strings.HasPrefix(line, "*") {
-			style = "unordered"
-		} else if strings.HasPrefix(line, "-") {
-			style = "unordered"
-		} else if strings.Contains(line, "::") {
-			style = "labeled"
+		if key == "" {
+			key = marker.key
+			style = marker.style
+		}
+		if marker.key != key {
+			// An enclosing list's marker ends this list
+			for _, ancestor := range ancestors {
+				if ancestor == marker.key {
+					return p.finishList(items, itemStarts, style, checklist, start)
+				}
+			}
+			// Any other marker starts a list nested in the last item
+			if len(items) == 0 {
+				break
+			}
+			nested := p.parseListLevel(append(append([]string(nil), ancestors...), key))
+			items[len(items)-1].AddChild(nested)
+			continue
 		}
 
-		item := p.parseListItem(line)
-		if item != nil {
-			items = append(items, item)
+		itemStarts = append(itemStarts, p.lineNum)
+		item := p.parseListItem(marker)
+		if marker.style == "callout" {
+			// <.> items are numbered in order
+			number := marker.callout
+			if number == "." {
+				number = strconv.Itoa(len(items) + 1)
+			}
+			item.SetAttribute("callout", number)
 		}
-		p.lineNum++
+		if item.GetAttribute("checkbox") != "" {
+			checklist = true
+		}
+		items = append(items, item)
 	}
 
+	return p.finishList(items, itemStarts, style, checklist, start)
+}
+
+// finishList builds the List node for items parsed from lines start up to
+// the current line. Each item spans the lines up to the next item, which
+// covers its continuation blocks and nested lists.
+func (p *parser) finishList(items []*Node, itemStarts []int, style string, checklist bool, start int) *Node {
 	list := NewListNode()
 	list.SetAttribute("style", style)
+	if checklist {
+		list.SetAttribute("options", "checklist")
+	}
 
-	for _, item := range items {
+	for i, item := range items {
+		end := p.lineNum - 1
+		if i+1 < len(items) {
+			end = itemStarts[i+1] - 1
+		}
+		p.setSpan(item, itemStarts[i], end)
 		list.AddChild(item)
 	}
+	p.countNodes(start, len(items))
 
+	p.setSpan(list, start, p.lineNum-1)
 	return list
 }
 
-func (p *parser) parseListItem(line string) *Node {
-	// Remove list marker
-	content := strings.TrimLeft(line, ".*-")
-	content = strings.TrimSpace(content)
+// applyListAttributes applies the block attribute lines above the list that
+// starts on line start, such as [start=N] and the reversed option for
+// ordered lists
+func (p *parser) applyListAttributes(list *Node, start int) {
+	p.applyBlockAttributes(list, start)
+	if _, err := strconv.Atoi(list.GetAttribute("start")); err != nil {
+		delete(list.Attributes, "start")
+	}
+}
+
+// isListTextContinuation reports whether line continues the text of the list
+// item above it rather than starting something new
+func (p *parser) isListTextContinuation(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed == "+" || trimmed == "--" {
+		return false
+	}
+	if _, ok := parseListMarker(trimmed); ok {
+		return false
+	}
+	if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+		return false
+	}
+	if isTableDelimiter(trimmed) {
+		return false
+	}
+	for _, prefix := range []string{"----", "....", "====", "****", "____", "++++", "|===", "```", "//", "== "} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseListItem parses the item whose marker is on the current line, along
+// with any lines of text that directly follow it
+func (p *parser) parseListItem(marker listMarker) *Node {
+	start := p.lineNum
+	line := p.lines[p.lineNum]
 
 	item := NewListItemNode()
-	
-	// Handle labeled lists
-	if strings.Contains(content, "::") {
-		parts := strings.SplitN(content, "::", 2)
-		term := strings.TrimSpace(parts[0])
-		termNode := NewParagraphNode()
-		p.parseInlineContent(termNode, term)
-		item.SetAttribute("term", term)
-		item.AddChild(termNode)
-
-		desc := strings.TrimSpace(parts[1])
-		descNode := NewParagraphNode()
-		p.parseInlineContent(descNode, desc)
-		item.AddChild(descNode)
+
+	// The item's text runs on until a blank line or a new block
+	src := &inlineSource{}
+	if marker.style == "labeled" {
+		if marker.text != "" {
+			src.addSegment(marker.text, p.contentPosFrom(p.lineNum, marker.text, strings.Index(line, marker.key)+len(marker.key)))
+		}
 	} else {
-		// Regular list item content
-		p.parseInlineContent(item, content)
+		text := marker.text
+		if marker.style == "unordered" {
+			if m := checklistItemRegex.FindStringSubmatch(text); m != nil {
+				item.SetAttribute("checkbox", "true")
+				if m[1] != " " {
+					item.SetAttribute("checked", "true")
+				}
+				text = m[2]
+			}
+		}
+		src.addSegment(text, p.contentPosFrom(p.lineNum, text, len(line)-len(strings.TrimLeft(line, " \t"))+len(marker.key)))
 	}
-	
+	p.lineNum++
+	for p.lineNum < len(p.lines) && p.isListTextContinuation(p.lines[p.lineNum]) {
+		text := strings.TrimSpace(p.lines[p.lineNum])
+		if src.buf.Len() > 0 {
+			src.buf.WriteString(" ")
+		}
+		src.addSegment(text, p.contentPos(p.lineNum, text))
+		p.lineNum++
+	}
+	p.setSpan(item, start, p.lineNum-1)
+
+	if marker.style != "labeled" {
+		p.parseInlineContent(item, src.text(), src)
+		return item
+	}
+
+	// Labeled items hold the term and the description as paragraphs
+	term := strings.TrimSpace(marker.term)
+	termNode := NewParagraphNode()
+	p.setTextSpan(termNode, start, term)
+	p.parseInlineContent(termNode, term, newInlineSource(term, termNode.Start))
+	item.SetAttribute("term", term)
+	item.AddChild(termNode)
+
+	descNode := NewParagraphNode()
+	if len(src.segments) > 0 {
+		descNode.Start = src.segments[0].pos
+		descNode.End = item.End
+	}
+	p.parseInlineContent(descNode, src.text(), src)
+	item.AddChild(descNode)
+
 	return item
 }
 
@@ -1434,10 +1189,15 @@ func (p *parser) parseAdmonition() *Node {
 
 	admonition := NewAdmonitionNode()
 	admonition.SetAttribute("type", admonitionType)
+	p.setSpan(admonition, p.lineNum-1, p.lineNum-1)
+	p.applyBlockAttributes(admonition, p.lineNum-1)
 	
 	// Content is typically a paragraph
 	para := NewParagraphNode()
-	p.parseInlineContent(para, content)
+	contentPos := p.contentPosFrom(p.lineNum-1, content, strings.Index(p.lines[p.lineNum-1], ":")+1)
+	para.Start = contentPos
+	para.End = admonition.End
+	p.parseInlineSubs(para, content, newInlineSource(content, contentPos), p.blockSubs(admonition, normalSubs), false)
 	admonition.AddChild(para)
 	return admonition
 }
@@ -1447,7 +1207,7 @@ func (p *parser) parseImage() *Node {
 	p.lineNum++
 
 	// Parse image::path[alt,width,height] or image:path[alt]
-	var src, alt string
+	var src string
 	isBlock := strings.HasPrefix(line, "image::")
 	
 	if isBlock {
@@ -1459,19 +1219,22 @@ func (p *parser) parseImage() *Node {
 	// Extract path and attributes
 	parts := strings.SplitN(line, "[", 2)
 	src = strings.TrimSpace(parts[0])
-	if len(parts) > 1 {
-		attrs := strings.TrimSuffix(parts[1], "]")
-		attrParts := strings.Split(attrs, ",")
-		if len(attrParts) > 0 {
-			alt = strings.TrimSpace(attrParts[0])
-		}
-	}
 
 	image := NewBlockMacroNode("image")
 	image.SetAttribute("src", src)
-	if alt != "" {
-		image.SetAttribute("alt", alt)
+	p.setSpan(image, p.lineNum-1, p.lineNum-1)
+	p.applyBlockAttributes(image, p.lineNum-1)
+	if len(parts) > 1 {
+		attrs := p.macroAttributes(p.lineNum-1, strings.TrimSuffix(parts[1], "]"), false)
+		attrs.applyTo(image)
+		// Positional alt, width and height unless they are also named
+		for i, name := range []string{"alt", "width", "height"} {
+			if value := attrs.At(i); value != "" && attrs.Named[name] == "" {
+				image.SetAttribute(name, value)
+			}
+		}
 	}
+	p.captionBlock(image)
 	
 	return image
 }
@@ -1490,450 +1253,22 @@ func (p *parser) parseComponentMacro() *Node {
 
 	component := NewBlockMacroNode("component")
 	component.SetAttribute("component-name", componentName)
+	p.setSpan(component, p.lineNum-1, p.lineNum-1)
 
-	// Parse attributes if present
+	// Parse attributes if present: key="value" pairs, with commas allowed
+	// inside quoted values. Positional attributes have no meaning for
+	// components and are dropped.
+	p.applyBlockAttributes(component, p.lineNum-1)
 	if len(parts) > 1 {
-		attrsStr := strings.TrimSuffix(parts[1], "]")
-		
-		// Parse attributes: support both key="value" and comma-separated formats
-		// Split by comma, but need to be careful about commas inside quoted values
-		// Simple approach: split and parse each part
-		attrParts := splitAttributes(attrsStr)
-		
-		for _, part := range attrParts {
-			part = strings.TrimSpace(part)
-			if part == "" {
-				continue
-			}
-			
-			if strings.Contains(part, "=") {
-				// Key-value format: key="value" or key=value
-				// Support modern HTML attributes: keys can contain :, @, ., _, -
-				// Values can contain @, $, {, }, (, ) and other special chars
-				kv := strings.SplitN(part, "=", 2)
-				key := strings.TrimSpace(kv[0])
-				val := strings.TrimSpace(kv[1])
-				// Remove quotes only from the outer edges (handles both "value" and 'value')
-				if len(val) >= 2 && ((val[0] == '"' && val[len(val)-1] == '"') || (val[0] == '\'' && val[len(val)-1] == '\'')) {
-					val = val[1 : len(val)-1]
-				}
-				component.SetAttribute(key, val)
-			} else {
-				// Positional attribute - use as both key and value, or store with generic key
-				// For now, we'll skip positional-only attributes or could store with index
-				// Following AsciiDoc convention, positional attributes might be role or id
-				// For simplicity, we'll just store with the value as key and empty value
-				// Or better: store positional attributes with numeric keys
-				// Actually, let's store them with the attribute name being the value itself
-				// and value being empty, or we could use a convention like "attr0", "attr1"
-				// For CMS components, it's more likely to be key="value" format
-			}
-		}
+		p.macroAttributes(p.lineNum-1, strings.TrimSuffix(parts[1], "]"), true).applyTo(component)
 	}
 
 	return component
 }
 
-// splitAttributes splits attribute string by comma, respecting quoted values
-func splitAttributes(attrsStr string) []string {
-	var parts []string
-	var current strings.Builder
-	inQuotes := false
-	quoteChar := byte(0)
-
-	for i := 0; i < len(attrsStr); i++ {
-		c := attrsStr[i]
-		
-		if (c == '"' || c == '\'') && (i == 0 || attrsStr[i-1] != '\\') {
-			if !inQuotes {
-				inQuotes = true
-				quoteChar = c
-			} else if c == quoteChar {
-				inQuotes = false
-				quoteChar = 0
-			}
-			current.WriteByte(c)
-		} else if c == ',' && !inQuotes {
-			parts = append(parts, current.String())
-			current.Reset()
-		} else {
-			current.WriteByte(c)
-		}
-	}
-	
-	if current.Len() > 0 {
-		parts = append(parts, current.String())
-	}
-
-	return parts
-}
-
-func (p *parser) parseInlineContent(parent *Node, text string) {
-	// Collect all matches with their positions
-	type matchInfo struct {
-		start int
-		end   int
-		node  *Node
-	}
-	var allMatches []matchInfo
-
-	// Regex for inline formatting
-	// Bold: *text*
-	// Italic: _text_
-	// Monospace: `text`
-	// Superscript: ^text^
-	// Subscript: ~text~
-	// Highlight: #text#
-	// Inline passthrough: +text+
-	// Link: http://...[text] or link:url[text]
-	// Inline macros: macroname:[text] or macroname:text[]
-	
-	boldRegex := regexp.MustCompile(`\*([^*]+)\*`)
-	italicRegex := regexp.MustCompile(`_([^_]+)_`)
-	monoRegex := regexp.MustCompile("`([^`]+)`")
-	superscriptRegex := regexp.MustCompile(`\^([^^]+)\^`)
-	subscriptRegex := regexp.MustCompile(`~([^~]+)~`)
-	highlightRegex := regexp.MustCompile(`#([^#]+)#`)
-	passthroughInlineRegex := regexp.MustCompile(`\+([^+]+)\+`)
-	// Match HTTP/HTTPS links: http://... or https://... with optional [text]
-	httpLinkRegex := regexp.MustCompile(`(https?://[^\s\[\]]+)(\[([^\]]+)\])?`)
-	// Match AsciiDoc link: macros: link:url[text] or link:url
-	linkMacroRegex := regexp.MustCompile(`link:([^\s\[\]]+)(\[([^\]]+)\])?`)
-	// Match inline macros: macroname:[text] or macroname:target[text]
-	// This matches: word characters, colon, optional target (non-bracket chars), brackets with content
-	// Exclude link: which is handled separately
-	inlineMacroRegex := regexp.MustCompile(`(\w+):([^\[]*)\[([^\]]+)\]`)
-	// Match cross-references: <<anchor-id>> or xref:anchor-id[]
-	xrefRegex := regexp.MustCompile(`<<([^>>]+)>>`)
-	xrefMacroRegex := regexp.MustCompile(`xref:([^\s\[\]]+)(\[([^\]]+)\])?`)
-	// Match inline anchor: [#anchor-id]
-	inlineAnchorRegex := regexp.MustCompile(`\[#([^\]]+)\]`)
-	// Match footnotes: footnote:[text] or footnote:ref[text]
-	footnoteRegex := regexp.MustCompile(`footnote:([^\s\[\]]*)(\[([^\]]+)\])?`)
-	footnoterefRegex := regexp.MustCompile(`footnoteref:([^\s\[\]]+)(\[([^\]]+)\])?`)
-
-	// Find all bold matches
-	boldMatches := boldRegex.FindAllStringIndex(text, -1)
-	for _, match := range boldMatches {
-		content := text[match[0]+1 : match[1]-1]
-		strong := NewBoldNode()
-		// Recursively parse nested content
-		p.parseInlineContent(strong, content)
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  strong,
-		})
-	}
-
-	// Find all italic matches
-	italicMatches := italicRegex.FindAllStringIndex(text, -1)
-	for _, match := range italicMatches {
-		content := text[match[0]+1 : match[1]-1]
-		emphasis := NewItalicNode()
-		// Recursively parse nested content
-		p.parseInlineContent(emphasis, content)
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  emphasis,
-		})
-	}
-
-	// Find all monospace matches
-	monoMatches := monoRegex.FindAllStringIndex(text, -1)
-	for _, match := range monoMatches {
-		content := text[match[0]+1 : match[1]-1]
-		mono := NewMonospaceNode()
-		// Recursively parse nested content
-		p.parseInlineContent(mono, content)
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  mono,
-		})
-	}
-
-	// Find all superscript matches
-	superscriptMatches := superscriptRegex.FindAllStringIndex(text, -1)
-	for _, match := range superscriptMatches {
-		content := text[match[0]+1 : match[1]-1]
-		sup := NewSuperscriptNode()
-		// Recursively parse nested content
-		p.parseInlineContent(sup, content)
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  sup,
-		})
-	}
-
-	// Find all subscript matches
-	subscriptMatches := subscriptRegex.FindAllStringIndex(text, -1)
-	for _, match := range subscriptMatches {
-		content := text[match[0]+1 : match[1]-1]
-		sub := NewSubscriptNode()
-		// Recursively parse nested content
-		p.parseInlineContent(sub, content)
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  sub,
-		})
-	}
-
-	// Find all highlight matches
-	highlightMatches := highlightRegex.FindAllStringIndex(text, -1)
-	for _, match := range highlightMatches {
-		content := text[match[0]+1 : match[1]-1]
-		highlight := NewHighlightNode()
-		// Recursively parse nested content
-		p.parseInlineContent(highlight, content)
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  highlight,
-		})
-	}
-
-	// Find all inline passthrough matches
-	passthroughMatches := passthroughInlineRegex.FindAllStringIndex(text, -1)
-	for _, match := range passthroughMatches {
-		content := text[match[0]+1 : match[1]-1]
-		passthrough := NewPassthroughNode(content)
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  passthrough,
-		})
-	}
-
-	// Find all HTTP/HTTPS link matches (before inline macros to avoid conflicts)
-	httpLinkMatches := httpLinkRegex.FindAllStringSubmatchIndex(text, -1)
-	for _, match := range httpLinkMatches {
-		href := text[match[2]:match[3]]
-		linkText := href
-		if match[6] > 0 && match[7] > 0 {
-			linkText = text[match[6]:match[7]]
-		}
-		link := NewLinkNode()
-		link.SetAttribute("href", href)
-		link.AddChild(NewTextNode(linkText))
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  link,
-		})
-	}
-
-	// Find all inline macro matches (after HTTP links to avoid conflicts)
-	inlineMacroMatches := inlineMacroRegex.FindAllStringSubmatchIndex(text, -1)
-	for _, match := range inlineMacroMatches {
-		macroName := text[match[2]:match[3]]
-		target := text[match[4]:match[5]]
-		macroText := text[match[6]:match[7]]
-		
-		// Skip if it's a link: macro (handled separately)
-		if macroName == "link" {
-			continue
-		}
-		// Skip if it's http or https (handled as HTTP links above)
-		if macroName == "http" || macroName == "https" {
-			continue
-		}
-		
-		macro := NewInlineMacroNode(macroName)
-		if target != "" {
-			macro.SetAttribute("target", target)
-		}
-		macro.AddChild(NewTextNode(macroText))
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  macro,
-		})
-	}
-
-	// Find all link: macro matches
-	linkMacroMatches := linkMacroRegex.FindAllStringSubmatchIndex(text, -1)
-	for _, match := range linkMacroMatches {
-		href := text[match[2]:match[3]] // URL without "link:" prefix
-		linkText := href
-		if match[6] > 0 && match[7] > 0 {
-			linkText = text[match[6]:match[7]] // Text from [brackets]
-		}
-		link := NewLinkNode()
-		link.SetAttribute("href", href)
-		link.AddChild(NewTextNode(linkText))
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  link,
-		})
-	}
-
-	// Find all cross-reference matches: <<anchor-id>>
-	xrefMatches := xrefRegex.FindAllStringSubmatchIndex(text, -1)
-	for _, match := range xrefMatches {
-		anchorID := text[match[2]:match[3]]
-		xref := NewInlineMacroNode("xref")
-		xref.SetAttribute("target", anchorID)
-		// Default text is the anchor ID, but can be overridden
-		xref.AddChild(NewTextNode(anchorID))
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  xref,
-		})
-	}
-
-	// Find all xref: macro matches: xref:anchor-id[] or xref:anchor-id[text]
-	xrefMacroMatches := xrefMacroRegex.FindAllStringSubmatchIndex(text, -1)
-	for _, match := range xrefMacroMatches {
-		anchorID := text[match[2]:match[3]]
-		xrefText := anchorID
-		if match[6] > 0 && match[7] > 0 {
-			xrefText = text[match[6]:match[7]] // Text from [brackets]
-		}
-		xref := NewInlineMacroNode("xref")
-		xref.SetAttribute("target", anchorID)
-		xref.AddChild(NewTextNode(xrefText))
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  xref,
-		})
-	}
-
-	// Find all inline anchor matches: [#anchor-id]
-	inlineAnchorMatches := inlineAnchorRegex.FindAllStringSubmatchIndex(text, -1)
-	for _, match := range inlineAnchorMatches {
-		anchorID := text[match[2]:match[3]]
-		anchor := NewInlineMacroNode("anchor")
-		anchor.SetAttribute("id", anchorID)
-		p.anchors[anchorID] = anchor
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  anchor,
-		})
-	}
-
-	// Find all footnote matches: footnote:[text] or footnote:ref[text]
-	footnoteMatches := footnoteRegex.FindAllStringSubmatchIndex(text, -1)
-	for _, match := range footnoteMatches {
-		ref := text[match[2]:match[3]]
-		footnoteText := ""
-		if match[6] > 0 && match[7] > 0 {
-			footnoteText = text[match[6]:match[7]]
-		}
-		footnote := NewInlineMacroNode("footnote")
-		if ref != "" {
-			footnote.SetAttribute("ref", ref)
-		}
-		if footnoteText != "" {
-			footnote.AddChild(NewTextNode(footnoteText))
-		}
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  footnote,
-		})
-	}
-
-	// Find all footnoteref matches: footnoteref:ref[]
-	footnoterefMatches := footnoterefRegex.FindAllStringSubmatchIndex(text, -1)
-	for _, match := range footnoterefMatches {
-		ref := text[match[2]:match[3]]
-		footnoterefText := ref
-		if match[6] > 0 && match[7] > 0 {
-			footnoterefText = text[match[6]:match[7]]
-		}
-		footnoteref := NewInlineMacroNode("footnoteref")
-		footnoteref.SetAttribute("ref", ref)
-		footnoteref.AddChild(NewTextNode(footnoterefText))
-		allMatches = append(allMatches, matchInfo{
-			start: match[0],
-			end:   match[1],
-			node:  footnoteref,
-		})
-	}
-
-	// Sort by start position
-	for i := 0; i < len(allMatches)-1; i++ {
-		for j := i + 1; j < len(allMatches); j++ {
-			if allMatches[i].start > allMatches[j].start {
-				allMatches[i], allMatches[j] = allMatches[j], allMatches[i]
-			}
-		}
-	}
-
-	// Build result, handling overlaps (prefer earlier matches, skip overlapped)
-	lastPos := 0
-
-	for _, match := range allMatches {
-		// Overlap check
-		if match.start < lastPos {
-			continue 
-		}
-
-		// Add text before match (with attribute substitution)
-		if match.start > lastPos {
-			textBefore := text[lastPos:match.start]
-			textBefore = SubstituteAttributes(textBefore, p.getAllAttributes())
-			parent.AddChild(NewTextNode(textBefore))
-		}
-		
-		// Add match
-		parent.AddChild(match.node)
-		lastPos = match.end
-	}
-
-	// Add remaining text (with attribute substitution)
-	if lastPos < len(text) {
-		textRemaining := text[lastPos:]
-		textRemaining = SubstituteAttributes(textRemaining, p.getAllAttributes())
-		parent.AddChild(NewTextNode(textRemaining))
-	}
-}
-
 func (p *parser) isListItem(line string) bool {
-	trimmed := strings.TrimSpace(line)
-	// Don't match image lines
-	if strings.HasPrefix(trimmed, "image::") || strings.HasPrefix(trimmed, "image:") {
-		return false
-	}
-	return strings.HasPrefix(trimmed, "*") ||
-		strings.HasPrefix(trimmed, ".") ||
-		strings.HasPrefix(trimmed, "-") ||
-		strings.Contains(trimmed, "::")
-}
-
-func (p *parser) isBlockTitle(line string) bool {
-	// Check if a line starting with '.' is actually a title for a block
-	trimmed := strings.TrimSpace(line)
-	if !strings.HasPrefix(trimmed, ".") {
-		return false
-	}
-
-	// Look ahead for block delimiters
-	for i := p.lineNum + 1; i < len(p.lines); i++ {
-		nextLine := strings.TrimSpace(p.lines[i])
-		if nextLine == "" {
-			continue
-		}
-		// Check if it's a block delimiter
-		if strings.HasPrefix(nextLine, "====") ||
-			strings.HasPrefix(nextLine, "----") ||
-			strings.HasPrefix(nextLine, "....") ||
-			strings.HasPrefix(nextLine, "****") ||
-			strings.HasPrefix(nextLine, "____") {
-			return true
-		}
-		// If we hit something else, it's not a title
-		break
-	}
-	return false
+	_, ok := parseListMarker(line)
+	return ok
 }
 
 func (p *parser) isAdmonition(line string) bool {
@@ -1957,20 +1292,11 @@ func (p *parser) parseIncludeMacro() *Node {
 	
 	include := NewBlockMacroNode("include")
 	include.SetAttribute("target", filePath)
+	p.setSpan(include, p.lineNum-1, p.lineNum-1)
 	
 	if len(parts) > 1 {
-		attrs := strings.TrimSuffix(parts[1], "]")
 		// Parse attributes like lines=1..5, tags=tag1;tag2
-		attrParts := strings.Split(attrs, ",")
-		for _, part := range attrParts {
-			part = strings.TrimSpace(part)
-			if strings.Contains(part, "=") {
-				kv := strings.SplitN(part, "=", 2)
-				key := strings.TrimSpace(kv[0])
-				val := strings.TrimSpace(kv[1])
-				include.SetAttribute(key, val)
-			}
-		}
+		p.macroAttributes(p.lineNum-1, strings.TrimSuffix(parts[1], "]"), false).applyTo(include)
 	}
 	
 	return include
@@ -1984,19 +1310,12 @@ func (p *parser) parseTOCMacro() *Node {
 	// Parse toc::[] or toc::[levels=2]
 	line = strings.TrimPrefix(line, "toc::")
 	toc := NewBlockMacroNode("toc")
+	p.setSpan(toc, p.lineNum-1, p.lineNum-1)
 	
+	p.applyBlockAttributes(toc, p.lineNum-1)
 	if strings.HasPrefix(line, "[") {
 		attrs := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
-		attrParts := strings.Split(attrs, ",")
-		for _, part := range attrParts {
-			part = strings.TrimSpace(part)
-			if strings.Contains(part, "=") {
-				kv := strings.SplitN(part, "=", 2)
-				key := strings.TrimSpace(kv[0])
-				val := strings.TrimSpace(kv[1])
-				toc.SetAttribute(key, val)
-			}
-		}
+		p.macroAttributes(p.lineNum-1, attrs, false).applyTo(toc)
 	}
 	
 	return toc
@@ -2014,19 +1333,11 @@ func (p *parser) parseVideoMacro() *Node {
 	
 	video := NewBlockMacroNode("video")
 	video.SetAttribute("target", url)
+	p.setSpan(video, p.lineNum-1, p.lineNum-1)
 	
+	p.applyBlockAttributes(video, p.lineNum-1)
 	if len(parts) > 1 {
-		attrs := strings.TrimSuffix(parts[1], "]")
-		attrParts := strings.Split(attrs, ",")
-		for _, part := range attrParts {
-			part = strings.TrimSpace(part)
-			if strings.Contains(part, "=") {
-				kv := strings.SplitN(part, "=", 2)
-				key := strings.TrimSpace(kv[0])
-				val := strings.TrimSpace(kv[1])
-				video.SetAttribute(key, val)
-			}
-		}
+		p.macroAttributes(p.lineNum-1, strings.TrimSuffix(parts[1], "]"), false).applyTo(video)
 	}
 	
 	return video
@@ -2044,18 +1355,14 @@ func (p *parser) parseAudioMacro() *Node {
 	
 	audio := NewBlockMacroNode("audio")
 	audio.SetAttribute("target", url)
+	p.setSpan(audio, p.lineNum-1, p.lineNum-1)
 	
+	p.applyBlockAttributes(audio, p.lineNum-1)
 	if len(parts) > 1 {
-		attrs := strings.TrimSuffix(parts[1], "]")
-		attrParts := strings.Split(attrs, ",")
-		for _, part := range attrParts {
-			part = strings.TrimSpace(part)
-			if strings.Contains(part, "=") {
-				kv := strings.SplitN(part, "=", 2)
-				key := strings.TrimSpace(kv[0])
-				val := strings.TrimSpace(kv[1])
-				audio.SetAttribute(key, val)
-			} else {
+		attrs := p.macroAttributes(p.lineNum-1, strings.TrimSuffix(parts[1], "]"), false)
+		attrs.applyTo(audio)
+		for _, part := range attrs.Positional {
+			if part != "" {
 				// Boolean attribute
 				audio.SetAttribute(part, "true")
 			}
@@ -2065,8 +1372,22 @@ func (p *parser) parseAudioMacro() *Node {
 	return audio
 }
 
-// Validate attempts to parse the AsciiDoc content and returns an error if invalid
+// Validate parses the AsciiDoc content and returns a *ValidationError listing
+// the error diagnostics if it is invalid. Warnings do not make a document
+// invalid; use ValidateWithDiagnostics to see them.
 func Validate(reader io.Reader) error {
-	_, err := Parse(reader)
-	return err
+	diagnostics, err := ValidateWithDiagnostics(reader)
+	if err != nil {
+		return err
+	}
+	var errs []Diagnostic
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Diagnostics: errs}
+	}
+	return nil
 }
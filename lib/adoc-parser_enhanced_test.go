@@ -327,6 +327,31 @@ This is a paragraph with an anchor above it.`
 	}
 }
 
+func TestParseAnchors_BlockAnchorReftext(t *testing.T) {
+	input := `[[my-anchor, My Anchor ]]
+This is a paragraph with an anchor above it.
+
+See <<my-anchor>>.`
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	anchor := doc.Children[0]
+	if anchor.Type != BlockMacro || anchor.GetAttribute("id") != "my-anchor" || anchor.GetAttribute("reftext") != "My Anchor" {
+		t.Errorf("Expected anchor my-anchor with reftext 'My Anchor', got %v", anchor.Attributes)
+	}
+	var xref *Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == InlineMacro && n.Name == "xref" {
+			xref = n
+		}
+	})
+	if xref == nil || xref.GetAttribute("refid") != "my-anchor" || getTextContent(xref) != "My Anchor" {
+		t.Errorf("Expected the reference to resolve with the reftext, got %v", xref)
+	}
+}
+
 func TestParseAnchors_InlineAnchor(t *testing.T) {
 	input := `This is a paragraph with an [#inline-anchor] inline anchor.`
 	doc, err := Parse(bytes.NewReader([]byte(input)))
@@ -2,6 +2,7 @@ package lib
 
 import (
 	"bytes"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -402,6 +403,133 @@ func TestParse_Lists(t *testing.T) {
 	}
 }
 
+// listShape describes a list's structure as style(item item(...)) for comparison
+func listShape(list *Node) string {
+	var parts []string
+	for _, item := range list.Children {
+		var text strings.Builder
+		for _, child := range item.Children {
+			switch {
+			case child.Type == Text:
+				text.WriteString(child.Content)
+			case child.Type == List:
+				if text.Len() > 0 && !strings.HasSuffix(text.String(), " ") {
+					text.WriteString(" ")
+				}
+				text.WriteString(listShape(child))
+			case child.Type == Paragraph && len(child.Children) > 0 && item.GetAttribute("term") != "":
+				if text.Len() > 0 {
+					text.WriteString("=")
+				}
+				text.WriteString(child.Children[0].Content)
+			}
+		}
+		parts = append(parts, text.String())
+	}
+	return list.GetAttribute("style") + "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestParse_NestedLists(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"unordered depth", "* a\n** b\n*** c\n** d\n* e", "unordered(a unordered(b unordered(c) d) e)"},
+		{"ordered depth", ". one\n.. sub\n. two", "ordered(one ordered(sub) two)"},
+		{"mixed", "* a\n. step 1\n. step 2\n* b", "unordered(a ordered(step 1 step 2) b)"},
+		{"dash and star", "- a\n* b\n- c", "unordered(a unordered(b) c)"},
+		{"explicit numbers", "1. one\n2. two\na. sub\n3. three", "ordered(one two ordered(sub) three)"},
+		{"blank lines between items", "* a\n\n** b\n\n* c", "unordered(a unordered(b) c)"},
+		{"text continuation", "* first\nline\n* second", "unordered(first line second)"},
+		{"labeled depth", "CPU:: The brain\nCores::: Count\nCache;; L2\nRAM:: Memory", "labeled(CPU=The brain labeled(Cores=Count labeled(Cache=L2)) RAM=Memory)"},
+		{"labeled with nested list", "Fruits::\n* apple\n* pear\nVeg:: carrot", "labeled(Fruits unordered(apple pear) Veg=carrot)"},
+		{"description on next line", "Term::\nThe description.", "labeled(Term=The description.)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(bytes.NewReader([]byte(tt.input)))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if len(doc.Children) != 1 || doc.Children[0].Type != List {
+				t.Fatalf("Expected a single list, got %d children", len(doc.Children))
+			}
+			if got := listShape(doc.Children[0]); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParse_ListAttributes(t *testing.T) {
+	input := `[%reversed,start=4]
+. four
+. three
+
+[%reversed,start=2]
+. two`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(doc.Children) != 2 {
+		t.Fatalf("Expected 2 lists, got %d children", len(doc.Children))
+	}
+	for i, list := range doc.Children {
+		if list.Type != List {
+			t.Fatalf("Expected child %d to be a list, got %s", i, list.Type)
+		}
+		if list.GetAttribute("options") != "reversed" {
+			t.Errorf("Expected list %d to be reversed, got options %q", i, list.GetAttribute("options"))
+		}
+	}
+	if got := doc.Children[1].GetAttribute("start"); got != "2" {
+		t.Errorf("Expected start=2, got %q", got)
+	}
+}
+
+func TestParse_Checklist(t *testing.T) {
+	input := `* [x] done
+* [ ] todo
+* plain`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	list := findNode(doc, List)
+	if list == nil || list.GetAttribute("options") != "checklist" {
+		t.Fatalf("Expected a checklist, got %v", list)
+	}
+	done, todo, plain := list.Children[0], list.Children[1], list.Children[2]
+	if done.GetAttribute("checkbox") != "true" || done.GetAttribute("checked") != "true" || done.Children[0].Content != "done" {
+		t.Errorf("Unexpected checked item: %v", done.Attributes)
+	}
+	if todo.GetAttribute("checkbox") != "true" || todo.GetAttribute("checked") != "" {
+		t.Errorf("Unexpected unchecked item: %v", todo.Attributes)
+	}
+	if plain.GetAttribute("checkbox") != "" {
+		t.Errorf("Expected plain item without checkbox, got %v", plain.Attributes)
+	}
+}
+
+func TestParse_ListMarkersInText(t *testing.T) {
+	input := `*Bold* text starts this paragraph.
+
+include::missing.adoc[]`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if findNode(doc, List) != nil {
+		t.Errorf("Expected no list for bold text or block macros")
+	}
+}
+
 func TestParse_Admonitions(t *testing.T) {
 	input := `= Test
 
@@ -442,6 +570,74 @@ CAUTION: This is a caution.`
 	}
 }
 
+func TestParse_AdmonitionBlocks(t *testing.T) {
+	input := `[WARNING]
+.Before you start
+====
+Back up your data.
+
+* Stop the service
+* Copy the files
+
+----
+cp -r data backup
+----
+====
+
+[NOTE]
+A styled *paragraph*.
+
+[#intro.lead]
+.Quick tip
+TIP: One line.`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(doc.Children) != 3 {
+		t.Fatalf("Expected 3 admonitions, got %d blocks", len(doc.Children))
+	}
+	warning := doc.Children[0]
+	if warning.Type != Admonition || warning.GetAttribute("type") != "warning" || warning.GetAttribute("title") != "Before you start" {
+		t.Errorf("Expected a titled warning, got %v %v", warning.Type, warning.Attributes)
+	}
+	var types []NodeType
+	for _, child := range warning.Children {
+		types = append(types, child.Type)
+	}
+	if want := []NodeType{Paragraph, List, CodeBlock}; !reflect.DeepEqual(types, want) {
+		t.Errorf("Expected the warning to hold %v, got %v", want, types)
+	}
+
+	note := doc.Children[1]
+	if note.Type != Admonition || note.GetAttribute("type") != "note" || len(note.Children) != 1 || note.Children[0].Type != Paragraph {
+		t.Fatalf("Expected [NOTE] to make the paragraph a note, got %v", note)
+	}
+	if findNode(note, Bold) == nil || note.Start.Line != 15 {
+		t.Errorf("Expected inline markup and the paragraph's position, got %v", note.Start)
+	}
+
+	tip := doc.Children[2]
+	if tip.GetAttribute("id") != "intro" || tip.GetAttribute("role") != "lead" || tip.GetAttribute("title") != "Quick tip" {
+		t.Errorf("Expected the tip to take its ID, role and title, got %v", tip.Attributes)
+	}
+
+	result, err := Convert(strings.NewReader(input), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := `<div data-role="admonition" data-asciidoc-variant="warning">
+    <p data-role="admonition-title">WARNING</p>
+    <div data-role="admonition-content">
+        <p data-role="admonition-block-title">Before you start</p>
+        <p>Back up your data.</p>
+        <ul>`
+	if !strings.Contains(result.HTML, want) {
+		t.Errorf("Expected the warning's blocks in HTML output:\n%s", result.HTML)
+	}
+}
+
 func TestParse_Links(t *testing.T) {
 	input := `= Test
 
@@ -36,6 +36,12 @@ const (
 	VerseBlock
 	OpenBlock
 	PassthroughBlock
+	TableColumn
+	Comment
+	Callout
+	LineBreak
+	IndexTerm
+	BibliographyAnchor
 )
 
 // String returns a human-readable name for the NodeType
@@ -101,11 +107,61 @@ func (t NodeType) String() string {
 		return "OpenBlock"
 	case PassthroughBlock:
 		return "PassthroughBlock"
+	case TableColumn:
+		return "TableColumn"
+	case Comment:
+		return "Comment"
+	case Callout:
+		return "Callout"
+	case LineBreak:
+		return "LineBreak"
+	case IndexTerm:
+		return "IndexTerm"
+	case BibliographyAnchor:
+		return "BibliographyAnchor"
 	default:
 		return "Unknown"
 	}
 }
 
+// IsInline reports whether nodes of this type appear within running text
+// rather than as blocks
+func (t NodeType) IsInline() bool {
+	switch t {
+	case Text, InlineMacro, Bold, Italic, Monospace, Link, Passthrough, Superscript, Subscript, Highlight, Callout, LineBreak, IndexTerm, BibliographyAnchor:
+		return true
+	default:
+		return false
+	}
+}
+
+// Position is a location in the AsciiDoc source.
+// Line and Column are 1-based; Column counts runes, not bytes.
+// File names the included document the position is in; it is empty for the
+// document being parsed. The zero Position means the location is unknown.
+type Position struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// IsValid reports whether the position refers to a real source location
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position formatted as "line:column", or
+// "file:line:column" for a position in an included document
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // Node represents a node in the Abstract Syntax Tree
 type Node struct {
 	Type       NodeType
@@ -113,6 +169,8 @@ type Node struct {
 	Name       string            // For BlockMacro/InlineMacro (macro name)
 	Attributes map[string]string // Key-value pairs for attributes
 	Children   []*Node
+	Start      Position // Source position of the first character (set by the parser)
+	End        Position // Source position of the last character (set by the parser)
 }
 
 // NewDocumentNode creates a new Document node
@@ -254,6 +312,16 @@ func NewTableRowNode() *Node {
 	}
 }
 
+// NewTableColumnNode creates a new TableColumn node (a column of a table's
+// cols attribute)
+func NewTableColumnNode() *Node {
+	return &Node{
+		Type:       TableColumn,
+		Attributes: make(map[string]string),
+		Children:   make([]*Node, 0),
+	}
+}
+
 // NewTableCellNode creates a new TableCell node
 func NewTableCellNode() *Node {
 	return &Node{
@@ -345,6 +413,61 @@ func NewPassthroughBlockNode(content string) *Node {
 	}
 }
 
+// NewCommentNode creates a new Comment node (for // and //// comments kept by
+// ParseOptions.KeepComments)
+func NewCommentNode(content string) *Node {
+	return &Node{
+		Type:       Comment,
+		Content:    content,
+		Attributes: make(map[string]string),
+		Children:   make([]*Node, 0),
+	}
+}
+
+// NewCalloutNode creates a new Callout node for the callout marker number
+// in a listing block
+func NewCalloutNode(number string) *Node {
+	return &Node{
+		Type:       Callout,
+		Attributes: map[string]string{"number": number},
+		Children:   make([]*Node, 0),
+	}
+}
+
+// NewLineBreakNode creates a new LineBreak node for a hard line break in a
+// paragraph
+func NewLineBreakNode() *Node {
+	return &Node{
+		Type:       LineBreak,
+		Attributes: make(map[string]string),
+		Children:   make([]*Node, 0),
+	}
+}
+
+// NewIndexTermNode creates a new IndexTerm node. A flow term holds the text
+// shown in its place; a concealed one has no children.
+func NewIndexTermNode() *Node {
+	return &Node{
+		Type:       IndexTerm,
+		Attributes: make(map[string]string),
+		Children:   make([]*Node, 0),
+	}
+}
+
+// NewBibliographyAnchorNode creates a new BibliographyAnchor node for the
+// entry of a bibliography with the given ID. References to the entry show
+// its label, which defaults to the ID.
+func NewBibliographyAnchorNode(id, label string) *Node {
+	if label == "" {
+		label = id
+	}
+	return &Node{
+		Type:       BibliographyAnchor,
+		Attributes: map[string]string{"id": id, "label": label},
+		Children:   make([]*Node, 0),
+	}
+}
+
 // NewSuperscriptNode creates a new Superscript node
 func NewSuperscriptNode() *Node {
 	return &Node{
@@ -411,7 +534,8 @@ func (n *Node) GetAttribute(key string) string {
 	return n.Attributes[key]
 }
 
-// Traverse traverses the AST tree depth-first, calling visit for each node
+// Traverse traverses the AST tree depth-first, calling visit for each node.
+// Use Walk to skip subtrees, stop early or change the tree on the way.
 func (n *Node) Traverse(visit func(*Node)) {
 	visit(n)
 	for _, child := range n.Children {
@@ -5,31 +5,202 @@ import (
 	"strings"
 )
 
+// attributeReferenceRegex matches an attribute reference: { followed by an
+// attribute name (word chars, hyphens, underscores) and }, or a counter
+// reference such as {counter:name} or {counter2:name:A}
+var attributeReferenceRegex = regexp.MustCompile(`\{(?:(counter2?):([\w-]+)(?::([\w-]+))?|([\w\-_]+))\}`)
+
+// attributeEntryLineRegex matches an attribute entry: :name: value, :name!:
+// or :!name:
+var attributeEntryLineRegex = regexp.MustCompile(`^:(!?)(\w[\w-]*)(!?):(?:[ \t]+(.*))?$`)
+
+// documentAttributes are the attributes stored on the Document node under
+// their own name; other attribute entries are stored with a : prefix
+var documentAttributes = map[string]bool{
+	"author": true, "email": true, "revnumber": true, "revdate": true, "revremark": true, "doctype": true,
+}
+
+// attributeEntry is an attribute entry read from the document
+type attributeEntry struct {
+	name  string
+	value string
+	unset bool // :name!: or :!name:
+}
+
+// parseAttributeEntryLine parses the first line of an attribute entry. The value
+// still ends with " \" if it continues on the next line; see
+// splitContinuation.
+func parseAttributeEntryLine(line string) (attributeEntry, bool) {
+	m := attributeEntryLineRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return attributeEntry{}, false
+	}
+	return attributeEntry{name: m[2], value: strings.TrimSpace(m[4]), unset: m[1] != "" || m[3] != ""}, true
+}
+
+// splitContinuation removes the " \" that continues an attribute value on
+// the next line, reporting whether it was there
+func splitContinuation(value string) (string, bool) {
+	if value == `\` {
+		return "", true
+	}
+	if strings.HasSuffix(value, ` \`) {
+		return strings.TrimRight(value[:len(value)-2], " \t"), true
+	}
+	return value, false
+}
+
+// joinContinuation appends a continuation line to an attribute value
+func joinContinuation(value, line string) string {
+	line = strings.TrimSpace(line)
+	if value == "" || line == "" {
+		return value + line
+	}
+	return value + " " + line
+}
+
+// looksLikeAttributeEntry reports whether line is meant as an attribute
+// entry, well-formed or not, rather than a table delimiter or labeled list item
+func looksLikeAttributeEntry(line string) bool {
+	if !strings.HasPrefix(line, ":") || !strings.Contains(line[1:], ":") || isTableDelimiter(line) {
+		return false
+	}
+	_, isListItem := parseListMarker(line)
+	return !isListItem
+}
+
+// parseAttributeEntry reads the attribute entry on the current line, along
+// with the lines its value continues on, and applies it. A malformed entry
+// is reported and skipped.
+func (p *parser) parseAttributeEntry() {
+	p.checkAttributeEntry(p.lineNum)
+	entry, ok := parseAttributeEntryLine(p.lines[p.lineNum])
+	if !ok {
+		p.lineNum++
+		return
+	}
+	end := p.lineNum + 1
+	value, more := splitContinuation(entry.value)
+	for more && end < len(p.lines) {
+		var next string
+		next, more = splitContinuation(strings.TrimSpace(p.lines[end]))
+		value = joinContinuation(value, next)
+		end++
+	}
+	entry.value = value
+	// Applied on the entry's line, where a limit its value reaches is reported
+	p.applyAttributeEntry(entry)
+	p.lineNum = end
+}
+
+// applyAttributeEntry sets or unsets an attribute from this point of the
+// document on, unless it is locked. Values can refer to attributes defined
+// above them.
+func (p *parser) applyAttributeEntry(entry attributeEntry) {
+	if p.locked[entry.name] {
+		return
+	}
+	if entry.unset {
+		delete(p.attributes, entry.name)
+		delete(p.doc.Attributes, entry.name)
+		delete(p.doc.Attributes, ":"+entry.name)
+		return
+	}
+	value, drop := p.expandAttributes(p.lineNum, entry.value, p.getAllAttributes(), false)
+	if drop || p.limits.err != nil {
+		return
+	}
+	if entry.name == "leveloffset" {
+		value = resolveLevelOffset(p.attributes["leveloffset"], value)
+	}
+	p.attributes[entry.name] = value
+	if documentAttributes[entry.name] {
+		p.doc.SetAttribute(entry.name, value)
+	} else {
+		p.doc.SetAttribute(":"+entry.name, value)
+	}
+}
+
+// parseLockedAttribute interprets an attribute passed in through
+// ParseOptions.Attributes. The document can't change these attributes,
+// unless the name or value ends in @, as in "toc@" or "left@", which only
+// sets a default. A name ending in !, as in "sectnums!", keeps the attribute
+// unset.
+func parseLockedAttribute(name, value string) (entry attributeEntry, soft bool) {
+	if strings.HasSuffix(name, "@") {
+		name, soft = name[:len(name)-1], true
+	} else if strings.HasSuffix(value, "@") {
+		value, soft = value[:len(value)-1], true
+	}
+	if strings.HasSuffix(name, "!") {
+		return attributeEntry{name: name[:len(name)-1], unset: true}, soft
+	}
+	return attributeEntry{name: name, value: value}, soft
+}
+
 // SubstituteAttributes replaces attribute references in text with their values
 // Attribute references are in the format {attr-name} or {attr-name}
+// Intrinsic attributes such as {nbsp} are always defined; counter references
+// such as {counter:step} update attrs. A reference to an undefined attribute
+// is replaced with an empty string.
 func SubstituteAttributes(text string, attrs map[string]string) string {
-	if attrs == nil || len(attrs) == 0 {
-		return text
+	if attrs == nil {
+		attrs = make(map[string]string)
 	}
+	return substituteAttributeValues(text, attrs, nil)
+}
 
-	// Match attribute references: {attr-name} or {attr-name}
-	// This matches { followed by attribute name (word chars, hyphens, underscores) and }
-	attrRegex := regexp.MustCompile(`\{([\w\-_]+)\}`)
-
-	result := attrRegex.ReplaceAllStringFunc(text, func(match string) string {
-		// Extract attribute name (remove { and })
-		attrName := match[1 : len(match)-1]
-		
-		// Look up attribute value
-		if value, ok := attrs[attrName]; ok {
-			return value
+// substituteAttributeValues is SubstituteAttributes with a check of each value
+// before it replaces its reference. A reference whose value accept rejects
+// is left as written.
+func substituteAttributeValues(text string, attrs map[string]string, accept func(ref, value string) bool) string {
+	return replaceAttributeReferences(text, func(ref attributeReference, _ int) string {
+		var value string
+		if ref.counter != "" {
+			value = counterReference(ref, stepCounter(attrs, ref.name, ref.initial))
+		} else {
+			value, _ = lookupAttribute(attrs, ref.name)
 		}
-		
-		// If not found, return empty string (or could return the original match)
-		return ""
+		if accept != nil && !accept(ref.text, value) {
+			return ref.text
+		}
+		return value
 	})
+}
 
-	return result
+// attributeReference is an attribute reference found in text
+type attributeReference struct {
+	text    string // The reference as written, braces included
+	name    string
+	counter string // counter or counter2 for a counter reference
+	initial string // Initial value given to a counter reference
+}
+
+// replaceAttributeReferences replaces each attribute reference in text with
+// what replace returns for it; off is the reference's byte offset in text
+func replaceAttributeReferences(text string, replace func(ref attributeReference, off int) string) string {
+	matches := attributeReferenceRegex.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		ref := attributeReference{text: text[m[0]:m[1]]}
+		if m[2] >= 0 {
+			ref.counter, ref.name = text[m[2]:m[3]], text[m[4]:m[5]]
+			if m[6] >= 0 {
+				ref.initial = text[m[6]:m[7]]
+			}
+		} else {
+			ref.name = text[m[8]:m[9]]
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(replace(ref, m[0]))
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
 }
 
 // GetBuiltInAttribute returns a built-in attribute value based on document attributes
@@ -1,6 +1,8 @@
 package lib
 
 import (
+	"context"
+	"strings"
 	"testing"
 )
 
@@ -89,6 +91,12 @@ func TestSubstituteAttributes(t *testing.T) {
 			attrs:    map[string]string{"special": "a{b}c"},
 			expected: "Value: a{b}c",
 		},
+		{
+			name:     "intrinsic attribute",
+			text:     "A{sp}B{startsb}1{endsb}",
+			attrs:    map[string]string{},
+			expected: "A B[1]",
+		},
 		{
 			name:     "invalid attribute syntax (not replaced)",
 			text:     "{incomplete and {valid}",
@@ -349,3 +357,80 @@ func TestMergeAttributes(t *testing.T) {
 	})
 }
 
+
+func TestParse_AttributeEntriesInBody(t *testing.T) {
+	input := `= Title
+:product: Widget
+
+Before: {product}.
+
+:product: Gadget
+:!product:
+:product: Gizmo
+:edition: Pro \
+Plus
+
+After: {product} {edition}.
+
+====
+:product: Thing
+====
+
+Then: {product}.
+
+:product!:
+
+Gone: {product}.`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []string{"Before: Widget.", "After: Gizmo Pro Plus.", "Then: Thing.", "Gone: {product}."}
+	if got := paragraphTexts(doc); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if _, ok := doc.Attributes[":product"]; ok {
+		t.Errorf("Expected product to be unset at the end of the document, got %q", doc.Attributes[":product"])
+	}
+}
+
+func TestParseWithOptions_LockedAttributes(t *testing.T) {
+	input := `= Title
+:product: Widget
+:edition: Home
+:draft:
+
+{product} {edition}
+
+ifdef::draft[]
+Draft.
+endif::draft[]`
+
+	doc, err := ParseWithOptions(context.Background(), strings.NewReader(input), ParseOptions{
+		Attributes: map[string]string{"product": "Locked", "edition": "Default@", "draft!": ""},
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+	if got := strings.Join(paragraphTexts(doc), "|"); got != "Locked Home" {
+		t.Errorf("Expected the locked value, the document's edition and no draft paragraph, got %q", got)
+	}
+}
+
+func TestParse_ContinuedAttributeInConditional(t *testing.T) {
+	input := `:targets: html \
+pdf
+
+ifeval::["{targets}" == "html pdf"]
+Both.
+endif::[]`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := strings.Join(paragraphTexts(doc), "|"); got != "Both." {
+		t.Errorf("Expected the preprocessor to see the continued value, got %q", got)
+	}
+}
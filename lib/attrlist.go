@@ -0,0 +1,379 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AttributeList is a parsed attribute list, the text between the brackets of
+// a block attribute line such as [source#main.lead%linenums,go] or of a macro
+// such as image::tiger.png[Tiger,200,title="A tiger"].
+type AttributeList struct {
+	// Style is the first positional attribute with any shorthand removed
+	Style string
+	// ID comes from #id shorthand or the id attribute
+	ID string
+	// Roles come from .role shorthand or the role attribute
+	Roles []string
+	// Options come from %option shorthand or the options (opts) attribute
+	Options []string
+	// Positional holds the positional attributes in order; Positional[0] is
+	// the style when shorthand was parsed
+	Positional []string
+	// Named holds name=value attributes other than id, role and options
+	Named map[string]string
+}
+
+// AttributeListError describes a malformed attribute list
+type AttributeListError struct {
+	Offset  int // Byte offset of the problem within the list
+	Message string
+}
+
+// Error returns the message with the offset it applies to
+func (e *AttributeListError) Error() string {
+	return fmt.Sprintf("attribute list: %s at offset %d", e.Message, e.Offset)
+}
+
+// ParseAttributeList parses a block attribute list. The first positional
+// attribute may use the shorthand form style#id.role%option, in which each
+// part is optional and roles and options can repeat.
+//
+// Values may be quoted with double or single quotes to include commas; a
+// backslash escapes the quote character inside them. The list is parsed as
+// far as possible even when it is malformed, so the result is never nil; the
+// error reports the first problem found.
+func ParseAttributeList(s string) (*AttributeList, error) {
+	return parseAttributeList(s, true)
+}
+
+// ParseMacroAttributeList is like ParseAttributeList but keeps the first
+// positional attribute as written, for macros whose first positional
+// attribute is text such as an image's alt text.
+func ParseMacroAttributeList(s string) (*AttributeList, error) {
+	return parseAttributeList(s, false)
+}
+
+// attributeNameRegex matches the name in a name=value attribute. Besides
+// AsciiDoc names it allows the :prop, @event and x-on:click forms used by
+// component macros.
+var attributeNameRegex = regexp.MustCompile(`^[\w:@.-]+$`)
+
+func parseAttributeList(s string, shorthand bool) (*AttributeList, error) {
+	attrs := &AttributeList{Named: make(map[string]string)}
+	var firstErr error
+	fail := func(offset int, format string, args ...interface{}) {
+		if firstErr == nil {
+			firstErr = &AttributeListError{Offset: offset, Message: fmt.Sprintf(format, args...)}
+		}
+	}
+
+	if strings.TrimSpace(s) == "" {
+		return attrs, nil
+	}
+
+	i := 0
+	for {
+		// Skip leading whitespace
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		entryStart := i
+
+		// A name=value entry starts with a name followed by =
+		name := ""
+		if eq := strings.IndexByte(s[i:], '='); eq > 0 {
+			candidate := strings.TrimSpace(s[i : i+eq])
+			if attributeNameRegex.MatchString(candidate) && !strings.ContainsAny(s[i:i+eq], ",\"'") {
+				name = candidate
+				i += eq + 1
+				for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+					i++
+				}
+			}
+		} else if eq == 0 {
+			fail(i, "missing attribute name before '='")
+			i++
+		}
+
+		value, quoted := "", false
+		if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+			quote := s[i]
+			var b strings.Builder
+			j := i + 1
+			closed := false
+			for j < len(s) {
+				if s[j] == '\\' && j+1 < len(s) && s[j+1] == quote {
+					b.WriteByte(quote)
+					j += 2
+					continue
+				}
+				if s[j] == quote {
+					closed = true
+					j++
+					break
+				}
+				b.WriteByte(s[j])
+				j++
+			}
+			if !closed {
+				fail(i, "unterminated quoted value")
+			}
+			value, quoted = b.String(), true
+			i = j
+			for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+				i++
+			}
+			if i < len(s) && s[i] != ',' {
+				fail(i, "unexpected text after quoted value")
+				for i < len(s) && s[i] != ',' {
+					i++
+				}
+			}
+		} else {
+			end := strings.IndexByte(s[i:], ',')
+			if end < 0 {
+				end = len(s) - i
+			}
+			value = strings.TrimSpace(s[i : i+end])
+			i += end
+		}
+
+		if name != "" {
+			attrs.setNamed(name, value)
+		} else {
+			if len(attrs.Positional) == 0 && shorthand && !quoted {
+				if err := attrs.parseShorthand(value); err != nil {
+					err.Offset += entryStart
+					fail(err.Offset, "%s", err.Message)
+				}
+				value = attrs.Style
+			}
+			attrs.Positional = append(attrs.Positional, value)
+		}
+
+		if i >= len(s) {
+			break
+		}
+		i++ // Skip the comma
+	}
+
+	return attrs, firstErr
+}
+
+// parseShorthand parses the style#id.role%option form of the first
+// positional attribute
+func (a *AttributeList) parseShorthand(value string) *AttributeListError {
+	end := strings.IndexAny(value, "#.%")
+	if end < 0 {
+		a.Style = value
+		return nil
+	}
+	a.Style = value[:end]
+
+	var err *AttributeListError
+	for end < len(value) {
+		marker := value[end]
+		next := strings.IndexAny(value[end+1:], "#.%")
+		if next < 0 {
+			next = len(value)
+		} else {
+			next += end + 1
+		}
+		part := value[end+1 : next]
+		if part == "" && err == nil {
+			err = &AttributeListError{Offset: end, Message: fmt.Sprintf("empty %s in shorthand %q", shorthandNames[marker], value)}
+		}
+		if part != "" {
+			switch marker {
+			case '#':
+				if a.ID != "" && err == nil {
+					err = &AttributeListError{Offset: end, Message: fmt.Sprintf("more than one ID in shorthand %q", value)}
+				}
+				a.ID = part
+			case '.':
+				a.Roles = append(a.Roles, part)
+			case '%':
+				a.Options = append(a.Options, part)
+			}
+		}
+		end = next
+	}
+	return err
+}
+
+// shorthandNames names the parts of the shorthand form for error messages
+var shorthandNames = map[byte]string{'#': "ID", '.': "role", '%': "option"}
+
+// setNamed records a name=value attribute, folding id, role and options
+// into their fields
+func (a *AttributeList) setNamed(name, value string) {
+	switch name {
+	case "id":
+		a.ID = value
+	case "role":
+		a.Roles = append(a.Roles, strings.Fields(value)...)
+	case "options", "opts":
+		for _, opt := range strings.Split(value, ",") {
+			if opt = strings.TrimSpace(opt); opt != "" {
+				a.Options = append(a.Options, opt)
+			}
+		}
+	default:
+		a.Named[name] = value
+	}
+}
+
+// At returns the positional attribute at index i, or "" if there is none
+func (a *AttributeList) At(i int) string {
+	if i < 0 || i >= len(a.Positional) {
+		return ""
+	}
+	return a.Positional[i]
+}
+
+// HasOption reports whether the list sets the named option
+func (a *AttributeList) HasOption(name string) bool {
+	for _, opt := range a.Options {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge adds the attributes of other, which was written after a, to a.
+// Later IDs, named attributes and non-empty positional attributes replace
+// earlier ones; roles and options accumulate.
+func (a *AttributeList) Merge(other *AttributeList) {
+	if other.ID != "" {
+		a.ID = other.ID
+	}
+	for i, v := range other.Positional {
+		if v == "" {
+			continue
+		}
+		for len(a.Positional) <= i {
+			a.Positional = append(a.Positional, "")
+		}
+		a.Positional[i] = v
+	}
+	if other.Style != "" {
+		a.Style = other.Style
+	}
+	a.Roles = append(a.Roles, other.Roles...)
+	a.Options = append(a.Options, other.Options...)
+	for k, v := range other.Named {
+		a.Named[k] = v
+	}
+}
+
+// applyTo stores the ID, roles, options and named attributes on node. Roles
+// are joined with spaces and options with commas, added to any the node
+// already has. The style and other positional attributes mean different
+// things to different blocks, so each parser interprets those itself.
+func (a *AttributeList) applyTo(node *Node) {
+	if a.ID != "" {
+		node.SetAttribute("id", a.ID)
+	}
+	if len(a.Roles) > 0 {
+		roles := a.Roles
+		if existing := node.GetAttribute("role"); existing != "" {
+			roles = append(strings.Fields(existing), roles...)
+		}
+		node.SetAttribute("role", strings.Join(uniqueStrings(roles), " "))
+	}
+	addOptions(node, a.Options...)
+	for k, v := range a.Named {
+		node.SetAttribute(k, v)
+	}
+}
+
+// addOptions adds options to the comma-separated options attribute of node
+func addOptions(node *Node, options ...string) {
+	if len(options) == 0 {
+		return
+	}
+	if existing := node.GetAttribute("options"); existing != "" {
+		options = append(strings.Split(existing, ","), options...)
+	}
+	node.SetAttribute("options", strings.Join(uniqueStrings(options), ","))
+}
+
+// uniqueStrings returns values without repeats, keeping the first of each
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// blockAttributeLineRegex matches a block attribute line such as [source,go]
+// or [#id.role]. [[id]] anchors match too; callers exclude them.
+var blockAttributeLineRegex = regexp.MustCompile(`^\[(?:|[\w.#%{,"'].*)\]$`)
+
+// isBlockAttributeLine reports whether the trimmed line is a block attribute line
+func isBlockAttributeLine(line string) bool {
+	return blockAttributeLineRegex.MatchString(line) && !strings.HasPrefix(line, "[[")
+}
+
+// blockAttributes parses the attribute lines above the block starting at
+// line idx, skipping block titles, anchors and blank lines between them.
+// Lines closer to the block take precedence. It returns the index of the
+// nearest attribute line, or -1 if there is none. Problems are reported when
+// parseContent steps over the lines, not here.
+func (p *parser) blockAttributes(idx int) (*AttributeList, int) {
+	var lines []int
+	for i := idx - 1; i >= 0; i-- {
+		line := strings.TrimSpace(p.lines[i])
+		if line == "" || blockTitleRegex.MatchString(line) || strings.HasPrefix(line, "[[") || isLineComment(line) {
+			continue
+		}
+		if !isBlockAttributeLine(line) {
+			break
+		}
+		lines = append(lines, i)
+	}
+
+	attrs := &AttributeList{Named: make(map[string]string)}
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(p.lines[lines[i]])
+		parsed, _ := ParseAttributeList(line[1 : len(line)-1])
+		attrs.Merge(parsed)
+	}
+	if len(lines) == 0 {
+		return attrs, -1
+	}
+	return attrs, lines[0]
+}
+
+// applyBlockAttributes applies the attribute lines and the block title above
+// the block starting at line idx to node, registering its ID. It returns the
+// attributes so the caller can interpret the style and positional attributes.
+func (p *parser) applyBlockAttributes(node *Node, idx int) *AttributeList {
+	attrs, attrLine := p.blockAttributes(idx)
+	attrs.applyTo(node)
+	if title := p.blockTitle(idx); title != "" && node.Type != Section {
+		node.SetAttribute("title", title)
+	}
+	if attrs.ID != "" {
+		p.registerAnchor(attrs.ID, node, p.contentPos(attrLine, ""), p.lineEnd(attrLine))
+	}
+	return attrs
+}
+
+// macroAttributes parses the attribute list of the block macro on line idx,
+// reporting a malformed list
+func (p *parser) macroAttributes(idx int, list string, shorthand bool) *AttributeList {
+	attrs, err := parseAttributeList(list, shorthand)
+	if err != nil {
+		p.addLineDiagnostic(SeverityError, CodeMalformedAttributeList, idx, "%v", err)
+	}
+	return attrs
+}
@@ -0,0 +1,200 @@
+package lib
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAttributeList(t *testing.T) {
+	tests := []struct {
+		input      string
+		style      string
+		id         string
+		roles      []string
+		options    []string
+		positional []string
+		named      map[string]string
+	}{
+		{"", "", "", nil, nil, nil, map[string]string{}},
+		{"source,go", "source", "", nil, nil, []string{"source", "go"}, map[string]string{}},
+		{"source#main.lead.wide%linenums,go", "source", "main", []string{"lead", "wide"}, []string{"linenums"}, []string{"source", "go"}, map[string]string{}},
+		{"#intro", "", "intro", nil, nil, []string{""}, map[string]string{}},
+		{"%header%footer,cols=\"1,2\"", "", "", nil, []string{"header", "footer"}, []string{""}, map[string]string{"cols": "1,2"}},
+		{"quote, Abraham Lincoln, \"Address, 1863\"", "quote", "", nil, nil, []string{"quote", "Abraham Lincoln", "Address, 1863"}, map[string]string{}},
+		{`title='It\'s here', role="a b"`, "", "", []string{"a", "b"}, nil, nil, map[string]string{"title": "It's here"}},
+		{"options=\"header,autowidth\",id=tbl", "", "tbl", nil, []string{"header", "autowidth"}, nil, map[string]string{}},
+		{",go", "", "", nil, nil, []string{"", "go"}, map[string]string{}},
+		{`:items="list", @click=go()`, "", "", nil, nil, nil, map[string]string{":items": "list", "@click": "go()"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			attrs, err := ParseAttributeList(tt.input)
+			if err != nil {
+				t.Fatalf("ParseAttributeList failed: %v", err)
+			}
+			if attrs.Style != tt.style || attrs.ID != tt.id {
+				t.Errorf("Expected style %q id %q, got %q %q", tt.style, tt.id, attrs.Style, attrs.ID)
+			}
+			if !reflect.DeepEqual(attrs.Roles, tt.roles) || !reflect.DeepEqual(attrs.Options, tt.options) {
+				t.Errorf("Expected roles %q options %q, got %q %q", tt.roles, tt.options, attrs.Roles, attrs.Options)
+			}
+			if !reflect.DeepEqual(attrs.Positional, tt.positional) {
+				t.Errorf("Expected positional %q, got %q", tt.positional, attrs.Positional)
+			}
+			if !reflect.DeepEqual(attrs.Named, tt.named) {
+				t.Errorf("Expected named %v, got %v", tt.named, attrs.Named)
+			}
+		})
+	}
+}
+
+func TestParseAttributeList_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{`title="unclosed`, "unterminated"},
+		{`title="a"b`, "unexpected text"},
+		{"=value", "missing attribute name"},
+		{"#", "empty ID"},
+		{"source.", "empty role"},
+		{"#a#b", "more than one ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			attrs, err := ParseAttributeList(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("Expected error containing %q, got %v", tt.msg, err)
+			}
+			if attrs == nil {
+				t.Error("Expected a partial result alongside the error")
+			}
+		})
+	}
+}
+
+func TestParseMacroAttributeList(t *testing.T) {
+	attrs, err := ParseMacroAttributeList("A #1 dog.png, 200")
+	if err != nil {
+		t.Fatalf("ParseMacroAttributeList failed: %v", err)
+	}
+	if attrs.At(0) != "A #1 dog.png" || attrs.At(1) != "200" || attrs.ID != "" {
+		t.Errorf("Expected the first positional to be kept as written, got %+v", attrs)
+	}
+}
+
+func TestParse_BlockAttributes(t *testing.T) {
+	input := `[source#main.lead%linenums,go]
+----
+package main
+----
+
+[[tbl-anchor]]
+[cols="1,2",options="header"]
+[#people.striped]
+|===
+|Name |Age
+|===
+
+[quote, "Lincoln, Abraham", Gettysburg]
+____
+Four score.
+____
+
+[.lead]
+Intro paragraph.`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	code := findNode(doc, CodeBlock)
+	if code == nil {
+		t.Fatal("Code block not found")
+	}
+	for k, want := range map[string]string{"id": "main", "role": "lead", "options": "linenums", "language": "go"} {
+		if got := code.GetAttribute(k); got != want {
+			t.Errorf("Expected code block %s %q, got %q", k, want, got)
+		}
+	}
+
+	table := findNode(doc, Table)
+	if table == nil {
+		t.Fatal("Table not found")
+	}
+	for k, want := range map[string]string{"id": "people", "role": "striped", "options": "header", "cols": "1,2"} {
+		if got := table.GetAttribute(k); got != want {
+			t.Errorf("Expected table %s %q, got %q", k, want, got)
+		}
+	}
+
+	quote := findNode(doc, Quote)
+	if quote == nil || quote.GetAttribute("attribution") != "Lincoln, Abraham" || quote.GetAttribute("citation") != "Gettysburg" {
+		t.Errorf("Expected quoted attribution with a comma, got %v", quote)
+	}
+
+	para := doc.Children[len(doc.Children)-1]
+	if para.Type != Paragraph || para.GetAttribute("role") != "lead" {
+		t.Errorf("Expected paragraph role 'lead', got %v", para)
+	}
+}
+
+func TestParse_MacroAttributes(t *testing.T) {
+	input := `image::tiger.png[Tiger, 200, 100, title="A tiger, sleeping"]
+
+component::card[#featured.wide,heading="Hello, world"]`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var image, component *Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == BlockMacro && n.Name == "image" {
+			image = n
+		}
+		if n.Type == BlockMacro && n.Name == "component" {
+			component = n
+		}
+	})
+	if image == nil || component == nil {
+		t.Fatal("Expected image and component macros")
+	}
+	for k, want := range map[string]string{"alt": "Tiger", "width": "200", "height": "100", "title": "A tiger, sleeping"} {
+		if got := image.GetAttribute(k); got != want {
+			t.Errorf("Expected image %s %q, got %q", k, want, got)
+		}
+	}
+	for k, want := range map[string]string{"id": "featured", "role": "wide", "heading": "Hello, world"} {
+		if got := component.GetAttribute(k); got != want {
+			t.Errorf("Expected component %s %q, got %q", k, want, got)
+		}
+	}
+}
+
+func TestValidateWithDiagnostics_MalformedAttributeList(t *testing.T) {
+	input := `[source,title="unclosed]
+----
+code
+----
+
+image::a.png[alt="b"c]`
+
+	diagnostics, err := ValidateWithDiagnostics(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	found := diagnosticsWithCode(diagnostics, CodeMalformedAttributeList)
+	if len(found) != 2 {
+		t.Fatalf("Expected 2 malformed-attribute-list diagnostics, got %v", diagnostics)
+	}
+	if found[0].Start.Line != 1 || found[1].Start.Line != 6 {
+		t.Errorf("Expected diagnostics on lines 1 and 6, got %s and %s", found[0].Start, found[1].Start)
+	}
+}
@@ -55,7 +55,15 @@ type ProgressCallback func(current int, total int, file string, err error)
 // ProcessFilesParallel processes a list of files, optionally in parallel
 // logger is optional - if nil, no logging is performed
 func ProcessFilesParallel(files []string, processFn func(string) error, config BatchConfig, limits ProcessingLimits, progressCb ProgressCallback, logger *Logger) BatchResult {
-	ctx := context.Background()
+	return ProcessFilesParallelContext(context.Background(), files, func(_ context.Context, file string) error {
+		return processFn(file)
+	}, config, limits, progressCb, logger)
+}
+
+// ProcessFilesParallelContext is like ProcessFilesParallel, but passes the
+// job's context ctx on to processFn. Once ctx is done, the files not yet
+// started fail with its error.
+func ProcessFilesParallelContext(ctx context.Context, files []string, processFn func(context.Context, string) error, config BatchConfig, limits ProcessingLimits, progressCb ProgressCallback, logger *Logger) BatchResult {
 	startTime := time.Now()
 	result := BatchResult{
 		TotalFiles: len(files),
@@ -169,9 +177,11 @@ func ProcessFilesParallel(files []string, processFn func(string) error, config B
 			// Check file limits first
 			if limitErr := checkLimits(f, limits); limitErr != nil {
 				err = limitErr
+			} else if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
 			} else {
 			// Process file
-			err = processFn(f)
+			err = processFn(ctx, f)
 		}
 
 		// Report result
@@ -1,7 +1,10 @@
 package lib
 
 import (
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -64,3 +67,25 @@ func TestProcessFilesParallel_LimitExceeded(t *testing.T) {
 	}
 }
 
+
+func TestProcessFilesParallelContext_Cancelled(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "doc.adoc")
+	if err := os.WriteFile(file, []byte("Text."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	results := ProcessFilesParallelContext(ctx, []string{file}, func(context.Context, string) error {
+		called = true
+		return nil
+	}, BatchConfig{}, ProcessingLimits{MaxFileCount: 10, MaxFileSize: 1000}, nil, nil)
+
+	if called {
+		t.Error("Expected no file to be processed once the job is cancelled")
+	}
+	if results.ErrorCount != 1 || !errors.Is(results.Errors[0].Error, context.Canceled) {
+		t.Errorf("Expected the file to fail with the context's error, got %v", results.Errors)
+	}
+}
@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"strings"
+)
+
+// captionType returns the prefix of the attributes that label and count
+// the captions of node, as in example-caption and example-number, or "" if
+// node doesn't get an automatic caption
+func captionType(node *Node) string {
+	switch node.Type {
+	case Example:
+		return "example"
+	case Table:
+		return "table"
+	case CodeBlock:
+		return "listing"
+	case BlockMacro:
+		if node.Name == "image" {
+			return "figure"
+		}
+	}
+	return ""
+}
+
+// blockTitle returns the text of the .Title line among the attribute lines,
+// anchors and blank lines above the block starting at line idx, or "" if
+// there is none
+func (p *parser) blockTitle(idx int) string {
+	for i := idx - 1; i >= 0; i-- {
+		line := strings.TrimSpace(p.lines[i])
+		switch {
+		case blockTitleRegex.MatchString(line):
+			return line[1:]
+		case !isBlockMetadataLine(line):
+			return ""
+		}
+	}
+	return ""
+}
+
+// isBlockMetadataLine reports whether the trimmed line may come between a
+// block title and its block: a blank line, attribute line, anchor or line
+// comment
+func isBlockMetadataLine(line string) bool {
+	return line == "" || isBlockAttributeLine(line) || strings.HasPrefix(line, "[[") || isLineComment(line)
+}
+
+// captionBlock gives a titled example, table, listing or image its caption.
+// An explicit caption attribute is kept as written, or removed when empty to
+// turn the caption off. Otherwise the label from the type's caption
+// attribute, such as table-caption, becomes the caption and the block is
+// numbered with the type's counter. Unsetting the label attribute turns off
+// captions for the type.
+func (p *parser) captionBlock(node *Node) {
+	kind := captionType(node)
+	if kind == "" || node.GetAttribute("title") == "" {
+		return
+	}
+	if caption, ok := node.Attributes["caption"]; ok {
+		if caption == "" {
+			delete(node.Attributes, "caption")
+		}
+		return
+	}
+	label := p.attributes[kind+"-caption"]
+	if label == "" {
+		return
+	}
+	node.SetAttribute("caption", label)
+	node.SetAttribute("number", stepCounter(p.attributes, kind+"-number", ""))
+}
+
+// BlockLabel returns the caption shown before a block title: "Figure 1. "
+// for a numbered block, an explicit caption as written, or "" if the block
+// has no caption
+func BlockLabel(node *Node) string {
+	caption := node.GetAttribute("caption")
+	if number := node.GetAttribute("number"); number != "" && caption != "" {
+		return caption + " " + number + ". "
+	}
+	return caption
+}
+
+// BlockTitle returns the title of a block with its caption, as in
+// "Table 2. Results"
+func BlockTitle(node *Node) string {
+	return BlockLabel(node) + node.GetAttribute("title")
+}
+
+// refSignifier returns how a cross reference names a numbered block or
+// section, as in "Figure 1" or "Section 2.3", or "" if node isn't numbered
+func (p *parser) refSignifier(node *Node) string {
+	number := node.GetAttribute("number")
+	if number == "" {
+		return ""
+	}
+	label := node.GetAttribute("caption")
+	if node.Type == Section && label == "" {
+		label = p.attributes["section-refsig"]
+	}
+	if label == "" {
+		return number
+	}
+	return label + " " + number
+}
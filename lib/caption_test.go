@@ -0,0 +1,205 @@
+package lib
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParse_BlockTitles(t *testing.T) {
+	input := `.Steps
+* One
+* Two
+
+.Intro
+[.lead]
+Some text.
+
+.Results
+|===
+|a |b
+|===
+
+[quote]
+.Wisdom
+____
+Less is more.
+____
+
+.Diagram
+image::arch.png[Architecture]`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []struct {
+		typ   NodeType
+		title string
+	}{
+		{List, "Steps"},
+		{Paragraph, "Intro"},
+		{Table, "Results"},
+		{Quote, "Wisdom"},
+		{BlockMacro, "Diagram"},
+	}
+	if len(doc.Children) != len(want) {
+		t.Fatalf("Expected %d blocks, got %d", len(want), len(doc.Children))
+	}
+	for i, w := range want {
+		block := doc.Children[i]
+		if block.Type != w.typ || block.GetAttribute("title") != w.title {
+			t.Errorf("Block %d: expected %v titled %q, got %v titled %q", i, w.typ, w.title, block.Type, block.GetAttribute("title"))
+		}
+	}
+	if role := doc.Children[1].GetAttribute("role"); role != "lead" {
+		t.Errorf("Expected the attribute line after the title to apply, got role %q", role)
+	}
+}
+
+func TestParse_Captions(t *testing.T) {
+	input := `.First
+====
+Inside.
+====
+
+.Second
+====
+Inside.
+====
+
+.Data
+|===
+|a
+|===
+
+.Untitled caption
+[caption=""]
+----
+code
+----
+
+:listing-caption: Code
+
+.Main
+----
+main()
+----
+
+[caption="Fig. A: "]
+.Explicit
+image::a.png[]
+
+.Counted
+image::b.png[]
+
+:figure-caption!:
+
+.Plain
+image::c.png[]`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []string{
+		"Example 1. First",
+		"Example 2. Second",
+		"Table 1. Data",
+		"Untitled caption",
+		"Code 1. Main",
+		"Fig. A: Explicit",
+		"Figure 1. Counted",
+		"Plain",
+	}
+	var got []string
+	for _, block := range doc.Children {
+		got = append(got, BlockTitle(block))
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected titles %q, got %q", want, got)
+	}
+
+	xml := ToXML(doc)
+	for _, want := range []string{`caption="Example"`, `number="2"`, `caption="Fig. A: "`} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("Expected %s in XML output:\n%s", want, xml)
+		}
+	}
+}
+
+func TestConvert_Captions(t *testing.T) {
+	input := `.Data
+|===
+|a
+|===
+
+.Diagram
+image::arch.png[Architecture]
+
+.Config
+----
+key = value
+----
+
+.Note
+Some text.`
+
+	result, err := Convert(strings.NewReader(input), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	for _, want := range []string{
+		"<caption>Table 1. Data</caption>",
+		"<figcaption>Figure 1. Diagram</figcaption>",
+		`<p data-role="code-title">Listing 1. Config</p>`,
+		`<p data-role="paragraph-title">Note</p>`,
+	} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, result.HTML)
+		}
+	}
+	if strings.Contains(result.HTML, `title="`) {
+		t.Errorf("Expected block titles not to become title attributes:\n%s", result.HTML)
+	}
+}
+
+func TestResolveXrefs_Captions(t *testing.T) {
+	input := `:sectnums:
+
+== Overview
+
+See <<results>> and <<overview>>.
+
+[#results]
+.Results
+|===
+|a
+|===`
+
+	tests := []struct {
+		xrefstyle string
+		want      []string
+	}{
+		{"", []string{"Table 1, “Results”", "Overview"}},
+		{"short", []string{"Table 1", "Section 1"}},
+		{"basic", []string{"Results", "Overview"}},
+		{"full", []string{"Table 1, “Results”", "Section 1, “Overview”"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.xrefstyle, func(t *testing.T) {
+			opts := ParseOptions{Attributes: map[string]string{"xrefstyle": tt.xrefstyle}}
+			doc, err := ParseWithOptions(context.Background(), strings.NewReader(input), opts)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			var got []string
+			for _, xref := range xrefNodes(doc) {
+				got = append(got, getTextContent(xref))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
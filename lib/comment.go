@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"strings"
+)
+
+// isLineComment reports whether line is a // line comment. A line starting
+// with /// is not a comment unless it is a comment block delimiter.
+func isLineComment(line string) bool {
+	return strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "///")
+}
+
+// isCommentBlockDelimiter reports whether line opens or closes a comment
+// block: four or more slashes and nothing else
+func isCommentBlockDelimiter(line string) bool {
+	return len(line) >= 4 && strings.Trim(line, "/") == ""
+}
+
+// commentBlockEnd returns the index of the line closing the comment block
+// opened on line idx, or -1 if the block is never closed. The closing
+// delimiter must be the same length as the opening one.
+func (p *parser) commentBlockEnd(idx int) int {
+	delimiter := strings.TrimSpace(p.lines[idx])
+	for i := idx + 1; i < len(p.lines); i++ {
+		if strings.TrimSpace(p.lines[i]) == delimiter {
+			return i
+		}
+	}
+	return -1
+}
+
+// skipCommentBlock returns the index of the last line of the comment block
+// opened on line idx, or idx if the line doesn't open one. It lets lookahead
+// loops step over commented-out content.
+func (p *parser) skipCommentBlock(idx int) int {
+	if !isCommentBlockDelimiter(strings.TrimSpace(p.lines[idx])) {
+		return idx
+	}
+	if end := p.commentBlockEnd(idx); end >= 0 {
+		return end
+	}
+	return len(p.lines) - 1
+}
+
+// parseComment consumes the line comment or comment block on the current
+// line. Comments are dropped unless ParseOptions.KeepComments is set, in
+// which case it returns a Comment node holding the comment's text.
+func (p *parser) parseComment() *Node {
+	start := p.lineNum
+	line := strings.TrimSpace(p.lines[start])
+
+	if !isCommentBlockDelimiter(line) {
+		p.lineNum++
+		if !p.opts.KeepComments {
+			return nil
+		}
+		text := strings.TrimPrefix(line, "//")
+		if strings.HasPrefix(text, " ") {
+			text = text[1:]
+		}
+		comment := NewCommentNode(text)
+		comment.SetAttribute("type", "line")
+		p.setSpan(comment, start, start)
+		return comment
+	}
+
+	end := p.commentBlockEnd(start)
+	if end < 0 {
+		p.reportUnclosed(start)
+		end = len(p.lines)
+	}
+	p.lineNum = end + 1
+	return p.buildCommentBlock(start, p.lines[start+1:end])
+}
+
+// buildCommentBlock builds the comment block that started on line start and
+// ended before the current line, or returns nil unless comments are kept. It
+// also serves open blocks styled [comment].
+func (p *parser) buildCommentBlock(start int, content []string) *Node {
+	if !p.opts.KeepComments {
+		return nil
+	}
+	comment := NewCommentNode(strings.Join(content, "\n"))
+	comment.SetAttribute("type", "block")
+	p.setSpan(comment, start, p.lineNum-1)
+	return comment
+}
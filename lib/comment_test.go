@@ -0,0 +1,157 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// commentTestInput has comments in the header, between blocks, within a
+// paragraph, inside delimited blocks and in verbatim blocks
+const commentTestInput = `// Header comment
+= Title
+:toc:
+
+////
+== Not a section
+Commented out.
+////
+
+// GFM-specific HTML block (out-of-spec for standard Markdown)
+First line
+// dropped from the paragraph
+second line.
+
+[.lead]
+// between the attributes and the block
+Check this.
+
+====
+// inside an example
+Example text.
+====
+
+----
+// kept in code
+----
+
+[verse]
+____
+// kept in verse
+____`
+
+// hasRole reports whether a paragraph in doc has the given role
+func hasRole(doc *Node, role string) bool {
+	found := false
+	doc.Traverse(func(n *Node) {
+		if n.Type == Paragraph && n.GetAttribute("role") == role {
+			found = true
+		}
+	})
+	return found
+}
+
+func TestParseComments_Dropped(t *testing.T) {
+	doc, err := Parse(strings.NewReader(commentTestInput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if findNode(doc, Comment) != nil {
+		t.Error("Expected comments to be dropped by default")
+	}
+	if findNode(doc, Section) != nil {
+		t.Error("Expected the commented-out section to be skipped")
+	}
+	if doc.GetAttribute("title") != "Title" {
+		t.Errorf("Expected the header to be parsed after a comment, got title %q", doc.GetAttribute("title"))
+	}
+
+	para := findNode(doc, Paragraph)
+	if got := getTextContent(para); got != "First line second line." {
+		t.Errorf("Expected the comment to be dropped from the paragraph, got %q", got)
+	}
+	if !hasRole(doc, "lead") {
+		t.Error("Expected [.lead] to apply to the block after the comment")
+	}
+	if example := findNode(doc, Example); getTextContent(example) != "Example text." {
+		t.Errorf("Expected the comment inside the example to be dropped, got %q", getTextContent(example))
+	}
+	if code := findNode(doc, CodeBlock); getTextContent(code) != "// kept in code" {
+		t.Errorf("Expected the code block to keep its comment line, got %q", getTextContent(code))
+	}
+	if verse := findNode(doc, VerseBlock); getTextContent(verse) != "// kept in verse" {
+		t.Errorf("Expected the verse block to keep its comment line, got %q", getTextContent(verse))
+	}
+}
+
+func TestParseComments_Kept(t *testing.T) {
+	doc, err := ParseWithOptions(context.Background(), strings.NewReader(commentTestInput), ParseOptions{KeepComments: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+
+	var comments []*Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == Comment {
+			comments = append(comments, n)
+		}
+	})
+	want := []struct {
+		typ     string
+		content string
+		line    int
+	}{
+		{"line", "Header comment", 1},
+		{"block", "== Not a section\nCommented out.", 5},
+		{"line", "GFM-specific HTML block (out-of-spec for standard Markdown)", 10},
+		{"line", "between the attributes and the block", 16},
+		{"line", "inside an example", 20},
+	}
+	if len(comments) != len(want) {
+		t.Fatalf("Expected %d comments, got %d", len(want), len(comments))
+	}
+	for i, w := range want {
+		c := comments[i]
+		if c.GetAttribute("type") != w.typ || c.Content != w.content || c.Start.Line != w.line {
+			t.Errorf("comment %d: expected %s %q on line %d, got %s %q on line %d",
+				i, w.typ, w.content, w.line, c.GetAttribute("type"), c.Content, c.Start.Line)
+		}
+	}
+	if !hasRole(doc, "lead") {
+		t.Error("Expected a kept comment not to separate [.lead] from its block")
+	}
+}
+
+func TestParseComments_Unclosed(t *testing.T) {
+	diagnostics, err := ValidateWithDiagnostics(strings.NewReader("Text.\n\n////\nnever closed"))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	if found := diagnosticsWithCode(diagnostics, CodeUnclosedDelimiter); len(found) != 1 || found[0].Start.Line != 3 {
+		t.Errorf("Expected an unclosed comment block on line 3, got %v", diagnostics)
+	}
+}
+
+func TestConvertComments(t *testing.T) {
+	doc, err := ParseWithOptions(context.Background(), strings.NewReader("// a -- b\n\nText.\n\n////\n<block>\n////"), ParseOptions{KeepComments: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+
+	var html bytes.Buffer
+	toHTML(doc, &html, false, 0, RenderOptions{})
+	for _, want := range []string{"<!-- a - - b -->", "<!-- <block> -->"} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, html.String())
+		}
+	}
+
+	var xml bytes.Buffer
+	toXML(doc, &xml, 0, RenderOptions{})
+	for _, want := range []string{`<comment type="line">a -- b</comment>`, `<comment type="block">&lt;block&gt;</comment>`} {
+		if !strings.Contains(xml.String(), want) {
+			t.Errorf("Expected %q in XML output:\n%s", want, xml.String())
+		}
+	}
+}
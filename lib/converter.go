@@ -3,10 +3,12 @@ package lib
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"html"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -22,12 +24,27 @@ type ConvertOptions struct {
 	XHTML         bool   // If true, outputs well-formed XHTML5
 	PicoCSSPath   string // Path to PicoCSS (used for <link> tag if provided)
 	PicoCSSContent string // PicoCSS content to embed inline (if PicoCSSPath is empty and UsePicoCSS is true)
+
+	SourcePositions bool // If true, elements carry data-source-line attributes
+
+	ParseOptions ParseOptions // Options for parsing the input, such as the include resolver
+}
+
+// RenderOptions configures ToHTMLWithOptions and ToXMLWithOptions
+type RenderOptions struct {
+	// SourcePositions emits the source position of each node: data-source-line
+	// in HTML, and source-line, source-column, source-end-line and
+	// source-end-column in XML. Nodes from included documents also carry
+	// data-source-file or source-file.
+	SourcePositions bool
 }
 
 // Metadata contains parsed document metadata
 type Metadata struct {
 	Title      string
-	Author     string
+	Author     string   // Name of the first author
+	Authors    []Author // Every author, from the author line or attribute entries
+	Revision   Revision
 	Attributes map[string]string // e.g. map[":toc": "true"]
 }
 
@@ -46,87 +63,35 @@ func ParseDocument(reader io.Reader) (*Node, error) {
 
 // ToHTML converts an AST node to HTML string
 func ToHTML(node *Node) string {
+	return ToHTMLWithOptions(node, RenderOptions{})
+}
+
+// ToHTMLWithOptions converts an AST node to HTML string using opts
+func ToHTMLWithOptions(node *Node, opts RenderOptions) string {
 	var buf bytes.Buffer
-	toHTML(node, &buf, false, 0)
+	toHTML(node, &buf, false, 0, opts)
 	return buf.String()
 }
 
 // toHTML is the internal recursive function for HTML conversion
-func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
+func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int, opts RenderOptions) {
 	indentStr := strings.Repeat("    ", indent)
 
 	switch node.Type {
 	case Document:
-		// Document has no wrapper, just output children
-		// Check if first child is a preamble (Paragraph with role="preamble")
-		hasPreamble := false
-		if len(node.Children) > 0 {
-			firstChild := node.Children[0]
-			if firstChild.Type == Paragraph && (firstChild.GetAttribute("role") == "preamble" || firstChild.GetAttribute("data-role") == "preamble") {
-				hasPreamble = true
-				// Output preamble wrapper
-				indentStr := strings.Repeat("    ", indent)
-				var attrParts []string
-				attrParts = append(attrParts, `data-role="preamble"`)
-				otherAttrs := buildHTMLAttributes(firstChild, []string{"role", "id"})
-				if otherAttrs != "" {
-					attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
-				}
-				attrs := buildAttrsString(attrParts...)
-				fmt.Fprintf(buf, "%s<div%s>\n", indentStr, attrs)
-				// Output preamble content (children of the paragraph)
-				for _, grandchild := range firstChild.Children {
-					toHTML(grandchild, buf, xhtml, indent+1)
-				}
-				fmt.Fprintf(buf, "%s</div>\n", indentStr)
+		// Document has no wrapper, just output children. The preamble
+		// (Paragraph with role="preamble") follows a table of contents
+		// placed at the top.
+		for _, child := range node.Children {
+			if isPreamble(child) {
+				writeHTMLPreamble(child, buf, xhtml, indent, opts)
+			} else {
+				toHTML(child, buf, xhtml, indent, opts)
 			}
 		}
-		
-		// Output remaining children (skip first if it was preamble)
-		startIdx := 0
-		if hasPreamble {
-			startIdx = 1
-		}
-		for i := startIdx; i < len(node.Children); i++ {
-			toHTML(node.Children[i], buf, xhtml, indent)
-		}
 
 	case Section:
-		level := 1
-		if levelAttr := node.GetAttribute("level"); levelAttr != "" {
-			fmt.Sscanf(levelAttr, "%d", &level)
-		}
-		hLevel := level + 1
-		if hLevel > 6 {
-			hLevel = 6
-		}
-		tagName := fmt.Sprintf("h%d", hLevel)
-		
-		var attrParts []string
-		// Handle appendix and discrete attributes
-		if appendix := node.GetAttribute("appendix"); appendix != "" {
-			attrParts = append(attrParts, fmt.Sprintf(`data-asciidoc-appendix="%s"`, html.EscapeString(appendix)))
-		}
-		if discrete := node.GetAttribute("discrete"); discrete != "" {
-			attrParts = append(attrParts, fmt.Sprintf(`data-asciidoc-discrete="%s"`, html.EscapeString(discrete)))
-		}
-		// Add other attributes (role for ARIA, others as data-asciidoc-*)
-		// Exclude id, level, appendix, discrete, title, and marker
-		otherAttrs := buildHTMLAttributes(node, []string{"id", "level", "appendix", "discrete", "title", "marker"})
-		if otherAttrs != "" {
-			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
-		}
-		attrs := buildAttrsString(attrParts...)
-		
-		// Section title (first text child or title attribute)
-		titleText := node.GetAttribute("title")
-		if titleText == "" && len(node.Children) > 0 && node.Children[0].Type == Text {
-			titleText = node.Children[0].Content
-		}
-		
-		if titleText != "" {
-			fmt.Fprintf(buf, "%s<%s%s>%s</%s>\n", indentStr, tagName, attrs, html.EscapeString(titleText), tagName)
-		}
+		writeHTMLSectionHeading(node, buf, indentStr, opts)
 		
 		// Section content (skip first child if it was the title text)
 		startIdx := 0
@@ -134,24 +99,25 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			startIdx = 1
 		}
 		for i := startIdx; i < len(node.Children); i++ {
-			toHTML(node.Children[i], buf, xhtml, indent)
+			toHTML(node.Children[i], buf, xhtml, indent, opts)
 		}
 
 	case Paragraph:
 		// Skip preamble paragraphs - they're handled in Document case
-		if node.GetAttribute("role") == "preamble" || node.GetAttribute("data-role") == "preamble" {
+		if isPreamble(node) {
 			// Just output children directly without <p> wrapper
 			for _, child := range node.Children {
-				toHTML(child, buf, xhtml, indent)
+				toHTML(child, buf, xhtml, indent, opts)
 			}
 			return
 		}
 		
+		writeBlockTitle(buf, indentStr, node, "paragraph")
 		var attrParts []string
 		if id := node.GetAttribute("id"); id != "" {
 			attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 		}
-		otherAttrs := buildHTMLAttributes(node, []string{"id"})
+		otherAttrs := buildHTMLAttributes(node, []string{"id", "title"}, opts)
 		if otherAttrs != "" {
 			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 		}
@@ -162,7 +128,7 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		} else {
 			fmt.Fprintf(buf, "%s<p>", indentStr)
 		}
-		toHTMLInlineContent(node, buf, xhtml)
+		toHTMLInlineContent(node, buf, xhtml, opts)
 		buf.WriteString("</p>\n")
 
 	case BlockMacro:
@@ -186,26 +152,37 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			} else {
 				buf.WriteString(">\n")
 				for _, child := range node.Children {
-					toHTML(child, buf, xhtml, indent+1)
+					toHTML(child, buf, xhtml, indent+1, opts)
 				}
 				buf.WriteString(indentStr + "</cms-component>\n")
 			}
 		} else if node.Name == "image" {
 			src := node.GetAttribute("src")
 			alt := node.GetAttribute("alt")
-			buf.WriteString(indentStr + `<img style="display:block" src="` + html.EscapeString(src) + `"`)
+			title := node.GetAttribute("title")
+			imgIndent := indentStr
+			if title != "" {
+				// A titled image is a figure captioned with its title
+				buf.WriteString(indentStr + "<figure>\n")
+				imgIndent += "    "
+			}
+			buf.WriteString(imgIndent + `<img style="display:block" src="` + html.EscapeString(src) + `"`)
 			if alt != "" {
 				buf.WriteString(` alt="` + html.EscapeString(alt) + `"`)
 			} else {
 				buf.WriteString(` alt=""`)
 			}
-			attrs := buildHTMLAttributes(node, []string{"src", "alt"})
+			attrs := buildHTMLAttributes(node, []string{"src", "alt", "title", "caption", "number"}, opts)
 			buf.WriteString(attrs)
 			if xhtml {
 				buf.WriteString("/>\n")
 			} else {
 				buf.WriteString(">\n")
 			}
+			if title != "" {
+				fmt.Fprintf(buf, "%s<figcaption>%s</figcaption>\n", imgIndent, html.EscapeString(BlockTitle(node)))
+				buf.WriteString(indentStr + "</figure>\n")
+			}
 		} else if node.Name == "anchor" {
 			// Block anchor
 			id := node.GetAttribute("id")
@@ -219,7 +196,7 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			if len(node.Children) > 0 {
 				// Included content - render children
 				for _, child := range node.Children {
-					toHTML(child, buf, xhtml, indent)
+					toHTML(child, buf, xhtml, indent, opts)
 				}
 			} else {
 				// Placeholder
@@ -232,7 +209,7 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 				if file != "" {
 					attrParts = append(attrParts, fmt.Sprintf(`data-asciidoc-file="%s"`, html.EscapeString(file)))
 				}
-				otherAttrs := buildHTMLAttributes(node, []string{"src", "target"})
+				otherAttrs := buildHTMLAttributes(node, []string{"src", "target"}, opts)
 				if otherAttrs != "" {
 					attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 				}
@@ -240,21 +217,22 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 				fmt.Fprintf(buf, "%s<div%s>[Include: %s]</div>\n", indentStr, attrs, html.EscapeString(file))
 			}
 		} else if node.Name == "toc" {
-			// TOC generation - collect sections from document
-			// For now, output placeholder - full TOC generation would require document traversal
+			// The parser fills the table of contents in as a list of links
 			var attrParts []string
 			attrParts = append(attrParts, `data-role="toc"`)
 			levels := node.GetAttribute("levels")
 			if levels != "" {
 				attrParts = append(attrParts, fmt.Sprintf(`data-asciidoc-levels="%s"`, html.EscapeString(levels)))
 			}
-			otherAttrs := buildHTMLAttributes(node, []string{"levels"})
+			otherAttrs := buildHTMLAttributes(node, []string{"levels"}, opts)
 			if otherAttrs != "" {
 				attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 			}
 			attrs := buildAttrsString(attrParts...)
 			fmt.Fprintf(buf, "%s<nav%s>\n", indentStr, attrs)
-			fmt.Fprintf(buf, "%s    <!-- TOC would be generated here -->\n", indentStr)
+			for _, child := range node.Children {
+				toHTML(child, buf, xhtml, indent+1, opts)
+			}
 			fmt.Fprintf(buf, "%s</nav>\n", indentStr)
 		} else if node.Name == "video" {
 			src := node.GetAttribute("src")
@@ -281,7 +259,7 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			if height := node.GetAttribute("height"); height != "" {
 				attrParts = append(attrParts, fmt.Sprintf(`height="%s"`, html.EscapeString(height)))
 			}
-			otherAttrs := buildHTMLAttributes(node, []string{"src", "target", "controls", "autoplay", "loop", "poster", "width", "height"})
+			otherAttrs := buildHTMLAttributes(node, []string{"src", "target", "controls", "autoplay", "loop", "poster", "width", "height"}, opts)
 			if otherAttrs != "" {
 				attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 			}
@@ -307,7 +285,7 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			if loop := node.GetAttribute("loop"); loop == "true" {
 				attrParts = append(attrParts, "loop")
 			}
-			otherAttrs := buildHTMLAttributes(node, []string{"src", "target", "controls", "autoplay", "loop"})
+			otherAttrs := buildHTMLAttributes(node, []string{"src", "target", "controls", "autoplay", "loop"}, opts)
 			if otherAttrs != "" {
 				attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 			}
@@ -322,14 +300,14 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			var attrParts []string
 			attrParts = append(attrParts, `data-role="macro"`)
 			attrParts = append(attrParts, fmt.Sprintf(`data-asciidoc-macro="%s"`, html.EscapeString(node.Name)))
-			otherAttrs := buildHTMLAttributes(node, []string{})
+			otherAttrs := buildHTMLAttributes(node, []string{}, opts)
 			if otherAttrs != "" {
 				attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 			}
 			attrs := buildAttrsString(attrParts...)
 			fmt.Fprintf(buf, "%s<div%s>\n", indentStr, attrs)
 			for _, child := range node.Children {
-				toHTML(child, buf, xhtml, indent+1)
+				toHTML(child, buf, xhtml, indent+1, opts)
 			}
 			fmt.Fprintf(buf, "%s</div>\n", indentStr)
 		}
@@ -340,22 +318,38 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 	case List:
 		style := node.GetAttribute("style")
 		tagName := "ul"
-		if style == "ordered" {
+		if style == "ordered" || style == "callout" {
 			tagName = "ol"
 		} else if style == "labeled" {
 			tagName = "dl"
 		}
 		
 		var attrParts []string
+		if style == "callout" {
+			attrParts = append(attrParts, `data-role="callout-list"`)
+		}
 		if id := node.GetAttribute("id"); id != "" {
 			attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 		}
-		otherAttrs := buildHTMLAttributes(node, []string{"id", "style"})
+		if tagName == "ol" {
+			if start := node.GetAttribute("start"); start != "" {
+				attrParts = append(attrParts, fmt.Sprintf(`start="%s"`, html.EscapeString(start)))
+			}
+			if hasOption(node, "reversed") {
+				if xhtml {
+					attrParts = append(attrParts, `reversed="reversed"`)
+				} else {
+					attrParts = append(attrParts, "reversed")
+				}
+			}
+		}
+		otherAttrs := buildHTMLAttributes(node, []string{"id", "style", "start", "title"}, opts)
 		if otherAttrs != "" {
 			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 		}
 		attrs := buildAttrsString(attrParts...)
 		
+		writeBlockTitle(buf, indentStr, node, "list")
 		if attrs != "" {
 			fmt.Fprintf(buf, "%s<%s%s>\n", indentStr, tagName, attrs)
 		} else {
@@ -367,18 +361,19 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 				if style == "labeled" {
 					// For labeled lists, item has term and description as children
 					term := item.GetAttribute("term")
-					if term != "" {
+					if term != "" && len(item.Children) > 0 {
 						fmt.Fprintf(buf, "%s    <dt>", indentStr)
-						toHTMLInlineContent(item, buf, xhtml)
+						toHTMLInlineContent(item.Children[0], buf, xhtml, opts)
 						buf.WriteString("</dt>\n")
 					}
-					// Description is the second child paragraph
+					// Description is the second child paragraph, followed by any
+					// attached blocks and nested lists
 					if len(item.Children) > 1 {
 						var ddAttrParts []string
 						if id := item.GetAttribute("id"); id != "" {
 							ddAttrParts = append(ddAttrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 						}
-						otherAttrs := buildHTMLAttributes(item, []string{"id", "term"})
+						otherAttrs := buildHTMLAttributes(item, []string{"id", "term"}, opts)
 						if otherAttrs != "" {
 							ddAttrParts = append(ddAttrParts, strings.TrimSpace(otherAttrs))
 						}
@@ -388,7 +383,15 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 						} else {
 							fmt.Fprintf(buf, "%s    <dd>", indentStr)
 						}
-						toHTML(item.Children[1], buf, xhtml, 0)
+						if desc := item.Children[1]; len(desc.Children) > 0 {
+							toHTML(desc, buf, xhtml, 0, opts)
+						}
+						if blocks := item.Children[2:]; len(blocks) > 0 {
+							for _, block := range blocks {
+								toHTML(block, buf, xhtml, indent+2, opts)
+							}
+							fmt.Fprintf(buf, "%s    ", indentStr)
+						}
 						buf.WriteString("</dd>\n")
 					}
 				} else {
@@ -400,7 +403,7 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 					if callout := item.GetAttribute("callout"); callout != "" {
 						liAttrParts = append(liAttrParts, fmt.Sprintf(`data-asciidoc-callout="%s"`, html.EscapeString(callout)))
 					}
-					otherAttrs := buildHTMLAttributes(item, []string{"id", "callout", "term"})
+					otherAttrs := buildHTMLAttributes(item, []string{"id", "callout", "coids", "term", "checkbox", "checked"}, opts)
 					if otherAttrs != "" {
 						liAttrParts = append(liAttrParts, strings.TrimSpace(otherAttrs))
 					}
@@ -412,10 +415,39 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 						fmt.Fprintf(buf, "%s    <li>", indentStr)
 					}
 					// Add callout marker if present
+					// Add callout marker if present, linking back to the first
+					// callout in the listing
 					if callout := item.GetAttribute("callout"); callout != "" {
-						fmt.Fprintf(buf, `<span data-role="callout-marker">%s</span> `, html.EscapeString(callout))
+						if coids := strings.Fields(item.GetAttribute("coids")); len(coids) > 0 {
+							fmt.Fprintf(buf, `<a data-role="callout-marker" href="#%s">%s</a> `, html.EscapeString(coids[0]), html.EscapeString(callout))
+						} else {
+							fmt.Fprintf(buf, `<span data-role="callout-marker">%s</span> `, html.EscapeString(callout))
+						}
+					}
+					// Checklist items start with a read-only checkbox
+					if item.GetAttribute("checkbox") != "" {
+						checked := ""
+						if item.GetAttribute("checked") != "" {
+							checked = " checked"
+							if xhtml {
+								checked = ` checked="checked"`
+							}
+						}
+						if xhtml {
+							fmt.Fprintf(buf, `<input type="checkbox" data-role="checkbox"%s disabled="disabled" /> `, checked)
+						} else {
+							fmt.Fprintf(buf, `<input type="checkbox" data-role="checkbox"%s disabled> `, checked)
+						}
+					}
+					inline, blocks := splitListItemChildren(item)
+					toHTMLInlineNodes(inline, buf, xhtml, opts)
+					if len(blocks) > 0 {
+						buf.WriteString("\n")
+						for _, block := range blocks {
+							toHTML(block, buf, xhtml, indent+2, opts)
+						}
+						fmt.Fprintf(buf, "%s    ", indentStr)
 					}
-					toHTMLInlineContent(item, buf, xhtml)
 					buf.WriteString("</li>\n")
 				}
 			}
@@ -438,15 +470,13 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			buf.WriteString("</cms-mermaid>\n")
 		} else {
 			// Regular code block
-			if title := node.GetAttribute("title"); title != "" {
-				fmt.Fprintf(buf, "%s<p data-role=\"code-title\">%s</p>\n", indentStr, html.EscapeString(title))
-			}
+			writeBlockTitle(buf, indentStr, node, "code")
 			
 			var attrParts []string
 			if lang := node.GetAttribute("language"); lang != "" {
 				attrParts = append(attrParts, fmt.Sprintf(`data-asciidoc-language="%s"`, html.EscapeString(lang)))
 			}
-			otherAttrs := buildHTMLAttributes(node, []string{"language", "title", "id", "role"})
+			otherAttrs := buildHTMLAttributes(node, []string{"language", "title", "caption", "number", "id", "role"}, opts)
 			if otherAttrs != "" {
 				attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 			}
@@ -457,11 +487,7 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			} else {
 				fmt.Fprintf(buf, "%s<pre><code>", indentStr)
 			}
-			for _, child := range node.Children {
-				if child.Type == Text {
-					buf.WriteString(html.EscapeString(child.Content))
-				}
-			}
+			writeCodeLines(buf, node, xhtml, opts)
 			buf.WriteString("</code></pre>\n")
 		}
 
@@ -480,22 +506,19 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			buf.WriteString("</cms-mermaid>\n")
 		} else {
 			// Regular literal block
+			writeBlockTitle(buf, indentStr, node, "literal")
 			var attrParts []string
 			attrParts = append(attrParts, `data-role="literal-block"`)
 			if id := node.GetAttribute("id"); id != "" {
 				attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 			}
-			otherAttrs := buildHTMLAttributes(node, []string{"id"})
+			otherAttrs := buildHTMLAttributes(node, []string{"id", "title"}, opts)
 			if otherAttrs != "" {
 				attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 			}
 			attrs := buildAttrsString(attrParts...)
 			fmt.Fprintf(buf, "%s<pre%s>", indentStr, attrs)
-			for _, child := range node.Children {
-				if child.Type == Text {
-					buf.WriteString(html.EscapeString(child.Content))
-				}
-			}
+			writeCodeLines(buf, node, xhtml, opts)
 			buf.WriteString("</pre>\n")
 		}
 
@@ -506,17 +529,15 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		if id := node.GetAttribute("id"); id != "" {
 			attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 		}
-		otherAttrs := buildHTMLAttributes(node, []string{"id", "title"})
+		otherAttrs := buildHTMLAttributes(node, []string{"id", "title", "caption", "number"}, opts)
 		if otherAttrs != "" {
 			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 		}
 		attrs := buildAttrsString(attrParts...)
 		fmt.Fprintf(buf, "%s<div%s>\n", indentStr, attrs)
-		if title := node.GetAttribute("title"); title != "" {
-			fmt.Fprintf(buf, "%s    <p data-role=\"example-title\">%s</p>\n", indentStr, html.EscapeString(title))
-		}
+		writeBlockTitle(buf, indentStr+"    ", node, "example")
 		for _, child := range node.Children {
-			toHTML(child, buf, xhtml, indent+1)
+			toHTML(child, buf, xhtml, indent+1, opts)
 		}
 		fmt.Fprintf(buf, "%s</div>\n", indentStr)
 
@@ -527,17 +548,15 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		if id := node.GetAttribute("id"); id != "" {
 			attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 		}
-		otherAttrs := buildHTMLAttributes(node, []string{"id", "title"})
+		otherAttrs := buildHTMLAttributes(node, []string{"id", "title"}, opts)
 		if otherAttrs != "" {
 			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 		}
 		attrs := buildAttrsString(attrParts...)
 		fmt.Fprintf(buf, "%s<aside%s>\n", indentStr, attrs)
-		if title := node.GetAttribute("title"); title != "" {
-			fmt.Fprintf(buf, "%s    <p data-role=\"sidebar-title\">%s</p>\n", indentStr, html.EscapeString(title))
-		}
+		writeBlockTitle(buf, indentStr+"    ", node, "sidebar")
 		for _, child := range node.Children {
-			toHTML(child, buf, xhtml, indent+1)
+			toHTML(child, buf, xhtml, indent+1, opts)
 		}
 		fmt.Fprintf(buf, "%s</aside>\n", indentStr)
 
@@ -547,18 +566,19 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		if id := node.GetAttribute("id"); id != "" {
 			attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 		}
-		otherAttrs := buildHTMLAttributes(node, []string{"id", "attribution", "citation"})
+		otherAttrs := buildHTMLAttributes(node, []string{"id", "attribution", "citation", "title"}, opts)
 		if otherAttrs != "" {
 			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 		}
 		attrs := buildAttrsString(attrParts...)
+		writeBlockTitle(buf, indentStr, node, "quote")
 		if attrs != "" {
 			fmt.Fprintf(buf, "%s<blockquote%s>\n", indentStr, attrs)
 		} else {
 			fmt.Fprintf(buf, "%s<blockquote>\n", indentStr)
 		}
 		for _, child := range node.Children {
-			toHTML(child, buf, xhtml, indent+1)
+			toHTML(child, buf, xhtml, indent+1, opts)
 		}
 		if attribution := node.GetAttribute("attribution"); attribution != "" {
 			fmt.Fprintf(buf, "%s    <footer><cite>%s</cite></footer>\n", indentStr, html.EscapeString(attribution))
@@ -574,7 +594,7 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		if id := node.GetAttribute("id"); id != "" {
 			attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 		}
-		otherAttrs := buildHTMLAttributes(node, []string{"id"})
+		otherAttrs := buildHTMLAttributes(node, []string{"id", "title", "caption", "number"}, opts)
 		if otherAttrs != "" {
 			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 		}
@@ -585,107 +605,125 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		} else {
 			fmt.Fprintf(buf, "%s<table>\n", indentStr)
 		}
+		if node.GetAttribute("title") != "" {
+			fmt.Fprintf(buf, "%s    <caption>%s</caption>\n", indentStr, html.EscapeString(BlockTitle(node)))
+		}
 		
-		var theadStarted, tbodyStarted, tfootStarted bool
+		// Column widths
+		var columns []*Node
 		for _, child := range node.Children {
-			if child.Type == TableRow {
-				rowRole := child.GetAttribute("role")
-				if rowRole == "header" && !theadStarted {
-					if tbodyStarted {
-						// Close tbody before thead
-						fmt.Fprintf(buf, "%s    </tbody>\n", indentStr)
-						tbodyStarted = false
-					}
-					fmt.Fprintf(buf, "%s    <thead>\n", indentStr)
-					theadStarted = true
-				} else if rowRole == "footer" && !tfootStarted {
-					if tbodyStarted {
-						fmt.Fprintf(buf, "%s    </tbody>\n", indentStr)
-						tbodyStarted = false
-					}
-					if theadStarted {
-						fmt.Fprintf(buf, "%s    </thead>\n", indentStr)
-						theadStarted = false
-					}
-					fmt.Fprintf(buf, "%s    <tfoot>\n", indentStr)
-					tfootStarted = true
-				} else if !theadStarted && !tfootStarted && !tbodyStarted {
-					// First row without explicit role - assume header if first row
-					if !theadStarted {
-						fmt.Fprintf(buf, "%s    <thead>\n", indentStr)
-						theadStarted = true
-					}
-				} else if !tbodyStarted && !tfootStarted && theadStarted && rowRole != "header" {
-					// Transition from header to body
-					fmt.Fprintf(buf, "%s    </thead>\n", indentStr)
-					theadStarted = false
-					fmt.Fprintf(buf, "%s    <tbody>\n", indentStr)
-					tbodyStarted = true
-				} else if !tbodyStarted && !theadStarted && !tfootStarted {
-					// Regular body row
-					fmt.Fprintf(buf, "%s    <tbody>\n", indentStr)
-					tbodyStarted = true
+			if child.Type == TableColumn {
+				columns = append(columns, child)
+			}
+		}
+		if len(columns) > 0 {
+			fmt.Fprintf(buf, "%s    <colgroup>\n", indentStr)
+			for _, col := range columns {
+				colAttrs := ""
+				if width := col.GetAttribute("width"); width != "" {
+					colAttrs = fmt.Sprintf(` style="width: %s;"`, html.EscapeString(width))
+				}
+				if xhtml {
+					fmt.Fprintf(buf, "%s        <col%s/>\n", indentStr, colAttrs)
+				} else {
+					fmt.Fprintf(buf, "%s        <col%s>\n", indentStr, colAttrs)
+				}
+			}
+			fmt.Fprintf(buf, "%s    </colgroup>\n", indentStr)
+		}
+		
+		// Rows are grouped by role into thead, tbody and tfoot
+		section := ""
+		for _, child := range node.Children {
+			if child.Type != TableRow {
+				continue
+			}
+			rowRole := child.GetAttribute("role")
+			rowSection := "tbody"
+			if rowRole == "header" {
+				rowSection = "thead"
+			} else if rowRole == "footer" {
+				rowSection = "tfoot"
+			}
+			if rowSection != section {
+				if section != "" {
+					fmt.Fprintf(buf, "%s    </%s>\n", indentStr, section)
+				}
+				fmt.Fprintf(buf, "%s    <%s>\n", indentStr, rowSection)
+				section = rowSection
+			}
+			
+			var trAttrParts []string
+			if rowRole != "" && rowRole != "header" && rowRole != "footer" {
+				trAttrParts = append(trAttrParts, fmt.Sprintf(`data-role="%s"`, html.EscapeString(rowRole)))
+			}
+			otherAttrs := buildHTMLAttributes(child, []string{"role"}, opts)
+			if otherAttrs != "" {
+				trAttrParts = append(trAttrParts, strings.TrimSpace(otherAttrs))
+			}
+			trAttrs := buildAttrsString(trAttrParts...)
+			
+			if trAttrs != "" {
+				fmt.Fprintf(buf, "%s        <tr%s>\n", indentStr, trAttrs)
+			} else {
+				fmt.Fprintf(buf, "%s        <tr>\n", indentStr)
+			}
+			
+			for _, cell := range child.Children {
+				if cell.Type != TableCell {
+					continue
+				}
+				style := cell.GetAttribute("style")
+				cellTag := "td"
+				if rowRole == "header" || style == "header" {
+					cellTag = "th"
 				}
 				
-				var trAttrParts []string
-				if rowRole != "" && rowRole != "header" && rowRole != "footer" {
-					trAttrParts = append(trAttrParts, fmt.Sprintf(`data-role="%s"`, html.EscapeString(rowRole)))
+				var cellAttrParts []string
+				if align := cell.GetAttribute("align"); align != "" {
+					cellAttrParts = append(cellAttrParts, fmt.Sprintf(`data-align="%s"`, html.EscapeString(align)))
+				}
+				if valign := cell.GetAttribute("valign"); valign != "" {
+					cellAttrParts = append(cellAttrParts, fmt.Sprintf(`data-valign="%s"`, html.EscapeString(valign)))
+				}
+				if colspan := cell.GetAttribute("colspan"); colspan != "" {
+					cellAttrParts = append(cellAttrParts, fmt.Sprintf(`colspan="%s"`, html.EscapeString(colspan)))
+				}
+				if rowspan := cell.GetAttribute("rowspan"); rowspan != "" {
+					cellAttrParts = append(cellAttrParts, fmt.Sprintf(`rowspan="%s"`, html.EscapeString(rowspan)))
 				}
-				otherAttrs := buildHTMLAttributes(child, []string{"role"})
+				otherAttrs := buildHTMLAttributes(cell, []string{"align", "valign", "colspan", "rowspan"}, opts)
 				if otherAttrs != "" {
-					trAttrParts = append(trAttrParts, strings.TrimSpace(otherAttrs))
+					cellAttrParts = append(cellAttrParts, strings.TrimSpace(otherAttrs))
 				}
-				trAttrs := buildAttrsString(trAttrParts...)
+				cellAttrs := buildAttrsString(cellAttrParts...)
+				fmt.Fprintf(buf, "%s            <%s%s>", indentStr, cellTag, cellAttrs)
 				
-				if trAttrs != "" {
-					fmt.Fprintf(buf, "%s        <tr%s>\n", indentStr, trAttrs)
-				} else {
-					fmt.Fprintf(buf, "%s        <tr>\n", indentStr)
+				if hasBlockChildren(cell) {
+					// AsciiDoc, literal and multi-paragraph cells hold blocks
+					buf.WriteString("\n")
+					for _, block := range cell.Children {
+						toHTML(block, buf, xhtml, indent+4, opts)
+					}
+					fmt.Fprintf(buf, "%s            </%s>\n", indentStr, cellTag)
+					continue
 				}
 				
-				for _, cell := range child.Children {
-					if cell.Type == TableCell {
-						cellTag := "td"
-						if rowRole == "header" || (theadStarted && !tbodyStarted) {
-							cellTag = "th"
-						}
-						
-						var cellAttrParts []string
-						if align := cell.GetAttribute("align"); align != "" {
-							cellAttrParts = append(cellAttrParts, fmt.Sprintf(`data-align="%s"`, html.EscapeString(align)))
-						}
-						if colspan := cell.GetAttribute("colspan"); colspan != "" {
-							cellAttrParts = append(cellAttrParts, fmt.Sprintf(`colspan="%s"`, html.EscapeString(colspan)))
-						}
-						if rowspan := cell.GetAttribute("rowspan"); rowspan != "" {
-							cellAttrParts = append(cellAttrParts, fmt.Sprintf(`rowspan="%s"`, html.EscapeString(rowspan)))
-						}
-						otherAttrs := buildHTMLAttributes(cell, []string{"align", "colspan", "rowspan"})
-						if otherAttrs != "" {
-							cellAttrParts = append(cellAttrParts, strings.TrimSpace(otherAttrs))
-						}
-						cellAttrs := buildAttrsString(cellAttrParts...)
-						
-						if cellAttrs != "" {
-							fmt.Fprintf(buf, "%s            <%s%s>", indentStr, cellTag, cellAttrs)
-						} else {
-							fmt.Fprintf(buf, "%s            <%s>", indentStr, cellTag)
-						}
-						toHTMLInlineContent(cell, buf, xhtml)
-						fmt.Fprintf(buf, "</%s>\n", cellTag)
-					}
+				// Styled cells wrap their text in the matching inline element
+				wrap := map[string]string{"emphasis": "em", "strong": "strong", "monospace": "code"}[style]
+				if wrap != "" {
+					fmt.Fprintf(buf, "<%s>", wrap)
+				}
+				toHTMLInlineContent(cell, buf, xhtml, opts)
+				if wrap != "" {
+					fmt.Fprintf(buf, "</%s>", wrap)
 				}
-				fmt.Fprintf(buf, "%s        </tr>\n", indentStr)
+				fmt.Fprintf(buf, "</%s>\n", cellTag)
 			}
+			fmt.Fprintf(buf, "%s        </tr>\n", indentStr)
 		}
-		if theadStarted {
-			fmt.Fprintf(buf, "%s    </thead>\n", indentStr)
-		}
-		if tbodyStarted {
-			fmt.Fprintf(buf, "%s    </tbody>\n", indentStr)
-		}
-		if tfootStarted {
-			fmt.Fprintf(buf, "%s    </tfoot>\n", indentStr)
+		if section != "" {
+			fmt.Fprintf(buf, "%s    </%s>\n", indentStr, section)
 		}
 		fmt.Fprintf(buf, "%s</table>\n", indentStr)
 
@@ -700,7 +738,7 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		if id := node.GetAttribute("id"); id != "" {
 			attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 		}
-		otherAttrs := buildHTMLAttributes(node, []string{"id", "type"})
+		otherAttrs := buildHTMLAttributes(node, []string{"id", "type", "title"}, opts)
 		if otherAttrs != "" {
 			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 		}
@@ -709,9 +747,14 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		if admType != "" {
 			fmt.Fprintf(buf, "%s    <p data-role=\"admonition-title\">%s</p>\n", indentStr, html.EscapeString(strings.ToUpper(admType)))
 		}
+		// The content is any number of blocks: a paragraph for NOTE: text or
+		// [NOTE], or the whole content of a [NOTE] ==== block
+		fmt.Fprintf(buf, "%s    <div data-role=\"admonition-content\">\n", indentStr)
+		writeBlockTitle(buf, indentStr+"        ", node, "admonition-block")
 		for _, child := range node.Children {
-			toHTML(child, buf, xhtml, indent+1)
+			toHTML(child, buf, xhtml, indent+2, opts)
 		}
+		fmt.Fprintf(buf, "%s    </div>\n", indentStr)
 		fmt.Fprintf(buf, "%s</div>\n", indentStr)
 
 	case ThematicBreak:
@@ -727,18 +770,18 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		buf.WriteString(indentStr + `<div data-role="page-break"></div>` + "\n")
 
 	case Bold:
-		buf.WriteString("<strong>")
-		toHTMLInlineContent(node, buf, xhtml)
+		buf.WriteString("<strong" + htmlQuoteAttributes(node) + ">")
+		toHTMLInlineContent(node, buf, xhtml, opts)
 		buf.WriteString("</strong>")
 
 	case Italic:
-		buf.WriteString("<em>")
-		toHTMLInlineContent(node, buf, xhtml)
+		buf.WriteString("<em" + htmlQuoteAttributes(node) + ">")
+		toHTMLInlineContent(node, buf, xhtml, opts)
 		buf.WriteString("</em>")
 
 	case Monospace:
-		buf.WriteString("<code>")
-		toHTMLInlineContent(node, buf, xhtml)
+		buf.WriteString("<code" + htmlQuoteAttributes(node) + ">")
+		toHTMLInlineContent(node, buf, xhtml, opts)
 		buf.WriteString("</code>")
 
 	case Link:
@@ -758,10 +801,10 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 			attrs += fmt.Sprintf(` target="%s"`, html.EscapeString(window))
 		}
 		// Add other attributes as data-asciidoc-*
-		attrs += buildHTMLAttributes(node, []string{"href", "title", "window", "target"})
+		attrs += buildHTMLAttributes(node, []string{"href", "title", "window", "target"}, opts)
 		
 		fmt.Fprintf(buf, "<a %s>", attrs)
-		toHTMLInlineContent(node, buf, xhtml)
+		toHTMLInlineContent(node, buf, xhtml, opts)
 		buf.WriteString("</a>")
 
 	case Passthrough:
@@ -769,18 +812,18 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		buf.WriteString(node.Content)
 
 	case Superscript:
-		buf.WriteString(`<sup data-asciidoc="superscript">`)
-		toHTMLInlineContent(node, buf, xhtml)
+		buf.WriteString(`<sup data-asciidoc="superscript"` + htmlQuoteAttributes(node) + ">")
+		toHTMLInlineContent(node, buf, xhtml, opts)
 		buf.WriteString("</sup>")
 
 	case Subscript:
-		buf.WriteString(`<sub data-asciidoc="subscript">`)
-		toHTMLInlineContent(node, buf, xhtml)
+		buf.WriteString(`<sub data-asciidoc="subscript"` + htmlQuoteAttributes(node) + ">")
+		toHTMLInlineContent(node, buf, xhtml, opts)
 		buf.WriteString("</sub>")
 
 	case Highlight:
-		buf.WriteString(`<mark data-asciidoc="highlight">`)
-		toHTMLInlineContent(node, buf, xhtml)
+		buf.WriteString(`<mark data-asciidoc="highlight"` + htmlQuoteAttributes(node) + ">")
+		toHTMLInlineContent(node, buf, xhtml, opts)
 		buf.WriteString("</mark>")
 
 	case VerseBlock:
@@ -790,13 +833,14 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		if id := node.GetAttribute("id"); id != "" {
 			attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 		}
-		otherAttrs := buildHTMLAttributes(node, []string{"id"})
+		otherAttrs := buildHTMLAttributes(node, []string{"id", "title"}, opts)
 		if otherAttrs != "" {
 			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 		}
 		attrs := buildAttrsString(attrParts...)
 		
 		fmt.Fprintf(buf, "%s<div%s>\n", indentStr, attrs)
+		writeBlockTitle(buf, indentStr+"    ", node, "verse")
 		// Preserve line breaks - convert \n to <br> or wrap in <p>
 		content := getTextContent(node)
 		lines := strings.Split(content, "\n")
@@ -821,34 +865,227 @@ func toHTML(node *Node, buf *bytes.Buffer, xhtml bool, indent int) {
 		if id := node.GetAttribute("id"); id != "" {
 			attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
 		}
-		otherAttrs := buildHTMLAttributes(node, []string{"id"})
+		otherAttrs := buildHTMLAttributes(node, []string{"id", "title"}, opts)
 		if otherAttrs != "" {
 			attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
 		}
 		attrs := buildAttrsString(attrParts...)
 		
 		fmt.Fprintf(buf, "%s<div%s>\n", indentStr, attrs)
+		writeBlockTitle(buf, indentStr+"    ", node, "open-block")
 		for _, child := range node.Children {
-			toHTML(child, buf, xhtml, indent+1)
+			toHTML(child, buf, xhtml, indent+1, opts)
 		}
 		fmt.Fprintf(buf, "%s</div>\n", indentStr)
 
 	case PassthroughBlock:
+		// A stem block's math is delimited for MathJax or KaTeX, which
+		// read the text after the browser decodes it
+		if notation := node.GetAttribute("style"); notation != "" {
+			indentStr := strings.Repeat("    ", indent)
+			left, right := stemDelimiters(notation, true)
+			fmt.Fprintf(buf, "%s<div data-role=\"stem\" data-asciidoc-notation=\"%s\">%s%s%s</div>\n",
+				indentStr, html.EscapeString(notation), left, html.EscapeString(node.Content), right)
+			break
+		}
 		// Output raw HTML without escaping (disable-output-escaping equivalent)
 		buf.WriteString(node.Content)
 		buf.WriteString("\n")
 
+	case Comment:
+		indentStr := strings.Repeat("    ", indent)
+		fmt.Fprintf(buf, "%s<!-- %s -->\n", indentStr, htmlCommentText(node.Content))
+
+	case LineBreak:
+		if xhtml {
+			buf.WriteString("<br/>\n")
+		} else {
+			buf.WriteString("<br>\n")
+		}
+
+	case IndexTerm:
+		// The index links to the term's ID; a flow term also shows its text
+		id := html.EscapeString(node.GetAttribute("id"))
+		if len(node.Children) == 0 {
+			fmt.Fprintf(buf, `<a data-role="indexterm" id="%s"></a>`, id)
+		} else {
+			fmt.Fprintf(buf, `<span data-role="indexterm" id="%s">`, id)
+			toHTMLInlineContent(node, buf, xhtml, opts)
+			buf.WriteString("</span>")
+		}
+
+	case BibliographyAnchor:
+		fmt.Fprintf(buf, `<a data-role="bibliography-anchor" id="%s"></a>[%s]`,
+			html.EscapeString(node.GetAttribute("id")), html.EscapeString(node.GetAttribute("label")))
+
+	case Callout:
+		// A badge linking to the item of the callout list explaining it
+		number := html.EscapeString(node.GetAttribute("number"))
+		id := html.EscapeString(node.GetAttribute("id"))
+		if target := node.GetAttribute("target"); target != "" {
+			fmt.Fprintf(buf, `<a data-role="callout" id="%s" href="#%s">%s</a>`, id, html.EscapeString(target), number)
+		} else {
+			fmt.Fprintf(buf, `<span data-role="callout" id="%s">%s</span>`, id, number)
+		}
+
 	default:
 		// Unknown type, just output children
 		for _, child := range node.Children {
-			toHTML(child, buf, xhtml, indent)
+			toHTML(child, buf, xhtml, indent, opts)
 		}
 	}
 }
 
-// toHTMLInlineContent writes inline content (text and inline nodes)
-func toHTMLInlineContent(node *Node, buf *bytes.Buffer, xhtml bool) {
+// writeHTMLSectionHeading writes the heading of a section, with its label
+func writeHTMLSectionHeading(node *Node, buf *bytes.Buffer, indentStr string, opts RenderOptions) {
+	level := 1
+	if levelAttr := node.GetAttribute("level"); levelAttr != "" {
+		fmt.Sscanf(levelAttr, "%d", &level)
+	}
+	hLevel := level + 1
+	if hLevel > 6 {
+		hLevel = 6
+	}
+	tagName := fmt.Sprintf("h%d", hLevel)
+	
+	var attrParts []string
+	if id := node.GetAttribute("id"); id != "" {
+		attrParts = append(attrParts, fmt.Sprintf(`id="%s"`, html.EscapeString(id)))
+	}
+	// Handle appendix and discrete attributes
+	if appendix := node.GetAttribute("appendix"); appendix != "" {
+		attrParts = append(attrParts, fmt.Sprintf(`data-asciidoc-appendix="%s"`, html.EscapeString(appendix)))
+	}
+	if discrete := node.GetAttribute("discrete"); discrete != "" {
+		attrParts = append(attrParts, fmt.Sprintf(`data-asciidoc-discrete="%s"`, html.EscapeString(discrete)))
+	}
+	// Add other attributes (role for ARIA, others as data-asciidoc-*)
+	// Exclude id, level, appendix, discrete, title, marker, and the number shown in the title
+	otherAttrs := buildHTMLAttributes(node, []string{"id", "level", "appendix", "discrete", "title", "marker", "number", "caption"}, opts)
+	if otherAttrs != "" {
+		attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
+	}
+	attrs := buildAttrsString(attrParts...)
+	
+	// Section title (first text child or title attribute)
+	titleText := node.GetAttribute("title")
+	if titleText == "" && len(node.Children) > 0 && node.Children[0].Type == Text {
+		titleText = node.Children[0].Content
+	}
+	
+	if titleText != "" {
+		fmt.Fprintf(buf, "%s<%s%s>%s</%s>\n", indentStr, tagName, attrs, html.EscapeString(SectionLabel(node)+titleText), tagName)
+	}
+}
+
+// isPreamble reports whether node holds the content before the first
+// section, which is written without a paragraph of its own
+func isPreamble(node *Node) bool {
+	return node.Type == Paragraph && (node.GetAttribute("role") == "preamble" || node.GetAttribute("data-role") == "preamble")
+}
+
+// writeHTMLPreamble writes the preamble node as a div of its blocks
+func writeHTMLPreamble(node *Node, buf *bytes.Buffer, xhtml bool, indent int, opts RenderOptions) {
+	indentStr := strings.Repeat("    ", indent)
+	var attrParts []string
+	attrParts = append(attrParts, `data-role="preamble"`)
+	otherAttrs := buildHTMLAttributes(node, []string{"role", "id"}, opts)
+	if otherAttrs != "" {
+		attrParts = append(attrParts, strings.TrimSpace(otherAttrs))
+	}
+	attrs := buildAttrsString(attrParts...)
+	fmt.Fprintf(buf, "%s<div%s>\n", indentStr, attrs)
+	// Output preamble content (children of the paragraph)
+	for _, child := range node.Children {
+		toHTML(child, buf, xhtml, indent+1, opts)
+	}
+	fmt.Fprintf(buf, "%s</div>\n", indentStr)
+}
+
+// writeCodeLines writes the content of a code or literal block: its text
+// with callout badges and any inline markup its substitutions produced, each
+// line numbered when linenums is set, and the lines listed in highlight
+// marked
+func writeCodeLines(buf *bytes.Buffer, node *Node, xhtml bool, opts RenderOptions) {
+	linenums := node.GetAttribute("linenums") == "true"
+	highlighted := make(map[int]bool)
+	for _, n := range parseLineRanges(node.GetAttribute("highlight")) {
+		highlighted[n] = true
+	}
+	line := 1
+	if first, err := strconv.Atoi(node.GetAttribute("firstlinenum")); err == nil {
+		line = first
+	}
+	marked := false
+	startLine := func() {
+		if linenums {
+			fmt.Fprintf(buf, `<span data-role="line-number">%d</span> `, line)
+		}
+		if highlighted[line] {
+			buf.WriteString(`<mark data-role="highlighted-line">`)
+			marked = true
+		}
+	}
+	endLine := func() {
+		if marked {
+			buf.WriteString("</mark>")
+			marked = false
+		}
+		line++
+	}
+
+	startLine()
 	for _, child := range node.Children {
+		switch child.Type {
+		case Text, Passthrough:
+			for i, text := range strings.Split(child.Content, "\n") {
+				if i > 0 {
+					endLine()
+					buf.WriteString("\n")
+					startLine()
+				}
+				if child.Type == Text {
+					text = html.EscapeString(text)
+				}
+				buf.WriteString(text)
+			}
+		default:
+			toHTML(child, buf, xhtml, 0, opts)
+		}
+	}
+	endLine()
+}
+
+// writeBlockTitle writes the title of a block, with its caption, as a
+// paragraph with the data-role ROLE-title
+func writeBlockTitle(buf *bytes.Buffer, indentStr string, node *Node, role string) {
+	if node.GetAttribute("title") == "" {
+		return
+	}
+	fmt.Fprintf(buf, "%s<p data-role=\"%s-title\">%s</p>\n", indentStr, role, html.EscapeString(BlockTitle(node)))
+}
+
+// htmlQuoteAttributes returns the id and role given to quoted text by an
+// attribute list, as in [#term.keyword]*text*, or to an inline image or icon
+func htmlQuoteAttributes(node *Node) string {
+	var attrs string
+	if id := node.GetAttribute("id"); id != "" {
+		attrs += fmt.Sprintf(` id="%s"`, html.EscapeString(id))
+	}
+	if role := node.GetAttribute("role"); role != "" {
+		attrs += fmt.Sprintf(` role="%s"`, html.EscapeString(role))
+	}
+	return attrs
+}
+
+// toHTMLInlineContent writes inline content (text and inline nodes)
+func toHTMLInlineContent(node *Node, buf *bytes.Buffer, xhtml bool, opts RenderOptions) {
+	toHTMLInlineNodes(node.Children, buf, xhtml, opts)
+}
+
+// toHTMLInlineNodes writes a run of inline nodes
+func toHTMLInlineNodes(nodes []*Node, buf *bytes.Buffer, xhtml bool, opts RenderOptions) {
+	for _, child := range nodes {
 		if child.Type == Text {
 			buf.WriteString(html.EscapeString(child.Content))
 		} else if child.Type == InlineMacro {
@@ -861,13 +1098,34 @@ func toHTMLInlineContent(node *Node, buf *bytes.Buffer, xhtml bool) {
 				if id != "" {
 					fmt.Fprintf(buf, `<a id="%s"></a>`, html.EscapeString(id))
 				}
+			} else if child.Name == "xref" {
+				// resolveXrefs gives every cross reference an href
+				href := child.GetAttribute("href")
+				if href == "" {
+					href = "#" + child.GetAttribute("target")
+				}
+				attrs := buildHTMLAttributes(child, []string{"href", "target", "refid", "document"}, opts)
+				fmt.Fprintf(buf, `<a href="%s"%s>`, html.EscapeString(href), attrs)
+				toHTMLInlineContent(child, buf, xhtml, opts)
+				buf.WriteString("</a>")
+			} else if child.Name == "image" {
+				writeInlineImage(buf, child, xhtml)
+			} else if child.Name == "icon" {
+				writeInlineIcon(buf, child, xhtml)
+			} else if child.Name == "stem" {
+				// MathJax or KaTeX find the math by its delimiters and read
+				// the text after the browser decodes it
+				notation := child.GetAttribute("notation")
+				left, right := stemDelimiters(notation, false)
+				fmt.Fprintf(buf, `<span data-role="stem" data-asciidoc-notation="%s">%s%s%s</span>`,
+					html.EscapeString(notation), left, html.EscapeString(getTextContent(child)), right)
 			} else if child.Name == "kbd" {
 				buf.WriteString(`<kbd data-role="keyboard">`)
-				toHTMLInlineContent(child, buf, xhtml)
+				toHTMLInlineContent(child, buf, xhtml, opts)
 				buf.WriteString("</kbd>")
 			} else if child.Name == "btn" {
 				buf.WriteString(`<span data-role="button">`)
-				toHTMLInlineContent(child, buf, xhtml)
+				toHTMLInlineContent(child, buf, xhtml, opts)
 				buf.WriteString("</span>")
 			} else if child.Name == "menu" {
 				// Parse menu path from target attribute
@@ -892,7 +1150,7 @@ func toHTMLInlineContent(node *Node, buf *bytes.Buffer, xhtml bool) {
 						fmt.Fprintf(buf, `<span data-role="menu">%s</span>`, html.EscapeString(target))
 					}
 				} else {
-					toHTMLInlineContent(child, buf, xhtml)
+					toHTMLInlineContent(child, buf, xhtml, opts)
 				}
 				buf.WriteString("</span>")
 			} else if child.Name == "footnote" {
@@ -914,15 +1172,97 @@ func toHTMLInlineContent(node *Node, buf *bytes.Buffer, xhtml bool) {
 			} else {
 				// Generic inline macro
 				attrs := fmt.Sprintf(`data-role="macro" data-asciidoc-macro="%s"`, html.EscapeString(child.Name))
-				attrs += buildHTMLAttributes(child, []string{})
+				attrs += buildHTMLAttributes(child, []string{}, opts)
 				fmt.Fprintf(buf, `<span %s>`, attrs)
-				toHTMLInlineContent(child, buf, xhtml)
+				toHTMLInlineContent(child, buf, xhtml, opts)
 				buf.WriteString("</span>")
 			}
 		} else {
-			toHTML(child, buf, xhtml, 0)
+			toHTML(child, buf, xhtml, 0, opts)
+		}
+	}
+}
+
+// writeInlineImage writes an inline image, linked if it has a link
+func writeInlineImage(buf *bytes.Buffer, node *Node, xhtml bool) {
+	fmt.Fprintf(buf, `<span data-role="image"%s>`, htmlQuoteAttributes(node))
+	link := node.GetAttribute("link")
+	if link != "" {
+		fmt.Fprintf(buf, `<a href="%s">`, html.EscapeString(link))
+	}
+	fmt.Fprintf(buf, `<img src="%s" alt="%s"`, html.EscapeString(node.GetAttribute("src")), html.EscapeString(node.GetAttribute("alt")))
+	for _, k := range []string{"width", "height", "title"} {
+		if v := node.GetAttribute(k); v != "" {
+			fmt.Fprintf(buf, ` %s="%s"`, k, html.EscapeString(v))
+		}
+	}
+	if xhtml {
+		buf.WriteString("/>")
+	} else {
+		buf.WriteString(">")
+	}
+	if link != "" {
+		buf.WriteString("</a>")
+	}
+	buf.WriteString("</span>")
+}
+
+// writeInlineIcon writes an icon the way its mode says: as an icon font
+// glyph, an image, or its name in brackets
+func writeInlineIcon(buf *bytes.Buffer, node *Node, xhtml bool) {
+	name := node.GetAttribute("target")
+	size := node.GetAttribute("size")
+	fmt.Fprintf(buf, `<span data-role="icon"%s>`, htmlQuoteAttributes(node))
+	link := node.GetAttribute("link")
+	if link != "" {
+		fmt.Fprintf(buf, `<a href="%s">`, html.EscapeString(link))
+	}
+	title := ""
+	if t := node.GetAttribute("title"); t != "" {
+		title = fmt.Sprintf(` title="%s"`, html.EscapeString(t))
+	}
+	switch node.GetAttribute("mode") {
+	case "font":
+		class := "fa fa-" + name
+		if size != "" {
+			class += " fa-" + size
+		}
+		fmt.Fprintf(buf, `<i class="%s"%s></i>`, html.EscapeString(class), title)
+	case "image":
+		fmt.Fprintf(buf, `<img src="%s" alt="%s"%s`, html.EscapeString(node.GetAttribute("src")), html.EscapeString(name), title)
+		if xhtml {
+			buf.WriteString("/>")
+		} else {
+			buf.WriteString(">")
 		}
+	default:
+		fmt.Fprintf(buf, `[%s]`, html.EscapeString(name))
 	}
+	if link != "" {
+		buf.WriteString("</a>")
+	}
+	buf.WriteString("</span>")
+}
+
+// splitListItemChildren separates a list item's leading inline content from
+// the blocks and nested lists attached to it
+func splitListItemChildren(item *Node) (inline, blocks []*Node) {
+	for i, child := range item.Children {
+		if !child.Type.IsInline() {
+			return item.Children[:i], item.Children[i:]
+		}
+	}
+	return item.Children, nil
+}
+
+// hasOption reports whether name is in node's comma-separated options attribute
+func hasOption(node *Node, name string) bool {
+	for _, opt := range strings.Split(node.GetAttribute("options"), ",") {
+		if strings.TrimSpace(opt) == name {
+			return true
+		}
+	}
+	return false
 }
 
 // isStandardHTMLAttribute checks if an attribute name is a standard HTML5 attribute
@@ -939,13 +1279,19 @@ func isStandardHTMLAttribute(name string) bool {
 // buildHTMLAttributes builds HTML attributes string from node attributes
 // Maps non-standard attributes to data-asciidoc-* format
 // role is preserved for ARIA semantics
-func buildHTMLAttributes(node *Node, excludeAttrs []string) string {
+func buildHTMLAttributes(node *Node, excludeAttrs []string, opts RenderOptions) string {
 	excludeMap := make(map[string]bool)
 	for _, attr := range excludeAttrs {
 		excludeMap[attr] = true
 	}
 	
 	var attrs []string
+	if opts.SourcePositions && node.Start.IsValid() {
+		if node.Start.File != "" {
+			attrs = append(attrs, fmt.Sprintf(`data-source-file="%s"`, html.EscapeString(node.Start.File)))
+		}
+		attrs = append(attrs, fmt.Sprintf(`data-source-line="%d"`, node.Start.Line))
+	}
 	for k, v := range node.Attributes {
 		if excludeMap[k] {
 			continue
@@ -1006,68 +1352,51 @@ func ConvertToXML(reader io.Reader) (string, error) {
 
 // ToXML converts an AST node to XML string
 func ToXML(node *Node) string {
+	return ToXMLWithOptions(node, RenderOptions{})
+}
+
+// ToXMLWithOptions converts an AST node to XML string using opts
+func ToXMLWithOptions(node *Node, opts RenderOptions) string {
 	var buf bytes.Buffer
-	toXML(node, &buf, 0)
+	toXML(node, &buf, 0, opts)
 	return buf.String()
 }
 
 // toXML is the internal recursive function for XML conversion
-func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
+func toXML(node *Node, buf *bytes.Buffer, indentLevel int, opts RenderOptions) {
 	indent := strings.Repeat("  ", indentLevel)
 
 	switch node.Type {
 	case Document:
-		buf.WriteString(`<document xmlns="https://github.com/ndx-video/asciidoc-xml"`)
-		for k, v := range node.Attributes {
-			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
-		}
-		if len(node.Children) == 0 {
+		writeXMLStartTag(buf, "", "document", node, opts)
+		var header bytes.Buffer
+		writeXMLHeader(&header, node, indent+"  ")
+		if len(node.Children) == 0 && header.Len() == 0 {
 			buf.WriteString("/>")
 		} else {
 			buf.WriteString(">\n")
+			buf.Write(header.Bytes())
 			
-			// Check if first child is a preamble (Paragraph with role="preamble")
-			hasPreamble := false
-			if len(node.Children) > 0 {
-				firstChild := node.Children[0]
-				if firstChild.Type == Paragraph && firstChild.GetAttribute("role") == "preamble" {
-					hasPreamble = true
-					// Output preamble wrapper
-					buf.WriteString(indent + "  <preamble")
-					if firstChild.GetAttribute("role") != "" {
-						buf.WriteString(` role="` + escapeXML(firstChild.GetAttribute("role")) + `"`)
-					}
-					buf.WriteString(">\n")
-					// Output preamble content (children of the paragraph)
-					for _, grandchild := range firstChild.Children {
-						toXML(grandchild, buf, indentLevel+2)
-					}
-					buf.WriteString(indent + "  </preamble>\n")
+			// The preamble (Paragraph with role="preamble") follows a table
+			// of contents placed at the top
+			for _, child := range node.Children {
+				if isPreamble(child) {
+					writeXMLPreamble(child, buf, indentLevel+1, opts)
+				} else {
+					toXML(child, buf, indentLevel+1, opts)
 				}
 			}
-			
-			// Output remaining children (skip first if it was preamble)
-			startIdx := 0
-			if hasPreamble {
-				startIdx = 1
-			}
-			for i := startIdx; i < len(node.Children); i++ {
-				toXML(node.Children[i], buf, indentLevel+1)
-			}
 			buf.WriteString(indent + "</document>\n")
 		}
 
 	case Section:
-		buf.WriteString(indent + "<section")
-		for k, v := range node.Attributes {
-			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
-		}
+		writeXMLStartTag(buf, indent, "section", node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</section>\n")
 		}
@@ -1077,11 +1406,12 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">")
-			toXMLInlineContent(node, buf)
+			toXMLInlineContent(node, buf, opts)
 			buf.WriteString("</paragraph>\n")
 		}
 
@@ -1090,12 +1420,13 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</macro>\n")
 		}
@@ -1106,7 +1437,9 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 			// Output as dedicated <anchor> element
 			id := node.GetAttribute("id")
 			if id != "" {
-				buf.WriteString(`<anchor id="` + escapeXML(id) + `"/>`)
+				buf.WriteString(`<anchor id="` + escapeXML(id) + `"`)
+				writeXMLSourcePosition(buf, node, opts)
+				buf.WriteString("/>")
 			} else {
 				// Fallback to macro if no id
 				buf.WriteString(`<macro type="inline" name="anchor"`)
@@ -1115,6 +1448,11 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 				}
 				buf.WriteString("/>")
 			}
+		} else if node.Name == "stem" {
+			// Output as dedicated <stem> element holding the math as written
+			buf.WriteString(`<stem notation="` + escapeXML(node.GetAttribute("notation")) + `"`)
+			writeXMLSourcePosition(buf, node, opts)
+			buf.WriteString(">" + escapeXML(getTextContent(node)) + "</stem>")
 		} else if node.Name == "footnote" {
 			// Output as dedicated <footnote> element
 			buf.WriteString("<footnote")
@@ -1122,8 +1460,9 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 			if ref != "" {
 				buf.WriteString(` ref="` + escapeXML(ref) + `"`)
 			}
+			writeXMLSourcePosition(buf, node, opts)
 			buf.WriteString(">")
-			toXMLInlineContent(node, buf)
+			toXMLInlineContent(node, buf, opts)
 			buf.WriteString("</footnote>")
 		} else {
 			// Generic inline macro
@@ -1131,11 +1470,12 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 			for k, v := range node.Attributes {
 				buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 			}
+			writeXMLSourcePosition(buf, node, opts)
 			if len(node.Children) == 0 {
 				buf.WriteString("/>")
 			} else {
 				buf.WriteString(">")
-				toXMLInlineContent(node, buf)
+				toXMLInlineContent(node, buf, opts)
 				buf.WriteString("</macro>")
 			}
 		}
@@ -1148,12 +1488,13 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</list>\n")
 		}
@@ -1163,11 +1504,21 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">")
-			toXMLInlineContent(node, buf)
+			// The item's text comes first; attached blocks and nested lists follow it
+			inline, blocks := splitListItemChildren(node)
+			toXMLInlineNodes(inline, buf, opts)
+			if len(blocks) > 0 {
+				buf.WriteString("\n")
+				for _, block := range blocks {
+					toXML(block, buf, indentLevel+1, opts)
+				}
+				buf.WriteString(indent)
+			}
 			buf.WriteString("</listitem>\n")
 		}
 
@@ -1176,11 +1527,12 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">")
-			toXMLInlineContent(node, buf)
+			toXMLInlineContent(node, buf, opts)
 			buf.WriteString("</codeblock>\n")
 		}
 
@@ -1189,11 +1541,12 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">")
-			toXMLInlineContent(node, buf)
+			toXMLInlineContent(node, buf, opts)
 			buf.WriteString("</literalblock>\n")
 		}
 
@@ -1202,12 +1555,13 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</example>\n")
 		}
@@ -1217,12 +1571,13 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</sidebar>\n")
 		}
@@ -1232,12 +1587,13 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</quote>\n")
 		}
@@ -1247,12 +1603,29 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
+			// Columns are grouped ahead of the rows
+			var columns, rows []*Node
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				if child.Type == TableColumn {
+					columns = append(columns, child)
+				} else {
+					rows = append(rows, child)
+				}
+			}
+			if len(columns) > 0 {
+				buf.WriteString(indent + "  <colgroup>\n")
+				for _, col := range columns {
+					toXML(col, buf, indentLevel+2, opts)
+				}
+				buf.WriteString(indent + "  </colgroup>\n")
+			}
+			for _, child := range rows {
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</table>\n")
 		}
@@ -1262,26 +1635,41 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</row>\n")
 		}
 
+	case TableColumn:
+		buf.WriteString(indent + "<col")
+		for k, v := range node.Attributes {
+			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
+		}
+		buf.WriteString("/>\n")
+
 	case TableCell:
 		buf.WriteString(indent + "<cell")
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
+		} else if hasBlockChildren(node) {
+			buf.WriteString(">\n")
+			for _, child := range node.Children {
+				toXML(child, buf, indentLevel+1, opts)
+			}
+			buf.WriteString(indent + "</cell>\n")
 		} else {
 			buf.WriteString(">")
-			toXMLInlineContent(node, buf)
+			toXMLInlineContent(node, buf, opts)
 			buf.WriteString("</cell>\n")
 		}
 
@@ -1290,33 +1678,39 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</admonition>\n")
 		}
 
 	case ThematicBreak:
-		buf.WriteString(indent + "<thematicbreak/>\n")
+		buf.WriteString(indent + "<thematicbreak")
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString("/>\n")
 
 	case PageBreak:
-		buf.WriteString(indent + "<pagebreak/>\n")
+		buf.WriteString(indent + "<pagebreak")
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString("/>\n")
 
 	case VerseBlock:
 		buf.WriteString(indent + "<verseblock")
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 			}
 			buf.WriteString(indent + "</verseblock>\n")
 		}
@@ -1326,12 +1720,13 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>\n")
 		} else {
 			buf.WriteString(">\n")
 			for _, child := range node.Children {
-				toXML(child, buf, indentLevel+1)
+				toXML(child, buf, indentLevel+1, opts)
 		}
 		buf.WriteString(indent + "</openblock>\n")
 	}
@@ -1341,23 +1736,43 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		buf.WriteString(">")
 		buf.WriteString(escapeXML(node.Content))
 		buf.WriteString("</passthrough>\n")
 
+	case Comment:
+		buf.WriteString(indent + "<comment")
+		for k, v := range node.Attributes {
+			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
+		}
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString(">")
+		buf.WriteString(escapeXML(node.Content))
+		buf.WriteString("</comment>\n")
+
 	case Bold:
-		buf.WriteString("<strong>")
-		toXMLInlineContent(node, buf)
+		buf.WriteString("<strong")
+		writeXMLQuoteAttributes(buf, node)
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString(">")
+		toXMLInlineContent(node, buf, opts)
 		buf.WriteString("</strong>")
 
 	case Italic:
-		buf.WriteString("<emphasis>")
-		toXMLInlineContent(node, buf)
+		buf.WriteString("<emphasis")
+		writeXMLQuoteAttributes(buf, node)
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString(">")
+		toXMLInlineContent(node, buf, opts)
 		buf.WriteString("</emphasis>")
 
 	case Monospace:
-		buf.WriteString("<monospace>")
-		toXMLInlineContent(node, buf)
+		buf.WriteString("<monospace")
+		writeXMLQuoteAttributes(buf, node)
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString(">")
+		toXMLInlineContent(node, buf, opts)
 		buf.WriteString("</monospace>")
 
 	case Link:
@@ -1365,54 +1780,212 @@ func toXML(node *Node, buf *bytes.Buffer, indentLevel int) {
 		for k, v := range node.Attributes {
 			buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
 		}
+		writeXMLSourcePosition(buf, node, opts)
 		if len(node.Children) == 0 {
 			buf.WriteString("/>")
 		} else {
 			buf.WriteString(">")
-			toXMLInlineContent(node, buf)
+			toXMLInlineContent(node, buf, opts)
 			buf.WriteString("</link>")
 		}
 
 	case Passthrough:
 		// Wrap content in CDATA for XML
-		buf.WriteString("<passthrough><![CDATA[")
+		buf.WriteString("<passthrough")
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString("><![CDATA[")
 		buf.WriteString(node.Content)
 		buf.WriteString("]]></passthrough>")
 
 	case Superscript:
-		buf.WriteString("<superscript>")
-		toXMLInlineContent(node, buf)
+		buf.WriteString("<superscript")
+		writeXMLQuoteAttributes(buf, node)
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString(">")
+		toXMLInlineContent(node, buf, opts)
 		buf.WriteString("</superscript>")
 
 	case Subscript:
-		buf.WriteString("<subscript>")
-		toXMLInlineContent(node, buf)
+		buf.WriteString("<subscript")
+		writeXMLQuoteAttributes(buf, node)
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString(">")
+		toXMLInlineContent(node, buf, opts)
 		buf.WriteString("</subscript>")
 
 	case Highlight:
-		buf.WriteString("<highlight>")
-		toXMLInlineContent(node, buf)
+		buf.WriteString("<highlight")
+		writeXMLQuoteAttributes(buf, node)
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString(">")
+		toXMLInlineContent(node, buf, opts)
 		buf.WriteString("</highlight>")
 
+	case LineBreak:
+		buf.WriteString("<linebreak")
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString("/>")
+
+	case IndexTerm:
+		buf.WriteString("<indexterm")
+		for _, k := range []string{"id", "primary", "secondary", "tertiary"} {
+			if v := node.GetAttribute(k); v != "" {
+				buf.WriteString(fmt.Sprintf(` %s="%s"`, k, escapeXML(v)))
+			}
+		}
+		writeXMLSourcePosition(buf, node, opts)
+		if len(node.Children) == 0 {
+			buf.WriteString("/>")
+		} else {
+			buf.WriteString(">")
+			toXMLInlineContent(node, buf, opts)
+			buf.WriteString("</indexterm>")
+		}
+
+	case BibliographyAnchor:
+		buf.WriteString(fmt.Sprintf(`<bibliographyanchor id="%s" label="%s"`, escapeXML(node.GetAttribute("id")), escapeXML(node.GetAttribute("label"))))
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString("/>")
+
+	case Callout:
+		buf.WriteString("<callout")
+		for _, k := range []string{"number", "id", "target"} {
+			if v := node.GetAttribute(k); v != "" {
+				buf.WriteString(fmt.Sprintf(` %s="%s"`, k, escapeXML(v)))
+			}
+		}
+		writeXMLSourcePosition(buf, node, opts)
+		buf.WriteString("/>")
+
 	default:
 		// Unknown type
 		for _, child := range node.Children {
-			toXML(child, buf, indentLevel)
+			toXML(child, buf, indentLevel, opts)
 		}
 	}
 }
 
-// toXMLInlineContent writes inline content for XML
-func toXMLInlineContent(node *Node, buf *bytes.Buffer) {
+// writeXMLStartTag writes the start of the tag of an element named name for
+// node: its name, the node's attributes and its source position, leaving
+// the tag open. The document element also gets the namespace.
+func writeXMLStartTag(buf *bytes.Buffer, indent, name string, node *Node, opts RenderOptions) {
+	buf.WriteString(indent + "<" + name)
+	if node.Type == Document {
+		buf.WriteString(` xmlns="https://github.com/ndx-video/asciidoc-xml"`)
+	}
+	for k, v := range node.Attributes {
+		buf.WriteString(fmt.Sprintf(` %s="%s"`, sanitizeXMLAttributeName(k), escapeXML(v)))
+	}
+	writeXMLSourcePosition(buf, node, opts)
+}
+
+// writeXMLPreamble writes the preamble node as a preamble element holding
+// its blocks
+func writeXMLPreamble(node *Node, buf *bytes.Buffer, indentLevel int, opts RenderOptions) {
+	indent := strings.Repeat("  ", indentLevel)
+	buf.WriteString(indent + "<preamble")
+	if node.GetAttribute("role") != "" {
+		buf.WriteString(` role="` + escapeXML(node.GetAttribute("role")) + `"`)
+	}
+	buf.WriteString(">\n")
+	// Output preamble content (children of the paragraph)
 	for _, child := range node.Children {
+		toXML(child, buf, indentLevel+1, opts)
+	}
+	buf.WriteString(indent + "</preamble>\n")
+}
+
+// writeXMLQuoteAttributes writes the id and role given to quoted text by an
+// attribute list
+func writeXMLQuoteAttributes(buf *bytes.Buffer, node *Node) {
+	if id := node.GetAttribute("id"); id != "" {
+		buf.WriteString(` id="` + escapeXML(id) + `"`)
+	}
+	if role := node.GetAttribute("role"); role != "" {
+		buf.WriteString(` role="` + escapeXML(role) + `"`)
+	}
+}
+
+// writeXMLSourcePosition writes the source-* attributes of node when enabled
+func writeXMLSourcePosition(buf *bytes.Buffer, node *Node, opts RenderOptions) {
+	if !opts.SourcePositions || !node.Start.IsValid() {
+		return
+	}
+	if node.Start.File != "" {
+		fmt.Fprintf(buf, ` source-file="%s"`, escapeXML(node.Start.File))
+	}
+	fmt.Fprintf(buf, ` source-line="%d" source-column="%d"`, node.Start.Line, node.Start.Column)
+	if node.End.IsValid() {
+		fmt.Fprintf(buf, ` source-end-line="%d" source-end-column="%d"`, node.End.Line, node.End.Column)
+	}
+}
+
+// hasBlockChildren reports whether node holds blocks rather than inline
+// content, as AsciiDoc and literal table cells do
+func hasBlockChildren(node *Node) bool {
+	for _, child := range node.Children {
+		switch child.Type {
+		case Text, Bold, Italic, Monospace, Link, Passthrough, Superscript, Subscript, Highlight, InlineMacro:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// toXMLInlineContent writes inline content for XML
+func toXMLInlineContent(node *Node, buf *bytes.Buffer, opts RenderOptions) {
+	toXMLInlineNodes(node.Children, buf, opts)
+}
+
+// toXMLInlineNodes writes a run of inline nodes for XML
+func toXMLInlineNodes(nodes []*Node, buf *bytes.Buffer, opts RenderOptions) {
+	for _, child := range nodes {
 		if child.Type == Text {
 			buf.WriteString(escapeXML(child.Content))
 		} else {
-			toXML(child, buf, 0)
+			toXML(child, buf, 0, opts)
 		}
 	}
 }
 
+// writeXMLHeader writes the <header> of doc, with its title, authors and
+// revision, if it has any of them
+func writeXMLHeader(buf *bytes.Buffer, doc *Node, indent string) {
+	title := doc.GetAttribute("title")
+	authors := DocumentAuthors(doc)
+	rev := DocumentRevision(doc)
+	if title == "" && len(authors) == 0 && rev == (Revision{}) {
+		return
+	}
+
+	element := func(indent, name, value string) {
+		if value != "" {
+			fmt.Fprintf(buf, "%s<%s>%s</%s>\n", indent, name, escapeXML(value), name)
+		}
+	}
+	buf.WriteString(indent + "<header>\n")
+	element(indent+"  ", "title", title)
+	for _, author := range authors {
+		buf.WriteString(indent + "  <author>\n")
+		element(indent+"    ", "name", author.Name)
+		element(indent+"    ", "firstname", author.FirstName)
+		element(indent+"    ", "middlename", author.MiddleName)
+		element(indent+"    ", "lastname", author.LastName)
+		element(indent+"    ", "initials", author.Initials)
+		element(indent+"    ", "email", author.Email)
+		buf.WriteString(indent + "  </author>\n")
+	}
+	if rev != (Revision{}) {
+		buf.WriteString(indent + "  <revision>\n")
+		element(indent+"    ", "number", rev.Number)
+		element(indent+"    ", "date", rev.Date)
+		element(indent+"    ", "remark", rev.Remark)
+		buf.WriteString(indent + "  </revision>\n")
+	}
+	buf.WriteString(indent + "</header>\n")
+}
+
 // escapeXML escapes XML special characters
 func escapeXML(s string) string {
 	var result strings.Builder
@@ -1435,6 +2008,15 @@ func escapeXML(s string) string {
 	return result.String()
 }
 
+// htmlCommentText makes s safe to put in an HTML comment, which can't
+// contain "--" in XHTML
+func htmlCommentText(s string) string {
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "- -")
+	}
+	return s
+}
+
 // sanitizeXMLAttributeName converts an attribute name to be XML-compliant
 // XML attribute names must:
 // - Start with a letter or underscore
@@ -1485,6 +2067,14 @@ func sanitizeXMLAttributeName(name string) string {
 	return result.String()
 }
 
+// authorHref returns the link for an author's email, which may also be a URL
+func authorHref(email string) string {
+	if strings.Contains(email, "://") {
+		return email
+	}
+	return "mailto:" + email
+}
+
 // extractMetadata extracts metadata from a parsed document
 func extractMetadata(doc *Node) Metadata {
 	meta := Metadata{
@@ -1495,6 +2085,8 @@ func extractMetadata(doc *Node) Metadata {
 		// Extract title from document attributes
 		meta.Title = doc.GetAttribute("title")
 		meta.Author = doc.GetAttribute("author")
+		meta.Authors = DocumentAuthors(doc)
+		meta.Revision = DocumentRevision(doc)
 
 		// Extract all attributes (including custom ones with : prefix)
 		for k, v := range doc.Attributes {
@@ -1521,11 +2113,47 @@ func extractMetadata(doc *Node) Metadata {
 //	fmt.Println(result.HTML)
 //	fmt.Println(result.Meta.Title)
 func Convert(reader io.Reader, opts ConvertOptions) (Result, error) {
-	doc, err := ParseDocument(reader)
+	return ConvertContext(context.Background(), reader, opts)
+}
+
+// ConvertContext is like Convert, but parses with ParseWithOptions under
+// ctx. It fails with a *LimitError if the content goes past the limits in
+// opts.ParseOptions or ctx is done before it is parsed.
+func ConvertContext(ctx context.Context, reader io.Reader, opts ConvertOptions) (Result, error) {
+	doc, err := ParseWithOptions(ctx, reader, opts.ParseOptions)
 	if err != nil {
 		return Result{}, err
 	}
 
+	meta := documentMetadata(doc, opts)
+	var buf bytes.Buffer
+	if opts.Standalone {
+		writeHTMLPageStart(&buf, doc, meta, opts)
+	}
+
+	// Write content, with void elements closed for XHTML
+	var content bytes.Buffer
+	toHTML(doc, &content, opts.XHTML, 0, RenderOptions{SourcePositions: opts.SourcePositions})
+	htmlContent := content.String()
+	if opts.Standalone {
+		// Indent the content
+		w := &indentWriter{w: &buf, indent: "      "}
+		io.WriteString(w, htmlContent)
+		buf.WriteString("\n")
+		writeHTMLPageEnd(&buf)
+	} else {
+		buf.WriteString(htmlContent)
+	}
+
+	return Result{
+		HTML: buf.String(),
+		Meta: meta,
+	}, nil
+}
+
+// documentMetadata returns the metadata of doc, with the title and author
+// set in opts in place of the document's
+func documentMetadata(doc *Node, opts ConvertOptions) Metadata {
 	// Extract metadata first
 	meta := extractMetadata(doc)
 	
@@ -1535,126 +2163,147 @@ func Convert(reader io.Reader, opts ConvertOptions) (Result, error) {
 	}
 	if opts.Author != "" {
 		meta.Author = opts.Author
+		meta.Authors = []Author{{Name: opts.Author}}
 	}
 
-	var buf bytes.Buffer
+	return meta
+}
 
-	// If standalone, write full HTML document structure
-	if opts.Standalone {
-		if opts.XHTML {
-			buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
-			buf.WriteString(`<!DOCTYPE html>` + "\n")
-		} else {
-			buf.WriteString(`<!DOCTYPE html>` + "\n")
-		}
+// writeHTMLPageStart writes the start of a standalone page up to its
+// content: the head, the document header and the opening of main
+func writeHTMLPageStart(buf *bytes.Buffer, doc *Node, meta Metadata, opts ConvertOptions) {
+	if opts.XHTML {
+		buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+		buf.WriteString(`<!DOCTYPE html>` + "\n")
+	} else {
+		buf.WriteString(`<!DOCTYPE html>` + "\n")
+	}
 
-		// Get lang from document attributes
-		lang := doc.GetAttribute(":lang")
-		if lang == "" {
-			lang = "en"
-		}
+	// Get lang from document attributes
+	lang := doc.GetAttribute(":lang")
+	if lang == "" {
+		lang = "en"
+	}
 
-		if opts.XHTML {
-			fmt.Fprintf(&buf, `<html xmlns="http://www.w3.org/1999/xhtml" lang="%s">`+"\n", html.EscapeString(lang))
-		} else {
-			fmt.Fprintf(&buf, `<html lang="%s">`+"\n", html.EscapeString(lang))
-		}
+	if opts.XHTML {
+		fmt.Fprintf(buf, `<html xmlns="http://www.w3.org/1999/xhtml" lang="%s">`+"\n", html.EscapeString(lang))
+	} else {
+		fmt.Fprintf(buf, `<html lang="%s">`+"\n", html.EscapeString(lang))
+	}
 
-		// Head section
-		buf.WriteString("  <head>\n")
-		if opts.XHTML {
-			buf.WriteString("    <meta charset=\"UTF-8\"/>\n")
-		} else {
-			buf.WriteString("    <meta charset=\"UTF-8\">\n")
-		}
-
-		// Add PicoCSS if enabled
-		if opts.UsePicoCSS {
-			if opts.PicoCSSContent != "" {
-				// Embed CSS inline
-				buf.WriteString("    <style>\n")
-				buf.WriteString(opts.PicoCSSContent)
-				buf.WriteString("\n    </style>\n")
-			} else if opts.PicoCSSPath != "" {
-				// Use link tag
-				if opts.XHTML {
-					fmt.Fprintf(&buf, `    <link rel="stylesheet" href="%s"/>`+"\n", html.EscapeString(opts.PicoCSSPath))
-				} else {
-					fmt.Fprintf(&buf, `    <link rel="stylesheet" href="%s">`+"\n", html.EscapeString(opts.PicoCSSPath))
-				}
-			}
-		}
+	// Head section
+	buf.WriteString("  <head>\n")
+	if opts.XHTML {
+		buf.WriteString("    <meta charset=\"UTF-8\"/>\n")
+	} else {
+		buf.WriteString("    <meta charset=\"UTF-8\">\n")
+	}
 
-		// Title in head (use override if provided)
-		title := meta.Title
-		if opts.Title != "" {
-			title = opts.Title
-		}
-		if title != "" {
-			fmt.Fprintf(&buf, "    <title>%s</title>\n", html.EscapeString(title))
+	// Add PicoCSS if enabled
+	if opts.UsePicoCSS {
+		if opts.PicoCSSContent != "" {
+			// Embed CSS inline
+			buf.WriteString("    <style>\n")
+			buf.WriteString(opts.PicoCSSContent)
+			buf.WriteString("\n    </style>\n")
+		} else if opts.PicoCSSPath != "" {
+			// Use link tag
+			if opts.XHTML {
+				fmt.Fprintf(buf, `    <link rel="stylesheet" href="%s"/>`+"\n", html.EscapeString(opts.PicoCSSPath))
+			} else {
+				fmt.Fprintf(buf, `    <link rel="stylesheet" href="%s">`+"\n", html.EscapeString(opts.PicoCSSPath))
+			}
 		}
-		buf.WriteString("  </head>\n")
+	}
 
-		// Body section
-		buf.WriteString("  <body>\n")
+	// Title in head (use override if provided)
+	title := meta.Title
+	if opts.Title != "" {
+		title = opts.Title
+	}
+	if title != "" {
+		fmt.Fprintf(buf, "    <title>%s</title>\n", html.EscapeString(title))
 	}
+	buf.WriteString("  </head>\n")
 
-	if opts.Standalone {
-		// Write document header in standalone mode
-		title := meta.Title
-		if opts.Title != "" {
-			title = opts.Title
-		}
-		author := meta.Author
-		if opts.Author != "" {
-			author = opts.Author
+	// Body section
+	buf.WriteString("  <body>\n")
+
+	// Document header
+	rev := meta.Revision
+	hasRevision := rev.Number != "" || rev.Date != "" || rev.Remark != ""
+	
+	if title != "" || len(meta.Authors) > 0 || hasRevision {
+		buf.WriteString("    <header>\n")
+		if title != "" {
+			fmt.Fprintf(buf, "      <h1>%s</h1>\n", html.EscapeString(title))
+		}
+		if len(meta.Authors) > 0 {
+			buf.WriteString("      <address class=\"authors\">\n")
+			for _, author := range meta.Authors {
+				fmt.Fprintf(buf, "        <p><span class=\"author-name\">%s</span>", html.EscapeString(author.Name))
+				if author.Email != "" {
+					fmt.Fprintf(buf, ` <a href="%s" class="author-email">%s</a>`, html.EscapeString(authorHref(author.Email)), html.EscapeString(author.Email))
+				}
+				buf.WriteString("</p>\n")
+			}
+			buf.WriteString("      </address>\n")
 		}
-		
-		if title != "" || author != "" {
-			buf.WriteString("    <header>\n")
-			if title != "" {
-				fmt.Fprintf(&buf, "      <h1>%s</h1>\n", html.EscapeString(title))
+		if hasRevision {
+			buf.WriteString("      <p class=\"revision\">")
+			if rev.Number != "" {
+				fmt.Fprintf(buf, `<span class="revision-number">Version %s</span>`, html.EscapeString(rev.Number))
 			}
-			if author != "" {
-				buf.WriteString("      <address class=\"authors\">\n")
-				fmt.Fprintf(&buf, "        <p><span class=\"author-name\">%s</span>", html.EscapeString(author))
-				if email := doc.GetAttribute("email"); email != "" {
-					fmt.Fprintf(&buf, ` <a href="mailto:%s" class="author-email">%s</a>`, html.EscapeString(email), html.EscapeString(email))
+			if rev.Date != "" {
+				if rev.Number != "" {
+					buf.WriteString(", ")
 				}
-				buf.WriteString("</p>\n")
-				buf.WriteString("      </address>\n")
+				fmt.Fprintf(buf, `<time class="revision-date">%s</time>`, html.EscapeString(rev.Date))
+			}
+			if rev.Remark != "" {
+				if rev.Number != "" || rev.Date != "" {
+					buf.WriteString(": ")
+				}
+				fmt.Fprintf(buf, `<span class="revision-remark">%s</span>`, html.EscapeString(rev.Remark))
 			}
-			buf.WriteString("    </header>\n")
+			buf.WriteString("</p>\n")
 		}
-		buf.WriteString("    <main>\n")
+		buf.WriteString("    </header>\n")
 	}
+	buf.WriteString("    <main>\n")
+}
 
-	// Write content
-	htmlContent := ToHTML(doc)
-	if opts.Standalone {
-		// Indent the content
-		lines := strings.Split(htmlContent, "\n")
-		for _, line := range lines {
-			if line != "" {
-				buf.WriteString("      " + line + "\n")
-			} else {
-				buf.WriteString("\n")
-			}
+// writeHTMLPageEnd writes the end of a standalone page after its content
+func writeHTMLPageEnd(buf *bytes.Buffer) {
+	buf.WriteString("    </main>\n")
+	buf.WriteString("  </body>\n")
+	buf.WriteString("</html>\n")
+}
+
+// indentWriter indents each line written through it, leaving blank lines
+// empty
+type indentWriter struct {
+	w       io.Writer
+	indent  string
+	midLine bool // The last line written hasn't ended yet
+}
+
+func (iw *indentWriter) Write(b []byte) (int, error) {
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if len(line) == 0 {
+			continue
 		}
-	} else {
-		buf.WriteString(htmlContent)
+		if !iw.midLine && line[0] != '\n' {
+			out.WriteString(iw.indent)
+		}
+		out.Write(line)
+		iw.midLine = line[len(line)-1] != '\n'
 	}
-
-	if opts.Standalone {
-		buf.WriteString("    </main>\n")
-		buf.WriteString("  </body>\n")
-		buf.WriteString("</html>\n")
+	if _, err := iw.w.Write(out.Bytes()); err != nil {
+		return 0, err
 	}
-
-	return Result{
-		HTML: buf.String(),
-		Meta: meta,
-	}, nil
+	return len(b), nil
 }
 
 // ConvertToHTML converts AsciiDoc to HTML5 string
@@ -1688,6 +2337,8 @@ func getTextContent(node *Node) string {
 	for _, child := range node.Children {
 		if child.Type == Text {
 			buf.WriteString(child.Content)
+		} else if child.Type == LineBreak {
+			buf.WriteString("\n")
 		} else {
 			buf.WriteString(getTextContent(child))
 		}
@@ -1851,7 +2502,7 @@ func convertMarkdownToAsciiDocLegacy(reader io.Reader) (string, error) {
 				}
 				colCount := len(cells)
 				// Write table start with column count
-				result.WriteString(fmt.Sprintf("[cols=\"%s\"]\n", strings.Repeat("1,", colCount)[:len(strings.Repeat("1,", colCount))-1]))
+				result.WriteString(fmt.Sprintf("[cols=\"%s\",options=\"header\"]\n", strings.Repeat("1,", colCount)[:len(strings.Repeat("1,", colCount))-1]))
 				result.WriteString("|===\n")
 				inTable = true
 			}
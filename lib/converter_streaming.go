@@ -435,7 +435,7 @@ func convertMarkdownLinesStreaming(lines []string, linkRefs map[string]LinkRefer
 				colCount := len(cells)
 				// Build column specification with alignments
 				colSpec := buildColumnSpec(colCount, tableAlignments)
-				if _, err := fmt.Fprintf(writer, "[cols=\"%s\"]\n", colSpec); err != nil {
+				if _, err := fmt.Fprintf(writer, "[cols=\"%s\",options=\"header\"]\n", colSpec); err != nil {
 					return err
 				}
 				if _, err := writer.Write([]byte("|===\n")); err != nil {
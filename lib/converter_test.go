@@ -333,6 +333,61 @@ term2:: definition2`
 	}
 }
 
+func TestToHTML_NestedLists(t *testing.T) {
+	input := `[%reversed,start=3]
+. three
+.. nested
+. two
+
+Checklist:
+
+* [x] done
+
+Glossary:
+
+Term:: Definition
+Sub::: Detail`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	html := ToHTML(doc)
+
+	for _, want := range []string{
+		`<ol start="3" reversed`,
+		"<li>three\n",
+		"<li>nested</li>",
+		`<input type="checkbox" data-role="checkbox" checked disabled> done`,
+		"<dt>Term</dt>",
+		"<dt>Sub</dt>",
+		"<dd><p>Detail</p>\n</dd>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected HTML to contain %q, got:\n%s", want, html)
+		}
+	}
+	if strings.Index(html, "<li>nested</li>") > strings.Index(html, "<li>two</li>") {
+		t.Errorf("Expected nested item inside the first item, got:\n%s", html)
+	}
+}
+
+func TestToXML_NestedLists(t *testing.T) {
+	doc, err := Parse(bytes.NewReader([]byte("* a\n** b\n* c")))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	xml := ToXML(doc)
+	want := `    <listitem>a
+      <list style="unordered">
+        <listitem>b</listitem>
+      </list>
+    </listitem>`
+	if !strings.Contains(xml, want) {
+		t.Errorf("Expected nested list inside the item, got:\n%s", xml)
+	}
+}
+
 func TestConvert_Admonition(t *testing.T) {
 	input := `= Test
 
@@ -1499,7 +1554,11 @@ Text with [#inline-anchor] anchor.`
 	}
 
 	xmlOutput := ToXML(doc)
-	if !strings.Contains(xmlOutput, `<anchor id="anchor-id"`) || !strings.Contains(xmlOutput, `<anchor id="inline-anchor"`) {
+	// [#anchor-id] is a block attribute line, so the ID belongs to the section
+	if !strings.Contains(xmlOutput, `id="anchor-id"`) || strings.Contains(xmlOutput, `<anchor id="anchor-id"`) {
+		t.Error("Expected the section to carry the anchor-id ID in XML output")
+	}
+	if !strings.Contains(xmlOutput, `<anchor id="inline-anchor"`) {
 		t.Error("Expected <anchor> element with id attribute in XML output")
 	}
 }
@@ -0,0 +1,305 @@
+package lib
+
+import (
+	"strings"
+)
+
+// delimiterKinds maps the character repeated in a delimiter line to the kind
+// of block it opens
+var delimiterKinds = map[byte]string{
+	'=': "example",
+	'-': "listing",
+	'.': "literal",
+	'*': "sidebar",
+	'_': "quote",
+	'+': "pass",
+	'/': "comment",
+}
+
+// compoundKinds are the kinds of delimited block whose content is parsed as
+// blocks
+var compoundKinds = map[string]bool{
+	"example": true,
+	"sidebar": true,
+	"quote":   true,
+	"open":    true,
+}
+
+// delimiterKind returns the kind of block the trimmed line opens, or "" if
+// it is not a delimiter: four or more of the same delimiter character, -- for
+// an open block, or ``` for a fenced code block
+func delimiterKind(line string) string {
+	switch {
+	case line == "--":
+		return "open"
+	case strings.HasPrefix(line, "```"):
+		return "fenced"
+	case len(line) < 4:
+		return ""
+	}
+	kind, ok := delimiterKinds[line[0]]
+	if !ok || strings.Trim(line, line[:1]) != "" {
+		return ""
+	}
+	return kind
+}
+
+// blockTable records where the delimited blocks in the lines of the parser
+// that built it close. Each block that parser opens is matched in one pass,
+// which matches the blocks nested in it too, so together the passes read its
+// lines once. Sub-parsers share the table and look their blocks up by line
+// offset instead of matching them again.
+type blockTable struct {
+	owner *parser     // Parser the table was built for
+	lines []string    // Its lines
+	ends  map[int]int // Closing line of each block matched, or -1 if unclosed
+	next  []int       // Line that would close a block opened on each delimiter line if nothing came between, or -1
+}
+
+// delimitedBlockEnd returns the index of the line closing the delimited
+// block opened on line idx, or -1 if it is never closed or parsing has
+// stopped. The closing delimiter must repeat the opening one exactly, so a
+// block can hold a block of its own kind whose delimiter is longer or
+// shorter.
+func (p *parser) delimitedBlockEnd(idx int) int {
+	if end, ok := p.blocks.lookup(p, idx); ok {
+		return end
+	}
+	if p.blocks == nil || p.blocks.owner != p {
+		p.blocks = &blockTable{owner: p, lines: p.lines, ends: make(map[int]int)}
+	}
+	return p.blocks.match(idx, p.haltedAt)
+}
+
+// lookup returns the end of the block opened on line idx of p if the table
+// has matched it and p's lines around it are the table's
+func (t *blockTable) lookup(p *parser, idx int) (int, bool) {
+	if t == nil {
+		return 0, false
+	}
+	shift := p.lineOffset - t.owner.lineOffset
+	end, ok := t.ends[shift+idx]
+	if !ok || t.lines[shift+idx] != p.lines[idx] {
+		return 0, false
+	}
+	if end < 0 {
+		return -1, true
+	}
+	if end-shift >= len(p.lines) || t.lines[end] != p.lines[end-shift] {
+		return 0, false
+	}
+	return end - shift, true
+}
+
+// match finds the end of the block opened on line idx with a stack of the
+// blocks open, recording the ends of those nested in it as it goes. A
+// delimiter closes the innermost open block it repeats, leaving any opened
+// within that one unclosed. A block that isn't compound can only be closed by
+// its own delimiter, so everything up to it is its content, and one that is
+// never closed is content of the block around it.
+func (t *blockTable) match(idx int, halted func(int) bool) int {
+	type openBlock struct {
+		line      int
+		delimiter string
+	}
+	var stack []openBlock
+	open := make(map[string][]int) // Stack positions of the open blocks with each delimiter
+	for i := idx; i < len(t.lines); i++ {
+		line := strings.TrimSpace(t.lines[i])
+		kind := delimiterKind(line)
+		if kind == "" {
+			continue
+		}
+		if halted(i) {
+			return -1
+		}
+		if at := open[line]; len(at) > 0 {
+			closed := at[len(at)-1]
+			for k := len(stack) - 1; k > closed; k-- {
+				t.ends[stack[k].line] = -1
+				open[stack[k].delimiter] = open[stack[k].delimiter][:len(open[stack[k].delimiter])-1]
+			}
+			t.ends[stack[closed].line] = i
+			open[line] = at[:len(at)-1]
+			stack = stack[:closed]
+			if len(stack) == 0 {
+				return i
+			}
+			continue
+		}
+		if compoundKinds[kind] {
+			open[line] = append(open[line], len(stack))
+			stack = append(stack, openBlock{i, line})
+			continue
+		}
+		end := t.closing(i)
+		t.ends[i] = end
+		if len(stack) == 0 {
+			return end
+		}
+		if end >= 0 {
+			i = end
+		}
+	}
+	for _, b := range stack {
+		t.ends[b.line] = -1
+	}
+	return -1
+}
+
+// closing returns the next line repeating the delimiter on line i, which
+// closes a block opened there that isn't compound, or -1. The lines are
+// indexed on first use.
+func (t *blockTable) closing(i int) int {
+	if t.next == nil {
+		t.next = make([]int, len(t.lines))
+		last := make(map[string]int)
+		for k := len(t.lines) - 1; k >= 0; k-- {
+			line := strings.TrimSpace(t.lines[k])
+			kind := delimiterKind(line)
+			if kind == "" {
+				continue
+			}
+			delimiter := line
+			if kind == "fenced" {
+				delimiter = "```"
+			}
+			t.next[k] = -1
+			if end, ok := last[delimiter]; ok {
+				t.next[k] = end
+			}
+			last[line] = k
+		}
+	}
+	return t.next[i]
+}
+
+// readDelimitedBlock consumes the delimited block opened on the current line
+// and returns its content lines and the index of the first. An unclosed block
+// is reported and runs to the end of the document.
+func (p *parser) readDelimitedBlock() ([]string, int) {
+	start := p.lineNum
+	end := p.delimitedBlockEnd(start)
+	if end < 0 {
+		if !p.halted() {
+			p.reportUnclosed(start)
+		}
+		end = len(p.lines)
+		p.lineNum = end
+	} else {
+		p.lineNum = end + 1
+	}
+	content := make([]string, end-start-1)
+	copy(content, p.lines[start+1:end])
+	return content, start + 1
+}
+
+// blockMasquerades lists the styles that turn a block of one kind into
+// another, as [source] does on a literal block. An open block can take on
+// any of them. Admonition styles turn examples, open blocks and paragraphs
+// into admonitions.
+var blockMasquerades = map[string]map[string]string{
+	"listing": {"literal": "literal"},
+	"literal": {"source": "listing", "listing": "listing"},
+	"pass":    {"stem": "stem", "latexmath": "stem", "asciimath": "stem"},
+	"quote":   {"verse": "verse"},
+	"open": {
+		"source": "listing", "listing": "listing", "literal": "literal",
+		"pass": "pass", "stem": "stem", "latexmath": "stem", "asciimath": "stem",
+		"example": "example", "sidebar": "sidebar", "quote": "quote", "verse": "verse",
+		"comment": "comment",
+	},
+	"paragraph": {
+		"source": "listing", "listing": "listing", "literal": "literal",
+		"pass": "pass", "stem": "stem", "latexmath": "stem", "asciimath": "stem",
+		"example": "example", "sidebar": "sidebar", "quote": "quote", "verse": "verse",
+	},
+}
+
+// styledKind returns the kind of block a block of the given kind becomes
+// with style, or kind itself if the style doesn't change it
+func styledKind(kind, style string) string {
+	if admonitionStyles[style] && (kind == "example" || kind == "open") {
+		return "admonition"
+	}
+	if styled, ok := blockMasquerades[kind][style]; ok {
+		return styled
+	}
+	return kind
+}
+
+// parseDelimitedBlock parses the delimited block opened on the current line,
+// as the kind of block its style makes it
+func (p *parser) parseDelimitedBlock() *Node {
+	start := p.lineNum
+	kind := delimiterKind(strings.TrimSpace(p.lines[start]))
+	content, contentStart := p.readDelimitedBlock()
+	attrs, _ := p.blockAttributes(start)
+	return p.buildBlock(styledKind(kind, attrs.Style), start, contentStart, content)
+}
+
+// parseStyledParagraph parses the paragraph on the current line as the kind
+// of block its style makes it, as in [source] or [quote], or returns nil if
+// the style doesn't change it. The block's content is the paragraph's lines
+// up to the next blank line.
+func (p *parser) parseStyledParagraph() *Node {
+	start := p.lineNum
+	attrs, _ := p.blockAttributes(start)
+	kind := styledKind("paragraph", attrs.Style)
+	if kind == "paragraph" {
+		return nil
+	}
+	end := start
+	for end < len(p.lines) && strings.TrimSpace(p.lines[end]) != "" {
+		end++
+	}
+	content := make([]string, end-start)
+	copy(content, p.lines[start:end])
+	p.lineNum = end
+	return p.buildBlock(kind, start, start, content)
+}
+
+// buildBlock builds a block of the given kind from content, the lines from
+// contentStart. Its attributes come from the lines above start.
+func (p *parser) buildBlock(kind string, start, contentStart int, content []string) *Node {
+	switch kind {
+	case "listing", "fenced":
+		return p.buildCodeBlock(start, contentStart, content)
+	case "literal":
+		return p.buildLiteralBlock(start, contentStart, content)
+	case "pass", "stem":
+		return p.buildPassthroughBlock(start, content, kind == "stem")
+	case "example", "admonition":
+		return p.buildExampleBlock(start, contentStart, content)
+	case "sidebar":
+		return p.buildSidebar(start, contentStart, content)
+	case "quote":
+		return p.buildQuote(start, contentStart, content)
+	case "verse":
+		return p.buildVerseBlock(start, contentStart, content)
+	case "comment":
+		return p.buildCommentBlock(start, content)
+	default:
+		return p.buildOpenBlock(start, contentStart, content)
+	}
+}
+
+// opensDelimitedBlock reports whether the trimmed line opens a delimited
+// block other than a comment, which parseComment handles
+func opensDelimitedBlock(line string) bool {
+	kind := delimiterKind(line)
+	return kind != "" && kind != "comment"
+}
+
+// stemNotation returns the math notation of a block styled style: the style
+// itself, or for [stem] the notation set by the stem document attribute,
+// asciimath unless it is latexmath
+func (p *parser) stemNotation(style string) string {
+	if style != "stem" {
+		return style
+	}
+	if p.attributes["stem"] == "latexmath" {
+		return "latexmath"
+	}
+	return "asciimath"
+}
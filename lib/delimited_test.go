@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelimiterKind(t *testing.T) {
+	tests := map[string]string{
+		"----":     "listing",
+		"--------": "listing",
+		"--":       "open",
+		"---":      "",
+		"====":     "example",
+		"=== x":    "",
+		"....":     "literal",
+		"****":     "sidebar",
+		"____":     "quote",
+		"++++":     "pass",
+		"////":     "comment",
+		"```go":    "fenced",
+		"--=-":     "",
+	}
+	for line, want := range tests {
+		if got := delimiterKind(line); got != want {
+			t.Errorf("delimiterKind(%q) = %q, expected %q", line, got, want)
+		}
+	}
+}
+
+func TestParse_NestedDelimitedBlocks(t *testing.T) {
+	doc, err := Parse(strings.NewReader("======\nouter\n\n====\ninner\n====\n\nafter\n======"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(doc.Children) != 1 {
+		t.Fatalf("Expected one outer example, got %d blocks", len(doc.Children))
+	}
+	outer := doc.Children[0]
+	if outer.Type != Example || len(outer.Children) != 3 || outer.Children[1].Type != Example {
+		t.Fatalf("Expected an example nested between two paragraphs, got %v", outer)
+	}
+	if got := getTextContent(outer.Children[2]); got != "after" {
+		t.Errorf("Expected the outer example to continue after the inner one, got %q", got)
+	}
+
+	// A shorter delimiter inside a listing is content
+	doc, _ = Parse(strings.NewReader("------\ncode\n----\nmore\n------"))
+	if got := getTextContent(findNode(doc, CodeBlock)); got != "code\n----\nmore" {
+		t.Errorf("Expected the inner delimiter to be code, got %q", got)
+	}
+
+	// A listing inside an example can't close it
+	doc, _ = Parse(strings.NewReader("====\n----\n====\n----\nstill\n===="))
+	example := findNode(doc, Example)
+	if len(example.Children) != 2 || getTextContent(example.Children[0]) != "====" {
+		t.Errorf("Expected the listing to hold ====, got %v", example.Children)
+	}
+
+	// An example's delimiter closes it around a sidebar left unclosed
+	doc, _ = Parse(strings.NewReader("====\n****\ninner\n====\n\nafter"))
+	if len(doc.Children) != 2 || doc.Children[0].Type != Example || getTextContent(doc.Children[1]) != "after" {
+		t.Fatalf("Expected the example to close before the paragraph, got %v", doc.Children)
+	}
+	if sidebar := doc.Children[0].Children; len(sidebar) != 1 || sidebar[0].Type != Sidebar {
+		t.Errorf("Expected the unclosed sidebar inside the example, got %v", sidebar)
+	}
+}
+
+func TestParse_UnclosedNestedDelimiters(t *testing.T) {
+	// Each unclosed block runs to the end of the document, so its end must
+	// not be looked for again by every block around it
+	var b strings.Builder
+	for i := 0; i < 40; i++ {
+		b.WriteString(strings.Repeat("=", 4+i) + "\n")
+	}
+	for _, input := range []string{b.String(), "[NOTE]\n" + b.String()} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		doc, err := ParseWithOptions(ctx, strings.NewReader(input), ParseOptions{})
+		cancel()
+		if err != nil {
+			t.Fatalf("ParseWithOptions failed: %v", err)
+		}
+		depth := 0
+		for n := doc; len(n.Children) > 0; n = n.Children[0] {
+			depth++
+		}
+		if depth != 40 {
+			t.Errorf("Expected 40 nested blocks, got %d", depth)
+		}
+	}
+}
+
+func BenchmarkParse_UnclosedDelimiters(b *testing.B) {
+	patterns := map[string]func(i int) string{
+		"alternating": func(i int) string { return []string{"====", "****"}[i%2] },
+		"distinct":    func(i int) string { return strings.Repeat([]string{"=", "*"}[i%2], 4+i/2) },
+		"separated": func(i int) string {
+			if i%2 == 1 {
+				return ""
+			}
+			return strings.Repeat("=", 4+i/2)
+		},
+	}
+	for name, line := range patterns {
+		lines := make([]string, 4000)
+		for i := range lines {
+			lines[i] = line(i)
+		}
+		input := strings.Join(lines, "\n")
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				// Nesting that deep stops at MaxNestingDepth
+				var limit *LimitError
+				if _, err := Parse(strings.NewReader(input)); err != nil && !errors.As(err, &limit) {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_BlockMasquerading(t *testing.T) {
+	tests := []struct {
+		input string
+		want  NodeType
+		style string
+	}{
+		{"[source,ruby]\n....\nputs 1\n....", CodeBlock, ""},
+		{"[literal]\n----\nx\n----", LiteralBlock, ""},
+		{"[quote,Someone]\nA quoted paragraph.", Quote, ""},
+		{"[source]\nputs 1", CodeBlock, ""},
+		{"[listing]\n--\nraw *text*\n--", CodeBlock, ""},
+		{"[pass]\n--\n<b>x</b>\n--", PassthroughBlock, ""},
+		{"[stem]\n--\nsqrt(4)\n--", PassthroughBlock, "asciimath"},
+		{"[latexmath]\n++++\n\\frac{1}{2}\n++++", PassthroughBlock, "latexmath"},
+		{"[sidebar]\n--\nside\n--", Sidebar, ""},
+		{"[verse]\n--\na\nb\n--", VerseBlock, ""},
+		{"[NOTE]\n--\nnote\n--", Admonition, ""},
+	}
+	for _, tt := range tests {
+		doc, err := Parse(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if len(doc.Children) != 1 || doc.Children[0].Type != tt.want {
+			t.Errorf("Expected %q to parse as one %v, got %v", tt.input, tt.want, doc.Children)
+			continue
+		}
+		if got := doc.Children[0].GetAttribute("style"); got != tt.style {
+			t.Errorf("Expected %q to have style %q, got %q", tt.input, tt.style, got)
+		}
+	}
+
+	doc, _ := Parse(strings.NewReader("[abstract]\n--\nSummary.\n--"))
+	if open := findNode(doc, OpenBlock); open == nil || open.GetAttribute("style") != "abstract" {
+		t.Errorf("Expected an open block keeping its style, got %v", doc.Children)
+	}
+	doc, _ = Parse(strings.NewReader("[comment]\n--\nhidden\n--\nshown"))
+	if len(doc.Children) != 1 || getTextContent(doc.Children[0]) != "shown" {
+		t.Errorf("Expected a [comment] open block to be dropped, got %v", doc.Children)
+	}
+}
+
+func TestConvert_StemBlock(t *testing.T) {
+	result, err := Convert(strings.NewReader(":stem: latexmath\n\n[stem]\n++++\nx < 1\n++++"), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if want := `<div data-role="stem" data-asciidoc-notation="latexmath">\[x &lt; 1\]</div>`; !strings.Contains(result.HTML, want) {
+		t.Errorf("Expected %q in HTML output:\n%s", want, result.HTML)
+	}
+}
@@ -0,0 +1,203 @@
+package lib
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Author is an author of a document, from the author line under the title
+// or the author and email attribute entries
+type Author struct {
+	Name       string // Full name, e.g. Jane Q Doe
+	FirstName  string
+	MiddleName string
+	LastName   string
+	Initials   string // First letter of each part of the name, e.g. JQD
+	Email      string // Email address or URL given in angle brackets
+}
+
+// Revision describes the revision of a document, from the revision line
+// under the author line or the revnumber, revdate and revremark attribute
+// entries
+type Revision struct {
+	Number string // e.g. 2.1 for v2.1
+	Date   string
+	Remark string
+}
+
+// authorAttribute returns the name of the document attribute holding key
+// for the nth author, counting from 1: firstname, then firstname_2, ...
+func authorAttribute(key string, n int) string {
+	if n == 1 {
+		return key
+	}
+	return key + "_" + strconv.Itoa(n)
+}
+
+// parseAuthorLine parses an author line such as
+// "Jane Doe <jane@example.com>; John Roe"
+func parseAuthorLine(line string) []Author {
+	var authors []Author
+	for _, part := range strings.Split(line, ";") {
+		if author := parseAuthor(part); author.Name != "" {
+			authors = append(authors, author)
+		}
+	}
+	return authors
+}
+
+// parseAuthor parses one author: up to three names, an underscore joining
+// words within a name, optionally followed by an email address in angle
+// brackets. A longer name is kept whole as the first name.
+func parseAuthor(text string) Author {
+	text = strings.TrimSpace(text)
+	var author Author
+	if strings.HasSuffix(text, ">") {
+		if i := strings.LastIndex(text, "<"); i >= 0 {
+			author.Email = strings.TrimSpace(text[i+1 : len(text)-1])
+			text = strings.TrimSpace(text[:i])
+		}
+	}
+
+	names := strings.Fields(text)
+	for i, name := range names {
+		names[i] = strings.ReplaceAll(name, "_", " ")
+	}
+	switch len(names) {
+	case 0:
+		return author
+	case 1:
+		author.FirstName = names[0]
+	case 2:
+		author.FirstName, author.LastName = names[0], names[1]
+	case 3:
+		author.FirstName, author.MiddleName, author.LastName = names[0], names[1], names[2]
+	default:
+		author.FirstName = strings.Join(names, " ")
+		author.Name = author.FirstName
+		author.Initials = initial(author.FirstName)
+		return author
+	}
+	author.Name = strings.Join(names, " ")
+	author.Initials = initial(author.FirstName) + initial(author.MiddleName) + initial(author.LastName)
+	return author
+}
+
+// initial returns the first letter of name
+func initial(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	if size == 0 || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return ""
+	}
+	return string(r)
+}
+
+// parseRevisionLine parses a revision line: an optional version and date,
+// separated by a comma, then an optional remark after a colon, as in
+// "v2.1, 2026-01-05: Draft". Without a comma the line is a date, or a
+// version if it starts with v.
+func parseRevisionLine(line string) Revision {
+	var rev Revision
+	line = strings.TrimSpace(line)
+	if i := strings.Index(line, ":"); i > 0 {
+		rev.Remark = strings.TrimSpace(line[i+1:])
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line[:i]), ","))
+	}
+	if i := strings.Index(line, ","); i >= 0 {
+		rev.Number = strings.TrimLeftFunc(strings.TrimSpace(line[:i]), func(r rune) bool {
+			return !unicode.IsDigit(r) && r != '{'
+		})
+		rev.Date = strings.TrimSpace(line[i+1:])
+	} else if strings.HasPrefix(line, "v") {
+		rev.Number = line[1:]
+	} else {
+		rev.Date = line
+	}
+	return rev
+}
+
+// setAuthors records authors as document attributes: author, firstname,
+// middlename, lastname, authorinitials and email for the first author,
+// the same with _2, _3, ... for the others, and authors and authorcount
+func (p *parser) setAuthors(authors []Author) {
+	var names []string
+	for i, author := range authors {
+		n := i + 1
+		for key, value := range map[string]string{
+			"author":         author.Name,
+			"firstname":      author.FirstName,
+			"middlename":     author.MiddleName,
+			"lastname":       author.LastName,
+			"authorinitials": author.Initials,
+			"email":          author.Email,
+		} {
+			if value != "" {
+				p.setDocumentAttribute(authorAttribute(key, n), value)
+			}
+		}
+		names = append(names, author.Name)
+	}
+	if len(authors) > 0 {
+		p.setDocumentAttribute("authors", strings.Join(names, ", "))
+		p.setDocumentAttribute("authorcount", strconv.Itoa(len(authors)))
+	}
+}
+
+// setRevision records rev as the revnumber, revdate and revremark document
+// attributes
+func (p *parser) setRevision(rev Revision) {
+	for key, value := range map[string]string{"revnumber": rev.Number, "revdate": rev.Date, "revremark": rev.Remark} {
+		if value != "" {
+			p.setDocumentAttribute(key, value)
+		}
+	}
+}
+
+// setDocumentAttribute sets a built-in attribute on the document and for
+// substitution in the content
+func (p *parser) setDocumentAttribute(key, value string) {
+	p.attributes[key] = value
+	p.doc.SetAttribute(key, value)
+}
+
+// isHeaderTextLine reports whether line idx can be the author or revision
+// line: a line of text rather than an attribute entry, comment or the end of
+// the header
+func (p *parser) isHeaderTextLine(idx int) bool {
+	if idx >= len(p.lines) {
+		return false
+	}
+	line := strings.TrimSpace(p.lines[idx])
+	return line != "" && !strings.HasPrefix(line, ":") && !strings.HasPrefix(line, "//") &&
+		!strings.HasPrefix(line, "=") && !isBlockAttributeLine(line)
+}
+
+// DocumentAuthors returns the authors of a document returned by Parse
+func DocumentAuthors(doc *Node) []Author {
+	var authors []Author
+	for n := 1; ; n++ {
+		name := doc.GetAttribute(authorAttribute("author", n))
+		if name == "" {
+			return authors
+		}
+		authors = append(authors, Author{
+			Name:       name,
+			FirstName:  doc.GetAttribute(authorAttribute("firstname", n)),
+			MiddleName: doc.GetAttribute(authorAttribute("middlename", n)),
+			LastName:   doc.GetAttribute(authorAttribute("lastname", n)),
+			Initials:   doc.GetAttribute(authorAttribute("authorinitials", n)),
+			Email:      doc.GetAttribute(authorAttribute("email", n)),
+		})
+	}
+}
+
+// DocumentRevision returns the revision of a document returned by Parse
+func DocumentRevision(doc *Node) Revision {
+	return Revision{
+		Number: doc.GetAttribute("revnumber"),
+		Date:   doc.GetAttribute("revdate"),
+		Remark: doc.GetAttribute("revremark"),
+	}
+}
@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAuthorLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []Author
+	}{
+		{"Jane Doe", []Author{{Name: "Jane Doe", FirstName: "Jane", LastName: "Doe", Initials: "JD"}}},
+		{"Jane Q. Doe <jane@example.com>", []Author{{Name: "Jane Q. Doe", FirstName: "Jane", MiddleName: "Q.", LastName: "Doe", Initials: "JQD", Email: "jane@example.com"}}},
+		{"Mary_Sue Brontë", []Author{{Name: "Mary Sue Brontë", FirstName: "Mary Sue", LastName: "Brontë", Initials: "MB"}}},
+		{"Plato", []Author{{Name: "Plato", FirstName: "Plato", Initials: "P"}}},
+		{"The Documentation Team Members", []Author{{Name: "The Documentation Team Members", FirstName: "The Documentation Team Members", Initials: "T"}}},
+		{"Jane Doe <jane@example.com>; John Roe", []Author{
+			{Name: "Jane Doe", FirstName: "Jane", LastName: "Doe", Initials: "JD", Email: "jane@example.com"},
+			{Name: "John Roe", FirstName: "John", LastName: "Roe", Initials: "JR"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := parseAuthorLine(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseRevisionLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want Revision
+	}{
+		{"v2.1, 2026-01-05: Draft", Revision{Number: "2.1", Date: "2026-01-05", Remark: "Draft"}},
+		{"Version 1.0, January 1, 2026", Revision{Number: "1.0", Date: "January 1, 2026"}},
+		{"2026-01-05", Revision{Date: "2026-01-05"}},
+		{"v3", Revision{Number: "3"}},
+		{"2026-01-05: Final review", Revision{Date: "2026-01-05", Remark: "Final review"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := parseRevisionLine(tt.line); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseHeader_AuthorAndRevisionLines(t *testing.T) {
+	input := `= Document Title
+Jane Doe <jane@example.com>; John Roe
+v2.1, 2026-01-05: Draft
+:toc:
+
+Written by {firstname} and {lastname_2}, version {revnumber}.`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	authors := DocumentAuthors(doc)
+	if len(authors) != 2 || authors[0].Email != "jane@example.com" || authors[1].Name != "John Roe" {
+		t.Errorf("Expected Jane Doe and John Roe, got %+v", authors)
+	}
+	if got, want := DocumentRevision(doc), (Revision{Number: "2.1", Date: "2026-01-05", Remark: "Draft"}); got != want {
+		t.Errorf("Expected revision %+v, got %+v", want, got)
+	}
+	if _, ok := doc.Attributes[":toc"]; !ok || doc.GetAttribute("authorcount") != "2" {
+		t.Errorf("Expected the header to continue after the revision line, got %v", doc.Attributes)
+	}
+
+	para := findNode(doc, Paragraph)
+	if got := getTextContent(para); got != "Written by Jane and Roe, version 2.1." {
+		t.Errorf("Expected author attributes to be substituted, got %q", got)
+	}
+	if len(doc.Children) != 1 {
+		t.Errorf("Expected the author and revision lines not to become content, got %d blocks", len(doc.Children))
+	}
+}
+
+func TestParseHeader_AuthorAttributeEntries(t *testing.T) {
+	doc, err := Parse(strings.NewReader("= Title\n:author: Jane Q Doe\n:email: jane@example.com\n:revnumber: 1.2\n\nText."))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Author{{Name: "Jane Q Doe", FirstName: "Jane", MiddleName: "Q", LastName: "Doe", Initials: "JQD", Email: "jane@example.com"}}
+	if got := DocumentAuthors(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if got := DocumentRevision(doc).Number; got != "1.2" {
+		t.Errorf("Expected revnumber 1.2, got %q", got)
+	}
+}
+
+func TestConvert_HeaderMetadata(t *testing.T) {
+	input := "= Title\nJane Doe <jane@example.com>; John Roe\nv2.1, 2026-01-05: Draft\n\nText."
+	result, err := Convert(strings.NewReader(input), ConvertOptions{Standalone: true})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if len(result.Meta.Authors) != 2 || result.Meta.Author != "Jane Doe" || result.Meta.Revision.Remark != "Draft" {
+		t.Errorf("Expected authors and revision in the metadata, got %+v", result.Meta)
+	}
+	for _, want := range []string{
+		`<span class="author-name">Jane Doe</span> <a href="mailto:jane@example.com" class="author-email">jane@example.com</a>`,
+		`<span class="author-name">John Roe</span>`,
+		`<span class="revision-number">Version 2.1</span>, <time class="revision-date">2026-01-05</time>: <span class="revision-remark">Draft</span>`,
+	} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, result.HTML)
+		}
+	}
+
+	doc, _ := Parse(strings.NewReader(input))
+	xml := ToXML(doc)
+	for _, want := range []string{
+		"<header>\n    <title>Title</title>\n    <author>\n      <name>Jane Doe</name>",
+		"<initials>JR</initials>",
+		"<revision>\n      <number>2.1</number>\n      <date>2026-01-05</date>\n      <remark>Draft</remark>\n    </revision>",
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("Expected %q in XML output:\n%s", want, xml)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxIncludeDepth is the include nesting depth used when
+// ParseOptions.MaxIncludeDepth is zero
+const DefaultMaxIncludeDepth = 64
+
+// ErrIncludeOutsideRoot is returned by resolvers when an include target
+// would escape the sandbox root
+var ErrIncludeOutsideRoot = errors.New("include target is outside the sandbox root")
+
+// IncludeResolver loads the content of include:: targets.
+//
+// Resolve returns the content of target as included from the document named
+// parent, along with a name that identifies the included document. The name is
+// passed back as parent for includes nested in that document, is used for
+// cycle detection, and appears in source positions. parent is
+// ParseOptions.DocumentName for includes in the root document.
+type IncludeResolver interface {
+	Resolve(target, parent string) (content []byte, name string, err error)
+}
+
+// FileSystemResolver resolves includes from the local filesystem.
+// Relative targets are resolved against the directory of the including
+// document, and no file outside Root can be included.
+type FileSystemResolver struct {
+	Root string // Sandbox root directory; relative document names are resolved against it
+}
+
+// NewFileSystemResolver creates a resolver sandboxed to root
+func NewFileSystemResolver(root string) *FileSystemResolver {
+	return &FileSystemResolver{Root: root}
+}
+
+// Resolve implements IncludeResolver. The returned name is the absolute path
+// of the included file.
+func (r *FileSystemResolver) Resolve(target, parent string) ([]byte, string, error) {
+	if strings.Contains(target, "://") {
+		return nil, "", fmt.Errorf("remote include %q is not supported", target)
+	}
+
+	root, err := filepath.Abs(r.Root)
+	if err != nil {
+		return nil, "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	base := root
+	if parent != "" {
+		parentPath := filepath.FromSlash(parent)
+		if !filepath.IsAbs(parentPath) {
+			parentPath = filepath.Join(root, parentPath)
+		}
+		base = filepath.Dir(parentPath)
+	}
+
+	file := filepath.FromSlash(target)
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(base, file)
+	}
+	file = filepath.Clean(file)
+	if resolved, err := filepath.EvalSymlinks(file); err == nil {
+		file = resolved
+	}
+
+	rel, err := filepath.Rel(root, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, "", ErrIncludeOutsideRoot
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, file, nil
+}
+
+// FSResolver resolves includes from an fs.FS, such as an embed.FS or an
+// os.DirFS. The root of the file system is the sandbox root.
+type FSResolver struct {
+	FS fs.FS
+}
+
+// NewFSResolver creates a resolver that reads from fsys
+func NewFSResolver(fsys fs.FS) *FSResolver {
+	return &FSResolver{FS: fsys}
+}
+
+// Resolve implements IncludeResolver. The returned name is the slash-separated
+// path of the included file within the file system.
+func (r *FSResolver) Resolve(target, parent string) ([]byte, string, error) {
+	if strings.Contains(target, "://") {
+		return nil, "", fmt.Errorf("remote include %q is not supported", target)
+	}
+
+	name := strings.TrimPrefix(target, "/")
+	if !strings.HasPrefix(target, "/") && parent != "" {
+		name = path.Join(path.Dir(parent), name)
+	}
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return nil, "", ErrIncludeOutsideRoot
+	}
+
+	content, err := fs.ReadFile(r.FS, name)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, name, nil
+}
+
+// includeDirectiveRegex matches an include directive, which must start the line
+var includeDirectiveRegex = regexp.MustCompile(`^(\\?)include::([^\[\s][^\[]*)\[(.*)\]$`)
+
+// include expands the include directive m found on a line from origin,
+// returning the included lines and their origins. If the target can't be
+// included, the directive line is returned unchanged.
+func (pp *preprocessor) include(line string, m []string, origin sourceLine, stack []string, levelOffset int) ([]string, []sourceLine) {
+	target := pp.substitute(m[2], origin)
+	included, name, err := pp.resolve(target, origin.file, stack)
+	if err != nil {
+		pp.addDiagnostic(err.code, origin, line, "%s", err.message)
+		// Keep the directive so it is rendered as an unresolved include
+		return []string{line}, []sourceLine{origin}
+	}
+
+	attrs := parseIncludeAttributes(m[3])
+	incLines, lineNums := splitIncludedLines(included)
+	if spec, ok := attrs["lines"]; ok {
+		incLines, lineNums = selectLines(incLines, lineNums, spec)
+	} else if spec, ok := attrs["tags"]; ok {
+		incLines, lineNums = selectTags(incLines, lineNums, spec)
+	} else if spec, ok := attrs["tag"]; ok {
+		incLines, lineNums = selectTags(incLines, lineNums, spec)
+	}
+	if indent, ok := attrs["indent"]; ok {
+		if n, err := strconv.Atoi(indent); err == nil && n >= 0 {
+			incLines = reindentLines(incLines, n)
+		}
+	}
+
+	incOrigins := make([]sourceLine, len(incLines))
+	for j, n := range lineNums {
+		incOrigins[j] = sourceLine{file: name, line: n}
+	}
+
+	offset := levelOffset
+	if lo, ok := attrs["leveloffset"]; ok {
+		if n, err := strconv.Atoi(strings.TrimPrefix(lo, "+")); err == nil {
+			if strings.HasPrefix(lo, "+") || strings.HasPrefix(lo, "-") {
+				offset = levelOffset + n
+			} else {
+				offset = n
+			}
+		}
+	}
+
+	nested := append(append([]string(nil), stack...), name)
+	incLines, incOrigins = pp.expand(incLines, incOrigins, nested, offset)
+	if offset != levelOffset {
+		incLines = shiftSectionLevels(incLines, offset-levelOffset)
+	}
+	return incLines, incOrigins
+}
+
+// includeError describes why an include directive could not be resolved
+type includeError struct {
+	code    string
+	message string
+}
+
+// resolve loads target as included from the document named from
+func (pp *preprocessor) resolve(target, from string, stack []string) ([]byte, string, *includeError) {
+	if len(stack) >= pp.maxDepth {
+		return nil, "", &includeError{CodeIncludeDepth, fmt.Sprintf("maximum include depth of %d exceeded including %q", pp.maxDepth, target)}
+	}
+
+	root := pp.p.opts.DocumentName
+	parent := from
+	if parent == "" {
+		parent = root
+	}
+	content, name, err := pp.p.opts.IncludeResolver.Resolve(target, parent)
+	if err != nil {
+		return nil, "", &includeError{CodeUnresolvedInclude, fmt.Sprintf("include %q could not be resolved: %v", target, err)}
+	}
+
+	if name != "" && name == root {
+		return nil, "", &includeError{CodeIncludeCycle, fmt.Sprintf("include %q includes the root document", target)}
+	}
+	for _, open := range stack {
+		if open == name {
+			return nil, "", &includeError{CodeIncludeCycle, fmt.Sprintf("include %q forms a cycle", target)}
+		}
+	}
+	return content, name, nil
+}
+
+// parseIncludeAttributes parses the attribute list of an include directive.
+// Only named attributes affect an include, so errors are left for the parser
+// to report if the directive stays unresolved.
+func parseIncludeAttributes(attrList string) map[string]string {
+	attrs, _ := ParseMacroAttributeList(attrList)
+	return attrs.Named
+}
+
+// splitIncludedLines splits included content into lines, returning each
+// line's 1-based number alongside it
+func splitIncludedLines(content []byte) ([]string, []int) {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil, nil
+	}
+	lines := strings.Split(text, "\n")
+	nums := make([]int, len(lines))
+	for i := range lines {
+		nums[i] = i + 1
+	}
+	return lines, nums
+}
+
+// selectLines keeps the lines selected by a lines= spec such as "1..5;8;10..-1"
+// or "1..5,8". A range ending in -1 (or with no end) runs to the last line.
+func selectLines(lines []string, nums []int, spec string) ([]string, []int) {
+	type lineRange struct{ from, to int }
+	var ranges []lineRange
+	for _, part := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == ',' }) {
+		part = strings.TrimSpace(part)
+		from, to := part, part
+		if i := strings.Index(part, ".."); i >= 0 {
+			from, to = part[:i], part[i+2:]
+		}
+		f, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			continue
+		}
+		t := -1
+		if strings.TrimSpace(to) != "" {
+			if t, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, lineRange{f, t})
+	}
+
+	var outLines []string
+	var outNums []int
+	for i, n := range nums {
+		for _, r := range ranges {
+			if n >= r.from && (r.to < 0 || n <= r.to) {
+				outLines = append(outLines, lines[i])
+				outNums = append(outNums, n)
+				break
+			}
+		}
+	}
+	return outLines, outNums
+}
+
+// tagDirectiveRegex matches a tag::name[] or end::name[] marker
+var tagDirectiveRegex = regexp.MustCompile(`\b(tag|end)::(\S+?)\[\](?:$|\s)`)
+
+// selectTags keeps the lines in the tagged regions selected by a tag= or tags=
+// spec. Names are separated by ";" or ","; "!name" excludes a region, "*"
+// selects every tagged region and "**" selects every line. Tag marker lines
+// are always dropped.
+func selectTags(lines []string, nums []int, spec string) ([]string, []int) {
+	selected := make(map[string]bool)
+	onlyNegated := true
+	wildcard := false
+	untagged := false
+	for _, name := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == ',' }) {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "**":
+			wildcard, untagged = true, true
+			onlyNegated = false
+		case name == "*":
+			wildcard = true
+			onlyNegated = false
+		case strings.HasPrefix(name, "!"):
+			selected[name[1:]] = false
+		case name != "":
+			selected[name] = true
+			onlyNegated = false
+		}
+	}
+	if onlyNegated {
+		// Only exclusions: start from every line
+		wildcard, untagged = true, true
+	}
+
+	var open []string // Tags enclosing the current line, innermost last
+	var outLines []string
+	var outNums []int
+	for i, line := range lines {
+		if m := tagDirectiveRegex.FindStringSubmatch(line); m != nil {
+			if m[1] == "tag" {
+				open = append(open, m[2])
+			} else {
+				for j := len(open) - 1; j >= 0; j-- {
+					if open[j] == m[2] {
+						open = append(open[:j], open[j+1:]...)
+						break
+					}
+				}
+			}
+			continue
+		}
+
+		include := untagged
+		if len(open) > 0 {
+			include = wildcard
+			for j := len(open) - 1; j >= 0; j-- {
+				if sel, ok := selected[open[j]]; ok {
+					include = sel
+					break
+				}
+			}
+		}
+		if include {
+			outLines = append(outLines, line)
+			outNums = append(outNums, nums[i])
+		}
+	}
+	return outLines, outNums
+}
+
+// reindentLines removes the common leading indentation of lines and indents
+// them by n spaces instead
+func reindentLines(lines []string, n int) []string {
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if common < 0 || indent < common {
+			common = indent
+		}
+	}
+	if common < 0 {
+		return lines
+	}
+
+	prefix := strings.Repeat(" ", n)
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			out[i] = ""
+			continue
+		}
+		out[i] = prefix + line[common:]
+	}
+	return out
+}
+
+// sectionTitleRegex matches a section title line: one or more = and the title
+var sectionTitleRegex = regexp.MustCompile(`^(=+)([ \t]+\S.*)$`)
+
+// shiftSectionLevels adds offset to the level of each section title in lines,
+// skipping the content of verbatim blocks
+func shiftSectionLevels(lines []string, offset int) []string {
+	out := make([]string, len(lines))
+	fence := ""
+	for i, line := range lines {
+		out[i] = line
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if trimmed == fence {
+				fence = ""
+			}
+			continue
+		}
+		if isVerbatimFence(trimmed) {
+			fence = trimmed
+			continue
+		}
+		m := sectionTitleRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		level := len(m[1]) + offset
+		if level < 1 {
+			level = 1
+		}
+		out[i] = strings.Repeat("=", level) + m[2]
+	}
+	return out
+}
+
+// isVerbatimFence reports whether line opens or closes a block whose content
+// is not parsed for structure
+func isVerbatimFence(line string) bool {
+	if line == "```" {
+		return true
+	}
+	if len(line) < 4 {
+		return false
+	}
+	switch line[0] {
+	case '-', '.', '+', '/':
+		return strings.Trim(line, line[:1]) == ""
+	}
+	return false
+}
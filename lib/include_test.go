@@ -0,0 +1,291 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func parseWithFS(t *testing.T, input string, files fstest.MapFS) (*Node, []Diagnostic) {
+	t.Helper()
+	diagnostics, err := ValidateWithOptions(bytes.NewReader([]byte(input)), ParseOptions{
+		IncludeResolver: NewFSResolver(files),
+		DocumentName:    "index.adoc",
+	})
+	if err != nil {
+		t.Fatalf("ValidateWithOptions failed: %v", err)
+	}
+	doc, err := ParseWithOptions(context.Background(), bytes.NewReader([]byte(input)), ParseOptions{
+		IncludeResolver: NewFSResolver(files),
+		DocumentName:    "index.adoc",
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+	return doc, diagnostics
+}
+
+func TestParseWithOptions_Include(t *testing.T) {
+	files := fstest.MapFS{
+		"chapters/one.adoc":  {Data: []byte("== Chapter One\n\ninclude::part.adoc[]\n")},
+		"chapters/part.adoc": {Data: []byte("Included *text*.\n")},
+	}
+	input := `= Book
+
+include::chapters/one.adoc[]`
+
+	doc, diagnostics := parseWithFS(t, input, files)
+	if len(diagnostics) != 0 {
+		t.Fatalf("Expected no diagnostics, got %v", diagnostics)
+	}
+
+	section := findNode(doc, Section)
+	if section == nil || section.GetAttribute("title") != "Chapter One" {
+		t.Fatalf("Expected included section 'Chapter One', got %v", section)
+	}
+	if section.Start != (Position{File: "chapters/one.adoc", Line: 1, Column: 1}) {
+		t.Errorf("Expected section at chapters/one.adoc:1:1, got %s", section.Start)
+	}
+
+	bold := findNode(doc, Bold)
+	if bold == nil {
+		t.Fatal("Expected nested include to be expanded")
+	}
+	if bold.Start != (Position{File: "chapters/part.adoc", Line: 1, Column: 10}) {
+		t.Errorf("Expected bold at chapters/part.adoc:1:10, got %s", bold.Start)
+	}
+}
+
+func TestParseWithOptions_IncludeLines(t *testing.T) {
+	files := fstest.MapFS{
+		"code.go": {Data: []byte("line1\nline2\nline3\nline4\nline5\n")},
+	}
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"2..3", "line2\nline3"},
+		{"1;4..-1", "line1\nline4\nline5"},
+		{`"1,5"`, "line1\nline5"},
+		{"4..", "line4\nline5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			input := "----\ninclude::code.go[lines=" + tt.spec + "]\n----"
+			doc, _ := parseWithFS(t, input, files)
+			code := findNode(doc, CodeBlock)
+			if code == nil || len(code.Children) == 0 {
+				t.Fatal("Code block not found")
+			}
+			if got := code.Children[0].Content; got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseWithOptions_IncludeTags(t *testing.T) {
+	source := `package main
+// tag::imports[]
+import "fmt"
+// end::imports[]
+// tag::main[]
+func main() {
+	// tag::body[]
+	fmt.Println("hi")
+	// end::body[]
+}
+// end::main[]`
+	files := fstest.MapFS{"main.go": {Data: []byte(source)}}
+
+	tests := []struct {
+		attrs string
+		want  string
+	}{
+		{"tag=imports", `import "fmt"`},
+		{"tag=body", `	fmt.Println("hi")`},
+		{"tags=imports;body", "import \"fmt\"\n\tfmt.Println(\"hi\")"},
+		{"tags=main;!body", "func main() {\n}"},
+		{"tags=*", "import \"fmt\"\nfunc main() {\n\tfmt.Println(\"hi\")\n}"},
+		{"tags=**;!main", "package main\nimport \"fmt\""},
+		{"tag=body,indent=0", `fmt.Println("hi")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.attrs, func(t *testing.T) {
+			input := "----\ninclude::main.go[" + tt.attrs + "]\n----"
+			doc, _ := parseWithFS(t, input, files)
+			code := findNode(doc, CodeBlock)
+			if code == nil || len(code.Children) == 0 {
+				t.Fatal("Code block not found")
+			}
+			if got := code.Children[0].Content; got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseWithOptions_IncludeLevelOffset(t *testing.T) {
+	files := fstest.MapFS{
+		"chapter.adoc": {Data: []byte("= Chapter\n\ninclude::sub.adoc[leveloffset=+1]\n\n----\n= not a title\n----\n")},
+		"sub.adoc":     {Data: []byte("= Sub\n\nText.\n")},
+	}
+	input := `= Book
+
+include::chapter.adoc[leveloffset=+1]`
+
+	doc, _ := parseWithFS(t, input, files)
+
+	var levels []string
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Type == Section {
+			levels = append(levels, n.GetAttribute("title")+"@"+n.GetAttribute("level"))
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	want := "Chapter@1 Sub@2"
+	if got := strings.Join(levels, " "); got != want {
+		t.Errorf("Expected sections %q, got %q", want, got)
+	}
+
+	code := findNode(doc, CodeBlock)
+	if code == nil || code.Children[0].Content != "= not a title" {
+		t.Errorf("Expected verbatim content to keep its level, got %v", code)
+	}
+}
+
+func TestParseWithOptions_IncludeErrors(t *testing.T) {
+	files := fstest.MapFS{
+		"a.adoc":    {Data: []byte("A\n\ninclude::b.adoc[]\n")},
+		"b.adoc":    {Data: []byte("B\n\ninclude::a.adoc[]\n")},
+		"self.adoc": {Data: []byte("include::self.adoc[]\n")},
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		code  string
+	}{
+		{"missing", "include::missing.adoc[]", CodeUnresolvedInclude},
+		{"escape root", "include::../secret.adoc[]", CodeUnresolvedInclude},
+		{"cycle", "include::a.adoc[]", CodeIncludeCycle},
+		{"self", "include::self.adoc[]", CodeIncludeCycle},
+		{"root", "include::index.adoc[]", CodeIncludeCycle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files["index.adoc"] = &fstest.MapFile{Data: []byte(tt.input)}
+			doc, diagnostics := parseWithFS(t, tt.input, files)
+			if found := diagnosticsWithCode(diagnostics, tt.code); len(found) != 1 {
+				t.Errorf("Expected 1 %s diagnostic, got %v", tt.code, diagnostics)
+			}
+			// The failed directive is kept as an include macro
+			if macro := findNode(doc, BlockMacro); macro == nil || macro.Name != "include" {
+				t.Errorf("Expected unresolved include macro, got %v", macro)
+			}
+		})
+	}
+}
+
+func TestParseWithOptions_IncludeMaxDepth(t *testing.T) {
+	files := fstest.MapFS{
+		"1.adoc": {Data: []byte("include::2.adoc[]")},
+		"2.adoc": {Data: []byte("include::3.adoc[]")},
+		"3.adoc": {Data: []byte("Deep.")},
+	}
+	opts := ParseOptions{IncludeResolver: NewFSResolver(files), MaxIncludeDepth: 2}
+
+	diagnostics, err := ValidateWithOptions(bytes.NewReader([]byte("include::1.adoc[]")), opts)
+	if err != nil {
+		t.Fatalf("ValidateWithOptions failed: %v", err)
+	}
+	found := diagnosticsWithCode(diagnostics, CodeIncludeDepth)
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 include-depth-exceeded diagnostic, got %v", diagnostics)
+	}
+	if found[0].Start.File != "2.adoc" {
+		t.Errorf("Expected diagnostic in 2.adoc, got %s", found[0].Start)
+	}
+}
+
+func TestParseWithOptions_EscapedInclude(t *testing.T) {
+	doc, diagnostics := parseWithFS(t, `\include::other.adoc[]`, fstest.MapFS{})
+	if len(diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", diagnostics)
+	}
+	para := findNode(doc, Paragraph)
+	if para == nil || para.Children[0].Content != "include::other.adoc[]" {
+		t.Errorf("Expected the escaped directive as text, got %v", para)
+	}
+}
+
+func TestParseWithOptions_IncludeTargetAttribute(t *testing.T) {
+	files := fstest.MapFS{"partials/note.adoc": {Data: []byte("From a partial.")}}
+	input := `= Doc
+:partialsdir: partials
+
+include::{partialsdir}/note.adoc[]`
+
+	doc, diagnostics := parseWithFS(t, input, files)
+	if len(diagnostics) != 0 {
+		t.Fatalf("Expected no diagnostics, got %v", diagnostics)
+	}
+	if para := findNode(doc, Paragraph); para == nil || para.Children[0].Content != "From a partial." {
+		t.Errorf("Expected included paragraph, got %v", para)
+	}
+}
+
+func TestParse_IncludeWithoutResolver(t *testing.T) {
+	doc, err := Parse(bytes.NewReader([]byte("include::other.adoc[]")))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if macro := findNode(doc, BlockMacro); macro == nil || macro.Name != "include" {
+		t.Errorf("Expected include macro without a resolver, got %v", macro)
+	}
+}
+
+func TestFileSystemResolver(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs", "parts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "parts", "a.adoc"), []byte("A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.adoc"), []byte("S"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewFileSystemResolver(filepath.Join(dir, "docs"))
+
+	content, name, err := r.Resolve("parts/a.adoc", "index.adoc")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if string(content) != "A" || filepath.Base(name) != "a.adoc" || !filepath.IsAbs(name) {
+		t.Errorf("Unexpected result %q, %q", content, name)
+	}
+
+	// Nested includes resolve against the including file
+	if _, _, err := r.Resolve("a.adoc", name); err != nil {
+		t.Errorf("Expected relative resolution against parent, got %v", err)
+	}
+
+	if _, _, err := r.Resolve("../secret.adoc", "index.adoc"); !errors.Is(err, ErrIncludeOutsideRoot) {
+		t.Errorf("Expected ErrIncludeOutsideRoot, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package lib
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// matchIndexTerm matches an index term starting at i: a concealed
+// (((primary, secondary, tertiary))) term, which is indexed but not shown, or
+// a flow ((term)), which is both
+func (s *inlineScanner) matchIndexTerm(i, end int) (*Node, int) {
+	rest := s.text[i:end]
+	if strings.HasPrefix(rest, "(((") {
+		if close := s.index(")))", i+3, end) - (i + 3); close > 0 {
+			if term := concealedIndexTerm(rest[3 : 3+close]); term != nil {
+				return term, i + 6 + close
+			}
+		}
+	}
+	if !strings.HasPrefix(rest, "((") {
+		return nil, 0
+	}
+	close := s.index("))", i+2, end) - (i + 2)
+	if close <= 0 || strings.TrimSpace(rest[2:2+close]) == "" || strings.ContainsRune(rest[2:2+close], '\n') {
+		return nil, 0
+	}
+	return s.flowIndexTerm(i+2, i+2+close), i + 4 + close
+}
+
+// flowIndexTerm returns a flow index term whose text is text[start:end]
+func (s *inlineScanner) flowIndexTerm(start, end int) *Node {
+	term := NewIndexTermNode()
+	s.parse(term, start, end)
+	term.SetAttribute("primary", strings.TrimSpace(getTextContent(term)))
+	return term
+}
+
+// concealedIndexTerm returns the concealed index term for a list of up to
+// three terms, such as those of (((primary, secondary))) or indexterm:[],
+// or nil if the list has no primary term
+func concealedIndexTerm(list string) *Node {
+	attrs, err := ParseMacroAttributeList(list)
+	if err != nil || strings.TrimSpace(attrs.At(0)) == "" {
+		return nil
+	}
+	term := NewIndexTermNode()
+	for i, key := range []string{"primary", "secondary", "tertiary"} {
+		if value := strings.TrimSpace(attrs.At(i)); value != "" {
+			term.SetAttribute(key, value)
+		}
+	}
+	return term
+}
+
+// IndexEntry is a term in a document's index
+type IndexEntry struct {
+	Term     string        `json:"term"`
+	Refs     []IndexRef    `json:"refs,omitempty"`     // Uses of the term, in document order
+	Subterms []*IndexEntry `json:"subterms,omitempty"` // Secondary terms, or tertiary terms of a secondary one, sorted
+}
+
+// IndexRef is a use of an index term
+type IndexRef struct {
+	ID      string `json:"id"`                // ID of the IndexTerm node
+	Section string `json:"section,omitempty"` // Title of the section the term is used in
+}
+
+// IndexGroup holds the index entries whose terms start with Letter, or with
+// anything other than a letter for the group "#"
+type IndexGroup struct {
+	Letter  string        `json:"letter"`
+	Entries []*IndexEntry `json:"entries"`
+}
+
+// Index returns the index of a document returned by Parse: its index terms,
+// grouped by their first letter and sorted regardless of case
+func Index(doc *Node) []IndexGroup {
+	var root IndexEntry
+	var visit func(n *Node, section string)
+	visit = func(n *Node, section string) {
+		if n.Type == Section && n.GetAttribute("discrete") == "" {
+			section = n.GetAttribute("title")
+		}
+		if n.Type == IndexTerm {
+			entry := &root
+			for _, key := range []string{"primary", "secondary", "tertiary"} {
+				term := n.GetAttribute(key)
+				if term == "" {
+					break
+				}
+				entry = entry.subterm(term)
+			}
+			if entry != &root {
+				entry.Refs = append(entry.Refs, IndexRef{ID: n.GetAttribute("id"), Section: section})
+			}
+		}
+		for _, child := range n.Children {
+			visit(child, section)
+		}
+	}
+	visit(doc, "")
+
+	var groups []IndexGroup
+	for _, entry := range root.Subterms {
+		letter := indexLetter(entry.Term)
+		if len(groups) == 0 || groups[len(groups)-1].Letter != letter {
+			groups = append(groups, IndexGroup{Letter: letter})
+		}
+		group := &groups[len(groups)-1]
+		group.Entries = append(group.Entries, entry)
+	}
+	return groups
+}
+
+// subterm returns the entry for term below e, adding it in sorted order if
+// it isn't there yet
+func (e *IndexEntry) subterm(term string) *IndexEntry {
+	key := indexSortKey(term)
+	i := sort.Search(len(e.Subterms), func(i int) bool {
+		other := indexSortKey(e.Subterms[i].Term)
+		return other > key || other == key && e.Subterms[i].Term >= term
+	})
+	if i < len(e.Subterms) && e.Subterms[i].Term == term {
+		return e.Subterms[i]
+	}
+	entry := &IndexEntry{Term: term}
+	e.Subterms = append(e.Subterms, nil)
+	copy(e.Subterms[i+1:], e.Subterms[i:])
+	e.Subterms[i] = entry
+	return entry
+}
+
+// indexSortKey orders terms without regard to case, with terms that don't
+// start with a letter first, as their group is
+func indexSortKey(term string) string {
+	key := strings.ToLower(term)
+	if indexLetter(term) == "#" {
+		return "\x00" + key
+	}
+	return key
+}
+
+// indexLetter returns the group of an index term: its first letter in upper
+// case, or "#"
+func indexLetter(term string) string {
+	r, _ := utf8.DecodeRuneInString(term)
+	if !unicode.IsLetter(r) {
+		return "#"
+	}
+	return string(unicode.ToUpper(r))
+}
+
+// generateIndex gives every index term in doc an ID and fills each section
+// styled [index] with the index
+func (p *parser) generateIndex(doc *Node) {
+	var indexes []*Node
+	doc.Traverse(func(n *Node) {
+		switch {
+		case n.Type == IndexTerm:
+			p.numberIndexTerm(n)
+		case n.Type == Section && n.GetAttribute("style") == "index":
+			indexes = append(indexes, n)
+		}
+	})
+	if len(indexes) == 0 || p.indexTerms == 0 {
+		return
+	}
+
+	groups := Index(doc)
+	for _, section := range indexes {
+		section.Children = append(section.Children, indexBlocks(section, groups)...)
+	}
+}
+
+// numberIndexTerm gives the next index term in the document its ID
+func (p *parser) numberIndexTerm(term *Node) {
+	p.indexTerms++
+	term.SetAttribute("id", "_indexterm_"+strconv.Itoa(p.indexTerms))
+}
+
+// indexBlocks returns the content of an index section: a discrete heading
+// for each letter followed by a list of its terms, each linking to the
+// sections using it
+func indexBlocks(section *Node, groups []IndexGroup) []*Node {
+	var blocks []*Node
+	level, _ := strconv.Atoi(section.GetAttribute("level"))
+	for _, group := range groups {
+		heading := NewSectionNode(level + 1)
+		heading.SetAttribute("title", group.Letter)
+		heading.SetAttribute("marker", strings.Repeat("=", level+2))
+		heading.SetAttribute("discrete", "true")
+		heading.AddChild(NewTextNode(group.Letter))
+		blocks = append(blocks, heading, indexList(group.Entries))
+	}
+	return blocks
+}
+
+// indexList returns the list of index entries, with their subterms in
+// nested lists
+func indexList(entries []*IndexEntry) *Node {
+	list := NewListNode()
+	list.SetAttribute("style", "unordered")
+	list.SetAttribute("role", "index")
+	for _, entry := range entries {
+		item := NewListItemNode()
+		item.AddChild(NewTextNode(entry.Term))
+		seen := make(map[string]bool)
+		for i, ref := range entry.Refs {
+			text := ref.Section
+			if text == "" {
+				text = strconv.Itoa(i + 1)
+			}
+			if seen[text] {
+				continue
+			}
+			seen[text] = true
+			item.AddChild(NewTextNode(", "))
+			link := NewLinkNode()
+			link.SetAttribute("href", "#"+ref.ID)
+			link.AddChild(NewTextNode(text))
+			item.AddChild(link)
+		}
+		if len(entry.Subterms) > 0 {
+			item.AddChild(indexList(entry.Subterms))
+		}
+		list.AddChild(item)
+	}
+	return list
+}
@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const indexInput = `= Manual
+
+== Knights
+
+The ((Arthur)) legend (((Knights, Round Table))) is indexterm2:[Lancelot] famous.
+indexterm:[Knights, Errant] See <<gof>>, <<taoup>> and \((not)).
+
+== Dragons
+
+((Arthur)) again, with an ((apple)).
+
+[bibliography]
+== References
+
+* [[[taoup]]] Eric Raymond. The Art of Unix Programming.
+* [[[gof,GoF]]] Gamma et al. Design Patterns.
+
+[index]
+== Index
+`
+
+func TestParse_IndexTerms(t *testing.T) {
+	doc, err := Parse(strings.NewReader(indexInput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var terms []string
+	doc.Traverse(func(n *Node) {
+		if n.Type == IndexTerm {
+			terms = append(terms, n.GetAttribute("id")+":"+n.GetAttribute("primary")+"/"+n.GetAttribute("secondary")+"="+getTextContent(n))
+		}
+	})
+	want := []string{
+		"_indexterm_1:Arthur/=Arthur",
+		"_indexterm_2:Knights/Round Table=",
+		"_indexterm_3:Lancelot/=Lancelot",
+		"_indexterm_4:Knights/Errant=",
+		"_indexterm_5:Arthur/=Arthur",
+		"_indexterm_6:apple/=apple",
+	}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("Expected index terms %q, got %q", want, terms)
+	}
+	if para := findNode(doc, Paragraph); !strings.Contains(getTextContent(para), "The Arthur legend  is Lancelot famous.") {
+		t.Errorf("Expected concealed terms to be hidden, got %q", getTextContent(para))
+	}
+}
+
+func TestIndex(t *testing.T) {
+	doc, _ := Parse(strings.NewReader(indexInput))
+	groups := Index(doc)
+	var letters []string
+	for _, group := range groups {
+		letters = append(letters, group.Letter)
+	}
+	if !reflect.DeepEqual(letters, []string{"A", "K", "L"}) {
+		t.Fatalf("Expected groups A, K and L, got %q", letters)
+	}
+	arthur := groups[0].Entries[1]
+	if groups[0].Entries[0].Term != "apple" || arthur.Term != "Arthur" {
+		t.Errorf("Expected apple before Arthur, got %v", groups[0].Entries)
+	}
+	wantRefs := []IndexRef{{ID: "_indexterm_1", Section: "Knights"}, {ID: "_indexterm_5", Section: "Dragons"}}
+	if !reflect.DeepEqual(arthur.Refs, wantRefs) {
+		t.Errorf("Expected refs %v, got %v", wantRefs, arthur.Refs)
+	}
+	knights := groups[1].Entries[0]
+	if len(knights.Refs) != 0 || len(knights.Subterms) != 2 || knights.Subterms[0].Term != "Errant" {
+		t.Errorf("Expected Knights to hold Errant and Round Table, got %+v", knights)
+	}
+}
+
+func TestConvert_IndexAndBibliography(t *testing.T) {
+	result, err := Convert(strings.NewReader(indexInput), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	for _, want := range []string{
+		`<span data-role="indexterm" id="_indexterm_1">Arthur</span>`,
+		`<a data-role="indexterm" id="_indexterm_2"></a>`,
+		`See <a href="#gof">[GoF]</a>, <a href="#taoup">[taoup]</a> and ((not)).`,
+		`<li><a data-role="bibliography-anchor" id="gof"></a>[GoF] Gamma et al. Design Patterns.</li>`,
+		`<h3 data-asciidoc-discrete="true">K</h3>`,
+		`<li>Arthur, <a href="#_indexterm_1">Knights</a>, <a href="#_indexterm_5">Dragons</a></li>`,
+		`<li>Round Table, <a href="#_indexterm_2">Knights</a></li>`,
+	} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, result.HTML)
+		}
+	}
+
+	doc, _ := Parse(strings.NewReader(indexInput))
+	xml := ToXML(doc)
+	for _, want := range []string{
+		`<indexterm id="_indexterm_4" primary="Knights" secondary="Errant"/>`,
+		`<bibliographyanchor id="taoup" label="taoup"/>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("Expected %q in XML output:\n%s", want, xml)
+		}
+	}
+}
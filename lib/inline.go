@@ -0,0 +1,942 @@
+package lib
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// parseInlineContent parses inline markup in text and appends the result to
+// parent. src maps offsets in text back to source positions; it may be nil.
+//
+// The text is scanned once from left to right. At each character that can
+// start markup the scanner tries the forms that start with it, takes the
+// first that matches and parses the marked-up range of the same text
+// recursively, so nested markup and source positions need no re-slicing.
+func (p *parser) parseInlineContent(parent *Node, text string, src *inlineSource) {
+	p.parseInlineSubs(parent, text, src, normalSubs, false)
+}
+
+// parseInlineSubs parses text like parseInlineContent, applying only the
+// substitutions in subs. With hardbreaks set, every line break in text is
+// a hard line break; otherwise only those after " +" are.
+func (p *parser) parseInlineSubs(parent *Node, text string, src *inlineSource, subs substitutions, hardbreaks bool) {
+	s := &inlineScanner{p: p, text: text, src: src, subs: subs, hardbreaks: hardbreaks}
+	s.parse(parent, 0, len(text))
+}
+
+// inlineScanner holds the state of one parseInlineContent call
+type inlineScanner struct {
+	p          *parser
+	text       string
+	src        *inlineSource
+	subs       substitutions              // Substitutions to apply
+	hardbreaks bool                       // Every line break is a hard line break
+	attrs      map[string]string          // Attributes for substitution, loaded on first use
+	dry        int                        // Non-zero while checking whether escaped markup would match
+	depth      int                        // Nesting of the formatting being parsed
+	misses     map[closerKey]int          // Smallest offset a closer search failed from
+	searches   map[searchKey]searchResult // Last result of each search for a closing delimiter
+	steps      int                        // Characters read, for checking the context now and then
+}
+
+// closerKey identifies a search for closing marks ending at end
+type closerKey struct {
+	mark        byte
+	constrained bool
+	end         int
+}
+
+// searchKey identifies a search for a closing delimiter ending at end. The
+// name is the delimiter for a plain search, or describes the search.
+type searchKey struct {
+	name string
+	end  int
+}
+
+// searchResult is where a search started and the offset it found, or -1
+type searchResult struct {
+	from, found int
+}
+
+// haltInterval is how many characters the inline scanner reads between
+// checks of the parse's context
+const haltInterval = 4096
+
+// quoteTypes maps quote marks to the node types they produce
+var quoteTypes = map[byte]NodeType{
+	'*': Bold,
+	'_': Italic,
+	'`': Monospace,
+	'#': Highlight,
+	'^': Superscript,
+	'~': Subscript,
+}
+
+// newQuoteNode returns an empty node for a quote mark's type
+func newQuoteNode(typ NodeType) *Node {
+	switch typ {
+	case Bold:
+		return NewBoldNode()
+	case Italic:
+		return NewItalicNode()
+	case Monospace:
+		return NewMonospaceNode()
+	case Superscript:
+		return NewSuperscriptNode()
+	case Subscript:
+		return NewSubscriptNode()
+	default:
+		return NewHighlightNode()
+	}
+}
+
+// pendingText is text between markup that has not been added yet. Bytes
+// before raw have already been substituted into lit; escapes leave out a
+// backslash or keep an attribute reference as written that way.
+type pendingText struct {
+	start int
+	raw   int
+	lit   strings.Builder
+}
+
+// parse appends the nodes for text[start:end] to parent. Nested deeper than
+// the parse allows, the text is kept as it is and the parse stops.
+func (s *inlineScanner) parse(parent *Node, start, end int) {
+	t := &pendingText{start: start, raw: start}
+	limits := s.p.limits
+	if s.depth > limits.maxInlineDepth {
+		if s.dry == 0 {
+			s.p.stop(s.src.position(start), "MaxInlineDepth", limits.maxInlineDepth, nil)
+		}
+		s.flush(parent, t, end)
+		return
+	}
+	s.depth++
+	defer func() { s.depth-- }()
+	if s.dry == 0 {
+		counted := len(parent.Children)
+		defer func() {
+			if s.p.addNodes(len(parent.Children) - counted) {
+				s.p.stop(s.src.position(start), "MaxNodes", limits.maxNodes, nil)
+			}
+		}()
+	}
+
+	i := start
+	for i < end && !s.halted(i) {
+		c := s.text[i]
+		if c == '\\' {
+			i = s.escape(t, i, start, end)
+			continue
+		}
+		if c == '\n' && s.subs&subPostReplacements != 0 {
+			i = s.lineBreak(parent, &t, i)
+			continue
+		}
+		var node *Node
+		next := 0
+		if _, ok := quoteTypes[c]; ok || c == '+' || c == '[' || c == '<' || c == '(' || isASCIILetter(c) {
+			node, next = s.match(i, start, end)
+		}
+		if node == nil {
+			i++
+			continue
+		}
+		s.flush(parent, t, i)
+		s.src.span(node, i, next)
+		parent.AddChild(node)
+		i = next
+		t = &pendingText{start: i, raw: i}
+	}
+	s.flush(parent, t, end)
+}
+
+// halted reports whether the parse's context is done, checking it every
+// haltInterval characters, since a long line can take a while to scan. The
+// rest of the text is then kept as it is.
+func (s *inlineScanner) halted(i int) bool {
+	limits := s.p.limits
+	if limits.err == nil {
+		if s.steps++; s.steps%haltInterval != 0 {
+			return false
+		}
+		if err := limits.ctx.Err(); err != nil {
+			s.p.stop(s.src.position(i), "Context", 0, err)
+		}
+	}
+	return limits.err != nil && limits.err.Err != nil
+}
+
+// lineBreak handles the line break at i and returns where scanning resumes.
+// A hard line break, after " +" or in a paragraph with hard breaks, ends the
+// pending text and adds a LineBreak node; any other is kept.
+func (s *inlineScanner) lineBreak(parent *Node, t **pendingText, i int) int {
+	cut := i
+	if i-2 >= (*t).raw && s.text[i-2:i] == " +" {
+		cut = i - 2
+	} else if !s.hardbreaks {
+		return i + 1
+	}
+	s.flush(parent, *t, cut)
+	br := NewLineBreakNode()
+	s.src.span(br, cut, i)
+	parent.AddChild(br)
+	*t = &pendingText{start: i + 1, raw: i + 1}
+	return i + 1
+}
+
+// escape handles the backslash at i and returns where scanning resumes. A
+// backslash before an attribute reference keeps the reference as written; one
+// before markup that would otherwise match makes that markup plain text.
+// Any other backslash is kept.
+func (s *inlineScanner) escape(t *pendingText, i, start, end int) int {
+	if i+1 >= end {
+		return i + 1
+	}
+	if s.text[i+1] == '{' && s.subs&subAttributes != 0 {
+		if loc := attributeReferenceRegex.FindStringIndex(s.text[i+1 : end]); loc != nil && loc[0] == 0 {
+			next := i + 1 + loc[1]
+			t.lit.WriteString(s.subst(t.raw, i))
+			t.lit.WriteString(s.text[i+1 : next])
+			t.raw = next
+			return next
+		}
+		return i + 1
+	}
+	if s.subs&subReplacements != 0 {
+		if n := escapedReplacement(s.text[:end], i); n > 0 {
+			t.lit.WriteString(s.subst(t.raw, i))
+			t.lit.WriteString(s.text[i+1 : i+1+n])
+			t.raw = i + 1 + n
+			return t.raw
+		}
+	}
+	s.dry++
+	node, next := s.match(i+1, start, end)
+	s.dry--
+	if node == nil {
+		return i + 1
+	}
+	t.lit.WriteString(s.subst(t.raw, i))
+	t.raw = i + 1
+	return next
+}
+
+// flush adds the pending text before end to parent
+func (s *inlineScanner) flush(parent *Node, t *pendingText, end int) {
+	if end <= t.start {
+		return
+	}
+	content := s.subst(t.raw, end)
+	if t.lit.Len() > 0 {
+		content = t.lit.String() + content
+	}
+	// Without special characters the text is passed through as markup
+	textNode := NewTextNode(content)
+	if s.subs&subSpecialChars == 0 {
+		textNode = NewPassthroughNode(content)
+	}
+	s.src.span(textNode, t.start, end)
+	parent.AddChild(textNode)
+}
+
+// subst replaces attribute references in s.text[start:end], then applies
+// the typographic replacements, as far as the scanner's substitutions
+// include them. References to undefined attributes follow the
+// attribute-missing policy, except that drop-line only drops the reference.
+// Text is left alone while checking an escape so that counters don't step
+// twice.
+func (s *inlineScanner) subst(start, end int) string {
+	text := s.text[start:end]
+	if s.dry > 0 {
+		return text
+	}
+	if s.subs&subAttributes != 0 && strings.IndexByte(text, '{') >= 0 {
+		text = s.substAttributes(text, start)
+	}
+	if s.subs&subReplacements != 0 {
+		text = replaceTypography(text)
+	}
+	return text
+}
+
+// substAttributes replaces the attribute references in text, which starts
+// at offset start. Once parsing stops, at a limit or because the context is
+// done, the remaining references are left as written.
+func (s *inlineScanner) substAttributes(text string, start int) string {
+	if s.attrs == nil {
+		s.attrs = s.p.getAllAttributes()
+	}
+	return replaceAttributeReferences(text, func(ref attributeReference, off int) string {
+		limits := s.p.limits
+		if limits.err == nil {
+			if err := limits.ctx.Err(); err != nil {
+				s.p.stop(s.src.position(start+off), "Context", 0, err)
+			}
+		}
+		if limits.err != nil {
+			return ref.text
+		}
+		if value, ok := s.p.resolveReference(ref, s.attrs); ok {
+			if s.p.addExpansion(ref.text, value) {
+				s.p.stop(s.src.position(start+off), "MaxAttributeExpansion", s.p.limits.maxExpansion, nil)
+				return ref.text
+			}
+			return value
+		}
+		switch s.attrs["attribute-missing"] {
+		case AttributeMissingDrop, AttributeMissingDropLine:
+			return ""
+		case AttributeMissingWarn:
+			s.p.addDiagnostic(SeverityWarning, CodeMissingAttribute, s.src.position(start+off), s.src.position(start+off+len(ref.text)-1),
+				"reference to missing attribute %q", ref.name)
+		}
+		return ref.text
+	})
+}
+
+// substituteAttributes replaces attribute references in text outside inline
+// passthroughs, keeping escaped references for parseInlineContent. Block
+// text is substituted before it is parsed so that attribute values can
+// supply macro targets, as in {url}[text]. The second result reports a
+// reference that drops the line under the drop-line policy. text is on line
+// idx.
+func (p *parser) substituteAttributes(idx int, text string, attrs map[string]string) (string, bool) {
+	s := &inlineScanner{p: p, text: text}
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(text); i++ {
+		next := 0
+		switch text[i] {
+		case '\\':
+			if i+1 < len(text) && text[i+1] == '{' {
+				if close := strings.IndexByte(text[i+2:], '}'); close > 0 {
+					next = i + 3 + close
+				}
+			}
+		case '+', 'p':
+			_, next = s.passthrough(i, 0, len(text))
+		}
+		if next > 0 {
+			expanded, drop := p.expandAttributes(idx, text[last:i], attrs, true)
+			if drop {
+				return "", true
+			}
+			b.WriteString(expanded)
+			b.WriteString(text[i:next])
+			last = next
+			i = next - 1
+		}
+	}
+	expanded, drop := p.expandAttributes(idx, text[last:], attrs, true)
+	if drop {
+		return "", true
+	}
+	b.WriteString(expanded)
+	return b.String(), false
+}
+
+// register records an inline ID unless the scanner is only checking an escape
+func (s *inlineScanner) register(id string, node *Node, start, end int) {
+	if s.dry == 0 {
+		s.p.registerAnchor(id, node, s.src.position(start), s.src.lastPosition(start, end))
+	}
+}
+
+// match returns the markup starting at i and the offset after it, or nil
+func (s *inlineScanner) match(i, start, end int) (*Node, int) {
+	macros, quotes := s.subs&subMacros != 0, s.subs&subQuotes != 0
+	switch c := s.text[i]; {
+	case c == '+':
+		if !macros {
+			break
+		}
+		if content, next := s.passthrough(i, start, end); next > 0 {
+			return NewPassthroughNode(content), next
+		}
+	case c == '[':
+		return s.matchBracket(i, start, end)
+	case c == '<':
+		if macros {
+			return s.matchXref(i, end)
+		}
+	case c == '(':
+		if macros {
+			return s.matchIndexTerm(i, end)
+		}
+	default:
+		if _, ok := quoteTypes[c]; ok {
+			if quotes {
+				return s.matchQuote(i, i, start, end)
+			}
+			break
+		}
+		if macros && s.boundaryBefore(i, start) {
+			return s.matchMacro(i, start, end)
+		}
+	}
+	return nil, 0
+}
+
+// matchQuote matches quoted text whose mark is at m. The constrained form
+// must not follow a word character; b is where the quote begins, which is
+// before m when the quote has an attribute list.
+func (s *inlineScanner) matchQuote(m, b, start, end int) (*Node, int) {
+	mark := s.text[m]
+	typ, ok := quoteTypes[mark]
+	if !ok {
+		return nil, 0
+	}
+
+	// Superscript and subscript are unconstrained and cannot contain spaces
+	if mark == '^' || mark == '~' {
+		j := m + 1
+		for j < end && s.text[j] != mark && !isASCIISpace(s.text[j]) {
+			j++
+		}
+		if j == m+1 || j >= end || s.text[j] != mark {
+			return nil, 0
+		}
+		node := newQuoteNode(typ)
+		s.parse(node, m+1, j)
+		return node, j + 1
+	}
+
+	// Unconstrained: **text** anywhere, even within a word
+	if m+2 < end && s.text[m+1] == mark {
+		if close := s.findUnconstrained(mark, m+2, end); close >= 0 {
+			node := newQuoteNode(typ)
+			s.parse(node, m+2, close)
+			return node, close + 2
+		}
+	}
+
+	// Constrained: *text* bounded by non-word characters
+	if b > start {
+		prev, _ := utf8.DecodeLastRuneInString(s.text[start:b])
+		if isWordRune(prev) || strings.ContainsRune(";:}", prev) || (mark == '`' && strings.ContainsRune("\"'`", prev)) {
+			return nil, 0
+		}
+	}
+	if m+1 >= end {
+		return nil, 0
+	}
+	if first, _ := utf8.DecodeRuneInString(s.text[m+1 : end]); unicode.IsSpace(first) {
+		return nil, 0
+	}
+	close := s.findConstrained(mark, m+1, end)
+	if close < 0 {
+		return nil, 0
+	}
+	node := newQuoteNode(typ)
+	s.parse(node, m+1, close)
+	return node, close + 1
+}
+
+// findUnconstrained returns the offset of the first doubled mark after
+// from that closes an unconstrained quote, or -1
+func (s *inlineScanner) findUnconstrained(mark byte, from, end int) int {
+	key := closerKey{mark: mark, end: end}
+	if miss, ok := s.misses[key]; ok && from >= miss {
+		return -1
+	}
+	for j := from + 1; j+1 < end; j++ {
+		switch c := s.text[j]; {
+		case c == '\\':
+			j++
+		case c == mark && s.text[j+1] == mark:
+			return j
+		case c == '+' || c == 'p':
+			if _, next := s.passthrough(j, from, end); next > 0 {
+				j = next - 1
+			}
+		}
+	}
+	s.miss(key, from)
+	return -1
+}
+
+// findConstrained returns the offset of the first mark after from that
+// closes a constrained quote: it follows a non-space character and is not
+// followed by a word character. Doubled marks close nested unconstrained
+// quotes, so those pairs are skipped.
+func (s *inlineScanner) findConstrained(mark byte, from, end int) int {
+	key := closerKey{mark: mark, constrained: true, end: end}
+	if miss, ok := s.misses[key]; ok && from >= miss {
+		return -1
+	}
+	for j := from; j < end; j++ {
+		switch c := s.text[j]; {
+		case c == '\\':
+			j++
+		case c == mark:
+			if j+1 < end && s.text[j+1] == mark {
+				if close := s.findUnconstrained(mark, j+2, end); close >= 0 {
+					j = close + 1
+					continue
+				}
+			}
+			if j > from && s.closesConstrained(mark, j, end) {
+				return j
+			}
+		case c == '+' || c == 'p':
+			if _, next := s.passthrough(j, from, end); next > 0 {
+				j = next - 1
+			}
+		}
+	}
+	s.miss(key, from)
+	return -1
+}
+
+// closesConstrained reports whether the mark at j can close a constrained quote
+func (s *inlineScanner) closesConstrained(mark byte, j, end int) bool {
+	prev, _ := utf8.DecodeLastRuneInString(s.text[:j])
+	if unicode.IsSpace(prev) {
+		return false
+	}
+	if j+1 >= end {
+		return true
+	}
+	next, _ := utf8.DecodeRuneInString(s.text[j+1 : end])
+	return !isWordRune(next) && !(mark == '`' && strings.ContainsRune("\"'`", next))
+}
+
+// miss records that a closer search from offset from found nothing. A search
+// starting later in the same range cannot succeed either, which keeps text
+// with many unmatched marks linear.
+func (s *inlineScanner) miss(key closerKey, from int) {
+	if s.misses == nil {
+		s.misses = make(map[closerKey]int)
+	}
+	if miss, ok := s.misses[key]; !ok || from < miss {
+		s.misses[key] = from
+	}
+}
+
+// search returns the offset find returns for a search from offset from that
+// ends at end. find must return the first offset at or after from that
+// passes a test of its own, or -1, so that a search starting between an
+// earlier one and what it found finds the same, and one starting after a
+// failed search fails too. Remembering the last result of each search keeps
+// text with many unmatched openers linear.
+func (s *inlineScanner) search(name string, from, end int, find func() int) int {
+	key := searchKey{name: name, end: end}
+	if last, ok := s.searches[key]; ok && from >= last.from && (last.found < 0 || from <= last.found) {
+		return last.found
+	}
+	found := find()
+	if s.searches == nil {
+		s.searches = make(map[searchKey]searchResult)
+	}
+	s.searches[key] = searchResult{from: from, found: found}
+	return found
+}
+
+// index returns the offset of the first closer in text[from:end], or -1
+func (s *inlineScanner) index(closer string, from, end int) int {
+	return s.search(closer, from, end, func() int {
+		if i := strings.Index(s.text[from:end], closer); i >= 0 {
+			return from + i
+		}
+		return -1
+	})
+}
+
+// passthrough matches the inline passthrough starting at i: +++text+++,
+// ++text++, a constrained +text+ or pass:[text]. It returns the content and
+// the offset after the passthrough, or a zero offset if there is none.
+func (s *inlineScanner) passthrough(i, start, end int) (string, int) {
+	rest := s.text[i:end]
+	switch {
+	case strings.HasPrefix(rest, "pass:"):
+		if i > start && isWordByte(s.text[i-1]) {
+			return "", 0
+		}
+		open := i + len("pass:")
+		for open < end && (isWordByte(s.text[open]) || s.text[open] == ',') {
+			open++
+		}
+		if open >= end || s.text[open] != '[' {
+			return "", 0
+		}
+		close := s.findBracketClose(open+1, end)
+		if close < 0 {
+			return "", 0
+		}
+		return strings.ReplaceAll(s.text[open+1:close], `\]`, "]"), close + 1
+	case strings.HasPrefix(rest, "+++"):
+		if close := s.index("+++", i+3, end); close >= 0 {
+			return s.text[i+3 : close], close + 3
+		}
+	}
+	if strings.HasPrefix(rest, "++") {
+		if close := s.index("++", i+2, end); close > i+2 {
+			return s.text[i+2 : close], close + 2
+		}
+	}
+	if !strings.HasPrefix(rest, "+") || len(rest) < 3 || isASCIISpace(rest[1]) {
+		return "", 0
+	}
+	if i > start {
+		prev, _ := utf8.DecodeLastRuneInString(s.text[start:i])
+		if isWordRune(prev) || strings.ContainsRune(";:}", prev) {
+			return "", 0
+		}
+	}
+	close := s.search("constrained +", i+2, end, func() int {
+		for j := i + 2; j < end; j++ {
+			if s.text[j] == '+' && s.closesConstrained('+', j, end) {
+				return j
+			}
+		}
+		return -1
+	})
+	if close < 0 {
+		return "", 0
+	}
+	return s.text[i+1 : close], close + 1
+}
+
+// matchBracket matches an inline anchor, [[id]] or [#id], a bibliography
+// anchor, [[[id]]] or [[[id,label]]], or quoted text with an attribute list
+// such as [.role]#text#
+func (s *inlineScanner) matchBracket(i, start, end int) (*Node, int) {
+	if strings.HasPrefix(s.text[i:end], "[[[") && s.subs&subMacros != 0 {
+		if close := s.index("]]]", i+3, end) - (i + 3); close > 0 {
+			id, label := s.text[i+3:i+3+close], ""
+			if comma := strings.IndexByte(id, ','); comma >= 0 {
+				id, label = strings.TrimSpace(id[:comma]), strings.TrimSpace(id[comma+1:])
+			}
+			if id != "" && !strings.ContainsAny(id, " \t\n[]") {
+				next := i + 6 + close
+				anchor := NewBibliographyAnchorNode(id, label)
+				s.register(id, anchor, i, next)
+				return anchor, next
+			}
+		}
+	}
+	if strings.HasPrefix(s.text[i:end], "[[") {
+		if s.subs&subMacros == 0 {
+			return nil, 0
+		}
+		close := s.index("]]", i+2, end) - (i + 2)
+		if close <= 0 {
+			return nil, 0
+		}
+		id, reftext := splitAnchor(s.text[i+2 : i+2+close])
+		if id == "" || strings.ContainsAny(id, " \t\n[]") {
+			return nil, 0
+		}
+		next := i + 4 + close
+		anchor := NewInlineMacroNode("anchor")
+		anchor.SetAttribute("id", id)
+		if reftext != "" {
+			anchor.SetAttribute("reftext", reftext)
+		}
+		s.register(id, anchor, i, next)
+		return anchor, next
+	}
+
+	close := s.search("]\n", i+1, end, func() int {
+		if j := strings.IndexAny(s.text[i+1:end], "]\n"); j >= 0 {
+			return i + 1 + j
+		}
+		return -1
+	})
+	if close <= i+1 || s.text[close] != ']' {
+		return nil, 0
+	}
+	list := s.text[i+1 : close]
+
+	if close+1 < end && s.subs&subQuotes != 0 {
+		if _, ok := quoteTypes[s.text[close+1]]; ok {
+			if attrs, err := ParseAttributeList(list); err == nil {
+				if node, next := s.matchQuote(close+1, i, start, end); node != nil {
+					// A style on quoted text is a role, as in [underline]#text#
+					if attrs.Style != "" {
+						attrs.Roles = append([]string{attrs.Style}, attrs.Roles...)
+					}
+					attrs.applyTo(node)
+					if attrs.ID != "" {
+						s.register(attrs.ID, node, i, close+1)
+					}
+					return node, next
+				}
+			}
+		}
+	}
+
+	if strings.HasPrefix(list, "#") && len(list) > 1 && s.subs&subMacros != 0 {
+		anchor := NewInlineMacroNode("anchor")
+		anchor.SetAttribute("id", list[1:])
+		s.register(list[1:], anchor, i, close+1)
+		return anchor, close + 1
+	}
+	return nil, 0
+}
+
+// matchXref matches a cross reference, <<id>> or <<id,text>>
+func (s *inlineScanner) matchXref(i, end int) (*Node, int) {
+	if !strings.HasPrefix(s.text[i:end], "<<") {
+		return nil, 0
+	}
+	close := s.index(">>", i+2, end) - (i + 2)
+	if close <= 0 {
+		return nil, 0
+	}
+	inner := s.text[i+2 : i+2+close]
+	if first, _ := utf8.DecodeRuneInString(inner); !isWordRune(first) && first != '"' {
+		return nil, 0
+	}
+	if strings.ContainsAny(inner, "<>\n") {
+		return nil, 0
+	}
+	target, text := inner, ""
+	if comma := strings.IndexByte(inner, ','); comma >= 0 {
+		target = strings.TrimSpace(inner[:comma])
+		text = strings.TrimSpace(inner[comma+1:])
+	}
+	return xrefNode(target, text), i + 4 + close
+}
+
+// matchMacro matches an inline macro starting at i: a URL, link:, xref:,
+// image:, icon:, stem:, footnote:, footnoteref:, pass: or any other
+// name:target[text]
+func (s *inlineScanner) matchMacro(i, start, end int) (*Node, int) {
+	j := i
+	for j < end && isWordByte(s.text[j]) {
+		j++
+	}
+	// A double colon is a block macro written inline, not an inline macro
+	if j >= end || s.text[j] != ':' || (j+1 < end && s.text[j+1] == ':') {
+		return nil, 0
+	}
+	name := s.text[i:j]
+
+	switch name {
+	case "http", "https":
+		if !strings.HasPrefix(s.text[j:end], "://") {
+			return nil, 0
+		}
+		return s.matchURL(i, j+3, end)
+	case "pass":
+		if content, next := s.passthrough(i, start, end); next > 0 {
+			return NewPassthroughNode(content), next
+		}
+		return nil, 0
+	}
+
+	k := j + 1
+	for k < end && s.text[k] != '[' && s.text[k] != ']' && !isASCIISpace(s.text[k]) {
+		k++
+	}
+	target := s.text[j+1 : k]
+	close := -1
+	if k < end && s.text[k] == '[' {
+		close = s.findBracketClose(k+1, end)
+	}
+	if close < 0 {
+		// link: and xref: may leave out the brackets
+		if target == "" || (name != "link" && name != "xref") {
+			return nil, 0
+		}
+		if name == "link" {
+			return s.linkNode(target, k, k), k
+		}
+		return xrefNode(target, ""), k
+	}
+	next := close + 1
+
+	switch name {
+	case "link":
+		if target == "" {
+			return nil, 0
+		}
+		return s.linkNode(target, k+1, close), next
+	case "xref":
+		if target == "" {
+			return nil, 0
+		}
+		return xrefNode(target, strings.TrimSpace(s.text[k+1:close])), next
+	case "footnote":
+		footnote := NewInlineMacroNode("footnote")
+		if target != "" {
+			footnote.SetAttribute("ref", target)
+		}
+		s.parse(footnote, k+1, close)
+		return footnote, next
+	case "image":
+		if target == "" {
+			return nil, 0
+		}
+		return imageMacro(target, strings.ReplaceAll(s.text[k+1:close], `\]`, "]")), next
+	case "icon":
+		if target == "" {
+			return nil, 0
+		}
+		return s.p.iconMacro(target, s.text[k+1:close]), next
+	case "stem", "latexmath", "asciimath":
+		if target != "" {
+			return nil, 0
+		}
+		return s.p.stemMacro(name, s.text[k+1:close]), next
+	case "indexterm":
+		term := concealedIndexTerm(s.text[k+1 : close])
+		if target != "" || term == nil {
+			return nil, 0
+		}
+		return term, next
+	case "indexterm2":
+		if target != "" || strings.TrimSpace(s.text[k+1:close]) == "" {
+			return nil, 0
+		}
+		return s.flowIndexTerm(k+1, close), next
+	case "footnoteref":
+		ref, text := target, s.text[k+1:close]
+		if ref == "" {
+			// footnoteref:[id,text]
+			ref, text = text, ""
+			if comma := strings.IndexByte(ref, ','); comma >= 0 {
+				ref, text = strings.TrimSpace(ref[:comma]), strings.TrimSpace(ref[comma+1:])
+			}
+		}
+		if ref == "" {
+			return nil, 0
+		}
+		if text == "" {
+			text = ref
+		}
+		footnoteref := NewInlineMacroNode("footnoteref")
+		footnoteref.SetAttribute("ref", ref)
+		footnoteref.AddChild(NewTextNode(text))
+		return footnoteref, next
+	}
+
+	text := strings.ReplaceAll(s.text[k+1:close], `\]`, "]")
+	if target == "" && text == "" {
+		return nil, 0
+	}
+	macro := NewInlineMacroNode(name)
+	if target != "" {
+		macro.SetAttribute("target", target)
+	}
+	if strings.Contains(text, "=") {
+		if attrs, err := ParseMacroAttributeList(text); err == nil && len(attrs.Named) > 0 {
+			text = attrs.At(0)
+			attrs.applyTo(macro)
+		}
+	}
+	if text != "" {
+		macro.AddChild(NewTextNode(text))
+	}
+	return macro, next
+}
+
+// matchURL matches a bare URL whose scheme starts at i and whose address
+// starts at from, with optional link text in brackets
+func (s *inlineScanner) matchURL(i, from, end int) (*Node, int) {
+	k := from
+	for k < end && !isASCIISpace(s.text[k]) && !strings.ContainsRune("[]<>\"", rune(s.text[k])) {
+		k++
+	}
+	if k < end && s.text[k] == '[' {
+		if close := s.findBracketClose(k+1, end); close >= 0 && k > from {
+			return s.linkNode(s.text[i:k], k+1, close), close + 1
+		}
+	}
+	// Punctuation ending a sentence is not part of the URL
+	url := s.text[i:k]
+	for len(url) > from-i {
+		last := url[len(url)-1]
+		if !strings.ContainsRune(".,;:!?", rune(last)) && !(last == ')' && !strings.Contains(url, "(")) {
+			break
+		}
+		url = url[:len(url)-1]
+	}
+	if len(url) == from-i {
+		return nil, 0
+	}
+	return s.linkNode(url, i+len(url), i+len(url)), i + len(url)
+}
+
+// linkNode returns a link to href whose text is text[start:end]. The text
+// defaults to href, and may be an attribute list whose first positional
+// attribute is the text.
+func (s *inlineScanner) linkNode(href string, start, end int) *Node {
+	link := NewLinkNode()
+	link.SetAttribute("href", href)
+	text := s.text[start:end]
+	if strings.Contains(text, "=") {
+		if attrs, err := ParseMacroAttributeList(text); err == nil && len(attrs.Named)+len(attrs.Roles) > 0 {
+			attrs.applyTo(link)
+			if text = attrs.At(0); text == "" {
+				text = href
+			}
+			link.AddChild(NewTextNode(text))
+			return link
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		link.AddChild(NewTextNode(href))
+		return link
+	}
+	s.parse(link, start, end)
+	return link
+}
+
+// xrefNode returns a cross reference to target with the given text. Without
+// text, resolveXrefs fills in the title of the target.
+func xrefNode(target, text string) *Node {
+	xref := NewInlineMacroNode("xref")
+	xref.SetAttribute("target", target)
+	if text != "" {
+		xref.AddChild(NewTextNode(text))
+	}
+	return xref
+}
+
+// findBracketClose returns the offset of the ] closing a macro's attribute
+// list that starts at from, skipping \] escapes, or -1. The list follows a
+// [, so a search from an earlier list never skips from as part of an escape.
+func (s *inlineScanner) findBracketClose(from, end int) int {
+	return s.search(`\]`, from, end, func() int {
+		for j := from; j < end; j++ {
+			switch s.text[j] {
+			case '\\':
+				if j+1 < end && s.text[j+1] == ']' {
+					j++
+				}
+			case ']':
+				return j
+			}
+		}
+		return -1
+	})
+}
+
+// boundaryBefore reports whether offset i starts a word within text[start:]
+func (s *inlineScanner) boundaryBefore(i, start int) bool {
+	if i == start {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(s.text[start:i])
+	return !isWordRune(prev)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || isASCIILetter(c) || ('0' <= c && c <= '9')
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isASCIISpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
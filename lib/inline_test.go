@@ -0,0 +1,244 @@
+package lib
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// inlineHTML parses input as a document and renders the inline content of
+// its first paragraph
+func inlineHTML(t *testing.T, input string) string {
+	t.Helper()
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	para := findNode(doc, Paragraph)
+	if para == nil {
+		t.Fatalf("Paragraph not found in %q", input)
+	}
+	var buf bytes.Buffer
+	toHTMLInlineNodes(para.Children, &buf, false, RenderOptions{})
+	return buf.String()
+}
+
+func TestParseInlineContent(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"*bold* and _italic_", "<strong>bold</strong> and <em>italic</em>"},
+		{"**b**old and __it__alic", "<strong>b</strong>old and <em>it</em>alic"},
+		{"__word__", "<em>word</em>"},
+		{"``mono``spaced and `code`", "<code>mono</code>spaced and <code>code</code>"},
+		{"snake_case_words and file_name.go", "snake_case_words and file_name.go"},
+		{"2 * 3 * 4 and a*b*c", "2 * 3 * 4 and a*b*c"},
+		{"*bold _italic_*", "<strong>bold <em>italic</em></strong>"},
+		{"*a **b** c*", "<strong>a <strong>b</strong> c</strong>"},
+		{"_italic *bold* text_", "<em>italic <strong>bold</strong> text</em>"},
+		{"*bold*, _italic_.", "<strong>bold</strong>, <em>italic</em>."},
+		{"E=mc^2^ and H~2~O", `E=mc<sup data-asciidoc="superscript">2</sup> and H<sub data-asciidoc="subscript">2</sub>O`},
+		{"#marked# text", `<mark data-asciidoc="highlight">marked</mark> text`},
+		{"[.underline]#styled# and [#term.key]*bold*", `<mark data-asciidoc="highlight" role="underline">styled</mark> and <strong id="term" role="key">bold</strong>`},
+		{`\*not bold* and \_not italic_`, "*not bold* and _not italic_"},
+		{`\**not strong**`, "**not strong**"},
+		{`a \ backslash`, `a \ backslash`},
+		{`\link:x.html[not a link]`, "link:x.html[not a link]"},
+		{"+*not bold*+ and ++_raw_++", "*not bold* and _raw_"},
+		{"*bold +*+ text*", "<strong>bold * text</strong>"},
+		{"pass:[<u>raw</u>] text", "<u>raw</u> text"},
+		{"`+{name}+`", "<code>{name}</code>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := inlineHTML(t, tt.input); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseInlineContent_Macros(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"See https://example.com.", `See <a href="https://example.com">https://example.com</a>.`},
+		{"(see https://example.com/a_b)", `(see <a href="https://example.com/a_b">https://example.com/a_b</a>)`},
+		{"https://example.com[*Example*]", `<a href="https://example.com"><strong>Example</strong></a>`},
+		{`link:guide.html[Guide, title="The guide"]`, `<a href="guide.html" title="The guide">Guide</a>`},
+		{"<<intro>> and <<intro,the introduction>>", `<a href="#intro">intro</a> and <a href="#intro">the introduction</a>`},
+		{"a << b >> c", "a &lt;&lt; b &gt;&gt; c"},
+		{"Press kbd:[Ctrl+\\]] now", `Press <kbd data-role="keyboard">Ctrl+]</kbd> now`},
+		{"Remember: see [this] and block::macro[]", "Remember: see [this] and block::macro[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := inlineHTML(t, tt.input); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseInlineContent_Attributes(t *testing.T) {
+	input := `:name: World
+:underscored: a_b_c
+
+Hello *{name}*, {underscored} and \{name}.`
+
+	if got, want := inlineHTML(t, input), `Hello <strong>World</strong>, a_b_c and {name}.`; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestParseInlineContent_Anchors(t *testing.T) {
+	input := `[[first]]Inline anchor and [[second,Second]]another, [#third]*bold* and \[[not-an-anchor]].
+
+Reusing [[first]] is a duplicate.`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var ids []string
+	doc.Traverse(func(n *Node) {
+		if id := n.GetAttribute("id"); id != "" && n.Type != Document {
+			ids = append(ids, id)
+		}
+	})
+	if got := strings.Join(ids, ","); got != "first,second,third,first" {
+		t.Errorf("Expected ids first,second,third,first, got %s", got)
+	}
+	diagnostics, err := ValidateWithDiagnostics(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	found := diagnosticsWithCode(diagnostics, CodeDuplicateID)
+	if len(found) != 1 || found[0].Start.Line != 3 {
+		t.Errorf("Expected one duplicate-id diagnostic on line 3, got %v", diagnostics)
+	}
+}
+
+func TestParseInlineContent_UnmatchedMarksAreLinear(t *testing.T) {
+	// Each unmatched opener must not rescan the rest of the paragraph
+	text := strings.Repeat("(*a _b `c #d ", 2000)
+	p := newParser("")
+	p.doc = NewDocumentNode()
+	para := NewParagraphNode()
+	p.parseInlineContent(para, text, nil)
+	if len(para.Children) != 1 || para.Children[0].Content != text {
+		t.Errorf("Expected unmatched marks to stay plain text, got %d nodes", len(para.Children))
+	}
+}
+
+func TestParseInlineContent_UnmatchedOpenersAreLinear(t *testing.T) {
+	// A failed search for a closer must not be repeated from each opener
+	for _, opener := range []string{"pass:[", "footnote:[", "[[", "[[[", "<<", "(((", "+a "} {
+		t.Run(opener, func(t *testing.T) {
+			text := strings.Repeat(opener, 20000)
+			p := newParser("")
+			p.doc = NewDocumentNode()
+			para := NewParagraphNode()
+			p.parseInlineContent(para, text, nil)
+			if len(para.Children) != 1 || para.Children[0].Content != text {
+				t.Errorf("Expected unmatched openers to stay plain text, got %d nodes", len(para.Children))
+			}
+		})
+	}
+}
+
+// loadTestbedCorpus converts the Markdown files of the testbed to AsciiDoc
+func loadTestbedCorpus(b *testing.B) []string {
+	b.Helper()
+	var docs []string
+	for _, pattern := range []string{"../testbed/*.md", "../testbed/*/*.md"} {
+		files, _ := filepath.Glob(pattern)
+		for _, f := range files {
+			if filepath.Base(f) == "README.md" || strings.Contains(f, "corrupt") {
+				continue
+			}
+			content, err := os.ReadFile(f)
+			if err != nil {
+				b.Fatal(err)
+			}
+			adoc, err := ConvertMarkdownToAsciiDoc(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			docs = append(docs, adoc)
+		}
+	}
+	if len(docs) == 0 {
+		b.Skip("testbed corpus not found")
+	}
+	return docs
+}
+
+func BenchmarkParse_Testbed(b *testing.B) {
+	docs := loadTestbedCorpus(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, doc := range docs {
+			if _, err := Parse(strings.NewReader(doc)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkParseInlineContent_Testbed(b *testing.B) {
+	var lines []string
+	for _, doc := range loadTestbedCorpus(b) {
+		for _, line := range strings.Split(doc, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	p := newParser("")
+	p.doc = NewDocumentNode()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			p.parseInlineContent(NewParagraphNode(), line, nil)
+		}
+	}
+}
+
+func BenchmarkParseInlineContent_LongParagraph(b *testing.B) {
+	sentence := "Some *bold* and _italic_ text with `code`, a link:https://example.com[link] and snake_case_words. "
+	for _, n := range []int{10, 100, 1000} {
+		text := strings.Repeat(sentence, n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			p := newParser("")
+			p.doc = NewDocumentNode()
+			b.SetBytes(int64(len(text)))
+			for i := 0; i < b.N; i++ {
+				p.parseInlineContent(NewParagraphNode(), text, nil)
+			}
+		})
+	}
+}
+
+func BenchmarkParseInlineContent_UnmatchedOpeners(b *testing.B) {
+	for _, opener := range []string{"pass:[", "**[", "link:a[", "http://x[", "[[", "<<", "(((", "+a "} {
+		for _, n := range []int{1000, 10000} {
+			text := strings.Repeat(opener, n)
+			b.Run(opener+"/"+strconv.Itoa(n), func(b *testing.B) {
+				p := newParser("")
+				p.doc = NewDocumentNode()
+				b.SetBytes(int64(len(text)))
+				for i := 0; i < b.N; i++ {
+					p.parseInlineContent(NewParagraphNode(), text, nil)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package lib
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// intrinsicAttributes are always defined. Most stand for characters that
+// are awkward to write directly or would otherwise be read as markup.
+var intrinsicAttributes = map[string]string{
+	"blank":          "",
+	"empty":          "",
+	"sp":             " ",
+	"nbsp":           "\u00a0",
+	"zwsp":           "\u200b",
+	"wj":             "\u2060",
+	"apos":           "'",
+	"quot":           `"`,
+	"lsquo":          "‘",
+	"rsquo":          "’",
+	"ldquo":          "“",
+	"rdquo":          "”",
+	"deg":            "°",
+	"plus":           "+",
+	"brvbar":         "¦",
+	"vbar":           "|",
+	"amp":            "&",
+	"lt":             "<",
+	"gt":             ">",
+	"startsb":        "[",
+	"endsb":          "]",
+	"caret":          "^",
+	"asterisk":       "*",
+	"tilde":          "~",
+	"backslash":      `\`,
+	"backtick":       "`",
+	"two-colons":     "::",
+	"two-semicolons": ";;",
+	"cpp":            "C++",
+	"pp":             "++",
+}
+
+// defaultAttributes are the attributes set at the start of every document,
+// which can be overridden or unset like any other
+var defaultAttributes = map[string]string{
+	"attribute-missing": AttributeMissingSkip,
+	"appendix-caption":  "Appendix",
+	"example-caption":   "Example",
+	"figure-caption":    "Figure",
+	"listing-caption":   "Listing",
+	"table-caption":     "Table",
+	"section-refsig":    "Section",
+	"sectids":           "",
+	"idprefix":          "",
+	"idseparator":       "_",
+	"toclevels":         "2",
+}
+
+// Values of the attribute-missing attribute, which decides what happens to
+// a reference to an undefined attribute
+const (
+	AttributeMissingSkip     = "skip"      // Leave the reference as written (the default)
+	AttributeMissingDrop     = "drop"      // Remove the reference
+	AttributeMissingDropLine = "drop-line" // Remove the line holding the reference
+	AttributeMissingWarn     = "warn"      // Leave the reference and report a warning
+)
+
+// lookupAttribute returns the value of the attribute name in attrs or, if
+// attrs doesn't define it, of the intrinsic attribute
+func lookupAttribute(attrs map[string]string, name string) (string, bool) {
+	if value, ok := attrs[name]; ok {
+		return value, true
+	}
+	value, ok := intrinsicAttributes[name]
+	return value, ok
+}
+
+// stepCounter advances the counter name in attrs and returns its new value.
+// A counter starts at initial, or 1 without one, and then counts up: 1, 2,
+// 3 ... or A, B, C ... after a letter.
+func stepCounter(attrs map[string]string, name, initial string) string {
+	value, ok := attrs[name]
+	switch {
+	case !ok:
+		value = initial
+		if value == "" {
+			value = "1"
+		}
+	default:
+		if n, err := strconv.Atoi(value); err == nil {
+			value = strconv.Itoa(n + 1)
+		} else {
+			value = nextLetters(value)
+		}
+	}
+	attrs[name] = value
+	return value
+}
+
+// nextLetters returns the letters that follow s, carrying like a number: A
+// is followed by B, Z by AA and az by ba. Characters other than ASCII letters
+// are left as they are.
+func nextLetters(s string) string {
+	b := []byte(s)
+	for i := len(b) - 1; i >= 0; i-- {
+		switch {
+		case b[i] == 'z' || b[i] == 'Z':
+			b[i] -= 'z' - 'a'
+		case isASCIILetter(b[i]):
+			b[i]++
+			return string(b)
+		default:
+			return string(b)
+		}
+	}
+	if len(b) == 0 || !isASCIILetter(b[0]) {
+		return string(b)
+	}
+	return string(b[0]) + string(b)
+}
+
+// counterReference returns what a counter reference is replaced with: the
+// counter's new value, or nothing for counter2
+func counterReference(ref attributeReference, value string) string {
+	if ref.counter == "counter2" {
+		return ""
+	}
+	return value
+}
+
+// setEnvironmentAttributes defines the attributes describing the document
+// and when it was converted: docname, docfile, docdir and docfilesuffix from
+// ParseOptions.DocumentName when it is set, docdate, doctime, docdatetime
+// and docyear from ParseOptions.ModTime, localdate, localtime,
+// localdatetime and localyear from the current time, asciidoc-version, and
+// the defaultAttributes. Attributes passed in ParseOptions.Attributes and
+// entries in the document take precedence.
+func (p *parser) setEnvironmentAttributes() {
+	now := time.Now()
+	modified := p.opts.ModTime
+	if modified.IsZero() {
+		modified = now
+	}
+	attrs := map[string]string{"asciidoc-version": Version}
+	for k, v := range defaultAttributes {
+		attrs[k] = v
+	}
+	for prefix, t := range map[string]time.Time{"doc": modified, "local": now} {
+		attrs[prefix+"date"] = t.Format("2006-01-02")
+		attrs[prefix+"time"] = t.Format("15:04:05-0700")
+		attrs[prefix+"datetime"] = t.Format("2006-01-02 15:04:05-0700")
+		attrs[prefix+"year"] = strconv.Itoa(t.Year())
+	}
+	if name := p.opts.DocumentName; name != "" {
+		suffix := filepath.Ext(name)
+		attrs["docfile"] = name
+		attrs["docdir"] = filepath.Dir(name)
+		attrs["docname"] = strings.TrimSuffix(filepath.Base(name), suffix)
+		attrs["docfilesuffix"] = suffix
+	}
+	for k, v := range attrs {
+		p.attributes[k] = v
+	}
+}
+
+// resolveReference returns the value of an attribute reference from attrs.
+// Counter references step the counter in the attributes in scope as well as
+// in attrs, so that it keeps counting in later blocks.
+func (p *parser) resolveReference(ref attributeReference, attrs map[string]string) (string, bool) {
+	if ref.counter == "" {
+		return lookupAttribute(attrs, ref.name)
+	}
+	value := stepCounter(p.attributes, ref.name, ref.initial)
+	attrs[ref.name] = value
+	return counterReference(ref, value), true
+}
+
+// expandAttributes replaces the attribute references in an attribute entry
+// value or in block text before its inline markup is parsed, so that
+// attribute values can supply macro targets. References to undefined
+// attributes follow the attribute-missing policy; the second result is true
+// when the line should be dropped. Skipped references are left as written,
+// for the inline scanner to report under the warn policy. With
+// deferIntrinsic set, references to intrinsic attributes are left for the
+// inline scanner too, so that {asterisk} and the like can't start markup.
+// Past MaxAttributeExpansion, or once the context is done, the parse stops
+// at line idx, where text is, and the remaining references are left as
+// written. The context is checked at each reference, since one line can
+// expand to a lot of text.
+func (p *parser) expandAttributes(idx int, text string, attrs map[string]string, deferIntrinsic bool) (string, bool) {
+	dropLine := false
+	result := replaceAttributeReferences(text, func(ref attributeReference, _ int) string {
+		if _, ok := intrinsicAttributes[ref.name]; ok && deferIntrinsic && ref.counter == "" {
+			return ref.text
+		}
+		if p.haltedAt(idx) {
+			return ref.text
+		}
+		if value, ok := p.resolveReference(ref, attrs); ok {
+			if p.addExpansion(ref.text, value) {
+				p.stopAt(idx, "MaxAttributeExpansion", p.limits.maxExpansion, nil)
+				return ref.text
+			}
+			return value
+		}
+		switch attrs["attribute-missing"] {
+		case AttributeMissingDrop:
+			return ""
+		case AttributeMissingDropLine:
+			dropLine = true
+			return ""
+		}
+		return ref.text
+	})
+	return result, dropLine
+}
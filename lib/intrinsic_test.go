@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStepCounter(t *testing.T) {
+	tests := []struct {
+		name    string
+		attrs   map[string]string
+		initial string
+		want    string
+	}{
+		{"starts at 1", map[string]string{}, "", "1"},
+		{"starts at initial number", map[string]string{}, "5", "5"},
+		{"starts at initial letter", map[string]string{}, "A", "A"},
+		{"counts numbers", map[string]string{"n": "9"}, "", "10"},
+		{"counts letters", map[string]string{"n": "b"}, "", "c"},
+		{"carries letters", map[string]string{"n": "Az"}, "", "Ba"},
+		{"adds a letter", map[string]string{"n": "Z"}, "", "AA"},
+		{"ignores initial once set", map[string]string{"n": "2"}, "A", "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stepCounter(tt.attrs, "n", tt.initial); got != tt.want || tt.attrs["n"] != tt.want {
+				t.Errorf("Expected %q, got %q (stored %q)", tt.want, got, tt.attrs["n"])
+			}
+		})
+	}
+}
+
+func TestParse_IntrinsicAttributes(t *testing.T) {
+	input := `A{nbsp}B{zwsp}C{empty}D {asterisk}not bold{asterisk} {cpp}
+
+{docname} {docfilesuffix} {docdate} {docyear} {asciidoc-version}
+
+\{nbsp} stays`
+
+	doc, err := ParseWithOptions(context.Background(), strings.NewReader(input), ParseOptions{
+		DocumentName: "docs/guide.adoc",
+		ModTime:      time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+	want := []string{
+		"A\u00a0B\u200bCD *not bold* C++",
+		"guide .adoc 2024-03-05 2024 " + Version,
+		"{nbsp} stays",
+	}
+	if got := paragraphTexts(doc); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if findNode(doc, Bold) != nil {
+		t.Error("Expected {asterisk} not to start bold text")
+	}
+}
+
+func TestParse_Counters(t *testing.T) {
+	input := `Step {counter:step}. Step {counter:step}.{counter2:step}
+
+Now {step}. Appendix {counter:appendix:A}, then {counter:appendix}.
+
+* Item {counter:step}
+
+Escaped \{counter:step}, still {step}.`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []string{"Step 1. Step 2.", "Now 3. Appendix A, then B.", "Escaped {counter:step}, still 4."}
+	if got := paragraphTexts(doc); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if item := findNode(doc, ListItem); getTextContent(item) != "Item 4" {
+		t.Errorf("Expected the counter to continue in the list, got %q", getTextContent(item))
+	}
+}
+
+func TestParse_AttributeMissing(t *testing.T) {
+	tests := []struct {
+		policy   string
+		want     string
+		warnings int
+	}{
+		{"", "First {missing} line. Last line.", 0},
+		{"skip", "First {missing} line. Last line.", 0},
+		{"drop", "First  line. Last line.", 0},
+		{"drop-line", "Last line.", 0},
+		{"warn", "First {missing} line. Last line.", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			input := "First {missing} line.\nLast line."
+			if tt.policy != "" {
+				input = ":attribute-missing: " + tt.policy + "\n\n" + input
+			}
+			doc, err := Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if got := getTextContent(findNode(doc, Paragraph)); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+
+			diagnostics, err := ValidateWithDiagnostics(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+			}
+			if found := diagnosticsWithCode(diagnostics, CodeMissingAttribute); len(found) != tt.warnings {
+				t.Errorf("Expected %d missing attribute warnings, got %v", tt.warnings, diagnostics)
+			}
+		})
+	}
+}
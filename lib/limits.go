@@ -0,0 +1,163 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+)
+
+// Limits used when the matching ParseOptions field is zero
+const (
+	DefaultMaxNestingDepth       = 64
+	DefaultMaxLineLength         = 1024 * 1024 // 1MB
+	DefaultMaxNodes              = 1000000
+	DefaultMaxInlineDepth        = 32
+	DefaultMaxAttributeExpansion = 16 * 1024 * 1024 // 16MB
+)
+
+// LimitError is returned by ParseWithOptions, along with the tree parsed so
+// far, when parsing stops early: at one of the limits set in ParseOptions,
+// or because the context is done, in which case Err is the context's error.
+type LimitError struct {
+	Limit    string   // Name of the ParseOptions field reached, or "Context"
+	Max      int      // Value of the limit (0 for the context)
+	Position Position // Where parsing stopped
+	Err      error    // The context's error, if it was done
+}
+
+// Error describes where parsing stopped and why
+func (e *LimitError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: parsing stopped: %v", e.Position, e.Err)
+	}
+	return fmt.Sprintf("%s: parsing stopped: %s of %d exceeded", e.Position, e.Limit, e.Max)
+}
+
+// Unwrap returns the context's error, so that errors.Is matches
+// context.Canceled and context.DeadlineExceeded
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// parseLimits tracks a parse against its limits and context, shared by a
+// parser and its sub-parsers. Once err is set, parsing winds down: each
+// loop stops at its next check and the blocks read so far are kept.
+type parseLimits struct {
+	ctx            context.Context
+	maxDepth       int
+	maxLineLength  int
+	maxNodes       int
+	maxInlineDepth int
+	maxExpansion   int
+
+	depth    int // Blocks, sections and lists open
+	nodes    int // Nodes added so far
+	expanded int // Bytes added by attribute references so far
+	err      *LimitError
+}
+
+// newParseLimits returns the limits set in opts, with defaults for those
+// that are zero
+func newParseLimits(ctx context.Context, opts ParseOptions) *parseLimits {
+	orDefault := func(value, def int) int {
+		if value <= 0 {
+			return def
+		}
+		return value
+	}
+	return &parseLimits{
+		ctx:            ctx,
+		maxDepth:       orDefault(opts.MaxNestingDepth, DefaultMaxNestingDepth),
+		maxLineLength:  orDefault(opts.MaxLineLength, DefaultMaxLineLength),
+		maxNodes:       orDefault(opts.MaxNodes, DefaultMaxNodes),
+		maxInlineDepth: orDefault(opts.MaxInlineDepth, DefaultMaxInlineDepth),
+		maxExpansion:   orDefault(opts.MaxAttributeExpansion, DefaultMaxAttributeExpansion),
+	}
+}
+
+// stop ends the parse at pos, unless it has already ended
+func (p *parser) stop(pos Position, limit string, max int, err error) {
+	if p.limits.err == nil {
+		p.limits.err = &LimitError{Limit: limit, Max: max, Position: pos, Err: err}
+	}
+}
+
+// stopAt ends the parse at line idx, or at the last line past the end
+func (p *parser) stopAt(idx int, limit string, max int, err error) {
+	if idx >= len(p.lines) {
+		idx = len(p.lines) - 1
+	}
+	p.stop(p.contentPos(idx, ""), limit, max, err)
+}
+
+// halted reports whether parsing has stopped, checking the context first
+func (p *parser) halted() bool {
+	return p.haltedAt(p.lineNum)
+}
+
+// haltedAt is halted for work on line idx, where parsing stops if the
+// context is done
+func (p *parser) haltedAt(idx int) bool {
+	l := p.limits
+	if l.err == nil {
+		if err := l.ctx.Err(); err != nil {
+			p.stopAt(idx, "Context", 0, err)
+		}
+	}
+	return l.err != nil
+}
+
+// enter records a block, section or list opening at the current line. It
+// returns false, stopping the parse, if that nests too deep; otherwise
+// leave must be called when it ends.
+func (p *parser) enter() bool {
+	if p.limits.depth >= p.limits.maxDepth {
+		p.stopAt(p.lineNum, "MaxNestingDepth", p.limits.maxDepth, nil)
+		return false
+	}
+	p.limits.depth++
+	return true
+}
+
+// leave records the end of what enter recorded
+func (p *parser) leave() {
+	p.limits.depth--
+}
+
+// countNodes records n nodes added at line idx, stopping the parse if there
+// are now too many
+func (p *parser) countNodes(idx, n int) {
+	if p.addNodes(n) {
+		p.stopAt(idx, "MaxNodes", p.limits.maxNodes, nil)
+	}
+}
+
+// addNodes records n nodes added and reports whether there are now too many
+func (p *parser) addNodes(n int) bool {
+	p.limits.nodes += n
+	return p.limits.nodes > p.limits.maxNodes
+}
+
+// addExpansion records the bytes an attribute reference adds by replacing
+// ref with value and reports whether there are now too many. References
+// that chain through attribute values can otherwise grow a short document
+// without bound.
+func (p *parser) addExpansion(ref, value string) bool {
+	if len(value) > len(ref) {
+		p.limits.expanded += len(value) - len(ref)
+	}
+	return p.limits.expanded > p.limits.maxExpansion
+}
+
+// truncateLongLines cuts the parser's lines off before the first one longer
+// than the limit. The error it returns, if any, is for once the lines before
+// are parsed.
+func (p *parser) truncateLongLines() *LimitError {
+	for i, line := range p.lines {
+		if len(line) > p.limits.maxLineLength {
+			err := &LimitError{Limit: "MaxLineLength", Max: p.limits.maxLineLength, Position: p.contentPos(i, "")}
+			p.lines = p.lines[:i]
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// parseWithLimit parses input with opts and returns the tree and the
+// LimitError it stopped with
+func parseWithLimit(t *testing.T, ctx context.Context, input string, opts ParseOptions) (*Node, *LimitError) {
+	t.Helper()
+	doc, err := ParseWithOptions(ctx, strings.NewReader(input), opts)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Expected a LimitError, got %v", err)
+	}
+	if doc == nil {
+		t.Fatal("Expected the partial tree along with the error")
+	}
+	return doc, limitErr
+}
+
+func TestParseWithOptions_MaxNestingDepth(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("Before.\n\n")
+	for i := 10; i >= 4; i-- {
+		input.WriteString(strings.Repeat("=", i) + "\n")
+	}
+	input.WriteString("Deep.\n")
+	for i := 4; i <= 10; i++ {
+		input.WriteString(strings.Repeat("=", i) + "\n")
+	}
+
+	doc, err := parseWithLimit(t, context.Background(), input.String(), ParseOptions{MaxNestingDepth: 3})
+	if err.Limit != "MaxNestingDepth" || err.Max != 3 {
+		t.Errorf("Expected MaxNestingDepth of 3, got %s of %d", err.Limit, err.Max)
+	}
+	if len(doc.Children) != 2 || getTextContent(doc.Children[0]) != "Before." || doc.Children[1].Type != Example {
+		t.Errorf("Expected the paragraph and the outer example, got %v", doc.Children)
+	}
+	if strings.Contains(ToXML(doc), "Deep.") {
+		t.Error("Expected the content past the limit to be left out")
+	}
+
+	// Nested lists count too
+	_, err = parseWithLimit(t, context.Background(), "* a\n** b\n*** c\n**** d", ParseOptions{MaxNestingDepth: 3})
+	if err.Limit != "MaxNestingDepth" || err.Position.Line != 3 {
+		t.Errorf("Expected the list to stop at line 3, got %v", err)
+	}
+}
+
+func TestParseWithOptions_MaxLineLength(t *testing.T) {
+	input := "= Title\n\nFirst.\n\n" + strings.Repeat("x", 100) + "\n\nLast."
+	doc, err := parseWithLimit(t, context.Background(), input, ParseOptions{MaxLineLength: 99})
+	if err.Limit != "MaxLineLength" || err.Position.Line != 5 {
+		t.Errorf("Expected MaxLineLength at line 5, got %v", err)
+	}
+	if want := "5:1: parsing stopped: MaxLineLength of 99 exceeded"; err.Error() != want {
+		t.Errorf("Expected message %q, got %q", want, err.Error())
+	}
+	if len(doc.Children) != 1 || getTextContent(doc.Children[0]) != "First." {
+		t.Errorf("Expected only the first paragraph, got %v", doc.Children)
+	}
+	if doc.GetAttribute("title") != "Title" {
+		t.Errorf("Expected the header to be parsed, got %v", doc.Attributes)
+	}
+}
+
+func TestParseWithOptions_MaxNodes(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&input, "Paragraph %d.\n\n", i)
+	}
+	doc, err := parseWithLimit(t, context.Background(), input.String(), ParseOptions{MaxNodes: 20})
+	if err.Limit != "MaxNodes" {
+		t.Errorf("Expected MaxNodes, got %v", err)
+	}
+	// Each paragraph is two nodes: itself and its text
+	if n := len(doc.Children); n < 5 || n > 11 {
+		t.Errorf("Expected about 10 paragraphs, got %d", n)
+	}
+
+	// A repeated table cell counts once for each cell it makes
+	_, err = parseWithLimit(t, context.Background(), "|===\n1000000*|x\n|===", ParseOptions{MaxNodes: 50})
+	if err.Limit != "MaxNodes" {
+		t.Errorf("Expected MaxNodes, got %v", err)
+	}
+}
+
+func TestParseWithOptions_MaxInlineDepth(t *testing.T) {
+	doc, err := parseWithLimit(t, context.Background(), "A *bold _italic `mono #mark#`_* word.\n\nNext.", ParseOptions{MaxInlineDepth: 3})
+	if err.Limit != "MaxInlineDepth" || err.Position.Column != 24 {
+		t.Errorf("Expected MaxInlineDepth at column 24, got %v", err)
+	}
+	if mark := findNode(doc, Highlight); mark == nil || len(mark.Children) != 1 || mark.Children[0].Type != Text {
+		t.Errorf("Expected the innermost formatting to hold plain text, got %s", ToXML(doc))
+	}
+	if got := getTextContent(doc.Children[0]); got != "A bold italic mono mark word." {
+		t.Errorf("Expected the text past the limit to be kept, got %q", got)
+	}
+	if len(doc.Children) != 1 {
+		t.Errorf("Expected parsing to stop after the paragraph, got %v", doc.Children)
+	}
+}
+
+func TestParseWithOptions_MaxAttributeExpansion(t *testing.T) {
+	// Each entry doubles the one before, so the last would be 2^40 bytes
+	var input strings.Builder
+	input.WriteString("= Title\n:a0: xxxxxxxx\n")
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&input, ":a%d: {a%d}{a%d}\n", i, i-1, i-1)
+	}
+	input.WriteString("\n{a40}")
+	doc, err := parseWithLimit(t, context.Background(), input.String(), ParseOptions{MaxAttributeExpansion: 1 << 20})
+	if err.Limit != "MaxAttributeExpansion" || err.Max != 1<<20 {
+		t.Errorf("Expected MaxAttributeExpansion of 1MB, got %v", err)
+	}
+	if err.Position.Line != 19 {
+		t.Errorf("Expected the limit at the entry for a17 on line 19, got %v", err.Position)
+	}
+	if len(doc.Children) != 0 {
+		t.Errorf("Expected nothing past the limit, got %v", doc.Children)
+	}
+
+	// References on a line count too
+	input.Reset()
+	input.WriteString(":big: " + strings.Repeat("x", 1<<16) + "\n\nFirst.\n\n")
+	input.WriteString(strings.Repeat("{big}", 40) + "\n")
+	doc, err = parseWithLimit(t, context.Background(), input.String(), ParseOptions{MaxAttributeExpansion: 1 << 20})
+	if err.Limit != "MaxAttributeExpansion" || err.Position.Line != 5 {
+		t.Errorf("Expected MaxAttributeExpansion at line 5, got %v", err)
+	}
+	if len(doc.Children) == 0 || getTextContent(doc.Children[0]) != "First." {
+		t.Errorf("Expected the paragraph before the limit, got %v", doc.Children)
+	}
+
+	// So do references the preprocessor expands
+	input.Reset()
+	input.WriteString(":a: xxxxxxxx\n\nFirst.\n\nifeval::[\"" + strings.Repeat("{a}", 1000) + "\" == \"\"]\nendif::[]\n")
+	doc, err = parseWithLimit(t, context.Background(), input.String(), ParseOptions{MaxAttributeExpansion: 1000})
+	if err.Limit != "MaxAttributeExpansion" || err.Position.Line != 5 {
+		t.Errorf("Expected MaxAttributeExpansion at line 5, got %v", err)
+	}
+	if len(doc.Children) != 1 || getTextContent(doc.Children[0]) != "First." {
+		t.Errorf("Expected the paragraph before the limit, got %v", doc.Children)
+	}
+}
+
+func TestParseWithOptions_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	doc, err := parseWithLimit(t, ctx, "= Title\n\nText.", ParseOptions{})
+	if !errors.Is(err, context.Canceled) || err.Limit != "Context" {
+		t.Errorf("Expected the context's error, got %v", err)
+	}
+	if doc.GetAttribute("title") != "Title" || len(doc.Children) != 0 {
+		t.Errorf("Expected only the header, got %v", doc.Children)
+	}
+
+	if _, err := ConvertContext(ctx, strings.NewReader("Text."), ConvertOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected ConvertContext to stop, got %v", err)
+	}
+}
+
+func TestParseWithOptions_ContextWithinLine(t *testing.T) {
+	// A long line is checked against the context while it is scanned, and
+	// the rest of it is kept as text
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := newParser("")
+	p.doc = NewDocumentNode()
+	p.limits = newParseLimits(ctx, ParseOptions{})
+	para := NewParagraphNode()
+	p.parseInlineContent(para, strings.Repeat("*b* ", 10000), nil)
+	if err := p.limits.err; err == nil || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the context's error, got %v", err)
+	}
+	if len(para.Children) == 0 || para.Children[0].Type != Bold {
+		t.Fatalf("Expected markup before the check to be parsed, got %v", para.Children)
+	}
+	last := para.Children[len(para.Children)-1]
+	if last.Type != Text || !strings.HasSuffix(last.Content, "*b* *b* ") {
+		t.Errorf("Expected the rest of the line as text, got %v", last)
+	}
+}
+
+func TestParseWithOptions_ContextWithinExpansion(t *testing.T) {
+	// Attribute references are checked against the context one by one, and
+	// those left once it is done are kept as written
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := newParser("{a}{a}")
+	p.doc = NewDocumentNode()
+	p.limits = newParseLimits(ctx, ParseOptions{})
+	p.attributes["a"] = "x"
+	if got, _ := p.expandAttributes(0, "{a}{a}", p.getAllAttributes(), false); got != "{a}{a}" {
+		t.Errorf("Expected the references as written, got %q", got)
+	}
+	if err := p.limits.err; err == nil || !errors.Is(err, context.Canceled) || err.Position.Line != 1 {
+		t.Fatalf("Expected the context's error at line 1, got %v", err)
+	}
+
+	// The preprocessor checks it too
+	pp := p.newPreprocessor()
+	if got := pp.substitute("{a}", sourceLine{line: 5}); got != "{a}" {
+		t.Errorf("Expected the reference as written, got %q", got)
+	}
+	if err := pp.err; err == nil || !errors.Is(err, context.Canceled) || err.Position.Line != 5 {
+		t.Errorf("Expected the context's error at line 5, got %v", err)
+	}
+}
+
+func TestParseStream_Limit(t *testing.T) {
+	input := "== One\n\nFirst.\n\n" + strings.Repeat("x", 100) + "\n\n== Two"
+	depth := 0
+	err := ParseStream(strings.NewReader(input), ParseOptions{MaxLineLength: 99}, func(event Event) error {
+		if event.Type == EventStart {
+			depth++
+		} else {
+			depth--
+		}
+		return nil
+	})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Position.Line != 5 {
+		t.Fatalf("Expected a LimitError at line 5, got %v", err)
+	}
+	if depth != 0 {
+		t.Errorf("Expected every node started to end, %d left open", depth)
+	}
+}
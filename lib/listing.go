@@ -0,0 +1,258 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// calloutItemRegex matches a callout list item, <1> text, or <.> text to
+// number the items in order
+var calloutItemRegex = regexp.MustCompile(`^<(\d+|\.)>(?:[ \t]+(.*))?$`)
+
+// calloutMarkerRegex matches the last callout marker on a line of code: <1>,
+// <.> to number the markers in order, or <!--1--> for XML. A marker escaped
+// with \ is code.
+var calloutMarkerRegex = regexp.MustCompile(`(\\)?<(!--)?(\d+|\.)(--)?>[ \t]*$`)
+
+// calloutCommentRegex matches the line comment hiding callout markers from
+// the code's language, as in // <1>, # <1> or -- <1>
+var calloutCommentRegex = regexp.MustCompile(`(^|[ \t])(//|#|;;|--)[ \t]*$`)
+
+// calloutState links the callout markers of a listing block to the callout
+// list that follows it
+type calloutState struct {
+	block   int                // Number of listing blocks with callouts so far
+	pending map[string][]*Node // Markers of the last such block, by number
+}
+
+// splitCallouts returns a line of code without the callout markers at its
+// end, and the numbers of those markers in order
+func splitCallouts(line string) (string, []string) {
+	var numbers []string
+	for {
+		m := calloutMarkerRegex.FindStringSubmatchIndex(line)
+		if m == nil {
+			break
+		}
+		escaped := m[2] >= 0
+		xmlComment := m[4] >= 0
+		if xmlComment != (m[8] >= 0) {
+			break
+		}
+		if escaped {
+			line = line[:m[2]] + line[m[3]:]
+			break
+		}
+		numbers = append([]string{line[m[6]:m[7]]}, numbers...)
+		line = line[:m[0]]
+	}
+	if len(numbers) > 0 {
+		line = strings.TrimRight(calloutCommentRegex.ReplaceAllString(line, "$1"), " \t")
+		if line != "" {
+			line += " "
+		}
+	}
+	return line, numbers
+}
+
+// addCode adds the lines of a listing or literal block to it as text, with
+// a Callout node for each callout marker when subs includes callouts. The
+// markers are numbered CO1-1, CO1-2 ... by block and wait for the callout
+// list to link them to its items. Diagrams are always kept as written.
+func (p *parser) addCode(block *Node, content []string, contentStart int, subs substitutions) {
+	type codeLine struct {
+		text    string
+		markers []string
+	}
+	if block.GetAttribute("role") == "mermaid" {
+		subs = verbatimSubs &^ subCallouts
+	}
+	lines := make([]codeLine, len(content))
+	found := false
+	for i, line := range content {
+		lines[i].text = line
+		if subs&subCallouts != 0 {
+			lines[i].text, lines[i].markers = splitCallouts(line)
+		}
+		found = found || len(lines[i].markers) > 0
+	}
+	if !found {
+		p.addCodeText(block, strings.Join(content, "\n"), contentStart, contentStart+len(content)-1, subs)
+		return
+	}
+
+	p.callouts.block++
+	p.callouts.pending = make(map[string][]*Node)
+	var text strings.Builder
+	textStart, count, auto := 0, 0, 0
+	for i, line := range lines {
+		if i > 0 {
+			text.WriteString("\n")
+		}
+		text.WriteString(line.text)
+		if len(line.markers) == 0 && i < len(lines)-1 {
+			continue
+		}
+		if text.Len() > 0 {
+			p.addCodeText(block, text.String(), contentStart+textStart, contentStart+i, subs)
+			text.Reset()
+		}
+		textStart = i + 1
+		for _, marker := range line.markers {
+			number := marker
+			if number == "." {
+				auto++
+				number = strconv.Itoa(auto)
+			}
+			count++
+			callout := NewCalloutNode(number)
+			callout.SetAttribute("id", fmt.Sprintf("CO%d-%d", p.callouts.block, count))
+			p.setTextSpan(callout, contentStart+i, "<"+marker)
+			block.AddChild(callout)
+			p.callouts.pending[number] = append(p.callouts.pending[number], callout)
+		}
+	}
+}
+
+// addCodeText adds text from lines first to last of a verbatim block. With
+// no more than the verbatim substitutions it stays one node, a Text node or,
+// without special characters, a Passthrough node. Other substitutions parse
+// it as inline content.
+func (p *parser) addCodeText(block *Node, text string, first, last int, subs substitutions) {
+	if subs&^verbatimSubs == 0 {
+		node := NewTextNode(text)
+		if subs&subSpecialChars == 0 {
+			node = NewPassthroughNode(text)
+		}
+		p.setVerbatimSpan(node, first, last)
+		block.AddChild(node)
+		return
+	}
+	var attrs map[string]string
+	if subs&subAttributes != 0 {
+		attrs = p.getAllAttributes()
+	}
+	src := &inlineSource{}
+	for i, line := range strings.Split(text, "\n") {
+		if attrs != nil {
+			line, _ = p.substituteAttributes(first+i, line, attrs)
+		}
+		if i > 0 {
+			src.buf.WriteString("\n")
+		}
+		src.addSegment(line, p.posAt(first+i, 0))
+	}
+	p.parseInlineSubs(block, src.text(), src, subs, false)
+}
+
+// linkCallouts links the items of a callout list to the markers of the
+// listing block before it: each item gets an ID and the IDs of its markers
+// in coids, and each marker the item's ID as its target. Items without a
+// marker are reported.
+func (p *parser) linkCallouts(list *Node) {
+	for _, item := range list.Children {
+		number := item.GetAttribute("callout")
+		markers := p.callouts.pending[number]
+		if len(markers) == 0 {
+			p.addDiagnostic(SeverityWarning, CodeUnmatchedCallout, item.Start, item.End, "no callout <%s> in the listing above", number)
+			continue
+		}
+		id := item.GetAttribute("id")
+		if id == "" {
+			id = fmt.Sprintf("CL%d-%s", p.callouts.block, number)
+			item.SetAttribute("id", id)
+		}
+		var coids []string
+		for _, marker := range markers {
+			marker.SetAttribute("target", id)
+			coids = append(coids, marker.GetAttribute("id"))
+		}
+		item.SetAttribute("coids", strings.Join(coids, " "))
+	}
+	p.callouts.pending = nil
+}
+
+// applyListingOptions interprets the options of a listing block: linenums
+// (as an option, a named attribute or the third positional attribute),
+// start, which becomes firstlinenum, highlight, normalized to a list of line
+// numbers, and indent, which reindents content. It returns the content.
+func applyListingOptions(block *Node, attrs *AttributeList, content []string) []string {
+	if hasOption(block, "linenums") || attrs.At(2) == "linenums" || attrs.Named["linenums"] != "" {
+		block.SetAttribute("linenums", "true")
+	}
+	if start := block.GetAttribute("start"); start != "" {
+		delete(block.Attributes, "start")
+		if _, err := strconv.Atoi(start); err == nil {
+			block.SetAttribute("firstlinenum", start)
+		}
+	}
+	if spec := block.GetAttribute("highlight"); spec != "" {
+		var lines []string
+		for _, n := range parseLineRanges(spec) {
+			lines = append(lines, strconv.Itoa(n))
+		}
+		block.SetAttribute("highlight", strings.Join(lines, ","))
+	}
+	if indent := block.GetAttribute("indent"); indent != "" {
+		n, err := strconv.Atoi(indent)
+		if err != nil || n < 0 {
+			delete(block.Attributes, "indent")
+			return content
+		}
+		return reindent(content, n)
+	}
+	return content
+}
+
+// parseLineRanges returns the line numbers named by a list of numbers and
+// ranges separated by commas or semicolons, such as "1,3..5" or "2-4;7"
+func parseLineRanges(spec string) []int {
+	var lines []int
+	for _, part := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		from, to, isRange := strings.Cut(part, "..")
+		if !isRange {
+			from, to, isRange = strings.Cut(part, "-")
+		}
+		first, err := strconv.Atoi(from)
+		if err != nil {
+			continue
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(to); err != nil || last < first {
+				continue
+			}
+		}
+		for n := first; n <= last; n++ {
+			lines = append(lines, n)
+		}
+	}
+	return lines
+}
+
+// reindent removes the indentation the non-blank lines have in common and
+// indents them by n spaces instead
+func reindent(lines []string, n int) []string {
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if indent := len(line) - len(strings.TrimLeft(line, " \t")); common < 0 || indent < common {
+			common = indent
+		}
+	}
+	if common < 0 {
+		return lines
+	}
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		result[i] = strings.Repeat(" ", n) + line[common:]
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCallouts(t *testing.T) {
+	tests := []struct {
+		line    string
+		code    string
+		numbers []string
+	}{
+		{"puts 'hello' <1>", "puts 'hello' ", []string{"1"}},
+		{"require 'x' # <1> <2>", "require 'x' ", []string{"1", "2"}},
+		{"int x; // <3>", "int x; ", []string{"3"}},
+		{"<item/> <!--4-->", "<item/> ", []string{"4"}},
+		{"step() <.>", "step() ", []string{"."}},
+		{`print("\<1>")`, `print("\<1>")`, nil},
+		{`literal \<1>`, "literal <1>", nil},
+		{"List<T>", "List<T>", nil},
+	}
+	for _, tt := range tests {
+		code, numbers := splitCallouts(tt.line)
+		if code != tt.code || !reflect.DeepEqual(numbers, tt.numbers) {
+			t.Errorf("splitCallouts(%q) = %q, %q; expected %q, %q", tt.line, code, numbers, tt.code, tt.numbers)
+		}
+	}
+}
+
+func TestParseLineRanges(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []int
+	}{
+		{"1,3..5", []int{1, 3, 4, 5}},
+		{"2-4;7", []int{2, 3, 4, 7}},
+		{"x, 6", []int{6}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := parseLineRanges(tt.spec); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLineRanges(%q) = %v, expected %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Callouts(t *testing.T) {
+	input := `[source,ruby]
+----
+require 'sinatra' # <1>
+
+get '/hi' do # <2>
+  "Hello" <.>
+end <2>
+----
+<1> Library import
+<2> URL mapping
+<3> Response`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	code := findNode(doc, CodeBlock)
+	var markers []string
+	for _, child := range code.Children {
+		if child.Type == Callout {
+			markers = append(markers, child.GetAttribute("number")+":"+child.GetAttribute("id")+">"+child.GetAttribute("target"))
+		}
+	}
+	want := []string{"1:CO1-1>CL1-1", "2:CO1-2>CL1-2", "1:CO1-3>CL1-1", "2:CO1-4>CL1-2"}
+	if !reflect.DeepEqual(markers, want) {
+		t.Errorf("Expected markers %q, got %q", want, markers)
+	}
+	if got := getTextContent(code); got != "require 'sinatra' \n\nget '/hi' do \n  \"Hello\" \nend " {
+		t.Errorf("Expected the markers to be removed from the code, got %q", got)
+	}
+
+	list := findNode(doc, List)
+	if list.GetAttribute("style") != "callout" || len(list.Children) != 3 {
+		t.Fatalf("Expected a callout list of 3 items, got %v", list)
+	}
+	if got := list.Children[1].GetAttribute("coids"); got != "CO1-2 CO1-4" {
+		t.Errorf("Expected item 2 to link to CO1-2 and CO1-4, got %q", got)
+	}
+	if got := getTextContent(list.Children[0]); got != "Library import" {
+		t.Errorf("Expected the item text, got %q", got)
+	}
+
+	diags, err := ValidateWithDiagnostics(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Code != CodeUnmatchedCallout {
+		t.Errorf("Expected a warning for callout 3, got %v", diags)
+	}
+}
+
+func TestParse_ListingOptions(t *testing.T) {
+	input := `[source,go,linenums,start=10,highlight="1,3..4",indent=2]
+----
+		func main() {
+			run()
+		}
+----`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	code := findNode(doc, CodeBlock)
+	for k, want := range map[string]string{"language": "go", "linenums": "true", "firstlinenum": "10", "highlight": "1,3,4", "indent": "2"} {
+		if got := code.GetAttribute(k); got != want {
+			t.Errorf("Expected %s %q, got %q", k, want, got)
+		}
+	}
+	if code.GetAttribute("start") != "" {
+		t.Error("Expected start to become firstlinenum")
+	}
+	if got := getTextContent(code); got != "  func main() {\n  \trun()\n  }" {
+		t.Errorf("Expected the code to be reindented, got %q", got)
+	}
+
+	doc, _ = Parse(strings.NewReader("[%linenums]\n----\nx\n----"))
+	if got := findNode(doc, CodeBlock).GetAttribute("linenums"); got != "true" {
+		t.Errorf("Expected the linenums option to set linenums, got %q", got)
+	}
+}
+
+func TestConvert_CalloutsAndLineNumbers(t *testing.T) {
+	input := `[source,sh,linenums,highlight=2]
+----
+cd /tmp # <1>
+ls
+----
+<1> Go somewhere`
+
+	result, err := Convert(strings.NewReader(input), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	for _, want := range []string{
+		`<span data-role="line-number">1</span> cd /tmp <a data-role="callout" id="CO1-1" href="#CL1-1">1</a>`,
+		`<span data-role="line-number">2</span> <mark data-role="highlighted-line">ls</mark></code></pre>`,
+		`<ol data-role="callout-list">`,
+		`<li id="CL1-1" data-asciidoc-callout="1"><a data-role="callout-marker" href="#CO1-1">1</a> Go somewhere</li>`,
+	} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, result.HTML)
+		}
+	}
+
+	doc, _ := Parse(strings.NewReader(input))
+	if xml := ToXML(doc); !strings.Contains(xml, `cd /tmp <callout number="1" id="CO1-1" target="CL1-1"/>`) {
+		t.Errorf("Expected a callout element in XML output:\n%s", xml)
+	}
+}
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultIconsDir is where icons are looked up in image mode unless the
+// iconsdir attribute sets another directory
+const DefaultIconsDir = "./images/icons"
+
+// imageMacro returns the inline image for image:target[text]. The text is an
+// attribute list whose positional attributes are the alt text, width and
+// height; without alt text, it is made from the file name.
+func imageMacro(target, text string) *Node {
+	image := NewInlineMacroNode("image")
+	image.SetAttribute("src", target)
+	attrs, err := ParseMacroAttributeList(text)
+	if err != nil {
+		attrs = &AttributeList{Positional: []string{text}, Named: map[string]string{}}
+	}
+	attrs.applyTo(image)
+	for i, name := range []string{"alt", "width", "height"} {
+		if value := attrs.At(i); value != "" && attrs.Named[name] == "" {
+			image.SetAttribute(name, value)
+		}
+	}
+	if image.GetAttribute("alt") == "" {
+		image.SetAttribute("alt", defaultAltText(target))
+	}
+	return image
+}
+
+// defaultAltText returns the alt text for an image without one: its file
+// name without the extension, with dashes and underscores as spaces
+func defaultAltText(target string) string {
+	name := path.Base(target)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	return strings.NewReplacer("-", " ", "_", " ").Replace(name)
+}
+
+// iconMacro returns the icon for icon:name[text], whose attribute list may
+// give a size, as in 2x, and a link, title or role. The icons attribute
+// sets how it is shown: font for an icon font, any other value for an image
+// from iconsdir, and unset for its name in brackets.
+func (p *parser) iconMacro(name, text string) *Node {
+	icon := NewInlineMacroNode("icon")
+	icon.SetAttribute("target", name)
+	if attrs, err := ParseMacroAttributeList(text); err == nil {
+		attrs.applyTo(icon)
+		if size := attrs.At(0); size != "" && attrs.Named["size"] == "" {
+			icon.SetAttribute("size", size)
+		}
+	}
+
+	mode, ok := p.attributes["icons"]
+	switch {
+	case !ok:
+		icon.SetAttribute("mode", "text")
+	case mode == "font":
+		icon.SetAttribute("mode", "font")
+	default:
+		icon.SetAttribute("mode", "image")
+		dir, ok := p.attributes["iconsdir"]
+		if !ok {
+			dir = DefaultIconsDir
+		}
+		iconType := p.attributes["icontype"]
+		if iconType == "" {
+			iconType = "png"
+		}
+		icon.SetAttribute("src", path.Join(dir, name+"."+iconType))
+	}
+	return icon
+}
+
+// stemMacro returns the inline math of stem:[text], latexmath:[text] or
+// asciimath:[text], kept as written and marked with its notation
+func (p *parser) stemMacro(name, text string) *Node {
+	stem := NewInlineMacroNode("stem")
+	stem.SetAttribute("notation", p.stemNotation(name))
+	stem.AddChild(NewTextNode(strings.ReplaceAll(text, `\]`, "]")))
+	return stem
+}
+
+// stemDelimiters returns the delimiters MathJax looks for around math in
+// notation, displayed as a block or inline
+func stemDelimiters(notation string, block bool) (string, string) {
+	switch {
+	case notation != "latexmath":
+		return `\$`, `\$`
+	case block:
+		return `\[`, `\]`
+	}
+	return `\(`, `\)`
+}
@@ -0,0 +1,140 @@
+package lib
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParse_InlineImage(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`A logo image:logo.png[Logo,16,24,link=https://example.org,role=thumb,title="The logo"] and image:my-diagram_v2.svg[].`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var images []*Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == InlineMacro && n.Name == "image" {
+			images = append(images, n)
+		}
+	})
+	if len(images) != 2 {
+		t.Fatalf("Expected 2 inline images, got %d", len(images))
+	}
+	for k, want := range map[string]string{"src": "logo.png", "alt": "Logo", "width": "16", "height": "24", "link": "https://example.org", "role": "thumb", "title": "The logo"} {
+		if got := images[0].GetAttribute(k); got != want {
+			t.Errorf("Expected %s %q, got %q", k, want, got)
+		}
+	}
+	if got := images[1].GetAttribute("alt"); got != "my diagram v2" {
+		t.Errorf("Expected alt text from the file name, got %q", got)
+	}
+
+	// An image macro alone on a line is still a block image
+	doc, _ = Parse(strings.NewReader("image:screenshot.png[Screenshot]"))
+	if len(doc.Children) != 1 || doc.Children[0].Type != BlockMacro {
+		t.Errorf("Expected a block image, got %v", doc.Children)
+	}
+}
+
+func TestConvert_InlineImageAndIcons(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			"See image:logo.png[Logo,16,link=https://example.org] here.",
+			`See <span data-role="image"><a href="https://example.org"><img src="logo.png" alt="Logo" width="16"></a></span> here.`,
+		},
+		{
+			"Like icon:heart[2x] it.",
+			`Like <span data-role="icon">[heart]</span> it.`,
+		},
+		{
+			":icons: font\n\nLike icon:heart[2x,title=Love] it.",
+			`Like <span data-role="icon"><i class="fa fa-heart fa-2x" title="Love"></i></span> it.`,
+		},
+		{
+			":icons:\n:iconsdir: /img\n:icontype: svg\n\nLike icon:heart[link=/love] it.",
+			`Like <span data-role="icon"><a href="/love"><img src="/img/heart.svg" alt="heart"></a></span> it.`,
+		},
+	}
+	for _, tt := range tests {
+		result, err := Convert(strings.NewReader(tt.input), ConvertOptions{})
+		if err != nil {
+			t.Fatalf("Convert failed: %v", err)
+		}
+		if !strings.Contains(result.HTML, tt.want) {
+			t.Errorf("Expected %q in HTML output:\n%s", tt.want, result.HTML)
+		}
+	}
+}
+
+func TestConvert_InlineStem(t *testing.T) {
+	input := "Math stem:[sqrt(4) = 2] and latexmath:[\\frac{a*b*}{c} < 1] and asciimath:[x\\]]."
+	result, err := Convert(strings.NewReader(input), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	for _, want := range []string{
+		`<span data-role="stem" data-asciidoc-notation="asciimath">\$sqrt(4) = 2\$</span>`,
+		`<span data-role="stem" data-asciidoc-notation="latexmath">\(\frac{a*b*}{c} &lt; 1\)</span>`,
+		`<span data-role="stem" data-asciidoc-notation="asciimath">\$x]\$</span>`,
+	} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, result.HTML)
+		}
+	}
+
+	doc, _ := Parse(strings.NewReader(":stem: latexmath\n\nstem:[x^2 < y]"))
+	if xml := ToXML(doc); !strings.Contains(xml, `<stem notation="latexmath">x^2 &lt; y</stem>`) {
+		t.Errorf("Expected a stem element in XML output:\n%s", xml)
+	}
+}
+
+func TestConvert_StemEscapesMarkup(t *testing.T) {
+	input := "Math stem:[<script>alert(1)</script>] and stem:[a < b && c].\n\n[stem]\n++++\nx < y && y > z\n++++"
+	result, err := Convert(strings.NewReader(input), ConvertOptions{Standalone: true, XHTML: true})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	for _, want := range []string{
+		`\$&lt;script&gt;alert(1)&lt;/script&gt;\$`,
+		`\$a &lt; b &amp;&amp; c\$`,
+		`\$x &lt; y &amp;&amp; y &gt; z\$`,
+	} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, result.HTML)
+		}
+	}
+	if strings.Contains(result.HTML, "<script>alert") {
+		t.Errorf("Expected math to be escaped, got:\n%s", result.HTML)
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(result.HTML))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Expected well-formed XHTML, got %v:\n%s", err, result.HTML)
+		}
+	}
+}
+
+func TestReplaceTypography_CharacterReferences(t *testing.T) {
+	tests := map[string]string{
+		"&copy; 2024":      "© 2024",
+		"&#8212; &#x2014;": "— —",
+		"&bogus; AT&T":     "&bogus; AT&T",
+	}
+	for in, want := range tests {
+		if got := replaceTypography(in); got != want {
+			t.Errorf("replaceTypography(%q) = %q, expected %q", in, got, want)
+		}
+	}
+
+	doc, _ := Parse(strings.NewReader(`\&copy; is written &copy;`))
+	if got := getTextContent(findNode(doc, Paragraph)); got != "&copy; is written ©" {
+		t.Errorf("Expected an escaped reference to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,245 @@
+package lib
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// posAt returns the source position of byte offset off in line idx of the
+// parser's lines. Offsets outside the line are clamped. Runes on a long line
+// are counted from marks kept for it, since a table on one line asks for a
+// position for each of its cells.
+func (p *parser) posAt(idx, off int) Position {
+	if idx < 0 || idx >= len(p.lines) {
+		return Position{}
+	}
+	line := p.lines[idx]
+	if off < 0 {
+		off = 0
+	}
+	if off > len(line) {
+		off = len(line)
+	}
+	pos := Position{Line: p.lineOffset + idx + 1}
+	if len(line) > runeMarkInterval {
+		if p.lineRunes == nil {
+			p.lineRunes = make(map[int]*runeIndex)
+		}
+		r := p.lineRunes[idx]
+		if r == nil {
+			r = &runeIndex{}
+			p.lineRunes[idx] = r
+		}
+		pos.Column = r.runesBefore(line, off) + 1
+	} else {
+		pos.Column = utf8.RuneCountInString(line[:off]) + 1
+	}
+	if abs := p.lineOffset + idx; abs < len(p.origins) {
+		pos.File = p.origins[abs].file
+		pos.Line = p.origins[abs].line
+	}
+	return pos
+}
+
+// contentPos returns the position of text within line idx.
+func (p *parser) contentPos(idx int, text string) Position {
+	return p.contentPosFrom(idx, text, 0)
+}
+
+// contentPosFrom returns the position of text within line idx, searching from
+// byte offset from. If text is not found, the first non-blank character at or
+// after from is used instead.
+func (p *parser) contentPosFrom(idx int, text string, from int) Position {
+	if idx < 0 || idx >= len(p.lines) {
+		return Position{}
+	}
+	line := p.lines[idx]
+	if from < 0 || from > len(line) {
+		from = 0
+	}
+	if text != "" {
+		if i := strings.Index(line[from:], text); i >= 0 {
+			return p.posAt(idx, from+i)
+		}
+	}
+	rest := line[from:]
+	return p.posAt(idx, from+len(rest)-len(strings.TrimLeft(rest, " \t")))
+}
+
+// lineEnd returns the position of the last non-blank character of line idx.
+func (p *parser) lineEnd(idx int) Position {
+	line := strings.TrimRight(p.lines[idx], " \t\r")
+	if line == "" {
+		return p.posAt(idx, 0)
+	}
+	_, size := utf8.DecodeLastRuneInString(line)
+	return p.posAt(idx, len(line)-size)
+}
+
+// setSpan sets node's Start and End to cover lines startIdx through endIdx.
+// Blank lines at either end are ignored so a block's span starts and ends on
+// its own content.
+func (p *parser) setSpan(node *Node, startIdx, endIdx int) {
+	if startIdx < 0 {
+		startIdx = 0
+	}
+	if endIdx >= len(p.lines) {
+		endIdx = len(p.lines) - 1
+	}
+	for startIdx < endIdx && strings.TrimSpace(p.lines[startIdx]) == "" {
+		startIdx++
+	}
+	for endIdx > startIdx && strings.TrimSpace(p.lines[endIdx]) == "" {
+		endIdx--
+	}
+	if startIdx > endIdx || startIdx >= len(p.lines) {
+		return
+	}
+	node.Start = p.contentPos(startIdx, "")
+	node.End = p.lineEnd(endIdx)
+	if node.End.Line < node.Start.Line || (node.End.Line == node.Start.Line && node.End.Column < node.Start.Column) {
+		node.End = node.Start
+	}
+}
+
+// setVerbatimSpan sets the span of verbatim content on lines startIdx through
+// endIdx. Unlike setSpan, leading whitespace is part of the content.
+func (p *parser) setVerbatimSpan(node *Node, startIdx, endIdx int) {
+	if startIdx < 0 || startIdx >= len(p.lines) || endIdx < startIdx {
+		return
+	}
+	if endIdx >= len(p.lines) {
+		endIdx = len(p.lines) - 1
+	}
+	node.Start = p.posAt(startIdx, 0)
+	node.End = p.lineEnd(endIdx)
+}
+
+// setTextSpan sets the span of node to the occurrence of text in line idx.
+func (p *parser) setTextSpan(node *Node, idx int, text string) {
+	node.Start = p.contentPos(idx, text)
+	if !node.Start.IsValid() {
+		return
+	}
+	node.End = node.Start
+	if n := utf8.RuneCountInString(text); n > 0 {
+		node.End.Column += n - 1
+	}
+}
+
+// inlineSegment maps a run of inline text starting at offset to its source
+// position.
+type inlineSegment struct {
+	offset int
+	pos    Position
+}
+
+// inlineSource maps byte offsets in text handed to parseInlineContent back to
+// source positions. Text joined from several source lines has one segment per
+// line. A nil *inlineSource is valid and yields zero positions.
+type inlineSource struct {
+	buf      strings.Builder // The text, added to a line at a time
+	segments []inlineSegment
+	runes    runeIndex
+}
+
+// runeIndex counts the runes before byte offsets in a text from marks made
+// through it on first use, so that offsets far into a long text don't each
+// count from its start
+type runeIndex struct {
+	marks []runeMark
+}
+
+// runeMark records the number of runes in the text before offset, which
+// starts a rune
+type runeMark struct {
+	offset, runes int
+}
+
+// runeMarkInterval is the number of bytes between rune marks
+const runeMarkInterval = 256
+
+// newInlineSource returns a source for text that starts at pos on one line.
+func newInlineSource(text string, pos Position) *inlineSource {
+	src := &inlineSource{}
+	src.addSegment(text, pos)
+	return src
+}
+
+// addSegment appends line to the source text, recording that it begins at pos.
+func (s *inlineSource) addSegment(line string, pos Position) {
+	s.segments = append(s.segments, inlineSegment{offset: s.buf.Len(), pos: pos})
+	s.buf.WriteString(line)
+	s.runes = runeIndex{}
+}
+
+// text returns the text added so far
+func (s *inlineSource) text() string {
+	return s.buf.String()
+}
+
+// runesBefore returns the number of runes in text before offset off,
+// counting on from the nearest mark. text must be the same on every call.
+func (r *runeIndex) runesBefore(text string, off int) int {
+	if r.marks == nil {
+		r.marks = []runeMark{{}}
+		for b := runeMarkInterval; b < len(text); b += runeMarkInterval {
+			start := b
+			for start > 0 && !utf8.RuneStart(text[start]) {
+				start--
+			}
+			last := r.marks[len(r.marks)-1]
+			r.marks = append(r.marks, runeMark{offset: start, runes: last.runes + utf8.RuneCountInString(text[last.offset:start])})
+		}
+	}
+	i := sort.Search(len(r.marks), func(i int) bool { return r.marks[i].offset > off }) - 1
+	mark := r.marks[i]
+	return mark.runes + utf8.RuneCountInString(text[mark.offset:off])
+}
+
+// position returns the source position of byte offset off in the text.
+func (s *inlineSource) position(off int) Position {
+	if s == nil || len(s.segments) == 0 {
+		return Position{}
+	}
+	i := sort.Search(len(s.segments), func(i int) bool { return s.segments[i].offset > off }) - 1
+	seg := s.segments[max(i, 0)]
+	if !seg.pos.IsValid() {
+		return Position{}
+	}
+	end := off
+	if end > s.buf.Len() {
+		end = s.buf.Len()
+	}
+	if end < seg.offset {
+		end = seg.offset
+	}
+	pos := seg.pos
+	text := s.text()
+	pos.Column += s.runes.runesBefore(text, end) - s.runes.runesBefore(text, seg.offset)
+	return pos
+}
+
+// lastPosition returns the source position of the last rune in bytes
+// [start, end) of the text, which begins before end - 1 when it is multibyte.
+func (s *inlineSource) lastPosition(start, end int) Position {
+	if s == nil {
+		return Position{}
+	}
+	text := s.text()
+	end = min(end, len(text)) - 1
+	for end > start && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return s.position(end)
+}
+
+// span sets node's Start and End to cover bytes [start, end) of the text.
+func (s *inlineSource) span(node *Node, start, end int) {
+	if s == nil || end <= start {
+		return
+	}
+	node.Start = s.position(start)
+	node.End = s.lastPosition(start, end)
+}
@@ -0,0 +1,315 @@
+package lib
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// findNode returns the first node of type t in a depth-first walk of n.
+func findNode(n *Node, t NodeType) *Node {
+	if n.Type == t {
+		return n
+	}
+	for _, child := range n.Children {
+		if found := findNode(child, t); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestPosition_String(t *testing.T) {
+	if got := (Position{}).String(); got != "-" {
+		t.Errorf("Expected '-' for zero Position, got '%s'", got)
+	}
+	if got := (Position{Line: 3, Column: 7}).String(); got != "3:7" {
+		t.Errorf("Expected '3:7', got '%s'", got)
+	}
+}
+
+func TestParse_SourcePositions_Blocks(t *testing.T) {
+	input := `= Document Title
+
+== First Section
+
+A paragraph
+spanning two lines.
+
+[source,go]
+----
+func main() {}
+----`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	section := findNode(doc, Section)
+	if section == nil {
+		t.Fatal("Section not found")
+	}
+	if section.Start != (Position{Line: 3, Column: 1}) {
+		t.Errorf("Expected section to start at 3:1, got %s", section.Start)
+	}
+	if section.End != (Position{Line: 11, Column: 4}) {
+		t.Errorf("Expected section to end at 11:4, got %s", section.End)
+	}
+
+	para := findNode(section, Paragraph)
+	if para == nil {
+		t.Fatal("Paragraph not found")
+	}
+	if para.Start != (Position{Line: 5, Column: 1}) || para.End != (Position{Line: 6, Column: 19}) {
+		t.Errorf("Expected paragraph span 5:1-6:19, got %s-%s", para.Start, para.End)
+	}
+
+	code := findNode(section, CodeBlock)
+	if code == nil {
+		t.Fatal("Code block not found")
+	}
+	if code.Start.Line != 9 || code.End.Line != 11 {
+		t.Errorf("Expected code block on lines 9-11, got %s-%s", code.Start, code.End)
+	}
+	if text := code.Children[0]; text.Start != (Position{Line: 10, Column: 1}) {
+		t.Errorf("Expected code content to start at 10:1, got %s", text.Start)
+	}
+}
+
+func TestParse_SourcePositions_Inline(t *testing.T) {
+	input := `First line
+with *bold* and ` + "`mono`" + ` text.`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	bold := findNode(doc, Bold)
+	if bold == nil {
+		t.Fatal("Bold node not found")
+	}
+	if bold.Start != (Position{Line: 2, Column: 6}) || bold.End != (Position{Line: 2, Column: 11}) {
+		t.Errorf("Expected bold span 2:6-2:11, got %s-%s", bold.Start, bold.End)
+	}
+	if text := bold.Children[0]; text.Start != (Position{Line: 2, Column: 7}) {
+		t.Errorf("Expected bold text to start at 2:7, got %s", text.Start)
+	}
+
+	mono := findNode(doc, Monospace)
+	if mono == nil {
+		t.Fatal("Monospace node not found")
+	}
+	if mono.Start != (Position{Line: 2, Column: 17}) {
+		t.Errorf("Expected monospace to start at 2:17, got %s", mono.Start)
+	}
+}
+
+func TestParse_SourcePositions_LongLine(t *testing.T) {
+	// Columns count runes, however far along a line the node is
+	input := "First line\n" + strings.Repeat("ü *b* ", 500)
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var bolds []*Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == Bold {
+			bolds = append(bolds, n)
+		}
+	})
+	if len(bolds) != 500 {
+		t.Fatalf("Expected 500 bold nodes, got %d", len(bolds))
+	}
+	for i, bold := range bolds {
+		col := 3 + 6*i
+		if bold.Start != (Position{Line: 2, Column: col}) || bold.End != (Position{Line: 2, Column: col + 2}) {
+			t.Fatalf("Expected bold %d at 2:%d-2:%d, got %s-%s", i, col, col+2, bold.Start, bold.End)
+		}
+	}
+}
+
+func TestParse_SourcePositions_MultibyteEnd(t *testing.T) {
+	// A span ending on a multibyte character ends at that character's column
+	doc, err := Parse(strings.NewReader("ab *日本* c"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	bold := findNode(doc, Bold)
+	if bold == nil || len(bold.Children) != 1 {
+		t.Fatalf("Expected bold text, got %v", bold)
+	}
+	if text := bold.Children[0]; text.Start != (Position{Line: 1, Column: 5}) || text.End != (Position{Line: 1, Column: 6}) {
+		t.Errorf("Expected the text at 1:5-1:6, got %s-%s", text.Start, text.End)
+	}
+	if bold.End != (Position{Line: 1, Column: 7}) {
+		t.Errorf("Expected the bold text to end at 1:7, got %s", bold.End)
+	}
+}
+
+func TestParse_SourcePositions_LongParagraph(t *testing.T) {
+	// Joining the lines of a paragraph must not copy it once per line
+	const n = 20000
+	doc, err := Parse(strings.NewReader(strings.Repeat("a *b* line\n", n)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var last *Node
+	doc.Traverse(func(node *Node) {
+		if node.Type == Bold {
+			last = node
+		}
+	})
+	if last == nil || last.Start != (Position{Line: n, Column: 3}) {
+		t.Errorf("Expected the last bold text at %d:3, got %v", n, last)
+	}
+}
+
+func TestParse_SourcePositions_NestedBlocks(t *testing.T) {
+	input := `Intro.
+
+====
+Inside *example*.
+
+* item one
+* item two
+====`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	example := findNode(doc, Example)
+	if example == nil {
+		t.Fatal("Example not found")
+	}
+	if example.Start.Line != 3 || example.End.Line != 8 {
+		t.Errorf("Expected example on lines 3-8, got %s-%s", example.Start, example.End)
+	}
+
+	bold := findNode(example, Bold)
+	if bold == nil {
+		t.Fatal("Bold node not found")
+	}
+	if bold.Start != (Position{Line: 4, Column: 8}) {
+		t.Errorf("Expected bold inside sub-parser to start at 4:8, got %s", bold.Start)
+	}
+
+	list := findNode(example, List)
+	if list == nil {
+		t.Fatal("List not found")
+	}
+	if len(list.Children) != 2 {
+		t.Fatalf("Expected 2 list items, got %d", len(list.Children))
+	}
+	if item := list.Children[1]; item.Start.Line != 7 {
+		t.Errorf("Expected second item on line 7, got %s", item.Start)
+	}
+}
+
+func TestParse_SourcePositions_TableCells(t *testing.T) {
+	input := `|===
+|Name |Value
+|a |b
+|===`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	cell := findNode(doc, TableCell)
+	if cell == nil {
+		t.Fatal("Table cell not found")
+	}
+	if cell.Start != (Position{Line: 2, Column: 1}) {
+		t.Errorf("Expected first cell to start at 2:1, got %s", cell.Start)
+	}
+}
+
+func TestParse_SourcePositions_LongTableLine(t *testing.T) {
+	// Cells on one long line are positioned by the runes before them, and
+	// each ends on its last character
+	input := "|===\n" + strings.Repeat("|xü ", 1000) + "\n|==="
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var cells []*Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == TableCell {
+			cells = append(cells, n)
+		}
+	})
+	if len(cells) != 1000 {
+		t.Fatalf("Expected 1000 cells, got %d", len(cells))
+	}
+	for i, cell := range cells {
+		col := 1 + 4*i
+		if cell.Start != (Position{Line: 2, Column: col}) || cell.End != (Position{Line: 2, Column: col + 2}) {
+			t.Fatalf("Expected cell %d at 2:%d-2:%d, got %s-%s", i, col, col+2, cell.Start, cell.End)
+		}
+	}
+}
+
+func BenchmarkParse_LongTableLine(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		input := "|===\n" + strings.Repeat("|xü ", n) + "\n|==="
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse(strings.NewReader(input)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestToHTMLWithOptions_SourcePositions(t *testing.T) {
+	input := `= Title
+
+First paragraph.
+
+Second paragraph.`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	html := ToHTMLWithOptions(doc, RenderOptions{SourcePositions: true})
+	if !strings.Contains(html, `data-source-line="5"`) {
+		t.Errorf("Expected data-source-line=\"5\" in HTML, got:\n%s", html)
+	}
+
+	if plain := ToHTML(doc); strings.Contains(plain, "data-source-line") {
+		t.Errorf("Expected no data-source-line without the option, got:\n%s", plain)
+	}
+}
+
+func TestToXMLWithOptions_SourcePositions(t *testing.T) {
+	input := `== Section
+
+Some *text*.`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	xml := ToXMLWithOptions(doc, RenderOptions{SourcePositions: true})
+	if !strings.Contains(xml, `<paragraph source-line="3" source-column="1" source-end-line="3" source-end-column="12">`) {
+		t.Errorf("Expected paragraph source attributes in XML, got:\n%s", xml)
+	}
+	if !strings.Contains(xml, `<strong source-line="3" source-column="6"`) {
+		t.Errorf("Expected strong source attributes in XML, got:\n%s", xml)
+	}
+
+	if plain := ToXML(doc); strings.Contains(plain, "source-line") {
+		t.Errorf("Expected no source attributes without the option, got:\n%s", plain)
+	}
+}
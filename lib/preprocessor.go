@@ -0,0 +1,358 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sourceLine records where a line of preprocessed input came from
+type sourceLine struct {
+	file string // Name of the included document, or "" for the root document
+	line int    // 1-based line number within file
+}
+
+// conditionalDirectiveRegex matches ifdef::, ifndef::, ifeval:: and endif::
+// directives, including the single-line ifdef::attr[content] form
+var conditionalDirectiveRegex = regexp.MustCompile(`^(\\?)(ifdef|ifndef|ifeval|endif)::(\S*?(?:([,+])\S*?)?)\[(.*)\]$`)
+
+// ifevalRegex splits an ifeval expression into its operands and operator
+var ifevalRegex = regexp.MustCompile(`^(.*?)\s*(==|!=|<=|>=|<|>)\s*(.*)$`)
+
+// preprocessor evaluates conditional directives and expands includes line by
+// line before the document is parsed. It tracks attribute entries as it goes,
+// so conditions and include targets see the attributes defined above them.
+type preprocessor struct {
+	p          *parser
+	attributes map[string]string
+	maxDepth   int
+	continued  *attributeEntry // Entry whose value continues on the next line
+	expanded   int             // Bytes added by attribute references, apart from the parser's
+	err        *LimitError     // Limit reached, after which no more lines are produced
+}
+
+// conditional is an open ifdef, ifndef or ifeval block
+type conditional struct {
+	name   string // Attribute target, matched against endif::name[]
+	skip   bool   // Whether the lines in the block are dropped
+	origin sourceLine
+	line   string
+}
+
+// preprocess runs the preprocessor over the parser's lines, recording the
+// origin of each resulting line. The error it returns, if any, is for once
+// the lines produced before the limit are parsed.
+func (p *parser) preprocess() *LimitError {
+	origins := make([]sourceLine, len(p.lines))
+	for i := range p.lines {
+		origins[i] = sourceLine{line: i + 1}
+	}
+	pp := p.newPreprocessor()
+	p.lines, p.origins = pp.expand(p.lines, origins, nil, 0)
+	return pp.err
+}
+
+// newPreprocessor creates a preprocessor starting from the parser's
+// attributes
+func (p *parser) newPreprocessor() *preprocessor {
+	pp := &preprocessor{p: p, attributes: make(map[string]string), maxDepth: p.opts.MaxIncludeDepth}
+	if pp.maxDepth <= 0 {
+		pp.maxDepth = DefaultMaxIncludeDepth
+	}
+	for k, v := range p.attributes {
+		pp.attributes[k] = v
+	}
+	return pp
+}
+
+// expansion is the state of preprocessing one document: the conditional
+// blocks open, the verbatim block being passed through, and the lines
+// produced so far
+type expansion struct {
+	stack        []string // Names of the documents being included, innermost last
+	levelOffset  int      // leveloffset in effect for the lines
+	conditionals []conditional
+	skipping     bool
+	fence        string
+	out          []string
+	origins      []sourceLine
+}
+
+// expand preprocesses lines, dropping the lines in false conditional blocks
+// and replacing include directives with the content they reference. origins
+// gives the source of each line; stack holds the names of the documents being
+// included, innermost last, and levelOffset is the leveloffset in effect for
+// lines.
+func (pp *preprocessor) expand(lines []string, origins []sourceLine, stack []string, levelOffset int) ([]string, []sourceLine) {
+	x := &expansion{stack: stack, levelOffset: levelOffset}
+	for i, line := range lines {
+		pp.expandLine(x, line, origins[i])
+	}
+	pp.finish(x)
+	return x.out, x.origins
+}
+
+// expandLine preprocesses the next line, from origin, appending what it
+// becomes to x.out
+func (pp *preprocessor) expandLine(x *expansion, line string, origin sourceLine) {
+	if pp.err != nil {
+		return
+	}
+	if m := conditionalDirectiveRegex.FindStringSubmatch(line); m != nil {
+		if m[1] != "" {
+			// Escaped directive: keep it as text
+			if !x.skipping {
+				x.emit(line[1:], origin)
+			}
+			return
+		}
+		if content, ok := pp.conditional(m, line, origin, &x.conditionals, x.skipping); ok {
+			x.emit(content, origin)
+			pp.trackAttribute(content, origin)
+		}
+		x.skipping = false
+		for _, c := range x.conditionals {
+			if c.skip {
+				x.skipping = true
+				break
+			}
+		}
+		return
+	}
+	if x.skipping {
+		return
+	}
+
+	trimmed := strings.TrimSpace(line)
+	if x.fence == "" && isVerbatimFence(trimmed) {
+		x.fence = trimmed
+	} else if x.fence != "" && trimmed == x.fence {
+		x.fence = ""
+	}
+
+	if m := includeDirectiveRegex.FindStringSubmatch(line); m != nil && pp.p.opts.IncludeResolver != nil {
+		if m[1] != "" {
+			// Escaped directive: verbatim content loses the backslash here,
+			// elsewhere the parser renders the line as text
+			if x.fence != "" {
+				line = line[1:]
+			}
+			x.emit(line, origin)
+			return
+		}
+		incLines, incOrigins := pp.include(line, m, origin, x.stack, x.levelOffset)
+		x.out = append(x.out, incLines...)
+		x.origins = append(x.origins, incOrigins...)
+		return
+	}
+
+	if x.fence == "" {
+		pp.trackAttribute(line, origin)
+	}
+	x.emit(line, origin)
+}
+
+// finish reports the conditional blocks left open at the end of x
+func (pp *preprocessor) finish(x *expansion) {
+	for _, c := range x.conditionals {
+		pp.addDiagnostic(CodeUnbalancedConditional, c.origin, c.line, "%q has no matching endif", c.line)
+	}
+}
+
+// emit appends a line of output from origin
+func (x *expansion) emit(line string, origin sourceLine) {
+	x.out = append(x.out, line)
+	x.origins = append(x.origins, origin)
+}
+
+// conditional handles the conditional directive m. Block directives update
+// conditionals; a true single-line ifdef or ifndef returns its content.
+func (pp *preprocessor) conditional(m []string, line string, origin sourceLine, conditionals *[]conditional, skipping bool) (string, bool) {
+	directive, target, delimiter, text := m[2], m[3], m[4], m[5]
+
+	if directive == "endif" {
+		if len(*conditionals) == 0 {
+			pp.addDiagnostic(CodeUnbalancedConditional, origin, line, "%q has no matching ifdef, ifndef or ifeval", line)
+			return "", false
+		}
+		open := (*conditionals)[len(*conditionals)-1]
+		if target != "" && target != open.name {
+			pp.addDiagnostic(CodeUnbalancedConditional, origin, line, "mismatched %q, expected endif::%s[]", line, open.name)
+			return "", false
+		}
+		*conditionals = (*conditionals)[:len(*conditionals)-1]
+		return "", false
+	}
+
+	if skipping {
+		// Nested blocks inside a false block only need to be balanced
+		if directive == "ifeval" || text == "" {
+			*conditionals = append(*conditionals, conditional{name: target, skip: true, origin: origin, line: line})
+		}
+		return "", false
+	}
+
+	var keep bool
+	switch directive {
+	case "ifeval":
+		if target != "" {
+			pp.addDiagnostic(CodeInvalidConditional, origin, line, "ifeval takes no target: %q", line)
+		}
+		var err error
+		if keep, err = pp.evaluate(text, origin); err != nil {
+			pp.addDiagnostic(CodeInvalidConditional, origin, line, "invalid ifeval expression %q: %v", text, err)
+		}
+		*conditionals = append(*conditionals, conditional{skip: !keep, origin: origin, line: line})
+		return "", false
+	case "ifdef":
+		keep = pp.defined(target, delimiter)
+	case "ifndef":
+		keep = !pp.defined(target, delimiter)
+	}
+
+	if text != "" {
+		// Single-line form: ifdef::attr[content]
+		return text, keep
+	}
+	*conditionals = append(*conditionals, conditional{name: target, skip: !keep, origin: origin, line: line})
+	return "", false
+}
+
+// defined evaluates an ifdef target: "a,b" is true if any attribute is set,
+// "a+b" only if all of them are. ifndef negates the result.
+func (pp *preprocessor) defined(target, delimiter string) bool {
+	switch delimiter {
+	case ",":
+		for _, name := range strings.Split(target, ",") {
+			if _, ok := pp.attributes[name]; ok {
+				return true
+			}
+		}
+		return false
+	case "+":
+		for _, name := range strings.Split(target, "+") {
+			if _, ok := pp.attributes[name]; !ok {
+				return false
+			}
+		}
+		return true
+	default:
+		_, ok := pp.attributes[target]
+		return ok
+	}
+}
+
+// evaluate evaluates an ifeval expression such as "{sectnumlevels} >= 2" or
+// "\"{backend}\" == \"html5\"", found on a line from origin. Numbers compare
+// numerically, anything else as strings.
+func (pp *preprocessor) evaluate(expr string, origin sourceLine) (bool, error) {
+	m := ifevalRegex.FindStringSubmatch(strings.TrimSpace(expr))
+	if m == nil {
+		return false, fmt.Errorf("no comparison operator")
+	}
+	lhs := ifevalOperand(pp.substitute(m[1], origin))
+	rhs := ifevalOperand(pp.substitute(m[3], origin))
+
+	var cmp int
+	ln, lerr := strconv.ParseFloat(lhs, 64)
+	rn, rerr := strconv.ParseFloat(rhs, 64)
+	switch {
+	case lerr == nil && rerr == nil:
+		switch {
+		case ln < rn:
+			cmp = -1
+		case ln > rn:
+			cmp = 1
+		}
+	default:
+		cmp = strings.Compare(lhs, rhs)
+	}
+
+	switch m[2] {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// ifevalOperand returns the value of an ifeval operand, removing quotes
+func ifevalOperand(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// trackAttribute records the attribute set or unset by line, from origin, if
+// it is an attribute entry or continues one
+func (pp *preprocessor) trackAttribute(line string, origin sourceLine) {
+	var entry attributeEntry
+	if pp.continued != nil {
+		entry = *pp.continued
+		next, more := splitContinuation(strings.TrimSpace(line))
+		entry.value = joinContinuation(entry.value, next)
+		pp.continued = nil
+		if more {
+			pp.continued = &entry
+			return
+		}
+	} else {
+		var ok, more bool
+		if entry, ok = parseAttributeEntryLine(line); !ok {
+			return
+		}
+		if entry.value, more = splitContinuation(entry.value); more {
+			pp.continued = &entry
+			return
+		}
+	}
+
+	if pp.p.locked[entry.name] {
+		return
+	}
+	if entry.unset {
+		delete(pp.attributes, entry.name)
+		return
+	}
+	pp.attributes[entry.name] = pp.substitute(entry.value, origin)
+}
+
+// substitute replaces the attribute references in text, on a line from
+// origin, with the values tracked so far. Past MaxAttributeExpansion, or
+// once the context is done, preprocessing stops at that line and the
+// remaining references are left as written.
+func (pp *preprocessor) substitute(text string, origin sourceLine) string {
+	limits := pp.p.limits
+	pos := Position{File: origin.file, Line: origin.line, Column: 1}
+	return substituteAttributeValues(text, pp.attributes, func(ref, value string) bool {
+		if len(value) > len(ref) {
+			pp.expanded += len(value) - len(ref)
+		}
+		if pp.err == nil {
+			if err := limits.ctx.Err(); err != nil {
+				pp.err = &LimitError{Limit: "Context", Position: pos, Err: err}
+			} else if pp.expanded > limits.maxExpansion {
+				pp.err = &LimitError{Limit: "MaxAttributeExpansion", Max: limits.maxExpansion, Position: pos}
+			}
+		}
+		return pp.err == nil
+	})
+}
+
+// addDiagnostic records an error diagnostic covering a line from origin
+func (pp *preprocessor) addDiagnostic(code string, origin sourceLine, line string, format string, args ...interface{}) {
+	start := Position{File: origin.file, Line: origin.line, Column: 1}
+	end := Position{File: origin.file, Line: origin.line, Column: len([]rune(line))}
+	pp.p.addDiagnostic(SeverityError, code, start, end, format, args...)
+}
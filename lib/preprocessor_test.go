@@ -0,0 +1,195 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// paragraphTexts returns the text of each paragraph in the document
+func paragraphTexts(doc *Node) []string {
+	var texts []string
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Type == Paragraph {
+			var text strings.Builder
+			for _, child := range n.Children {
+				text.WriteString(child.Content)
+			}
+			texts = append(texts, text.String())
+			return
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return texts
+}
+
+func TestParse_Conditionals(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"ifdef set", ":pro:\n\nifdef::pro[]\nPro.\nendif::pro[]\n\nAll.", "Pro.|All."},
+		{"ifdef unset", "ifdef::pro[]\nPro.\nendif::pro[]\n\nAll.", "All."},
+		{"ifndef", "ifndef::pro[]\nFree.\nendif::[]", "Free."},
+		{"any of", ":b:\n\nifdef::a,b[]\nYes.\nendif::a,b[]", "Yes."},
+		{"all of", ":b:\n\nifdef::a+b[]\nYes.\nendif::a+b[]\n\nNo.", "No."},
+		{"ifndef any of", ":b:\n\nifndef::a,b[]\nYes.\nendif::[]\n\nNo.", "No."},
+		{"single line", ":pro:\n\nifdef::pro[Pro only.]\nifndef::pro[Free only.]", "Pro only."},
+		{"nested", ":a:\n\nifdef::a[]\nA.\n\nifdef::b[]\nB.\nendif::b[]\nendif::a[]", "A."},
+		{"nested in false", "ifdef::a[]\nifndef::b[]\nHidden.\nendif::b[]\nendif::a[]\n\nShown.", "Shown."},
+		{"unset entry", ":pro:\n:pro!:\n\nifdef::pro[]\nPro.\nendif::[]\n\nAll.", "All."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(bytes.NewReader([]byte(tt.input)))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if got := strings.Join(paragraphTexts(doc), "|"); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParse_Ifeval(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"{level} > 2", true},
+		{"{level} == 3", true},
+		{"{level} <= 2", false},
+		{"{level} != 10", true},
+		{`"{edition}" == "pro"`, true},
+		{`'{edition}' != 'pro'`, false},
+		{"{missing} == ''", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			input := ":level: 3\n:edition: pro\n\nifeval::[" + tt.expr + "]\nTrue.\nendif::[]"
+			doc, err := Parse(bytes.NewReader([]byte(input)))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if got := len(paragraphTexts(doc)) == 1; got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseWithOptions_InjectedAttributes(t *testing.T) {
+	input := `= Guide
+
+ifdef::pro-edition[]
+Pro features.
+endif::pro-edition[]
+ifndef::pro-edition[]
+Upgrade today.
+endif::pro-edition[]`
+
+	doc, err := ParseWithOptions(context.Background(), bytes.NewReader([]byte(input)), ParseOptions{
+		Attributes: map[string]string{"pro-edition": ""},
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+	if got := strings.Join(paragraphTexts(doc), "|"); got != "Pro features." {
+		t.Errorf("Expected only the pro paragraph, got %q", got)
+	}
+}
+
+func TestParseWithOptions_ConditionalsWithIncludes(t *testing.T) {
+	files := fstest.MapFS{
+		"pro.adoc":    {Data: []byte(":pro-extras:\n\nPro chapter.\n")},
+		"extras.adoc": {Data: []byte("Extras.\n")},
+		"free.adoc":   {Data: []byte("Free chapter.\n")},
+	}
+	input := `:pro:
+
+ifdef::pro[]
+include::pro.adoc[]
+endif::pro[]
+ifndef::pro[]
+include::free.adoc[]
+endif::pro[]
+
+ifdef::pro-extras[]
+include::extras.adoc[]
+endif::[]`
+
+	diagnostics, err := ValidateWithOptions(bytes.NewReader([]byte(input)), ParseOptions{IncludeResolver: NewFSResolver(files)})
+	if err != nil {
+		t.Fatalf("ValidateWithOptions failed: %v", err)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", diagnostics)
+	}
+
+	doc, err := ParseWithOptions(context.Background(), bytes.NewReader([]byte(input)), ParseOptions{IncludeResolver: NewFSResolver(files)})
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+	if got := strings.Join(paragraphTexts(doc), "|"); got != "Pro chapter.|Extras." {
+		t.Errorf("Expected pro and extras content, got %q", got)
+	}
+}
+
+func TestParse_ConditionalPositions(t *testing.T) {
+	input := `ifdef::missing[]
+Dropped.
+endif::[]
+Kept *here*.`
+
+	doc, err := Parse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	bold := findNode(doc, Bold)
+	if bold == nil {
+		t.Fatal("Bold node not found")
+	}
+	if bold.Start != (Position{Line: 4, Column: 6}) {
+		t.Errorf("Expected bold at 4:6 despite dropped lines, got %s", bold.Start)
+	}
+}
+
+func TestValidateWithDiagnostics_UnbalancedConditionals(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		code  string
+		line  int
+	}{
+		{"unclosed", "Text.\n\nifdef::a[]\nMore.", CodeUnbalancedConditional, 3},
+		{"stray endif", "Text.\nendif::[]", CodeUnbalancedConditional, 2},
+		{"mismatched", "ifdef::a[]\nendif::b[]\nendif::a[]", CodeUnbalancedConditional, 2},
+		{"bad ifeval", "ifeval::[{a}]\nendif::[]", CodeInvalidConditional, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics, err := ValidateWithDiagnostics(bytes.NewReader([]byte(tt.input)))
+			if err != nil {
+				t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+			}
+			found := diagnosticsWithCode(diagnostics, tt.code)
+			if len(found) != 1 {
+				t.Fatalf("Expected 1 %s diagnostic, got %v", tt.code, diagnostics)
+			}
+			if found[0].Start.Line != tt.line {
+				t.Errorf("Expected diagnostic on line %d, got %s", tt.line, found[0].Start)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package lib
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// specialSectionStyles are the section styles for front and back matter.
+// Special sections and their subsections are never numbered.
+var specialSectionStyles = map[string]bool{
+	"abstract": true, "acknowledgments": true, "bibliography": true, "colophon": true,
+	"dedication": true, "glossary": true, "index": true, "preface": true,
+}
+
+// DefaultSectNumLevels is the deepest section level numbered when the
+// sectnumlevels attribute isn't set
+const DefaultSectNumLevels = 3
+
+// sectionNumbering tracks the numbers given to sections so far
+type sectionNumbering struct {
+	chapter  int      // Number of the last numbered level 1 section
+	appendix string   // Letter of the last appendix
+	parts    []string // Number of the last numbered section at each level
+}
+
+// next returns the number of the next numbered section at level
+func (n *sectionNumbering) next(level int) string {
+	if level == 1 {
+		n.chapter++
+		n.parts = []string{strconv.Itoa(n.chapter)}
+		return n.parts[0]
+	}
+	for len(n.parts) < level-1 {
+		n.parts = append(n.parts, "0")
+	}
+	count := 0
+	if len(n.parts) >= level {
+		count, _ = strconv.Atoi(n.parts[level-1])
+	}
+	n.parts = append(n.parts[:level-1], strconv.Itoa(count+1))
+	return strings.Join(n.parts, ".")
+}
+
+// nextAppendix returns the letter of the next appendix
+func (n *sectionNumbering) nextAppendix() string {
+	if n.appendix == "" {
+		n.appendix = "A"
+	} else {
+		n.appendix = nextLetters(n.appendix)
+	}
+	n.parts = []string{n.appendix}
+	return n.appendix
+}
+
+// headingLevel returns the section level of a heading line: one less than
+// the number of = markers, shifted by the leveloffset attribute
+func (p *parser) headingLevel(line string) int {
+	level := len(line) - len(strings.TrimLeft(line, "=")) - 1
+	if offset, err := strconv.Atoi(p.attributes["leveloffset"]); err == nil {
+		level += offset
+	}
+	if level < 0 {
+		level = 0
+	}
+	return level
+}
+
+// isDiscreteHeading reports whether line idx is a heading with the discrete
+// or float style, which is not a section and doesn't end one
+func (p *parser) isDiscreteHeading(idx int) bool {
+	attrs, _ := p.blockAttributes(idx)
+	return attrs.Style == "discrete" || attrs.Style == "float"
+}
+
+// resolveLevelOffset returns the leveloffset set by value: a number, or an
+// amount to add to the current offset when it starts with + or -
+func resolveLevelOffset(current, value string) string {
+	if !strings.HasPrefix(value, "+") && !strings.HasPrefix(value, "-") {
+		return value
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return value
+	}
+	base, _ := strconv.Atoi(current)
+	return strconv.Itoa(base + n)
+}
+
+// styleSection applies the section style: discrete headings are marked as
+// such, appendices are lettered and, when sectnums is set, other sections
+// down to sectnumlevels are numbered unless they are special or within an
+// unnumbered section. The number goes in the number attribute; an appendix
+// also gets the appendix-caption as its caption.
+func (p *parser) styleSection(section, parent *Node, level int, style string) {
+	if style == "discrete" || style == "float" {
+		section.SetAttribute("discrete", "true")
+		return
+	}
+	if style == "appendix" || specialSectionStyles[style] {
+		section.SetAttribute("style", style)
+	}
+	if specialSectionStyles[style] || level < 1 {
+		return
+	}
+	if style == "appendix" && level == 1 {
+		section.SetAttribute("number", p.sections.nextAppendix())
+		if caption := p.attributes["appendix-caption"]; caption != "" {
+			section.SetAttribute("caption", caption)
+		}
+		return
+	}
+
+	_, sectnums := p.attributes["sectnums"]
+	if _, numbered := p.attributes["numbered"]; numbered {
+		sectnums = true
+	}
+	levels, err := strconv.Atoi(p.attributes["sectnumlevels"])
+	if err != nil {
+		levels = DefaultSectNumLevels
+	}
+	if !sectnums || level > levels {
+		return
+	}
+	if parent != nil && parent.Type == Section && parent.GetAttribute("number") == "" {
+		return
+	}
+	section.SetAttribute("number", p.sections.next(level))
+}
+
+// SectionLabel returns the label shown before a section title: "1.2. " for
+// a numbered section, "Appendix A: " for an appendix, or "" if the section
+// has no number
+func SectionLabel(section *Node) string {
+	number := section.GetAttribute("number")
+	if number == "" {
+		return ""
+	}
+	if caption := section.GetAttribute("caption"); caption != "" {
+		return caption + " " + number + ": "
+	}
+	return number + ". "
+}
+
+// generateSectionID makes an ID for a section from its title, or returns ""
+// when the sectids attribute is unset. The title is lower-cased and keeps
+// only letters, digits, combining marks and underscores, in any script; runs
+// of spaces, hyphens and periods become the idseparator, and the idprefix
+// goes first. An ID already in the registry gets the separator and a
+// number, as in intro_2.
+func (p *parser) generateSectionID(title string) string {
+	if _, ok := p.attributes["sectids"]; !ok {
+		return ""
+	}
+	prefix, separator := p.attributes["idprefix"], p.attributes["idseparator"]
+	var b strings.Builder
+	b.WriteString(prefix)
+	gap := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			if gap && b.Len() > len(prefix) {
+				b.WriteString(separator)
+			}
+			gap = false
+			b.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '-' || r == '.':
+			gap = true
+		}
+	}
+	if b.Len() == len(prefix) {
+		// Nothing of the title is left, as in a title of punctuation
+		b.WriteString("section")
+	}
+
+	id := b.String()
+	if separator == "" {
+		separator = "_"
+	}
+	if p.anchors[id] == nil {
+		return id
+	}
+	// Suffixes below the last one given are taken, so each duplicate
+	// title doesn't try them all again
+	n := max(p.idSuffixes[id], 2)
+	unique := id + separator + strconv.Itoa(n)
+	for p.anchors[unique] != nil {
+		n++
+		unique = id + separator + strconv.Itoa(n)
+	}
+	p.idSuffixes[id] = n + 1
+	return unique
+}
+
+// adoptSectionAnchor makes a [[id]] or [[id,reftext]] anchor line among the
+// lines above the section title at line idx the ID and reftext of section,
+// taking the anchor block parsed from it out of parent, the section's
+// parent-to-be. It returns the ID, or "" if there is no such line.
+func (p *parser) adoptSectionAnchor(parent, section *Node, idx int) string {
+	for i := idx - 1; i >= 0; i-- {
+		line := strings.TrimSpace(p.lines[i])
+		if blockTitleRegex.MatchString(line) || isBlockAttributeLine(line) || isLineComment(line) {
+			continue
+		}
+		if !strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]]") {
+			return ""
+		}
+		id, reftext := splitAnchor(line[2 : len(line)-2])
+		anchor := p.anchors[id]
+		if anchor == nil || anchor.Type != BlockMacro || anchor.Name != "anchor" {
+			return ""
+		}
+		// The anchor is the last block parsed, so it ends parent or the
+		// section the new one follows. A preamble or other block left empty
+		// without it goes too.
+		path := []*Node{parent}
+		for n := parent; len(n.Children) > 0; n = n.Children[len(n.Children)-1] {
+			if n.Children[len(n.Children)-1] == anchor {
+				n.Children = n.Children[:len(n.Children)-1]
+				for i := len(path) - 1; i > 0 && len(path[i].Children) == 0; i-- {
+					path[i-1].Children = path[i-1].Children[:len(path[i-1].Children)-1]
+				}
+				break
+			}
+			path = append(path, n.Children[len(n.Children)-1])
+		}
+		section.SetAttribute("id", id)
+		if reftext != "" {
+			section.SetAttribute("reftext", reftext)
+		}
+		p.anchors[id] = section
+		return id
+	}
+	return ""
+}
+
+// generateTOC fills each toc::[] macro in doc with the table of contents.
+// Without one, the table goes where placement, from tocPlacement, puts it.
+func (p *parser) generateTOC(doc *Node, placement string) {
+	var tocs []*Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == BlockMacro && n.Name == "toc" {
+			tocs = append(tocs, n)
+		}
+	})
+	for _, toc := range tocs {
+		p.fillTOC(toc, doc)
+	}
+	if len(tocs) > 0 || placement == "" {
+		return
+	}
+	toc := NewBlockMacroNode("toc")
+	p.fillTOC(toc, doc)
+	if len(toc.Children) == 0 {
+		return
+	}
+	// After the preamble goes after comments kept from the header too
+	at := 0
+	if placement == "preamble" {
+		for at < len(doc.Children) && doc.Children[at].Type == Comment {
+			at++
+		}
+		if at < len(doc.Children) && isPreamble(doc.Children[at]) {
+			at++
+		}
+	}
+	doc.Children = append(doc.Children[:at], append([]*Node{toc}, doc.Children[at:]...)...)
+}
+
+// tocPlacement returns where the toc attribute in attrs puts the table of
+// contents of a document without a toc::[] macro: "top" for the top of the
+// document, as with auto, left and right, which are left to the stylesheet,
+// "preamble" for after the preamble, or "" for nowhere when toc is unset or
+// set to macro.
+func tocPlacement(attrs map[string]string) string {
+	switch value, ok := attrs["toc"]; {
+	case !ok || value == "macro":
+		return ""
+	case value == "preamble":
+		return "preamble"
+	}
+	return "top"
+}
+
+// fillTOC fills the toc::[] macro toc with a list linking to the sections
+// in root by their IDs, with subsections in nested lists, down to the
+// macro's levels attribute or else the toclevels attribute. Discrete
+// headings are left out.
+func (p *parser) fillTOC(toc, root *Node) {
+	levels, err := strconv.Atoi(toc.GetAttribute("levels"))
+	if err != nil {
+		levels, err = strconv.Atoi(p.attributes["toclevels"])
+	}
+	if err != nil {
+		levels = 2
+	}
+	if list := tocList(root, levels); list != nil {
+		toc.Children = []*Node{list}
+	}
+}
+
+// tocList returns the list of the sections in parent down to levels, or nil
+// if there are none
+func tocList(parent *Node, levels int) *Node {
+	var list *Node
+	for _, section := range parent.Children {
+		level, _ := strconv.Atoi(section.GetAttribute("level"))
+		if section.Type != Section || section.GetAttribute("discrete") != "" || level < 1 || level > levels {
+			continue
+		}
+		if list == nil {
+			list = NewListNode()
+			list.SetAttribute("style", "unordered")
+			list.SetAttribute("role", "toc")
+		}
+		item := NewListItemNode()
+		title := NewTextNode(SectionLabel(section) + section.GetAttribute("title"))
+		if id := section.GetAttribute("id"); id != "" {
+			link := NewLinkNode()
+			link.SetAttribute("href", "#"+id)
+			link.AddChild(title)
+			item.AddChild(link)
+		} else {
+			item.AddChild(title)
+		}
+		if sublist := tocList(section, levels); sublist != nil {
+			item.AddChild(sublist)
+		}
+		list.AddChild(item)
+	}
+	return list
+}
@@ -0,0 +1,365 @@
+package lib
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sectionNumbers returns "title=number" for each section in doc, in order
+func sectionNumbers(doc *Node) []string {
+	var numbers []string
+	doc.Traverse(func(n *Node) {
+		if n.Type == Section {
+			numbers = append(numbers, n.GetAttribute("title")+"="+n.GetAttribute("number"))
+		}
+	})
+	return numbers
+}
+
+func TestParseSection_Numbering(t *testing.T) {
+	input := `= Manual
+:sectnums:
+:sectnumlevels: 2
+
+== Introduction
+
+=== Background
+
+==== Too deep
+
+=== Scope
+
+[preface]
+== Preface
+
+=== Not numbered
+
+== Usage
+
+[appendix]
+== Options
+
+=== Flags
+
+[appendix]
+== Changes`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []string{
+		"Introduction=1", "Background=1.1", "Too deep=", "Scope=1.2",
+		"Preface=", "Not numbered=",
+		"Usage=2",
+		"Options=A", "Flags=A.1", "Changes=B",
+	}
+	if got := sectionNumbers(doc); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	var appendix *Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == Section && n.GetAttribute("title") == "Options" {
+			appendix = n
+		}
+	})
+	if appendix.GetAttribute("style") != "appendix" || SectionLabel(appendix) != "Appendix A: " {
+		t.Errorf("Expected a lettered appendix, got %v", appendix.Attributes)
+	}
+}
+
+func TestParseSection_AppendixWithoutSectnums(t *testing.T) {
+	doc, err := Parse(strings.NewReader("== Body\n\n[appendix]\n== Extra\n\n=== Detail"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []string{"Body=", "Extra=A", "Detail="}
+	if got := sectionNumbers(doc); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestParseSection_DiscreteHeading(t *testing.T) {
+	input := `== First
+
+[discrete]
+== Aside
+
+Still in the first section.
+
+== Second`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(doc.Children) != 2 {
+		t.Fatalf("Expected two sections, got %d top-level nodes", len(doc.Children))
+	}
+	first := doc.Children[0]
+	var discrete, para *Node
+	for _, child := range first.Children {
+		switch child.Type {
+		case Section:
+			discrete = child
+		case Paragraph:
+			para = child
+		}
+	}
+	if discrete == nil || discrete.GetAttribute("discrete") != "true" || len(discrete.Children) != 1 {
+		t.Fatalf("Expected a discrete heading without content in the first section, got %+v", discrete)
+	}
+	if para == nil {
+		t.Error("Expected the paragraph after the discrete heading to stay in the first section")
+	}
+}
+
+func TestParseSection_LevelOffset(t *testing.T) {
+	input := `== Chapter
+
+:leveloffset: +1
+
+== Nested
+
+:leveloffset: -1
+
+== Next`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(doc.Children) != 2 {
+		t.Fatalf("Expected two top-level sections, got %d", len(doc.Children))
+	}
+	var nested *Node
+	for _, child := range doc.Children[0].Children {
+		if child.Type == Section {
+			nested = child
+		}
+	}
+	if nested == nil || nested.GetAttribute("title") != "Nested" || nested.GetAttribute("level") != "2" {
+		t.Errorf("Expected Nested to become a level 2 subsection, got %+v", nested)
+	}
+	if next := doc.Children[1]; next.GetAttribute("level") != "1" {
+		t.Errorf("Expected the offset to be undone, got level %s", next.GetAttribute("level"))
+	}
+}
+
+func TestConvertSectionNumbers(t *testing.T) {
+	doc, err := Parse(strings.NewReader(":sectnums:\n\n== Intro\n\n[appendix]\n== Extra\n\n[glossary]\n== Terms"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var html bytes.Buffer
+	toHTML(doc, &html, false, 0, RenderOptions{})
+	for _, want := range []string{`<h2 id="intro">1. Intro</h2>`, `<h2 id="extra" data-asciidoc-style="appendix">Appendix A: Extra</h2>`, `<h2 id="terms" data-asciidoc-style="glossary">Terms</h2>`} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, html.String())
+		}
+	}
+
+	xml := ToXML(doc)
+	for _, want := range []string{`number="1"`, `number="A"`, `caption="Appendix"`, `style="glossary"`} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("Expected %q in XML output:\n%s", want, xml)
+		}
+	}
+}
+
+func TestGenerateSectionID(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"== Intro\n\n== Intro\n\n== Intro", []string{"intro", "intro_2", "intro_3"}},
+		{"== Hello-World v1.2\n\n== Über Straße", []string{"hello_world_v1_2", "über_straße"}},
+		{"== 快速入门\n\n== مرحبا بالعالم\n\n== नमस्ते दुनिया", []string{"快速入门", "مرحبا_بالعالم", "नमस्ते_दुनिया"}},
+		{"== ?!", []string{"section"}},
+		{":idprefix: _\n:idseparator: -\n\n== Getting Started\n\n== Getting Started", []string{"_getting-started", "_getting-started-2"}},
+		{":idprefix:\n:idseparator:\n\n== A B", []string{"ab"}},
+		{"[[intro]]\n== Overview\n\n== Intro", []string{"intro", "intro_2"}},
+		{":sectids!:\n\n== Intro", []string{""}},
+		{"[[intro_2]]\n== Other\n\n== Intro\n\n== Intro\n\n== Intro", []string{"intro_2", "intro", "intro_3", "intro_4"}},
+	}
+	for _, tt := range tests {
+		doc, err := Parse(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		var ids []string
+		doc.Traverse(func(n *Node) {
+			if n.Type == Section {
+				ids = append(ids, n.GetAttribute("id"))
+			}
+		})
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Expected %q to have IDs %q, got %q", tt.input, tt.want, ids)
+		}
+	}
+}
+
+func TestParse_SectionAnchorReftext(t *testing.T) {
+	doc, err := Parse(strings.NewReader("[[sec-a,Ref Text]]\n== Section A\n\nSee <<sec-a>>."))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	section := findNode(doc, Section)
+	if section == nil || section.GetAttribute("id") != "sec-a" || section.GetAttribute("reftext") != "Ref Text" {
+		t.Fatalf("Expected section sec-a with reftext 'Ref Text', got %v", section)
+	}
+	var xref *Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == InlineMacro && n.Name == "xref" {
+			xref = n
+		}
+	})
+	if xref == nil || xref.GetAttribute("refid") != "sec-a" || getTextContent(xref) != "Ref Text" {
+		t.Errorf("Expected the reference to resolve with the reftext, got %v", xref)
+	}
+}
+
+func TestParse_SectionAnchorOnlyPreamble(t *testing.T) {
+	// A preamble holding only the section's anchor goes with it
+	input := "= Title\n\n[[intro]]\n== Intro\n\nText."
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(doc.Children) != 1 || doc.Children[0].Type != Section || doc.Children[0].GetAttribute("id") != "intro" {
+		t.Fatalf("Expected only the section intro, got %v", doc.Children)
+	}
+	if xml := ToXML(doc); strings.Contains(xml, "<preamble") {
+		t.Errorf("Expected no preamble in the XML:\n%s", xml)
+	}
+	result, err := Convert(strings.NewReader(input), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if strings.Contains(result.HTML, "preamble") {
+		t.Errorf("Expected no preamble in the HTML:\n%s", result.HTML)
+	}
+}
+
+func TestGenerateSectionID_ManyDuplicates(t *testing.T) {
+	// Each duplicate title picks up where the last one's suffix left off
+	const n = 20000
+	doc, err := Parse(strings.NewReader(strings.Repeat("== h\n\n", n)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	seen := make(map[string]bool)
+	var last string
+	doc.Traverse(func(node *Node) {
+		if node.Type == Section {
+			last = node.GetAttribute("id")
+			seen[last] = true
+		}
+	})
+	if len(seen) != n || last != "h_"+strconv.Itoa(n) {
+		t.Errorf("Expected %d unique IDs ending with h_%d, got %d ending with %q", n, n, len(seen), last)
+	}
+}
+
+func TestParse_DuplicateSectionID(t *testing.T) {
+	diagnostics, err := ValidateWithDiagnostics(strings.NewReader("== Intro\n\n[#intro]\n== Other"))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	if found := diagnosticsWithCode(diagnostics, CodeDuplicateID); len(found) != 1 {
+		t.Errorf("Expected a duplicate ID diagnostic, got %v", diagnostics)
+	}
+}
+
+func TestConvert_TableOfContents(t *testing.T) {
+	input := `= Guide
+
+toc::[]
+
+== Intro
+
+=== Setup
+
+==== Deep
+
+== Intro
+
+[discrete]
+== Aside`
+	result, err := Convert(strings.NewReader(input), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	for _, want := range []string{
+		`<a href="#intro">Intro</a>`,
+		`<a href="#setup">Setup</a>`,
+		`<a href="#intro_2">Intro</a>`,
+	} {
+		if !strings.Contains(result.HTML, want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, result.HTML)
+		}
+	}
+	for _, unwanted := range []string{`href="#deep"`, `href="#aside"`} {
+		if strings.Contains(result.HTML, unwanted) {
+			t.Errorf("Expected no %q in HTML output:\n%s", unwanted, result.HTML)
+		}
+	}
+
+	doc, _ := Parse(strings.NewReader(":toclevels: 3\n\n" + input))
+	if xml := ToXML(doc); !strings.Contains(xml, `href="#deep"`) {
+		t.Errorf("Expected toclevels to reach Deep in XML output:\n%s", xml)
+	}
+}
+
+func TestConvert_TableOfContentsPlacement(t *testing.T) {
+	body := "A preamble.\n\n== Intro\n\nText."
+	tests := []struct {
+		header string
+		want   []string // Child types of the document, in order
+	}{
+		{":toc:", []string{"toc", "preamble", "section"}},
+		{":toc: right", []string{"toc", "preamble", "section"}},
+		{":toc: preamble", []string{"preamble", "toc", "section"}},
+		{":toc: macro", []string{"preamble", "section"}},
+		{"", []string{"preamble", "section"}},
+	}
+	for _, tt := range tests {
+		doc, err := Parse(strings.NewReader("= Guide\n" + tt.header + "\n\n" + body))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		var got []string
+		for _, child := range doc.Children {
+			switch {
+			case child.Type == BlockMacro && child.Name == "toc":
+				got = append(got, "toc")
+			case isPreamble(child):
+				got = append(got, "preamble")
+			case child.Type == Section:
+				got = append(got, "section")
+			default:
+				got = append(got, child.Type.String())
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Expected %q to give %q, got %q", tt.header, tt.want, got)
+		}
+	}
+
+	// A toc::[] macro takes the table's place
+	result, err := Convert(strings.NewReader("= Guide\n:toc:\n\ntoc::[]\n\n== Intro"), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if n := strings.Count(result.HTML, `data-role="toc"`); n != 1 {
+		t.Errorf("Expected one table of contents, got %d:\n%s", n, result.HTML)
+	}
+	if !strings.Contains(result.HTML, `<a href="#intro">Intro</a>`) {
+		t.Errorf("Expected the table to link to Intro:\n%s", result.HTML)
+	}
+}
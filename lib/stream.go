@@ -0,0 +1,748 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EventType is the kind of an Event
+type EventType int
+
+const (
+	// EventStart begins a node. Its attributes are set; its children follow
+	// as events of their own.
+	EventStart EventType = iota
+	// EventEnd ends the node most recently started at the same depth
+	EventEnd
+)
+
+// String returns "start" or "end"
+func (t EventType) String() string {
+	if t == EventEnd {
+		return "end"
+	}
+	return "start"
+}
+
+// Event is a step of a streaming parse: the start or the end of a node.
+// Text and other leaf nodes start and end straight away. The Children of a
+// node may not be filled in and shouldn't be relied on; they come as the
+// events between its start and end.
+type Event struct {
+	Type  EventType
+	Node  *Node
+	Depth int // Nesting depth of Node, 0 for the document
+}
+
+// StreamParser parses a document as it reads it, returning it as events
+// rather than a tree, so that documents too large to hold in memory can be
+// converted. It reads the input a chunk at a time, up to a blank line
+// outside any delimited block or list or up to a section title, and parses
+// each chunk as Parse would, forgetting it once its events are returned.
+//
+// Most of a document needs nothing from further on, but a few features do,
+// and only for them is anything held back:
+//
+//   - The preamble, the content between the header and the first section, is
+//     buffered until that section starts, or until the end, since it only
+//     counts as a preamble if a section follows.
+//   - A cross reference to an anchor further on needs the target for its
+//     text. Events from the reference on are held until the target is read;
+//     references to missing anchors hold them until the end.
+//   - A toc::[] macro, or the table of contents the toc attribute places
+//     without one, lists sections further on and an [index] section the
+//     terms of the whole document, so from either on, events are held until
+//     the end. Leave them out of very large documents.
+//
+// Section numbers, index term IDs and the anchor registry carry over from
+// chunk to chunk as they would in Parse, and only the IDs, titles and
+// numbers of sections and anchored blocks are kept for cross references.
+// Unlike Parse, the document start event carries the attributes set in the
+// header, not those set by entries further on, and the LinkChecker option
+// is ignored.
+//
+// The limits in ParseOptions apply as they do in ParseWithOptions, except
+// that MaxNodes and MaxAttributeExpansion limit each chunk rather than the
+// whole document. At a limit, the document ends with what has been read, and
+// Next returns the *LimitError after its end event.
+type StreamParser struct {
+	p       *parser
+	reader  *bufio.Reader
+	pp      *preprocessor
+	x       *expansion
+	source  int // Lines read so far
+	eof     bool
+	done    bool
+	err     error
+	started bool // The header has been parsed
+
+	// The chunk being read
+	lines      []string
+	origins    []sourceLine
+	delimiters []string // Delimiters of the blocks open at the end of the chunk, innermost last
+	last       string   // Last non-blank line of the chunk, trimmed
+	content    bool     // The chunk has more than blank lines, and before the header, comments
+	blank      bool     // The chunk ends with a blank line after its content
+
+	open     []*Node  // The document and the sections open, innermost last
+	outline  []*Node  // Copies of open without their content, holding the sections and index terms read so far
+	preamble *Node    // Content before the first section, or nil once that starts
+	end      Position // End of the last block
+
+	titles  map[string]*Node // Titles and reftexts for natural cross references
+	pending []pendingXref    // Cross references whose targets haven't been read yet
+	tocs    []*Node          // toc::[] macros to fill at the end
+	autoTOC *Node            // Table of contents placed by the toc attribute, filled at the end
+	tocAt   string           // Where the toc attribute places it, from tocPlacement
+	hold    bool             // Hold back everything until the end
+	held    []streamItem
+	ready   []streamItem
+	events  []Event
+}
+
+// pendingXref is a cross reference waiting for its target
+type pendingXref struct {
+	xref *Node
+	auto bool // Its text is to be taken from the target
+}
+
+// streamItemKind is the kind of a streamItem
+type streamItemKind int
+
+const (
+	itemStart streamItemKind = iota // The start of the document or a section
+	itemEnd                         // Its end
+	itemBlock                       // A block, complete with its content
+	itemIndex                       // Placeholder for the generated content of an index section
+)
+
+// streamItem is what a StreamParser produces: containers start and end
+// separately, and blocks come whole
+type streamItem struct {
+	kind  streamItemKind
+	node  *Node
+	depth int
+}
+
+// NewStreamParser creates a StreamParser reading from reader
+func NewStreamParser(reader io.Reader, opts ParseOptions) *StreamParser {
+	p := newParser("")
+	p.opts = opts
+	p.limits = newParseLimits(context.Background(), opts)
+	p.begin()
+	return &StreamParser{
+		p:      p,
+		reader: bufio.NewReader(reader),
+		pp:     p.newPreprocessor(),
+		x:      &expansion{},
+		titles: make(map[string]*Node),
+	}
+}
+
+// ParseStream parses the document read from reader, calling handler with
+// each event in turn. It stops at the first error, including one returned
+// by handler.
+func ParseStream(reader io.Reader, opts ParseOptions, handler func(Event) error) error {
+	s := NewStreamParser(reader, opts)
+	for {
+		event, err := s.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handler(event); err != nil {
+			return err
+		}
+	}
+}
+
+// Next returns the next event, or io.EOF after the end of the document
+func (s *StreamParser) Next() (Event, error) {
+	for len(s.events) == 0 {
+		item, err := s.next()
+		if err != nil {
+			return Event{}, err
+		}
+		s.events = appendItemEvents(s.events[:0], item)
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+// Document returns the document node, whose attributes are those of the
+// header once the first event has been returned
+func (s *StreamParser) Document() *Node {
+	return s.p.doc
+}
+
+// Diagnostics returns the problems found so far
+func (s *StreamParser) Diagnostics() []Diagnostic {
+	return *s.p.diagnostics
+}
+
+// appendItemEvents appends the events of item to events
+func appendItemEvents(events []Event, item streamItem) []Event {
+	switch item.kind {
+	case itemStart:
+		events = append(events, Event{Type: EventStart, Node: item.node, Depth: item.depth})
+		for _, child := range item.node.Children {
+			events = appendNodeEvents(events, child, item.depth+1)
+		}
+		return events
+	case itemEnd:
+		return append(events, Event{Type: EventEnd, Node: item.node, Depth: item.depth})
+	default:
+		return appendNodeEvents(events, item.node, item.depth)
+	}
+}
+
+// appendNodeEvents appends the events of node and its descendants to events
+func appendNodeEvents(events []Event, node *Node, depth int) []Event {
+	events = append(events, Event{Type: EventStart, Node: node, Depth: depth})
+	for _, child := range node.Children {
+		events = appendNodeEvents(events, child, depth+1)
+	}
+	return append(events, Event{Type: EventEnd, Node: node, Depth: depth})
+}
+
+// next returns the next item, or io.EOF after the end of the document
+func (s *StreamParser) next() (streamItem, error) {
+	for len(s.ready) == 0 {
+		if s.err != nil {
+			return streamItem{}, s.err
+		}
+		if s.done {
+			return streamItem{}, io.EOF
+		}
+		s.read()
+	}
+	item := s.ready[0]
+	s.ready = s.ready[1:]
+	return item, nil
+}
+
+// read reads and preprocesses the next line, or finishes the document at the
+// end of the input
+func (s *StreamParser) read() {
+	if s.eof {
+		s.finish()
+		return
+	}
+	// Lines are split as strings.Split splits them in Parse, so input
+	// ending in a newline ends with an empty line
+	line, err := s.reader.ReadString('\n')
+	switch {
+	case err == io.EOF:
+		s.eof = true
+	case err != nil:
+		s.err = err
+		return
+	default:
+		line = line[:len(line)-1]
+	}
+	s.source++
+	s.pp.expandLine(s.x, line, sourceLine{line: s.source})
+	limits := s.p.limits
+	if err := s.pp.err; err != nil {
+		s.p.stop(err.Position, err.Limit, err.Max, nil)
+	}
+	for i, out := range s.x.out {
+		origin := s.x.origins[i]
+		if len(out) > limits.maxLineLength {
+			s.p.stop(Position{File: origin.file, Line: origin.line, Column: 1}, "MaxLineLength", limits.maxLineLength, nil)
+			break
+		}
+		s.addLine(out, origin)
+	}
+	s.x.out, s.x.origins = s.x.out[:0], s.x.origins[:0]
+
+	// At a limit, the document ends with what has been read
+	if err := limits.err; err != nil {
+		s.finish()
+		s.err = err
+	}
+}
+
+// addLine adds a preprocessed line to the chunk, first parsing the chunk if
+// the line starts a new one
+func (s *StreamParser) addLine(line string, origin sourceLine) {
+	trimmed := strings.TrimSpace(line)
+	if len(s.delimiters) > 0 {
+		s.append(line, origin, trimmed)
+		if top := s.delimiters[len(s.delimiters)-1]; trimmed == top {
+			s.delimiters = s.delimiters[:len(s.delimiters)-1]
+		} else if compoundKinds[delimiterKind(top)] {
+			s.openDelimiter(trimmed)
+		}
+		return
+	}
+	if trimmed == "" {
+		s.append(line, origin, trimmed)
+		s.blank = s.content
+		return
+	}
+	if s.blank && s.canSplitBefore(trimmed) {
+		s.flush()
+	}
+	s.blank = false
+
+	// Before the header is parsed, = Title is the document title
+	if strings.HasPrefix(trimmed, "=") && delimiterKind(trimmed) == "" && (s.started || strings.HasPrefix(trimmed, "==")) {
+		s.heading(line, origin)
+		return
+	}
+	s.append(line, origin, trimmed)
+	if s.started || !isLineComment(trimmed) && !isCommentBlockDelimiter(trimmed) {
+		s.content = true
+	}
+	s.openDelimiter(trimmed)
+}
+
+// append adds a line to the chunk
+func (s *StreamParser) append(line string, origin sourceLine, trimmed string) {
+	s.lines = append(s.lines, line)
+	s.origins = append(s.origins, origin)
+	if trimmed != "" {
+		s.last = trimmed
+	}
+}
+
+// openDelimiter records the delimited block or table the trimmed line
+// opens, if any
+func (s *StreamParser) openDelimiter(trimmed string) {
+	switch kind := delimiterKind(trimmed); {
+	case kind == "fenced":
+		s.delimiters = append(s.delimiters, "```")
+	case kind != "" || isTableDelimiter(trimmed):
+		s.delimiters = append(s.delimiters, trimmed)
+	}
+}
+
+// canSplitBefore reports whether the chunk, which ends with a blank line,
+// can end before the trimmed line. Lists go on across blank lines, and
+// block attribute lines, titles and anchors apply to the block after them
+// even across blank lines.
+func (s *StreamParser) canSplitBefore(trimmed string) bool {
+	if trimmed == "+" {
+		return false
+	}
+	if _, ok := parseListMarker(trimmed); ok {
+		return false
+	}
+	return !isSectionPrefixLine(s.last)
+}
+
+// isSectionPrefixLine reports whether the trimmed line, above a section
+// title, belongs to it: a block attribute line, a block title, an anchor or
+// a line comment
+func isSectionPrefixLine(trimmed string) bool {
+	return isBlockAttributeLine(trimmed) || blockTitleRegex.MatchString(trimmed) ||
+		strings.HasPrefix(trimmed, "[[") && strings.HasSuffix(trimmed, "]]") || isLineComment(trimmed)
+}
+
+// chunkParser returns a parser for lines that shares the document's state
+func (s *StreamParser) chunkParser(lines []string, origins []sourceLine) *parser {
+	sub := s.p.newSubParser("", 0)
+	sub.lines, sub.origins = lines, origins
+	return sub
+}
+
+// flush parses the chunk read so far, the first one along with the header
+func (s *StreamParser) flush() {
+	lines, origins := s.lines, s.origins
+	s.lines, s.origins = nil, nil
+	s.content, s.blank = false, false
+	sub := s.chunkParser(lines, origins)
+	if !s.started {
+		s.start(sub)
+	}
+	if sub.lineNum < len(lines) {
+		for _, block := range s.parseContent(sub) {
+			s.emit(itemBlock, block, len(s.open))
+		}
+	}
+}
+
+// start parses the header at the start of sub and starts the document
+func (s *StreamParser) start(sub *parser) {
+	s.started = true
+	doc := s.p.doc
+	sub.parseHeader()
+	if len(sub.lines) > 0 {
+		sub.setSpan(doc, 0, len(sub.lines)-1)
+	}
+	comments := doc.Children
+	doc.Children = nil
+
+	s.open = []*Node{doc}
+	s.outline = []*Node{outlineNode(doc)}
+	s.preamble = NewParagraphNode()
+	s.preamble.SetAttribute("role", "preamble")
+	s.tocAt = tocPlacement(s.p.attributes)
+	s.emit(itemStart, doc, 0)
+	if s.tocAt == "top" {
+		s.placeTOC()
+	}
+	s.process(comments)
+	for _, comment := range comments {
+		s.emit(itemBlock, comment, 1)
+	}
+}
+
+// container returns the node the blocks being read belong to
+func (s *StreamParser) container() *Node {
+	if s.preamble != nil {
+		return s.preamble
+	}
+	return s.open[len(s.open)-1]
+}
+
+// parseContent parses the rest of sub's lines and emits the blocks found,
+// unless they are part of the preamble. It returns the blocks.
+func (s *StreamParser) parseContent(sub *parser) []*Node {
+	// Blocks go once written, so MaxNodes and MaxAttributeExpansion limit
+	// each chunk
+	s.p.limits.nodes, s.p.limits.expanded, s.pp.expanded = 0, 0, 0
+	parent := s.container()
+	mark := len(parent.Children)
+	sub.parseContent(parent, nil)
+	blocks := append([]*Node(nil), parent.Children[mark:]...)
+	s.process(blocks)
+	if parent == s.preamble {
+		return nil
+	}
+	parent.Children = parent.Children[:mark]
+	return blocks
+}
+
+// heading handles a section title line: the chunk before it is parsed, the
+// sections it ends are closed and the new section is opened. The attribute
+// lines, titles and anchors above the title are parsed along with it.
+func (s *StreamParser) heading(line string, origin sourceLine) {
+	cut := len(s.lines)
+	for cut > 0 && (strings.TrimSpace(s.lines[cut-1]) == "" || isSectionPrefixLine(strings.TrimSpace(s.lines[cut-1]))) {
+		cut--
+	}
+	lines := append(append([]string(nil), s.lines[cut:]...), line)
+	origins := append(append([]sourceLine(nil), s.origins[cut:]...), origin)
+	s.lines, s.origins = s.lines[:cut], s.origins[:cut]
+	s.flush()
+
+	sub := s.chunkParser(lines, origins)
+	trimmed := strings.TrimSpace(line)
+	discrete := sub.isDiscreteHeading(len(lines) - 1)
+	if !discrete || strings.HasPrefix(trimmed, "==") {
+		s.endPreamble()
+	}
+	if !discrete {
+		s.closeSections(sub.headingLevel(trimmed))
+	}
+	for _, block := range s.parseContent(sub) {
+		if block.Type == Section && block.GetAttribute("discrete") == "" {
+			s.openSection(block)
+		} else {
+			s.emit(itemBlock, block, len(s.open))
+		}
+	}
+}
+
+// endPreamble emits the preamble, which a section now follows, and the
+// table of contents if the toc attribute places it there
+func (s *StreamParser) endPreamble() {
+	preamble := s.preamble
+	if preamble == nil {
+		return
+	}
+	s.preamble = nil
+	if len(preamble.Children) > 0 {
+		preamble.Start = preamble.Children[0].Start
+		preamble.End = preamble.Children[len(preamble.Children)-1].End
+		s.emit(itemBlock, preamble, 1)
+	}
+	if s.tocAt == "preamble" {
+		s.placeTOC()
+	}
+}
+
+// placeTOC emits the table of contents the toc attribute places, holding
+// back everything from it on until the end, when it is filled in
+func (s *StreamParser) placeTOC() {
+	s.autoTOC = NewBlockMacroNode("toc")
+	s.hold = true
+	s.emit(itemBlock, s.autoTOC, 1)
+}
+
+// openSection starts section, whose content follows
+func (s *StreamParser) openSection(section *Node) {
+	s.emit(itemStart, section, len(s.open))
+	s.open = append(s.open, section)
+	outline := outlineNode(section)
+	s.outline[len(s.outline)-1].AddChild(outline)
+	s.outline = append(s.outline, outline)
+}
+
+// closeSections ends the open sections at level or deeper
+func (s *StreamParser) closeSections(level int) {
+	for len(s.open) > 1 {
+		section := s.open[len(s.open)-1]
+		if l, _ := strconv.Atoi(section.GetAttribute("level")); l < level {
+			return
+		}
+		s.open = s.open[:len(s.open)-1]
+		s.outline = s.outline[:len(s.outline)-1]
+		if s.end.IsValid() {
+			section.End = s.end
+		}
+		if section.GetAttribute("style") == "index" {
+			// Filled in at the end, when every term is known
+			s.hold = true
+			s.emit(itemIndex, section, len(s.open)+1)
+		}
+		s.emit(itemEnd, section, len(s.open))
+	}
+}
+
+// process does what Parse does once the whole document is read, as far as
+// it can for the blocks just parsed: it numbers their index terms and links
+// their cross references, along with earlier ones whose targets they hold
+func (s *StreamParser) process(blocks []*Node) {
+	var xrefs []*Node
+	for _, block := range blocks {
+		xrefs = append(xrefs, collectXrefs(block, s.titles)...)
+		block.Traverse(func(n *Node) {
+			switch {
+			case n.Type == IndexTerm:
+				s.p.numberIndexTerm(n)
+				s.outline[len(s.outline)-1].AddChild(outlineNode(n))
+			case n.Type == BlockMacro && n.Name == "toc":
+				s.tocs = append(s.tocs, n)
+				s.hold = true
+			}
+		})
+		if block.End.IsValid() {
+			s.end = block.End
+		}
+	}
+
+	var pending []pendingXref
+	for _, ref := range s.pending {
+		if ref.auto {
+			ref.xref.Children = nil
+		}
+		if !s.p.resolveXref(ref.xref, s.titles) {
+			pending = append(pending, ref)
+		}
+	}
+	for _, xref := range xrefs {
+		auto := len(xref.Children) == 0
+		if !s.p.resolveXref(xref, s.titles) {
+			pending = append(pending, pendingXref{xref: xref, auto: auto})
+		}
+	}
+	s.pending = pending
+	s.forget(blocks)
+
+	if len(s.pending) == 0 && !s.hold {
+		s.ready = append(s.ready, s.held...)
+		s.held = nil
+	}
+}
+
+// forget replaces the blocks in the anchor registry and the titles with
+// copies holding only their attributes, so the blocks themselves can go once
+// they are written
+func (s *StreamParser) forget(blocks []*Node) {
+	for _, block := range blocks {
+		block.Traverse(func(n *Node) {
+			id := n.GetAttribute("id")
+			if id == "" {
+				return
+			}
+			outline := outlineNode(n)
+			for _, key := range []string{id, "_" + id} {
+				if s.p.anchors[key] == n {
+					s.p.anchors[key] = outline
+				}
+			}
+			for _, key := range []string{"reftext", "title"} {
+				if text := n.GetAttribute(key); text != "" && s.titles[text] == n {
+					s.titles[text] = outline
+				}
+			}
+		})
+	}
+}
+
+// outlineNode returns a copy of n without its children
+func outlineNode(n *Node) *Node {
+	return &Node{Type: n.Type, Name: n.Name, Attributes: n.Attributes, Start: n.Start, End: n.End}
+}
+
+// emit queues an item, holding it back while something before it waits
+// for the rest of the document
+func (s *StreamParser) emit(kind streamItemKind, node *Node, depth int) {
+	item := streamItem{kind: kind, node: node, depth: depth}
+	if s.hold || len(s.pending) > 0 {
+		s.held = append(s.held, item)
+		return
+	}
+	s.ready = append(s.ready, item)
+}
+
+// finish parses the last chunk, ends the document and fills in what needed
+// all of it: tables of contents and indexes. Cross references still
+// pending have no target.
+func (s *StreamParser) finish() {
+	s.pp.finish(s.x)
+	s.flush()
+	if s.preamble != nil {
+		// Without a section, the content is no preamble
+		blocks := s.preamble.Children
+		s.preamble = nil
+		for _, block := range blocks {
+			s.emit(itemBlock, block, 1)
+		}
+	}
+	s.closeSections(0)
+	doc := s.p.doc
+	if s.end.IsValid() {
+		doc.End = s.end
+	}
+	s.emit(itemEnd, doc, 0)
+
+	root := s.outline[0]
+	for _, toc := range s.tocs {
+		s.p.fillTOC(toc, root)
+	}
+	if s.autoTOC != nil && len(s.tocs) == 0 {
+		s.p.fillTOC(s.autoTOC, root)
+	}
+	var groups []IndexGroup
+	if s.p.indexTerms > 0 {
+		groups = Index(root)
+	}
+	for _, item := range s.held {
+		if item.node == s.autoTOC && len(item.node.Children) == 0 {
+			// As in Parse, a toc::[] macro or a lack of sections leaves it out
+			continue
+		}
+		if item.kind != itemIndex {
+			s.ready = append(s.ready, item)
+			continue
+		}
+		for _, block := range indexBlocks(item.node, groups) {
+			s.ready = append(s.ready, streamItem{kind: itemBlock, node: block, depth: item.depth})
+		}
+	}
+	s.held, s.pending = nil, nil
+	s.done = true
+}
+
+// ConvertToXMLStreaming converts AsciiDoc to XML as ConvertToXML does, but
+// writes it to writer as it reads reader rather than holding the whole
+// document; see StreamParser for what it must still hold back. The document
+// element always has an end tag.
+func ConvertToXMLStreaming(reader io.Reader, writer io.Writer, opts ParseOptions) error {
+	s := NewStreamParser(reader, opts)
+	w := bufio.NewWriter(writer)
+	w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	var buf bytes.Buffer
+	for {
+		item, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		writeXMLItem(&buf, item, RenderOptions{})
+		w.Write(buf.Bytes())
+		buf.Reset()
+	}
+	return w.Flush()
+}
+
+// writeXMLItem writes item as ToXML writes its node, or just the start or
+// end tag of a document or section
+func writeXMLItem(buf *bytes.Buffer, item streamItem, opts RenderOptions) {
+	node := item.node
+	indent := strings.Repeat("  ", item.depth)
+	switch {
+	case item.kind == itemStart && node.Type == Document:
+		writeXMLStartTag(buf, "", "document", node, opts)
+		buf.WriteString(">\n")
+		writeXMLHeader(buf, node, "  ")
+	case item.kind == itemStart:
+		writeXMLStartTag(buf, indent, "section", node, opts)
+		buf.WriteString(">\n")
+		for _, child := range node.Children {
+			toXML(child, buf, item.depth+1, opts)
+		}
+	case item.kind == itemEnd && node.Type == Document:
+		buf.WriteString("</document>\n")
+	case item.kind == itemEnd:
+		buf.WriteString(indent + "</section>\n")
+	case isPreamble(node):
+		writeXMLPreamble(node, buf, item.depth, opts)
+	default:
+		toXML(node, buf, item.depth, opts)
+	}
+}
+
+// ConvertStreaming converts AsciiDoc to HTML as Convert does, but writes it
+// to writer as it reads reader rather than holding the whole document; see
+// StreamParser for what it must still hold back. It returns the metadata
+// from the document header.
+func ConvertStreaming(reader io.Reader, writer io.Writer, opts ConvertOptions) (Metadata, error) {
+	s := NewStreamParser(reader, opts.ParseOptions)
+	w := bufio.NewWriter(writer)
+	var content io.Writer = w
+	var meta Metadata
+	renderOpts := RenderOptions{SourcePositions: opts.SourcePositions}
+	var buf bytes.Buffer
+	for {
+		item, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return meta, err
+		}
+		node := item.node
+		switch {
+		case item.kind == itemStart && node.Type == Document:
+			meta = documentMetadata(node, opts)
+			if opts.Standalone {
+				writeHTMLPageStart(&buf, node, meta, opts)
+				w.Write(buf.Bytes())
+				buf.Reset()
+				// Indent the content
+				content = &indentWriter{w: w, indent: "      "}
+			}
+			continue
+		case item.kind == itemStart:
+			writeHTMLSectionHeading(node, &buf, "", renderOpts)
+		case item.kind == itemEnd:
+			continue
+		case isPreamble(node):
+			writeHTMLPreamble(node, &buf, opts.XHTML, 0, renderOpts)
+		default:
+			toHTML(node, &buf, opts.XHTML, 0, renderOpts)
+		}
+		content.Write(buf.Bytes())
+		buf.Reset()
+	}
+	if opts.Standalone {
+		buf.WriteString("\n")
+		writeHTMLPageEnd(&buf)
+		w.Write(buf.Bytes())
+	}
+	return meta, w.Flush()
+}
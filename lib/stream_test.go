@@ -0,0 +1,172 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const streamInput = `= Streaming
+:toc: macro
+
+Before the first section, see <<later>>.
+
+toc::[]
+
+== First
+
+* one
+* two
+
++
+more of two
+
+[source,go]
+----
+func main() {
+
+}
+----
+
+=== Nested
+
+A ((term)) and <<First>>.
+
+[[later]]
+== Later Section
+
+====
+example
+
+with a blank line
+====
+
+[index]
+== Index
+`
+
+func TestStreamParser_Events(t *testing.T) {
+	doc, err := Parse(strings.NewReader(streamInput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var want []string
+	for _, event := range appendNodeEvents(nil, doc, 0) {
+		want = append(want, fmt.Sprintf("%v %v %d", event.Type, event.Node.Type, event.Depth))
+	}
+
+	var got []string
+	err = ParseStream(strings.NewReader(streamInput), ParseOptions{}, func(event Event) error {
+		got = append(got, fmt.Sprintf("%v %v %d", event.Type, event.Node.Type, event.Depth))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseStream failed: %v", err)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected the events of the tree:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+// countingReader counts the bytes read from it
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestStreamParser_ReadsIncrementally(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("= Large\n\n")
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&input, "== Section %d\n\nParagraph %d.\n\n", i, i)
+	}
+	reader := &countingReader{r: strings.NewReader(input.String())}
+	s := NewStreamParser(reader, ParseOptions{})
+	paragraphs := 0
+	for paragraphs < 10 {
+		event, err := s.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if event.Type == EventEnd && event.Node.Type == Paragraph {
+			paragraphs++
+		}
+	}
+	if reader.n >= input.Len() {
+		t.Errorf("Expected only the start of the input to be read, read %d of %d bytes", reader.n, input.Len())
+	}
+}
+
+func TestStreamParser_HandlerError(t *testing.T) {
+	stop := fmt.Errorf("stop")
+	n := 0
+	err := ParseStream(strings.NewReader(streamInput), ParseOptions{}, func(event Event) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("Expected the handler's error after one event, got %v after %d", err, n)
+	}
+}
+
+func TestConvertStreaming(t *testing.T) {
+	inputs := []string{
+		streamInput,
+		indexInput,
+		"Just a paragraph.\n\nAnd another.",
+		"= Title\n\nA preamble.\n\n== Section\n\nContent.",
+		"= Title\n\nNo sections, so no preamble.\n\n[discrete]\n== Discrete",
+		"= Title\n\nA hard +\nbreak.\n\n== Section\n\nimage::logo.png[Logo]\n\n'''\n\nA rule above.",
+		"= Title\n:toc: left\n\nA preamble.\n\n== One\n\n=== Two",
+		"= Title\n:toc: preamble\n\nA preamble.\n\n== One\n\n== Two",
+		"= Title\n:toc: preamble\n\n== One",
+		"= Title\n:toc:\n\ntoc::[]\n\n== One",
+		"= Title\n:toc:\n\nNo sections to list.",
+	}
+	for _, input := range inputs {
+		for _, opts := range []ConvertOptions{{}, {Standalone: true}, {XHTML: true}, {Standalone: true, XHTML: true}} {
+			want, err := Convert(strings.NewReader(input), opts)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+			var got bytes.Buffer
+			meta, err := ConvertStreaming(strings.NewReader(input), &got, opts)
+			if err != nil {
+				t.Fatalf("ConvertStreaming failed: %v", err)
+			}
+			if got.String() != want.HTML {
+				t.Errorf("Expected the output of Convert:\n%s\ngot:\n%s", want.HTML, got.String())
+			}
+			if meta.Title != want.Meta.Title {
+				t.Errorf("Expected title %q, got %q", want.Meta.Title, meta.Title)
+			}
+		}
+	}
+}
+
+func TestConvertToXMLStreaming(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ConvertToXMLStreaming(strings.NewReader(streamInput), &buf, ParseOptions{}); err != nil {
+		t.Fatalf("ConvertToXMLStreaming failed: %v", err)
+	}
+	xml := buf.String()
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`see <macro type="inline" name="xref"`,
+		`>Later Section</macro>.</paragraph>`,
+		`<indexterm id="_indexterm_1" primary="term">term</indexterm>`,
+		"</document>\n",
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("Expected %q in XML output:\n%s", want, xml)
+		}
+	}
+}
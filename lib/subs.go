@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// substitutions is the set of substitutions applied to the text of a block.
+// They always run in AsciiDoc's order: special characters, quotes,
+// attributes, replacements, macros, then post replacements. Callouts apply
+// to verbatim blocks only.
+type substitutions uint8
+
+const (
+	subSpecialChars substitutions = 1 << iota
+	subQuotes
+	subAttributes
+	subReplacements
+	subMacros
+	subPostReplacements
+	subCallouts
+)
+
+const (
+	// normalSubs are the substitutions of paragraphs and other running text
+	normalSubs = subSpecialChars | subQuotes | subAttributes | subReplacements | subMacros | subPostReplacements
+	// verbatimSubs are the substitutions of listing and literal blocks
+	verbatimSubs = subSpecialChars | subCallouts
+)
+
+// substitutionNames maps the names and groups accepted by the subs
+// attribute, and their one-letter forms, to substitutions
+var substitutionNames = map[string]substitutions{
+	"specialcharacters": subSpecialChars,
+	"specialchars":      subSpecialChars,
+	"c":                 subSpecialChars,
+	"quotes":            subQuotes,
+	"q":                 subQuotes,
+	"attributes":        subAttributes,
+	"a":                 subAttributes,
+	"replacements":      subReplacements,
+	"r":                 subReplacements,
+	"macros":            subMacros,
+	"m":                 subMacros,
+	"post_replacements": subPostReplacements,
+	"p":                 subPostReplacements,
+	"callouts":          subCallouts,
+	"normal":            normalSubs,
+	"n":                 normalSubs,
+	"verbatim":          verbatimSubs,
+	"v":                 verbatimSubs,
+	"none":              0,
+}
+
+// resolveSubs applies a subs attribute value to a block's default
+// substitutions. The value lists names or groups separated by commas. A name
+// written +name or name+ adds to the set and -name removes from it; if the
+// first entry has neither, the set starts empty. It also returns the entries
+// it doesn't know.
+func resolveSubs(spec string, defaults substitutions) (substitutions, []string) {
+	subs := defaults
+	var unknown []string
+	for i, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		op := byte(0)
+		switch {
+		case strings.HasPrefix(entry, "+"), strings.HasPrefix(entry, "-"):
+			op, entry = entry[0], entry[1:]
+		case strings.HasSuffix(entry, "+"):
+			op, entry = '+', entry[:len(entry)-1]
+		}
+		set, ok := substitutionNames[entry]
+		if !ok {
+			unknown = append(unknown, entry)
+			continue
+		}
+		switch op {
+		case '+':
+			subs |= set
+		case '-':
+			subs &^= set
+		default:
+			if i == 0 {
+				subs = 0
+			}
+			subs |= set
+		}
+	}
+	return subs, unknown
+}
+
+// blockSubs returns the substitutions for a block whose type defaults to
+// defaults, as changed by the block's subs attribute. Unknown names are
+// reported.
+func (p *parser) blockSubs(node *Node, defaults substitutions) substitutions {
+	spec, ok := node.Attributes["subs"]
+	if !ok {
+		return defaults
+	}
+	subs, unknown := resolveSubs(spec, defaults)
+	for _, name := range unknown {
+		p.addDiagnostic(SeverityWarning, CodeUnknownSubstitution, node.Start, node.Start, "unknown substitution %q", name)
+	}
+	return subs
+}
+
+// hardBreaks reports whether every line break of a paragraph is kept, as
+// set by the hardbreaks option on the block or the hardbreaks-option
+// document attribute
+func (p *parser) hardBreaks(node *Node) bool {
+	if hasOption(node, "hardbreaks") {
+		return true
+	}
+	_, ok := p.attributes["hardbreaks-option"]
+	if !ok {
+		_, ok = p.attributes["hardbreaks"]
+	}
+	return ok
+}
+
+// replacements are the typographic replacements, in the order they are
+// tried at each offset. A spaced em dash gets thin spaces.
+var replacements = []struct {
+	from, to string
+}{
+	{"(C)", "©"},
+	{"(R)", "®"},
+	{"(TM)", "™"},
+	{" -- ", "\u2009—\u2009"},
+	{"--", "—"},
+	{"...", "…"},
+	{"->", "→"},
+	{"=>", "⇒"},
+	{"<-", "←"},
+	{"<=", "⇐"},
+	{"'", "’"},
+}
+
+// characterReferenceRegex matches a named, decimal or hexadecimal character
+// reference such as &copy;, &#169; or &#xA9;
+var characterReferenceRegex = regexp.MustCompile(`^&(?:[a-zA-Z][a-zA-Z0-9]*|#[0-9]+|#[xX][0-9a-fA-F]+);`)
+
+// characterReferenceAt returns the character referred to by the character
+// reference at i and the length of the reference, or 0 if there is none or
+// it names no character
+func characterReferenceAt(text string, i int) (string, int) {
+	ref := characterReferenceRegex.FindString(text[i:])
+	if ref == "" {
+		return "", 0
+	}
+	char := html.UnescapeString(ref)
+	if char == ref {
+		return "", 0
+	}
+	return char, len(ref)
+}
+
+// replacementAt returns the replacement for the text at i and the length it
+// replaces, or 0 if none applies. A character reference is replaced by the
+// character it refers to. An unspaced em dash and an apostrophe need a word
+// character on both sides.
+func replacementAt(text string, i int) (string, int) {
+	if text[i] == '&' {
+		return characterReferenceAt(text, i)
+	}
+	for _, r := range replacements {
+		if !strings.HasPrefix(text[i:], r.from) {
+			continue
+		}
+		if r.from == "--" || r.from == "'" {
+			prev, _ := utf8.DecodeLastRuneInString(text[:i])
+			next, _ := utf8.DecodeRuneInString(text[i+len(r.from):])
+			if i == 0 || i+len(r.from) >= len(text) || !isWordRune(prev) || !isWordRune(next) {
+				return "", 0
+			}
+		}
+		return r.to, len(r.from)
+	}
+	return "", 0
+}
+
+// replaceTypography applies the typographic replacements to text, as in
+// (C) to ©, -- to an em dash, ... to an ellipsis and &copy; to ©
+func replaceTypography(text string) string {
+	if !strings.ContainsAny(text, "(-.<=>'&") {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(text); i++ {
+		to, n := replacementAt(text, i)
+		if n == 0 {
+			continue
+		}
+		b.WriteString(text[last:i])
+		b.WriteString(to)
+		last = i + n
+		i = last - 1
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// escapedReplacement returns the length of the replacement written after a
+// backslash at i, which keeps it as written, or 0
+func escapedReplacement(text string, i int) int {
+	if _, n := characterReferenceAt(text, i+1); n > 0 {
+		return n
+	}
+	for _, r := range replacements {
+		if r.from != "'" && r.from[0] != ' ' && strings.HasPrefix(text[i+1:], r.from) {
+			return len(r.from)
+		}
+	}
+	return 0
+}
+
+// substitutePassthrough applies a passthrough block's substitutions, which
+// are none by default, to its content. Only the substitutions that work on
+// text apply: attributes, replacements and special characters, which escape
+// the content instead of passing it through. The block starts on line start.
+func (p *parser) substitutePassthrough(start int, content string, subs substitutions) string {
+	if subs&subAttributes != 0 {
+		content, _ = p.expandAttributes(start, content, p.getAllAttributes(), false)
+	}
+	if subs&subReplacements != 0 {
+		content = replaceTypography(content)
+	}
+	if subs&subSpecialChars != 0 {
+		content = html.EscapeString(content)
+	}
+	return content
+}
@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveSubs(t *testing.T) {
+	tests := []struct {
+		spec     string
+		defaults substitutions
+		want     substitutions
+	}{
+		{"+attributes,+quotes", verbatimSubs, verbatimSubs | subAttributes | subQuotes},
+		{"attributes+", verbatimSubs, verbatimSubs | subAttributes},
+		{"-replacements", normalSubs, normalSubs &^ subReplacements},
+		{"quotes,macros", normalSubs, subQuotes | subMacros},
+		{"verbatim", normalSubs, verbatimSubs},
+		{"normal,-callouts", verbatimSubs, normalSubs},
+		{"none", normalSubs, 0},
+		{"q,a", 0, subQuotes | subAttributes},
+	}
+	for _, tt := range tests {
+		if got, unknown := resolveSubs(tt.spec, tt.defaults); got != tt.want || len(unknown) > 0 {
+			t.Errorf("resolveSubs(%q) = %b, %q; expected %b", tt.spec, got, unknown, tt.want)
+		}
+	}
+	if _, unknown := resolveSubs("+bogus", normalSubs); len(unknown) != 1 || unknown[0] != "bogus" {
+		t.Errorf("Expected bogus to be unknown, got %q", unknown)
+	}
+}
+
+func TestReplaceTypography(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"(C) 2024 (R) (TM)", "© 2024 ® ™"},
+		{"wait... what", "wait… what"},
+		{"a--b and c -- d", "a—b and c\u2009—\u2009d"},
+		{"-> => <- <=", "→ ⇒ ← ⇐"},
+		{"don't 'quote'", "don’t 'quote'"},
+		{"--flag and ---", "--flag and ---"},
+	}
+	for _, tt := range tests {
+		if got := replaceTypography(tt.text); got != tt.want {
+			t.Errorf("replaceTypography(%q) = %q, expected %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Substitutions(t *testing.T) {
+	input := `:version: 1.2
+
+Copyright (C) {version} -- \(C) *bold*
+
+[subs="-replacements,-quotes"]
+No (C) or *bold*
+
+[subs=-specialchars]
+Raw <b>html</b>
+
+[subs="+attributes,+quotes"]
+----
+version = {version} *bold*
+----
+
+----
+version = {version} *bold*
+----
+
+[subs=attributes]
+++++
+<p>{version}</p>
+++++`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(doc.Children) != 6 {
+		t.Fatalf("Expected 6 blocks, got %d", len(doc.Children))
+	}
+	if got := getTextContent(doc.Children[0]); got != "Copyright © 1.2\u2009—\u2009(C) bold" {
+		t.Errorf("Expected replacements and attributes in a paragraph, got %q", got)
+	}
+	if findNode(doc.Children[0], Bold) == nil {
+		t.Error("Expected quotes in a paragraph")
+	}
+	if got := getTextContent(doc.Children[1]); got != "No (C) or *bold*" {
+		t.Errorf("Expected replacements and quotes to be turned off, got %q", got)
+	}
+	if raw := doc.Children[2].Children[0]; raw.Type != Passthrough || raw.Content != "Raw <b>html</b>" {
+		t.Errorf("Expected text without special characters to pass through, got %v", raw)
+	}
+	if got := getTextContent(doc.Children[3]); got != "version = 1.2 bold" || findNode(doc.Children[3], Bold) == nil {
+		t.Errorf("Expected attributes and quotes in the listing, got %q", got)
+	}
+	if got := getTextContent(doc.Children[4]); got != "version = {version} *bold*" {
+		t.Errorf("Expected a listing to be verbatim by default, got %q", got)
+	}
+	if got := doc.Children[5].Content; got != "<p>1.2</p>" {
+		t.Errorf("Expected attributes in the passthrough block, got %q", got)
+	}
+
+	diags, err := ValidateWithDiagnostics(strings.NewReader("[subs=+bogus]\n----\nx\n----"))
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Code != CodeUnknownSubstitution {
+		t.Errorf("Expected an unknown substitution warning, got %v", diags)
+	}
+}
+
+func TestParse_HardLineBreaks(t *testing.T) {
+	input := `Roses are red, +
+violets are *blue +
+and* so on.
+
+[%hardbreaks]
+Line one
+Line two
+
+[subs=-post_replacements]
+Kept +
+together`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []string{"Roses are red,\nviolets are blue\nand so on.", "Line one\nLine two", "Kept + together"}
+	for i, w := range want {
+		if got := getTextContent(doc.Children[i]); got != w {
+			t.Errorf("Paragraph %d: expected %q, got %q", i, w, got)
+		}
+	}
+	if br := findNode(findNode(doc, Bold), LineBreak); br == nil || br.Start.Line != 2 {
+		t.Errorf("Expected a line break on line 2 within the bold text, got %v", br)
+	}
+
+	result, err := Convert(strings.NewReader(input), ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if !strings.Contains(result.HTML, "<p>Roses are red,<br>\nviolets are <strong>blue<br>\nand</strong> so on.</p>") {
+		t.Errorf("Expected br elements in HTML output:\n%s", result.HTML)
+	}
+	if xml := ToXML(doc); !strings.Contains(xml, "Line one<linebreak/>Line two") {
+		t.Errorf("Expected a linebreak element in XML output:\n%s", xml)
+	}
+}
@@ -0,0 +1,631 @@
+package lib
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tableDelimiterRegex matches a table delimiter: |=== for cells separated by
+// |, ,=== for comma-separated data, :=== for delimiter-separated data and
+// !=== for a table nested in an AsciiDoc cell
+var tableDelimiterRegex = regexp.MustCompile(`^[|,:!]={3,}$`)
+
+// isTableDelimiter reports whether the trimmed line opens or closes a table
+func isTableDelimiter(line string) bool {
+	return tableDelimiterRegex.MatchString(line)
+}
+
+// columnSpecRegex matches one column of a cols attribute:
+// [multiplier*][halign][.valign][width][style], e.g. 3*, 2, ^.>3a or ~
+var columnSpecRegex = regexp.MustCompile(`^(?:(\d+)\*)?([<^>])?(?:\.([<^>]))?(\d+%?|~)?([adehlmsv])?$`)
+
+// cellSpecRegex matches the specifier in front of a cell's separator:
+// [factor*|colspan.rowspan+][halign][.valign][style], e.g. 2+, .3+^ or a
+var cellSpecRegex = regexp.MustCompile(`^(?:(\d+)\*|(\d*)(?:\.(\d+))?\+)?([<^>])?(?:\.([<^>]))?([adehlmsv])?$`)
+
+// Names of the horizontal and vertical alignments and of the cell styles
+var (
+	halignNames = map[string]string{"<": "left", "^": "center", ">": "right"}
+	valignNames = map[string]string{"<": "top", "^": "middle", ">": "bottom"}
+	styleNames  = map[string]string{
+		"a": "asciidoc", "d": "default", "e": "emphasis", "h": "header",
+		"l": "literal", "m": "monospace", "s": "strong", "v": "verse",
+	}
+)
+
+// columnSpec describes a column of a table
+type columnSpec struct {
+	width  string // Relative width, or ~ for automatic width
+	align  string
+	valign string
+	style  string
+}
+
+// parseColumnSpecs parses a cols attribute such as "1,2", "3*" or
+// "<1,^2a,>1m". A single number is a column count. It returns nil if cols is
+// empty or malformed, and no more than max+1 columns, enough to tell that
+// cols asks for too many.
+func parseColumnSpecs(cols string, max int) []columnSpec {
+	cols = strings.TrimSpace(cols)
+	if cols == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(cols); err == nil {
+		return make([]columnSpec, min(n, max+1))
+	}
+	var specs []columnSpec
+	for _, spec := range strings.FieldsFunc(cols, func(r rune) bool { return r == ',' || r == ';' }) {
+		m := columnSpecRegex.FindStringSubmatch(strings.TrimSpace(spec))
+		if m == nil {
+			return nil
+		}
+		col := columnSpec{
+			width:  strings.TrimSuffix(m[4], "%"),
+			align:  halignNames[m[2]],
+			valign: valignNames[m[3]],
+			style:  styleNames[m[5]],
+		}
+		repeat := 1
+		if m[1] != "" {
+			repeat, _ = strconv.Atoi(m[1])
+		}
+		for i := 0; i < repeat && len(specs) <= max; i++ {
+			specs = append(specs, col)
+		}
+	}
+	return specs
+}
+
+// cellSpec is the specifier written in front of a cell's separator
+type cellSpec struct {
+	repeat  int // Number of copies of the cell
+	colspan int
+	rowspan int
+	align   string
+	valign  string
+	style   string
+}
+
+// parseCellSpec parses a cell specifier such as 2+, .3+^ or 3*a
+func parseCellSpec(s string) (cellSpec, bool) {
+	spec := cellSpec{repeat: 1, colspan: 1, rowspan: 1}
+	if s == "" {
+		return spec, true
+	}
+	m := cellSpecRegex.FindStringSubmatch(s)
+	if m == nil {
+		return spec, false
+	}
+	if strings.Contains(s, "+") && m[2] == "" && m[3] == "" {
+		return spec, false
+	}
+	if m[1] != "" {
+		spec.repeat, _ = strconv.Atoi(m[1])
+	}
+	if m[2] != "" {
+		spec.colspan, _ = strconv.Atoi(m[2])
+	}
+	if m[3] != "" {
+		spec.rowspan, _ = strconv.Atoi(m[3])
+	}
+	if spec.repeat < 1 || spec.colspan < 1 || spec.rowspan < 1 {
+		return cellSpec{repeat: 1, colspan: 1, rowspan: 1}, false
+	}
+	spec.align = halignNames[m[4]]
+	spec.valign = valignNames[m[5]]
+	spec.style = styleNames[m[6]]
+	return spec, true
+}
+
+// tableCell is a cell's specifier and source text before it is parsed
+type tableCell struct {
+	spec     cellSpec
+	idx      int // Line of the cell's separator
+	off      int // Byte offset of the separator in that line
+	segments []cellSegment
+}
+
+// cellSegment is the part of a cell's text on one source line
+type cellSegment struct {
+	idx  int
+	off  int // Byte offset of text in the line
+	text string
+}
+
+// parseTable parses a delimited table. Cells flow into rows of the table's
+// column count, which comes from the cols attribute or else from the number
+// of cells on the first line, taking column and row spans into account.
+func (p *parser) parseTable() *Node {
+	start := p.lineNum
+	delimiter := strings.TrimSpace(p.lines[start])
+	p.lineNum++ // Skip opening
+	table := NewTableNode()
+
+	// Apply attributes: [cols="...", options="..."] or [%header]
+	attrs := p.applyBlockAttributes(table, start)
+	for _, part := range attrs.Positional {
+		// [header] and [footer] are accepted in place of options
+		if part == "header" || part == "footer" {
+			addOptions(table, part)
+		}
+	}
+	p.captionBlock(table)
+
+	bodyStart := p.lineNum
+	closed := false
+	for p.lineNum < len(p.lines) {
+		if strings.TrimSpace(p.lines[p.lineNum]) == delimiter {
+			closed = true
+			break
+		}
+		p.lineNum++
+	}
+	bodyEnd := p.lineNum
+	if closed {
+		p.lineNum++
+	} else {
+		p.reportUnclosed(start)
+	}
+
+	// The delimiter sets the data format unless the format attribute does
+	format := table.GetAttribute("format")
+	if format == "" {
+		switch delimiter[0] {
+		case ',':
+			format = "csv"
+		case ':':
+			format = "dsv"
+		default:
+			format = "psv"
+		}
+	}
+	separator := table.GetAttribute("separator")
+	if separator == `\t` {
+		separator = "\t"
+	}
+
+	var cells []*tableCell
+	switch format {
+	case "csv", "tsv":
+		comma := ','
+		if format == "tsv" {
+			comma = '\t'
+		}
+		if separator != "" {
+			comma = []rune(separator)[0]
+		}
+		cells = p.splitCSVCells(bodyStart, bodyEnd, comma)
+	case "dsv":
+		if separator == "" {
+			separator = ":"
+		}
+		cells = p.splitDSVCells(bodyStart, bodyEnd, separator)
+	default:
+		if separator == "" {
+			separator = delimiter[:1]
+		}
+		cells = p.splitPSVCells(bodyStart, bodyEnd, separator)
+	}
+
+	// Columns come from the cols attribute, or else from the first line.
+	// Each is a node, so there can't be more than the nodes left.
+	maxColumns := p.limits.maxNodes - p.limits.nodes
+	columns := parseColumnSpecs(table.GetAttribute("cols"), maxColumns)
+	firstLine := -1
+	if len(cells) > 0 {
+		firstLine = cells[0].idx
+	}
+	if len(columns) == 0 {
+		n := 0
+		for _, cell := range cells {
+			if cell.idx == firstLine && n <= maxColumns {
+				n += min(cell.spec.colspan, maxColumns+1) * min(cell.spec.repeat, maxColumns+1)
+			}
+		}
+		columns = make([]columnSpec, min(n, maxColumns+1))
+	}
+	p.countNodes(start, len(columns))
+	if p.halted() {
+		p.setSpan(table, start, p.lineNum-1)
+		return table
+	}
+	for _, col := range columnNodes(columns) {
+		table.AddChild(col)
+	}
+
+	// The first row is a header if the options say so, or if the first line
+	// is followed by a blank line and more rows
+	options := "," + table.GetAttribute("options") + ","
+	header := strings.Contains(options, ",header,")
+	if !header && !strings.Contains(options, ",noheader,") && firstLine >= 0 &&
+		cells[len(cells)-1].idx > firstLine+1 && strings.TrimSpace(p.lines[firstLine+1]) == "" {
+		header = true
+	}
+
+	rows := p.buildTableRows(cells, columns, header)
+	if len(rows) > 0 {
+		if header {
+			rows[0].SetAttribute("role", "header")
+		}
+		if strings.Contains(options, ",footer,") && (len(rows) > 1 || !header) {
+			rows[len(rows)-1].SetAttribute("role", "footer")
+		}
+	}
+	for _, row := range rows {
+		table.AddChild(row)
+	}
+
+	p.setSpan(table, start, p.lineNum-1)
+	return table
+}
+
+// columnNodes returns the TableColumn nodes for columns, with widths as
+// percentages of the table. Columns without a width share equally, and ~
+// leaves the width to the renderer.
+func columnNodes(columns []columnSpec) []*Node {
+	total := 0.0
+	for _, col := range columns {
+		if col.width == "~" {
+			continue
+		}
+		w, err := strconv.Atoi(col.width)
+		if err != nil || w < 1 {
+			w = 1
+		}
+		total += float64(w)
+	}
+
+	var nodes []*Node
+	for _, col := range columns {
+		node := NewTableColumnNode()
+		if col.width != "~" {
+			w, err := strconv.Atoi(col.width)
+			if err != nil || w < 1 {
+				w = 1
+			}
+			pc := math.Round(float64(w)*100/total*10000) / 10000
+			node.SetAttribute("width", strconv.FormatFloat(pc, 'f', -1, 64)+"%")
+		}
+		if col.align != "" {
+			node.SetAttribute("align", col.align)
+		}
+		if col.valign != "" {
+			node.SetAttribute("valign", col.valign)
+		}
+		if col.style != "" && col.style != "default" {
+			node.SetAttribute("style", col.style)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// splitPSVCells splits lines from through to-1 into cells that start at
+// separators. A cell specifier may come directly before a separator at the
+// start of a line or after a space; everything else up to the next
+// separator, over any number of lines, is the cell's text. A backslash
+// escapes the separator.
+func (p *parser) splitPSVCells(from, to int, sep string) []*tableCell {
+	var cells []*tableCell
+	var cur *tableCell
+	for idx := from; idx < to; idx++ {
+		line := p.lines[idx]
+		var run strings.Builder // Text since the last separator, escapes removed
+		runOff := 0             // Where that text starts in the line
+		for i := 0; i < len(line); {
+			if line[i] == '\\' && strings.HasPrefix(line[i+1:], sep) {
+				run.WriteString(sep)
+				i += 1 + len(sep)
+				continue
+			}
+			if !strings.HasPrefix(line[i:], sep) {
+				run.WriteByte(line[i])
+				i++
+				continue
+			}
+
+			// A separator ends the current cell and starts the next one
+			text, spec := splitCellSpec(run.String(), runOff == 0)
+			if cur != nil {
+				cur.segments = append(cur.segments, cellSegment{idx: idx, off: runOff, text: text})
+				cells = append(cells, cur)
+			}
+			cur = &tableCell{spec: spec, idx: idx, off: i}
+			i += len(sep)
+			run.Reset()
+			runOff = i
+		}
+		if cur != nil {
+			cur.segments = append(cur.segments, cellSegment{idx: idx, off: runOff, text: run.String()})
+		}
+	}
+	if cur != nil {
+		cells = append(cells, cur)
+	}
+	return cells
+}
+
+// splitCellSpec splits the text before a separator into the end of the
+// previous cell's text and the next cell's specifier. The specifier must
+// start the line or follow a space.
+func splitCellSpec(run string, lineStart bool) (string, cellSpec) {
+	none := cellSpec{repeat: 1, colspan: 1, rowspan: 1}
+	space := strings.LastIndexAny(run, " \t")
+	token := run[space+1:]
+	if token == "" || (space < 0 && !lineStart) {
+		return run, none
+	}
+	spec, ok := parseCellSpec(token)
+	if !ok {
+		return run, none
+	}
+	return run[:space+1], spec
+}
+
+// splitCSVCells splits comma-separated data into cells, one per value.
+// Quoted values may contain the separator, doubled quotes and line breaks.
+func (p *parser) splitCSVCells(from, to int, comma rune) []*tableCell {
+	r := csv.NewReader(strings.NewReader(strings.Join(p.lines[from:to], "\n")))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var cells []*tableCell
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			p.addLineDiagnostic(SeverityError, CodeMalformedTableData, from, "malformed CSV table data: %v", err)
+			break
+		}
+		for i, value := range record {
+			line, col := r.FieldPos(i)
+			idx, off := from+line-1, col-1
+			cell := &tableCell{spec: cellSpec{repeat: 1, colspan: 1, rowspan: 1}, idx: idx, off: off}
+			for j, text := range strings.Split(value, "\n") {
+				if j > 0 {
+					idx, off = idx+1, 0
+				}
+				cell.segments = append(cell.segments, cellSegment{idx: idx, off: off, text: text})
+			}
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// splitDSVCells splits delimiter-separated data into cells. Each line is a
+// record, and a backslash escapes the separator.
+func (p *parser) splitDSVCells(from, to int, sep string) []*tableCell {
+	var cells []*tableCell
+	for idx := from; idx < to; idx++ {
+		line := p.lines[idx]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var text strings.Builder
+		off := 0
+		for i := 0; i <= len(line); {
+			if i < len(line) && line[i] == '\\' && strings.HasPrefix(line[i+1:], sep) {
+				text.WriteString(sep)
+				i += 1 + len(sep)
+				continue
+			}
+			if i < len(line) && !strings.HasPrefix(line[i:], sep) {
+				text.WriteByte(line[i])
+				i++
+				continue
+			}
+			cells = append(cells, &tableCell{
+				spec:     cellSpec{repeat: 1, colspan: 1, rowspan: 1},
+				idx:      idx,
+				off:      off,
+				segments: []cellSegment{{idx: idx, off: off, text: text.String()}},
+			})
+			text.Reset()
+			i += len(sep)
+			off = i
+		}
+	}
+	return cells
+}
+
+// buildTableRows flows cells into rows of len(columns) columns. A cell
+// spanning rows takes its columns in the rows below it, so those rows hold
+// fewer cells. A short last row is reported.
+func (p *parser) buildTableRows(cells []*tableCell, columns []columnSpec, header bool) []*Node {
+	ncols := len(columns)
+	if ncols == 0 {
+		return nil
+	}
+
+	var rows []*Node
+	var row *Node
+	rowStart, rowEnd := 0, 0    // Source lines of the current row
+	taken := make([]int, ncols) // Rows, from the current one, in which each column is taken
+	col := 0
+	skipTaken := func() {
+		for col < ncols && taken[col] > 0 {
+			col++
+		}
+	}
+	endRow := func() {
+		p.setSpan(row, rowStart, rowEnd)
+		rows = append(rows, row)
+		row = nil
+		for i := range taken {
+			if taken[i] > 0 {
+				taken[i]--
+			}
+		}
+		col = 0
+	}
+
+	for _, cell := range cells {
+		// A repeated cell counts as many nodes as it makes
+		for n := 0; n < cell.spec.repeat && !p.halted(); n++ {
+			if row == nil {
+				row = NewTableRowNode()
+				rowStart = cell.idx
+				skipTaken()
+			}
+			if col >= ncols {
+				// Columns taken by spans from above leave no room in this row
+				endRow()
+				row = NewTableRowNode()
+				rowStart = cell.idx
+				skipTaken()
+			}
+			spec := cell.spec
+			if col+spec.colspan > ncols {
+				spec.colspan = ncols - col
+			}
+			if spec.align == "" {
+				spec.align = columns[col].align
+			}
+			if spec.valign == "" {
+				spec.valign = columns[col].valign
+			}
+			if spec.style == "" {
+				spec.style = columns[col].style
+			}
+			if header && len(rows) == 0 {
+				// Header cells are plain text whatever their style
+				spec.style = ""
+			}
+			row.AddChild(p.buildTableCell(cell, spec))
+			p.countNodes(cell.idx, 1)
+			if last := cell.segments; len(last) > 0 && last[len(last)-1].idx > rowEnd {
+				rowEnd = last[len(last)-1].idx
+			}
+			if cell.idx > rowEnd {
+				rowEnd = cell.idx
+			}
+			for i := col; i < col+spec.colspan; i++ {
+				taken[i] = spec.rowspan
+			}
+			col += spec.colspan
+			skipTaken()
+			if col >= ncols {
+				endRow()
+			}
+		}
+	}
+	if row != nil {
+		// A row cut short by a limit isn't missing cells
+		if p.limits.err == nil {
+			p.addDiagnostic(SeverityError, CodeTableCellCount, p.contentPos(rowStart, ""), p.lineEnd(rowEnd), "table row has %d cells, expected %d", col, ncols)
+		}
+		p.setSpan(row, rowStart, rowEnd)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// buildTableCell parses a cell's text according to its style. AsciiDoc
+// cells hold blocks, literal cells a literal block and other cells inline
+// content, or paragraphs when the text has blank lines.
+func (p *parser) buildTableCell(c *tableCell, spec cellSpec) *Node {
+	cell := NewTableCellNode()
+	cell.Start = p.posAt(c.idx, c.off)
+	cell.End = cell.Start
+	if spec.colspan > 1 {
+		cell.SetAttribute("colspan", strconv.Itoa(spec.colspan))
+	}
+	if spec.rowspan > 1 {
+		cell.SetAttribute("rowspan", strconv.Itoa(spec.rowspan))
+	}
+	if spec.align != "" {
+		cell.SetAttribute("align", spec.align)
+	}
+	if spec.valign != "" {
+		cell.SetAttribute("valign", spec.valign)
+	}
+	if spec.style != "" && spec.style != "default" {
+		cell.SetAttribute("style", spec.style)
+	}
+
+	// Drop blank lines around the text
+	segments := c.segments
+	for len(segments) > 0 && strings.TrimSpace(segments[0].text) == "" {
+		segments = segments[1:]
+	}
+	for len(segments) > 0 && strings.TrimSpace(segments[len(segments)-1].text) == "" {
+		segments = segments[:len(segments)-1]
+	}
+	if len(segments) == 0 {
+		return cell
+	}
+	last := segments[len(segments)-1]
+	if trimmed := strings.TrimRight(last.text, " \t"); trimmed != "" {
+		_, size := utf8.DecodeLastRuneInString(trimmed)
+		cell.End = p.posAt(last.idx, last.off+len(trimmed)-size)
+	}
+
+	switch spec.style {
+	case "asciidoc":
+		lines := make([]string, len(segments))
+		for i, seg := range segments {
+			lines[i] = seg.text
+		}
+		lines[0] = strings.TrimLeft(lines[0], " \t")
+		sub := p.newSubParser(strings.Join(lines, "\n"), segments[0].idx)
+		sub.parseContent(cell, nil)
+	case "literal":
+		lines := make([]string, len(segments))
+		for i, seg := range segments {
+			lines[i] = strings.TrimRight(seg.text, " \t")
+		}
+		lines[0] = strings.TrimLeft(lines[0], " \t")
+		literal := NewLiteralBlockNode()
+		literal.AddChild(NewTextNode(strings.Join(lines, "\n")))
+		literal.Start, literal.End = cell.Start, cell.End
+		cell.AddChild(literal)
+	default:
+		// Blank lines separate paragraphs
+		var paragraphs [][]cellSegment
+		var current []cellSegment
+		for _, seg := range segments {
+			if strings.TrimSpace(seg.text) == "" {
+				if len(current) > 0 {
+					paragraphs = append(paragraphs, current)
+					current = nil
+				}
+				continue
+			}
+			current = append(current, seg)
+		}
+		paragraphs = append(paragraphs, current)
+
+		attrs := p.getAllAttributes()
+		for _, para := range paragraphs {
+			src := &inlineSource{}
+			for _, seg := range para {
+				text, drop := p.substituteAttributes(seg.idx, strings.TrimSpace(seg.text), attrs)
+				if drop {
+					continue
+				}
+				off := seg.off + len(seg.text) - len(strings.TrimLeft(seg.text, " \t"))
+				if len(src.segments) > 0 {
+					src.buf.WriteString(" ")
+				}
+				src.addSegment(text, p.posAt(seg.idx, off))
+			}
+			if len(paragraphs) == 1 {
+				p.parseInlineContent(cell, src.text(), src)
+				continue
+			}
+			node := NewParagraphNode()
+			p.setSpan(node, para[0].idx, para[len(para)-1].idx)
+			p.parseInlineContent(node, src.text(), src)
+			cell.AddChild(node)
+		}
+	}
+	return cell
+}
@@ -0,0 +1,270 @@
+package lib
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseTestTable parses input and returns its first table
+func parseTestTable(t *testing.T, input string) *Node {
+	t.Helper()
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	table := findNode(doc, Table)
+	if table == nil {
+		t.Fatalf("Table not found in %q", input)
+	}
+	return table
+}
+
+// tableNodes returns the children of table of type typ
+func tableNodes(table *Node, typ NodeType) []*Node {
+	var nodes []*Node
+	for _, child := range table.Children {
+		if child.Type == typ {
+			nodes = append(nodes, child)
+		}
+	}
+	return nodes
+}
+
+// cellTexts returns the text of each cell of each row
+func cellTexts(table *Node) [][]string {
+	var rows [][]string
+	for _, row := range tableNodes(table, TableRow) {
+		var cells []string
+		for _, cell := range row.Children {
+			var text strings.Builder
+			cell.Traverse(func(n *Node) {
+				if n.Type == Text {
+					text.WriteString(n.Content)
+				}
+			})
+			cells = append(cells, text.String())
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+func TestParseTable_Columns(t *testing.T) {
+	table := parseTestTable(t, `[cols="1,3,>2m"]
+|===
+|a |b |c
+|===`)
+
+	cols := tableNodes(table, TableColumn)
+	if len(cols) != 3 {
+		t.Fatalf("Expected 3 columns, got %d", len(cols))
+	}
+	var widths []string
+	for _, col := range cols {
+		widths = append(widths, col.GetAttribute("width"))
+	}
+	if want := []string{"16.6667%", "50%", "33.3333%"}; !reflect.DeepEqual(widths, want) {
+		t.Errorf("Expected widths %v, got %v", want, widths)
+	}
+	if cols[2].GetAttribute("align") != "right" || cols[2].GetAttribute("style") != "monospace" {
+		t.Errorf("Expected third column right-aligned monospace, got %v", cols[2].Attributes)
+	}
+
+	cell := tableNodes(table, TableRow)[0].Children[2]
+	if cell.GetAttribute("align") != "right" || cell.GetAttribute("style") != "monospace" {
+		t.Errorf("Expected cell to inherit the column's align and style, got %v", cell.Attributes)
+	}
+}
+
+func TestParseTable_Spans(t *testing.T) {
+	input := `[cols="3*"]
+|===
+2+|wide |b
+|c .2+|tall |e
+|f |g
+3*|x
+|===`
+
+	table := parseTestTable(t, input)
+	want := [][]string{{"wide", "b"}, {"c", "tall", "e"}, {"f", "g"}, {"x", "x", "x"}}
+	if got := cellTexts(table); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected rows %v, got %v", want, got)
+	}
+	rows := tableNodes(table, TableRow)
+	if got := rows[0].Children[0].GetAttribute("colspan"); got != "2" {
+		t.Errorf("Expected colspan 2, got %q", got)
+	}
+	if got := rows[1].Children[1].GetAttribute("rowspan"); got != "2" {
+		t.Errorf("Expected rowspan 2, got %q", got)
+	}
+
+	diagnostics, err := ValidateWithDiagnostics(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	if found := diagnosticsWithCode(diagnostics, CodeTableCellCount); len(found) != 0 {
+		t.Errorf("Expected spans to fill every row, got %v", found)
+	}
+}
+
+func TestParseTable_CellStyles(t *testing.T) {
+	table := parseTestTable(t, `[cols="2*"]
+|===
+a|
+* one
+* two
+l|  keep   spacing
+|multi-line
+cell text
+^.>s|styled
+|===`)
+
+	rows := tableNodes(table, TableRow)
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+	asciidoc := rows[0].Children[0]
+	if asciidoc.GetAttribute("style") != "asciidoc" || findNode(asciidoc, List) == nil {
+		t.Errorf("Expected an AsciiDoc cell holding a list, got %v", asciidoc.Children)
+	}
+	literal := rows[0].Children[1]
+	if len(literal.Children) != 1 || literal.Children[0].Type != LiteralBlock ||
+		literal.Children[0].Children[0].Content != "keep   spacing" {
+		t.Errorf("Expected a literal block keeping its spacing, got %v", literal.Children)
+	}
+	if got := cellTexts(table)[1][0]; got != "multi-line cell text" {
+		t.Errorf("Expected a cell spanning lines, got %q", got)
+	}
+	styled := rows[1].Children[1]
+	for k, want := range map[string]string{"align": "center", "valign": "bottom", "style": "strong"} {
+		if got := styled.GetAttribute(k); got != want {
+			t.Errorf("Expected cell %s %q, got %q", k, want, got)
+		}
+	}
+}
+
+func TestParseTable_Header(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		header bool
+	}{
+		{"blank line after first row", "|===\n|A |B\n\n|1 |2\n|===", true},
+		{"no blank line", "|===\n|A |B\n|1 |2\n|===", false},
+		{"header option", "[%header]\n|===\n|A |B\n|1 |2\n|===", true},
+		{"noheader option", "[%noheader]\n|===\n|A |B\n\n|1 |2\n|===", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := tableNodes(parseTestTable(t, tt.input), TableRow)
+			if got := rows[0].GetAttribute("role") == "header"; got != tt.header {
+				t.Errorf("Expected header %v, got %v", tt.header, got)
+			}
+		})
+	}
+
+	rows := tableNodes(parseTestTable(t, "[%header%footer]\n|===\n|A |B\n|1 |2\n|Sum |3\n|==="), TableRow)
+	if rows[len(rows)-1].GetAttribute("role") != "footer" {
+		t.Errorf("Expected the last row to be the footer, got %v", rows[len(rows)-1].Attributes)
+	}
+}
+
+func TestParseTable_DataFormats(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][]string
+	}{
+		{"csv delimiter", ",===\nName,Role\n\"Doe, Jane\",\"Says \"\"hi\"\"\"\n,===", [][]string{{"Name", "Role"}, {"Doe, Jane", `Says "hi"`}}},
+		{"csv format", "[format=csv]\n|===\na,b\nc,d\n|===", [][]string{{"a", "b"}, {"c", "d"}}},
+		{"dsv delimiter", ":===\nroot:x:0\nuser:a\\:b:1\n:===", [][]string{{"root", "x", "0"}, {"user", "a:b", "1"}}},
+		{"custom separator", "[separator=;]\n|===\n;a;b\n;c;d\n|===", [][]string{{"a", "b"}, {"c", "d"}}},
+		{"escaped separator", "|===\n|a \\| b |c\n|===", [][]string{{"a | b", "c"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cellTexts(parseTestTable(t, tt.input)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected rows %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTable_Nested(t *testing.T) {
+	table := parseTestTable(t, `|===
+a|
+!===
+!inner !cell
+!===
+|outer
+|===`)
+
+	cell := tableNodes(table, TableRow)[0].Children[0]
+	inner := findNode(cell, Table)
+	if inner == nil {
+		t.Fatal("Expected a nested table in the AsciiDoc cell")
+	}
+	if got := cellTexts(inner); !reflect.DeepEqual(got, [][]string{{"inner", "cell"}}) {
+		t.Errorf("Expected nested cells, got %v", got)
+	}
+}
+
+func TestToHTML_Table(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`[cols="1,3"]
+|===
+|Name |Notes
+
+.2+|Ada 2+|ignored
+h|Label
+|===`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var buf bytes.Buffer
+	toHTML(doc, &buf, false, 0, RenderOptions{})
+	html := buf.String()
+
+	for _, want := range []string{
+		`<col style="width: 25%;">`,
+		`<col style="width: 75%;">`,
+		"<thead>",
+		`<td rowspan="2">Ada</td>`,
+		`<th data-asciidoc-style="header">Label</th>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected %q in HTML output:\n%s", want, html)
+		}
+	}
+}
+
+func TestToXML_Table(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`[cols="1,1"]
+|===
+2+|span
+a|Para one.
+
+Para two.
+|x
+|===`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var buf bytes.Buffer
+	toXML(doc, &buf, 0, RenderOptions{})
+	xml := buf.String()
+
+	for _, want := range []string{
+		"<colgroup>",
+		`<col width="50%"/>`,
+		`<cell colspan="2">span</cell>`,
+		"<paragraph>Para two.</paragraph>",
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("Expected %q in XML output:\n%s", want, xml)
+		}
+	}
+}
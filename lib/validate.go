@@ -0,0 +1,194 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Severity represents how serious a diagnostic is
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+// String returns the lower-case name of the severity
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the severity by name (used for JSON output)
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic codes reported by ValidateWithDiagnostics
+const (
+	CodeUnclosedDelimiter       = "unclosed-delimiter"
+	CodeDuplicateID             = "duplicate-id"
+	CodeMissingXrefTarget       = "missing-xref-target"
+	CodeUnknownAdmonition       = "unknown-admonition"
+	CodeMalformedAttributeEntry = "malformed-attribute-entry"
+	CodeTableCellCount          = "table-cell-count"
+	CodeUnresolvedInclude       = "unresolved-include"
+	CodeIncludeCycle            = "include-cycle"
+	CodeIncludeDepth            = "include-depth-exceeded"
+	CodeUnbalancedConditional   = "unbalanced-conditional"
+	CodeInvalidConditional      = "invalid-conditional"
+	CodeMalformedAttributeList  = "malformed-attribute-list"
+	CodeMalformedTableData      = "malformed-table-data"
+	CodeMissingAttribute        = "missing-attribute"
+	CodeUnmatchedCallout        = "unmatched-callout"
+	CodeUnknownSubstitution     = "unknown-substitution"
+)
+
+// Diagnostic is a problem found in an AsciiDoc document
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Start    Position `json:"start"`
+	End      Position `json:"end"`
+}
+
+// String formats the diagnostic as "line:column: severity: message [code]"
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", d.Start, d.Severity, d.Message, d.Code)
+}
+
+// ValidationError is returned by Validate when a document has error diagnostics
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+// Error joins the diagnostics into a single message
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		msgs[i] = d.String()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateWithDiagnostics parses AsciiDoc content and reports every problem found.
+// The returned error is only non-nil if the content could not be read, or
+// if it went past a parse limit, in which case the problems found up to
+// then are returned along with the *LimitError.
+func ValidateWithDiagnostics(reader io.Reader) ([]Diagnostic, error) {
+	return ValidateWithOptions(reader, ParseOptions{})
+}
+
+// ValidateWithOptions is like ValidateWithDiagnostics but parses with opts,
+// so problems in included documents are reported too.
+func ValidateWithOptions(reader io.Reader, opts ParseOptions) ([]Diagnostic, error) {
+	return ValidateContext(context.Background(), reader, opts)
+}
+
+// ValidateContext is like ValidateWithOptions but parses under ctx, stopping
+// with a *LimitError once it is done
+func ValidateContext(ctx context.Context, reader io.Reader, opts ParseOptions) ([]Diagnostic, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newParser(string(content))
+	p.opts = opts
+	doc, err := p.parse(ctx)
+	p.checkTree(doc)
+
+	return *p.diagnostics, err
+}
+
+// HasErrors reports whether any of the diagnostics has error severity
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// addDiagnostic records a diagnostic against the source range start..end
+func (p *parser) addDiagnostic(severity Severity, code string, start, end Position, format string, args ...interface{}) {
+	*p.diagnostics = append(*p.diagnostics, Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Start:    start,
+		End:      end,
+	})
+}
+
+// addLineDiagnostic records a diagnostic covering line idx
+func (p *parser) addLineDiagnostic(severity Severity, code string, idx int, format string, args ...interface{}) {
+	p.addDiagnostic(severity, code, p.contentPos(idx, ""), p.lineEnd(idx), format, args...)
+}
+
+// reportUnclosed records a delimited block opened at line idx that reached
+// the end of its container without a closing delimiter
+func (p *parser) reportUnclosed(idx int) {
+	delimiter := strings.TrimSpace(p.lines[idx])
+	p.addLineDiagnostic(SeverityError, CodeUnclosedDelimiter, idx, "unclosed delimited block: no closing %q found", delimiter)
+}
+
+// registerAnchor records an explicit ID defined at start..end, reporting it
+// if the ID is already taken
+func (p *parser) registerAnchor(id string, node *Node, start, end Position) {
+	if _, exists := p.anchors[id]; exists {
+		p.addDiagnostic(SeverityError, CodeDuplicateID, start, end, "duplicate ID %q", id)
+	}
+	p.anchors[id] = node
+}
+
+// checkAttributeEntry reports line idx if it looks like an attribute entry but is malformed
+func (p *parser) checkAttributeEntry(idx int) {
+	line := strings.TrimSpace(p.lines[idx])
+	if !attributeEntryLineRegex.MatchString(line) {
+		p.addLineDiagnostic(SeverityError, CodeMalformedAttributeEntry, idx, "malformed attribute entry %q", line)
+	}
+}
+
+// admonitionStyles lists the admonition labels AsciiDoc recognizes
+var admonitionStyles = map[string]bool{
+	"NOTE": true, "TIP": true, "IMPORTANT": true, "WARNING": true, "CAUTION": true,
+}
+
+// upperStyleRegex matches an all-caps block style, which AsciiDoc reserves for admonitions
+var upperStyleRegex = regexp.MustCompile(`^[A-Z]{2,}$`)
+
+// checkBlockAttributes reports a malformed block attribute line, or one whose
+// style looks like an admonition label but is not one of the known labels
+func (p *parser) checkBlockAttributes(idx int) {
+	line := strings.TrimSpace(p.lines[idx])
+	attrs, err := ParseAttributeList(line[1 : len(line)-1])
+	if err != nil {
+		p.addLineDiagnostic(SeverityError, CodeMalformedAttributeList, idx, "%v", err)
+	}
+	if upperStyleRegex.MatchString(attrs.Style) && !admonitionStyles[attrs.Style] {
+		p.addLineDiagnostic(SeverityWarning, CodeUnknownAdmonition, idx, "unknown admonition label %q", attrs.Style)
+	}
+}
+
+// checkTree runs the checks that need the complete document tree
+func (p *parser) checkTree(doc *Node) {
+	// References to other documents can't be checked here
+	for _, xref := range UnresolvedXrefs(doc) {
+		p.addDiagnostic(SeverityWarning, CodeMissingXrefTarget, xref.Start, xref.End, "cross reference to missing anchor %q", xref.Fragment)
+	}
+}
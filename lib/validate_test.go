@@ -0,0 +1,227 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// diagnosticsWithCode returns the diagnostics that have the given code
+func diagnosticsWithCode(diagnostics []Diagnostic, code string) []Diagnostic {
+	var matched []Diagnostic
+	for _, d := range diagnostics {
+		if d.Code == code {
+			matched = append(matched, d)
+		}
+	}
+	return matched
+}
+
+func TestValidateWithDiagnostics_ValidDocument(t *testing.T) {
+	input := `= Test Document
+:toc:
+
+[[intro]]
+== Introduction
+
+See <<intro>> and <<Introduction>>.
+
+[NOTE]
+This is a note.
+
+|===
+|A |B
+|1 |2
+|===`
+
+	diagnostics, err := ValidateWithDiagnostics(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", diagnostics)
+	}
+}
+
+func TestValidateWithDiagnostics_UnclosedDelimiters(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{"listing", "Text.\n\n----\ncode", 3},
+		{"example", "====\nExample", 1},
+		{"sidebar", "Intro.\n****\nSidebar", 2},
+		{"table", "|===\n|A |B", 1},
+		{"nested", "====\n----\ncode\n====", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics, err := ValidateWithDiagnostics(bytes.NewReader([]byte(tt.input)))
+			if err != nil {
+				t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+			}
+			found := diagnosticsWithCode(diagnostics, CodeUnclosedDelimiter)
+			if len(found) != 1 {
+				t.Fatalf("Expected 1 unclosed-delimiter diagnostic, got %v", diagnostics)
+			}
+			if found[0].Severity != SeverityError {
+				t.Errorf("Expected error severity, got %s", found[0].Severity)
+			}
+			if found[0].Start.Line != tt.line {
+				t.Errorf("Expected diagnostic on line %d, got %s", tt.line, found[0].Start)
+			}
+		})
+	}
+}
+
+func TestValidateWithDiagnostics_DuplicateIDs(t *testing.T) {
+	input := `[[setup]]
+== Setup
+
+Text.
+
+====
+[[setup]]
+Duplicate inside a block.
+====`
+
+	diagnostics, err := ValidateWithDiagnostics(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	found := diagnosticsWithCode(diagnostics, CodeDuplicateID)
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 duplicate-id diagnostic, got %v", diagnostics)
+	}
+	if found[0].Start.Line != 7 {
+		t.Errorf("Expected duplicate ID reported on line 7, got %s", found[0].Start)
+	}
+}
+
+func TestValidateWithDiagnostics_MissingXrefTarget(t *testing.T) {
+	input := `== Section
+
+See <<nowhere>> and xref:other.adoc#part[].`
+
+	diagnostics, err := ValidateWithDiagnostics(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	found := diagnosticsWithCode(diagnostics, CodeMissingXrefTarget)
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 missing-xref-target diagnostic, got %v", diagnostics)
+	}
+	if found[0].Severity != SeverityWarning {
+		t.Errorf("Expected warning severity, got %s", found[0].Severity)
+	}
+	if found[0].Start != (Position{Line: 3, Column: 5}) {
+		t.Errorf("Expected diagnostic at 3:5, got %s", found[0].Start)
+	}
+}
+
+func TestValidateWithDiagnostics_UnknownAdmonition(t *testing.T) {
+	input := `[WARN]
+Careful now.
+
+[source,go]
+----
+x := 1
+----`
+
+	diagnostics, err := ValidateWithDiagnostics(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	found := diagnosticsWithCode(diagnostics, CodeUnknownAdmonition)
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 unknown-admonition diagnostic, got %v", diagnostics)
+	}
+	if found[0].Start.Line != 1 {
+		t.Errorf("Expected diagnostic on line 1, got %s", found[0].Start)
+	}
+}
+
+func TestValidateWithDiagnostics_MalformedAttributeEntry(t *testing.T) {
+	input := `= Title
+:valid-name: value
+:bad name: value
+:missing-space:value
+
+Body.
+
+:also bad: here`
+
+	diagnostics, err := ValidateWithDiagnostics(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+	}
+	found := diagnosticsWithCode(diagnostics, CodeMalformedAttributeEntry)
+	if len(found) != 3 {
+		t.Fatalf("Expected 3 malformed-attribute-entry diagnostics, got %v", diagnostics)
+	}
+	for i, line := range []int{3, 4, 8} {
+		if found[i].Start.Line != line {
+			t.Errorf("Expected diagnostic %d on line %d, got %s", i, line, found[i].Start)
+		}
+	}
+}
+
+func TestValidateWithDiagnostics_TableCellCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"complete rows", "|===\n|A |B\n|1 |2\n|===", 0},
+		{"one cell per line", "|===\n|A |B\n\n|1\n|2\n|===", 0},
+		{"short last row", "|===\n|A |B |C\n|1 |2\n|===", 1},
+		{"cols attribute", "[cols=\"1,1,1\"]\n|===\n|A |B\n|===", 1},
+		{"cols multiplier", "[cols=\"2*\"]\n|===\n|A |B\n|1 |2\n|===", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics, err := ValidateWithDiagnostics(bytes.NewReader([]byte(tt.input)))
+			if err != nil {
+				t.Fatalf("ValidateWithDiagnostics failed: %v", err)
+			}
+			if found := diagnosticsWithCode(diagnostics, CodeTableCellCount); len(found) != tt.want {
+				t.Errorf("Expected %d table-cell-count diagnostics, got %v", tt.want, diagnostics)
+			}
+		})
+	}
+}
+
+func TestValidate_ReturnsValidationError(t *testing.T) {
+	err := Validate(bytes.NewReader([]byte("----\nunclosed")))
+	if err == nil {
+		t.Fatal("Expected an error for an unclosed block")
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected *ValidationError, got %T", err)
+	}
+	if len(validationErr.Diagnostics) != 1 {
+		t.Errorf("Expected 1 diagnostic, got %d", len(validationErr.Diagnostics))
+	}
+
+	// Warnings alone don't make a document invalid
+	if err := Validate(bytes.NewReader([]byte("See <<nowhere>>."))); err != nil {
+		t.Errorf("Expected warnings to be ignored by Validate, got %v", err)
+	}
+}
+
+func TestDiagnostic_String(t *testing.T) {
+	d := Diagnostic{
+		Severity: SeverityWarning,
+		Code:     CodeMissingXrefTarget,
+		Message:  "cross reference to missing anchor \"x\"",
+		Start:    Position{Line: 2, Column: 4},
+	}
+	want := `2:4: warning: cross reference to missing anchor "x" [missing-xref-target]`
+	if got := d.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,191 @@
+package lib
+
+import "slices"
+
+// WalkAction tells Walk what to do once a Visitor callback returns
+type WalkAction int
+
+const (
+	// WalkContinue goes on with the walk as usual
+	WalkContinue WalkAction = iota
+	// WalkSkipChildren leaves the node's children unvisited. Leave is still
+	// called for the node. From Leave it is the same as WalkContinue.
+	WalkSkipChildren
+	// WalkStop ends the walk straight away, without calling Leave for the
+	// nodes still open
+	WalkStop
+	// WalkReplace puts the nodes given to Cursor.Replace in place of the node,
+	// or removes it if there are none. The replacements aren't visited, and
+	// from Enter, neither are the node's children nor Leave.
+	WalkReplace
+	// WalkRemove removes the node. From Enter, its children aren't visited
+	// and Leave isn't called.
+	WalkRemove
+)
+
+// Visitor holds the callbacks Walk makes for each node: Enter before its
+// children are visited and Leave after. Either may be nil.
+type Visitor struct {
+	Enter func(c *Cursor) WalkAction
+	Leave func(c *Cursor) WalkAction
+}
+
+// Cursor is the node a Visitor callback is made for, along with where it is
+// in the tree. Its methods change the tree around the node in ways the walk
+// keeps track of, so they are safe to use while walking; other changes to
+// the node's parent are not. A Cursor is only valid during the callback.
+type Cursor struct {
+	w           *walker
+	node        *Node
+	parent      *Node
+	index       int
+	depth       int
+	level       *Node // Parent whose children the walk is iterating
+	last        int   // Index in level.Children of the last node in this node's place
+	replacement []*Node
+}
+
+// Node returns the node being visited
+func (c *Cursor) Node() *Node {
+	return c.node
+}
+
+// Parent returns the node's parent, or nil for the root of the walk
+func (c *Cursor) Parent() *Node {
+	if c.parent == c.w.top {
+		return nil
+	}
+	return c.parent
+}
+
+// Index returns the node's index in its parent's Children
+func (c *Cursor) Index() int {
+	return c.index
+}
+
+// Depth returns how deep the node is below the root of the walk, 0 for the
+// root itself
+func (c *Cursor) Depth() int {
+	return c.depth
+}
+
+// Replace sets the nodes to put in place of the node and returns
+// WalkReplace, for a callback to return
+func (c *Cursor) Replace(nodes ...*Node) WalkAction {
+	c.replacement = nodes
+	return WalkReplace
+}
+
+// InsertBefore inserts nodes before the node in its parent. They aren't
+// visited. It panics for the root of the walk.
+func (c *Cursor) InsertBefore(nodes ...*Node) {
+	c.insert(c.index, nodes, "InsertBefore")
+	c.index += len(nodes)
+}
+
+// InsertAfter inserts nodes after the node in its parent. They aren't
+// visited. It panics for the root of the walk.
+func (c *Cursor) InsertAfter(nodes ...*Node) {
+	c.insert(c.index+1, nodes, "InsertAfter")
+}
+
+// Wrap puts wrapper in place of the node and adds the node as wrapper's
+// last child. The walk carries on into the node's children as usual;
+// wrapper itself isn't visited. Wrapping the root of the walk makes wrapper
+// the new root that Walk returns.
+func (c *Cursor) Wrap(wrapper *Node) {
+	c.parent.Children[c.index] = wrapper
+	wrapper.Children = append(wrapper.Children, c.node)
+	c.parent = wrapper
+	c.index = len(wrapper.Children) - 1
+	c.depth++
+}
+
+// insert adds nodes to the node's parent at index i
+func (c *Cursor) insert(i int, nodes []*Node, method string) {
+	if c.parent == c.w.top {
+		panic("lib: Cursor." + method + " called for the root of a walk")
+	}
+	c.parent.Children = slices.Insert(c.parent.Children, i, nodes...)
+	if c.parent == c.level {
+		c.last += len(nodes)
+	}
+}
+
+// apply replaces or removes the node for action
+func (c *Cursor) apply(action WalkAction) {
+	nodes := c.replacement
+	if action == WalkRemove {
+		nodes = nil
+	}
+	if c.parent == c.w.top && len(nodes) > 1 {
+		panic("lib: the root of a walk can't be replaced by more than one node")
+	}
+	c.parent.Children = slices.Replace(c.parent.Children, c.index, c.index+1, nodes...)
+	if c.parent == c.level {
+		c.last += len(nodes) - 1
+	}
+}
+
+// walker holds the state of a Walk
+type walker struct {
+	v   Visitor
+	top *Node // Holds the root, so that it can be replaced like any node
+}
+
+// Walk visits root and the nodes below it depth-first, calling v.Enter
+// before a node's children and v.Leave after. The actions they return can
+// skip a node's children, stop the walk, or replace or remove the node, and
+// the Cursor they are given can insert nodes around it or wrap it.
+//
+// Walk returns the root, or what replaced or wrapped it; nil if it was
+// removed.
+func Walk(root *Node, v Visitor) *Node {
+	w := &walker{v: v, top: &Node{Children: []*Node{root}}}
+	w.walkChildren(w.top, 0)
+	if len(w.top.Children) == 0 {
+		return nil
+	}
+	return w.top.Children[0]
+}
+
+// walkChildren visits the children of parent, which are at depth, and
+// reports whether the walk should go on
+func (w *walker) walkChildren(parent *Node, depth int) bool {
+	for i := 0; i < len(parent.Children); i++ {
+		c := &Cursor{w: w, node: parent.Children[i], parent: parent, index: i, depth: depth, level: parent, last: i}
+		if !w.visit(c) {
+			return false
+		}
+		i = c.last
+	}
+	return true
+}
+
+// visit makes the callbacks for the node at c and walks its children
+func (w *walker) visit(c *Cursor) bool {
+	skip := false
+	if w.v.Enter != nil {
+		switch action := w.v.Enter(c); action {
+		case WalkStop:
+			return false
+		case WalkReplace, WalkRemove:
+			c.apply(action)
+			return true
+		case WalkSkipChildren:
+			skip = true
+		}
+	}
+	if !skip && !w.walkChildren(c.node, c.depth+1) {
+		return false
+	}
+	if w.v.Leave != nil {
+		switch action := w.v.Leave(c); action {
+		case WalkStop:
+			return false
+		case WalkReplace, WalkRemove:
+			c.apply(action)
+		}
+	}
+	return true
+}
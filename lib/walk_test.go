@@ -0,0 +1,128 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWalk_Order(t *testing.T) {
+	doc, err := Parse(strings.NewReader("== One\n\nFirst *bold*.\n\n== Two\n\nSecond."))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var got []string
+	Walk(doc, Visitor{
+		Enter: func(c *Cursor) WalkAction {
+			got = append(got, "+"+c.Node().Type.String())
+			if c.Node().Type == Paragraph {
+				return WalkSkipChildren
+			}
+			return WalkContinue
+		},
+		Leave: func(c *Cursor) WalkAction {
+			got = append(got, "-"+c.Node().Type.String())
+			if c.Node().Type == Section && c.Node().GetAttribute("title") == "One" {
+				return WalkStop
+			}
+			return WalkContinue
+		},
+	})
+	want := "+Document +Section +Text -Text +Paragraph -Paragraph -Section"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Expected %q, got %q", want, strings.Join(got, " "))
+	}
+}
+
+func TestWalk_ReplaceAndRemove(t *testing.T) {
+	input := "== Public\n\nSee https://old.example.com/page[the page].\n\n[.internal]\n== Internal\n\nNotes.\n\n== Also Public\n\nMore."
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	Walk(doc, Visitor{
+		Enter: func(c *Cursor) WalkAction {
+			n := c.Node()
+			if n.Type == Section && n.GetAttribute("role") == "internal" {
+				return WalkRemove
+			}
+			if n.Type == Link {
+				link := NewLinkNode()
+				link.SetAttribute("href", strings.Replace(n.GetAttribute("href"), "old.", "new.", 1))
+				link.Children = n.Children
+				return c.Replace(link)
+			}
+			return WalkContinue
+		},
+	})
+	xml := ToXML(doc)
+	if strings.Contains(xml, "Internal") || strings.Contains(xml, "old.example.com") {
+		t.Errorf("Expected the internal section and old link to be gone:\n%s", xml)
+	}
+	if !strings.Contains(xml, "https://new.example.com/page") || !strings.Contains(xml, "Also Public") {
+		t.Errorf("Expected the new link and the last section:\n%s", xml)
+	}
+}
+
+func TestWalk_InsertAndWrap(t *testing.T) {
+	doc := NewDocumentNode()
+	for _, text := range []string{"a", "b", "c"} {
+		para := NewParagraphNode()
+		para.AddChild(NewTextNode(text))
+		doc.AddChild(para)
+	}
+	var visited []string
+	Walk(doc, Visitor{
+		Enter: func(c *Cursor) WalkAction {
+			n := c.Node()
+			if n.Type != Text {
+				return WalkContinue
+			}
+			visited = append(visited, n.Content)
+			switch n.Content {
+			case "a":
+				c.InsertBefore(NewTextNode("before"))
+				c.InsertAfter(NewTextNode("after"))
+			case "b":
+				c.Wrap(NewBoldNode())
+				if c.Parent().Type != Bold || c.Depth() != 3 {
+					t.Errorf("Expected b inside the wrapper, got %v at depth %d", c.Parent().Type, c.Depth())
+				}
+			case "c":
+				return WalkRemove
+			}
+			return WalkContinue
+		},
+	})
+	if got := strings.Join(visited, " "); got != "a b c" {
+		t.Errorf("Expected only the original text visited, got %q", got)
+	}
+	if got := getTextContent(doc.Children[0]); got != "beforeaafter" {
+		t.Errorf("Expected text inserted around a, got %q", got)
+	}
+	if b := doc.Children[1].Children; len(b) != 1 || b[0].Type != Bold || b[0].Children[0].Content != "b" {
+		t.Errorf("Expected b to be wrapped, got %v", b)
+	}
+	if len(doc.Children[2].Children) != 0 {
+		t.Errorf("Expected c to be removed, got %v", doc.Children[2].Children)
+	}
+}
+
+func TestWalk_Root(t *testing.T) {
+	doc := NewDocumentNode()
+	root := Walk(doc, Visitor{
+		Enter: func(c *Cursor) WalkAction {
+			if c.Parent() == nil {
+				c.Wrap(NewOpenBlockNode())
+			}
+			return WalkContinue
+		},
+	})
+	if root.Type != OpenBlock || root.Children[0] != doc {
+		t.Errorf("Expected the wrapper as the new root, got %v", root)
+	}
+
+	root = Walk(doc, Visitor{Leave: func(c *Cursor) WalkAction { return WalkRemove }})
+	if root != nil {
+		t.Errorf("Expected nil once the root is removed, got %v", root)
+	}
+}
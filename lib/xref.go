@@ -0,0 +1,304 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultOutFileSuffix is the extension given to inter-document cross
+// references unless the outfilesuffix attribute sets another
+const DefaultOutFileSuffix = ".html"
+
+// resolveXrefs links each cross reference in doc to its target. A target is
+// an ID, or else the title or reftext of a section or block, as in
+// <<Section Title>>. Resolved references get a refid attribute naming the
+// target's ID. References to other documents, such as other.adoc#id, get a
+// document attribute instead. Every reference gets an href, and those
+// written without text take it from their target.
+func (p *parser) resolveXrefs(doc *Node) {
+	titles := make(map[string]*Node)
+	for _, xref := range collectXrefs(doc, titles) {
+		p.resolveXref(xref, titles)
+	}
+}
+
+// collectXrefs returns the cross references in doc, adding the titles and
+// reftexts of its sections and blocks with IDs to titles for natural cross
+// references; the first one wins
+func collectXrefs(doc *Node, titles map[string]*Node) []*Node {
+	var xrefs []*Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == InlineMacro && n.Name == "xref" {
+			xrefs = append(xrefs, n)
+			return
+		}
+		if n.Type == Document || n.GetAttribute("id") == "" {
+			return
+		}
+		for _, key := range []string{"reftext", "title"} {
+			if text := n.GetAttribute(key); text != "" && titles[text] == nil {
+				titles[text] = n
+			}
+		}
+	})
+	return xrefs
+}
+
+// resolveXref links xref to its target, looking up natural cross references
+// in titles. It reports whether the target was found or is in another
+// document.
+func (p *parser) resolveXref(xref *Node, titles map[string]*Node) bool {
+	suffix := p.attributes["outfilesuffix"]
+	if suffix == "" {
+		suffix = DefaultOutFileSuffix
+	}
+	self := filepath.ToSlash(p.opts.DocumentName)
+	self = strings.TrimSuffix(self, path.Ext(self))
+
+	target := xref.GetAttribute("target")
+	file, fragment := splitXrefTarget(target)
+	if file != "" && fragment != "" {
+		// A reference to the document itself is a reference within it
+		other := path.Join(path.Dir(self), file)
+		if strings.TrimSuffix(other, path.Ext(other)) == self {
+			file = ""
+		}
+	}
+	if file != "" {
+		xref.SetAttribute("document", file)
+		xref.SetAttribute("href", interDocumentHref(file, fragment, suffix))
+		if len(xref.Children) == 0 {
+			xref.AddChild(NewTextNode(target))
+		}
+		return true
+	}
+
+	node := p.anchors[fragment]
+	if node == nil {
+		node = titles[fragment]
+	}
+	text := fragment
+	if node != nil {
+		id := node.GetAttribute("id")
+		xref.SetAttribute("refid", id)
+		xref.SetAttribute("href", "#"+id)
+		text = p.xrefText(node, fragment)
+	} else {
+		xref.SetAttribute("href", "#"+fragment)
+	}
+	if len(xref.Children) == 0 {
+		xref.AddChild(NewTextNode(text))
+	}
+	return node != nil
+}
+
+// splitXrefTarget splits a cross reference target into the document it
+// names, if any, and the ID within it. A target names a document when it has
+// a # or ends in .adoc, as in other.adoc#id, other#id or other.adoc.
+func splitXrefTarget(target string) (file, fragment string) {
+	if i := strings.IndexByte(target, '#'); i >= 0 {
+		return target[:i], target[i+1:]
+	}
+	if strings.HasSuffix(target, ".adoc") {
+		return target, ""
+	}
+	return "", target
+}
+
+// splitAnchor splits the text between an anchor's double brackets, as in
+// [[id,reftext]], into the ID and the reftext, if any
+func splitAnchor(text string) (id, reftext string) {
+	id, reftext, _ = strings.Cut(text, ",")
+	return strings.TrimSpace(id), strings.TrimSpace(reftext)
+}
+
+// interDocumentHref returns the link to fragment in file once file is
+// converted to a document ending in suffix
+func interDocumentHref(file, fragment, suffix string) string {
+	if ext := path.Ext(file); ext == ".adoc" || ext == "" {
+		file = strings.TrimSuffix(file, ext) + suffix
+	}
+	if fragment != "" {
+		file += "#" + fragment
+	}
+	return file
+}
+
+// xrefText returns the text for a cross reference to node: the label of a
+// bibliography entry in brackets, its reftext, or else its title, preceded
+// by its number according to the xrefstyle attribute, or else fallback. The
+// full style gives "Figure 1, “Title”", the short style "Figure 1" and the
+// basic style just the title. Without an xrefstyle, numbered blocks use the
+// full style and sections the basic one.
+func (p *parser) xrefText(node *Node, fallback string) string {
+	// A bibliography entry is cited by its label
+	if node.Type == BibliographyAnchor {
+		return "[" + node.GetAttribute("label") + "]"
+	}
+	if text := node.GetAttribute("reftext"); text != "" {
+		return text
+	}
+	title := node.GetAttribute("title")
+	style := p.attributes["xrefstyle"]
+	if style == "" && node.Type != Section {
+		style = "full"
+	}
+	if signifier := p.refSignifier(node); signifier != "" {
+		switch {
+		case style == "short" || style == "full" && title == "":
+			return signifier
+		case style == "full":
+			return signifier + ", “" + title + "”"
+		}
+	}
+	if title != "" {
+		return title
+	}
+	return fallback
+}
+
+// Xref describes a cross reference in a parsed document
+type Xref struct {
+	Target   string   `json:"target"`             // As written, e.g. intro or other.adoc#intro
+	Document string   `json:"document,omitempty"` // Referenced document, for inter-document references
+	Fragment string   `json:"fragment,omitempty"` // ID within the document referenced
+	Resolved bool     `json:"resolved"`           // Whether the target was found; always false for inter-document references
+	Start    Position `json:"start"`
+	End      Position `json:"end"`
+}
+
+// Xrefs returns the cross references of a document returned by Parse, in
+// document order
+func Xrefs(doc *Node) []Xref {
+	var xrefs []Xref
+	doc.Traverse(func(n *Node) {
+		if n.Type != InlineMacro || n.Name != "xref" {
+			return
+		}
+		target := n.GetAttribute("target")
+		_, fragment := splitXrefTarget(target)
+		xrefs = append(xrefs, Xref{
+			Target:   target,
+			Document: n.GetAttribute("document"),
+			Fragment: fragment,
+			Resolved: n.GetAttribute("refid") != "",
+			Start:    n.Start,
+			End:      n.End,
+		})
+	})
+	return xrefs
+}
+
+// UnresolvedXrefs returns the cross references within a document returned
+// by Parse whose target doesn't exist. References to other documents can
+// only be checked against those documents; see LinkChecker.
+func UnresolvedXrefs(doc *Node) []Xref {
+	var unresolved []Xref
+	for _, xref := range Xrefs(doc) {
+		if xref.Document == "" && !xref.Resolved {
+			unresolved = append(unresolved, xref)
+		}
+	}
+	return unresolved
+}
+
+// BrokenLink is a cross reference that doesn't resolve
+type BrokenLink struct {
+	File    string `json:"file"`
+	Xref    Xref   `json:"xref"`
+	Message string `json:"message"`
+}
+
+// String formats the link as "file:line:column: message"
+func (l BrokenLink) String() string {
+	return fmt.Sprintf("%s:%s: %s", l.File, l.Xref.Start, l.Message)
+}
+
+// LinkChecker collects the IDs and cross references of a set of documents,
+// such as the files of a batch, so that references between them can be
+// checked once all are parsed. It is safe for concurrent use.
+type LinkChecker struct {
+	mu   sync.Mutex
+	docs map[string]*linkedDocument
+}
+
+// linkedDocument is what LinkChecker keeps of a document
+type linkedDocument struct {
+	ids   map[string]bool
+	xrefs []Xref
+}
+
+// NewLinkChecker creates an empty LinkChecker
+func NewLinkChecker() *LinkChecker {
+	return &LinkChecker{docs: make(map[string]*linkedDocument)}
+}
+
+// Add records the document parsed from file
+func (c *LinkChecker) Add(file string, doc *Node) {
+	ids := make(map[string]bool)
+	doc.Traverse(func(n *Node) {
+		if id := n.GetAttribute("id"); id != "" {
+			ids[id] = true
+			if n.Type == Section {
+				// Sections can be referenced with the conventional _ prefix too
+				ids["_"+id] = true
+			}
+		}
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs[filepath.Clean(file)] = &linkedDocument{ids: ids, xrefs: Xrefs(doc)}
+}
+
+// BrokenLinks returns the cross references of the added documents that
+// don't resolve, ordered by file and position. A reference to a document
+// that wasn't added is only reported if the document doesn't exist.
+func (c *LinkChecker) BrokenLinks() []BrokenLink {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var broken []BrokenLink
+	for file, doc := range c.docs {
+		for _, xref := range doc.xrefs {
+			if xref.Document == "" {
+				if !xref.Resolved {
+					broken = append(broken, BrokenLink{File: file, Xref: xref, Message: fmt.Sprintf("cross reference to missing anchor %q", xref.Fragment)})
+				}
+				continue
+			}
+
+			other := filepath.Join(filepath.Dir(file), filepath.FromSlash(xref.Document))
+			if filepath.Ext(other) == "" {
+				other += ".adoc"
+			}
+			target := c.docs[other]
+			if target == nil {
+				if _, err := os.Stat(other); err != nil {
+					broken = append(broken, BrokenLink{File: file, Xref: xref, Message: fmt.Sprintf("cross reference to missing document %q", xref.Document)})
+				}
+				continue
+			}
+			if xref.Fragment != "" && !target.ids[xref.Fragment] {
+				broken = append(broken, BrokenLink{File: file, Xref: xref, Message: fmt.Sprintf("cross reference to missing anchor %q in %s", xref.Fragment, xref.Document)})
+			}
+		}
+	}
+
+	sort.Slice(broken, func(i, j int) bool {
+		a, b := broken[i], broken[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Xref.Start.Line != b.Xref.Start.Line {
+			return a.Xref.Start.Line < b.Xref.Start.Line
+		}
+		return a.Xref.Start.Column < b.Xref.Start.Column
+	})
+	return broken
+}
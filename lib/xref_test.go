@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// xrefNodes returns the cross references of doc in document order
+func xrefNodes(doc *Node) []*Node {
+	var xrefs []*Node
+	doc.Traverse(func(n *Node) {
+		if n.Type == InlineMacro && n.Name == "xref" {
+			xrefs = append(xrefs, n)
+		}
+	})
+	return xrefs
+}
+
+func TestResolveXrefs(t *testing.T) {
+	input := `== Getting Started
+
+See <<installation>>, <<Installation>>, <<_installation>>, <<cfg>>, <<term>> and <<installation,the install steps>>.
+
+[[installation]]
+== Installation
+
+[#cfg]
+.Sample configuration
+----
+key = value
+----
+
+A [[term,Glossary term]]term to define.`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := []struct {
+		text  string
+		refid string
+	}{
+		{"Installation", "installation"},
+		{"Installation", "installation"},
+		{"Installation", "installation"},
+		{"Listing 1, “Sample configuration”", "cfg"},
+		{"Glossary term", "term"},
+		{"the install steps", "installation"},
+	}
+	xrefs := xrefNodes(doc)
+	if len(xrefs) != len(tests) {
+		t.Fatalf("Expected %d xrefs, got %d", len(tests), len(xrefs))
+	}
+	for i, tt := range tests {
+		xref := xrefs[i]
+		if got := getTextContent(xref); got != tt.text {
+			t.Errorf("xref %d: expected text %q, got %q", i, tt.text, got)
+		}
+		if got := xref.GetAttribute("refid"); got != tt.refid {
+			t.Errorf("xref %d: expected refid %q, got %q", i, tt.refid, got)
+		}
+		if got := xref.GetAttribute("href"); got != "#"+tt.refid {
+			t.Errorf("xref %d: expected href #%s, got %q", i, tt.refid, got)
+		}
+	}
+	if unresolved := UnresolvedXrefs(doc); len(unresolved) != 0 {
+		t.Errorf("Expected every xref to resolve, got %v", unresolved)
+	}
+}
+
+func TestResolveXrefs_InterDocument(t *testing.T) {
+	input := `See xref:guide.adoc#setup[], <<guide#setup,Setup>>, xref:ref/api.adoc[] and <<self.adoc#here>>.
+
+[[here]]Here.`
+
+	tests := []struct {
+		name   string
+		suffix string
+		hrefs  []string
+	}{
+		{"default suffix", "", []string{"guide.html#setup", "guide.html#setup", "ref/api.html", "#here"}},
+		{"outfilesuffix", ".xhtml", []string{"guide.xhtml#setup", "guide.xhtml#setup", "ref/api.xhtml", "#here"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ParseOptions{DocumentName: "self.adoc"}
+			if tt.suffix != "" {
+				opts.Attributes = map[string]string{"outfilesuffix": tt.suffix}
+			}
+			doc, err := ParseWithOptions(context.Background(), strings.NewReader(input), opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions failed: %v", err)
+			}
+			xrefs := xrefNodes(doc)
+			if len(xrefs) != len(tt.hrefs) {
+				t.Fatalf("Expected %d xrefs, got %d", len(tt.hrefs), len(xrefs))
+			}
+			for i, want := range tt.hrefs {
+				if got := xrefs[i].GetAttribute("href"); got != want {
+					t.Errorf("xref %d: expected href %q, got %q", i, want, got)
+				}
+			}
+			if got := getTextContent(xrefs[0]); got != "guide.adoc#setup" {
+				t.Errorf("Expected inter-document xref text to be the target, got %q", got)
+			}
+			if got := xrefs[2].GetAttribute("document"); got != "ref/api.adoc" {
+				t.Errorf("Expected document ref/api.adoc, got %q", got)
+			}
+		})
+	}
+}
+
+func TestUnresolvedXrefs(t *testing.T) {
+	doc, err := Parse(strings.NewReader("== Intro\n\nSee <<intro>>, <<nowhere>> and xref:other.adoc#x[]."))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	unresolved := UnresolvedXrefs(doc)
+	if len(unresolved) != 1 || unresolved[0].Target != "nowhere" || unresolved[0].Start.Line != 3 {
+		t.Fatalf("Expected only <<nowhere>> on line 3 to be unresolved, got %v", unresolved)
+	}
+	if got := getTextContent(xrefNodes(doc)[1]); got != "nowhere" {
+		t.Errorf("Expected an unresolved xref to show its target, got %q", got)
+	}
+}
+
+func TestLinkChecker(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.adoc": "See xref:guide.adoc#setup[], xref:guide.adoc#gone[], xref:missing.adoc[] and xref:extra.adoc[].\n\nAlso <<nowhere>>.",
+		"guide.adoc": "[[setup]]\n== Setup\n\nBack to xref:index.adoc[].",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// extra.adoc exists but isn't part of the batch
+	if err := os.WriteFile(filepath.Join(dir, "extra.adoc"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewLinkChecker()
+	for _, name := range []string{"index.adoc", "guide.adoc"} {
+		path := filepath.Join(dir, name)
+		opts := ParseOptions{DocumentName: path, LinkChecker: checker}
+		if _, err := ParseWithOptions(context.Background(), strings.NewReader(files[name]), opts); err != nil {
+			t.Fatalf("ParseWithOptions failed: %v", err)
+		}
+	}
+
+	var got []string
+	for _, link := range checker.BrokenLinks() {
+		got = append(got, strings.TrimPrefix(link.String(), dir+string(filepath.Separator)))
+	}
+	want := []string{
+		`index.adoc:1:30: cross reference to missing anchor "gone" in guide.adoc`,
+		`index.adoc:1:54: cross reference to missing document "missing.adoc"`,
+		`index.adoc:3:6: cross reference to missing anchor "nowhere"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected broken links:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"embed"
 	"encoding/hex"
@@ -45,9 +46,14 @@ type BatchJobProgress struct {
 	ErrorCount   int       `json:"errorCount"`
 	Errors       []string  `json:"errors,omitempty"`
 	ResultPath   string    `json:"resultPath,omitempty"`
+	BrokenLinks  []string  `json:"brokenLinks,omitempty"` // Cross references that don't resolve, as file:line:column: message
 	LastUpdated  time.Time `json:"-"`
+	cancel       context.CancelFunc // Stops the job's conversions
 }
 
+// batchFileTimeout is the longest a batch job spends converting one file
+const batchFileTimeout = 5 * time.Minute
+
 // WebConfig matches the CLI adc.json format for compatibility
 type WebConfig struct {
 	AutoOverwrite    *bool    `json:"autoOverwrite"`
@@ -111,15 +117,18 @@ func NewServer(port int) *Server {
 
 func (s *Server) runCleanupTask() {
 	for range s.cleanupTicker.C {
-		// Clean up old temp files > 24 hours
-		tempDir := os.TempDir()
-		entries, err := os.ReadDir(tempDir)
-		if err != nil {
-			continue
-		}
-		
-		threshold := time.Now().Add(-24 * time.Hour)
-		
+		s.cleanup()
+	}
+}
+
+// cleanup removes batch temp files and jobs untouched for a day, stopping
+// any such job that is still running
+func (s *Server) cleanup() {
+	threshold := time.Now().Add(-24 * time.Hour)
+
+	// Clean up old temp files > 24 hours
+	tempDir := os.TempDir()
+	if entries, err := os.ReadDir(tempDir); err == nil {
 		for _, entry := range entries {
 			if strings.HasPrefix(entry.Name(), "adc-batch-") || (strings.HasPrefix(entry.Name(), "adc-results-") && strings.HasSuffix(entry.Name(), ".zip")) {
 				info, err := entry.Info()
@@ -129,16 +138,19 @@ func (s *Server) runCleanupTask() {
 				}
 			}
 		}
-		
-		// Clean up old jobs from map
-		s.progressStore.Range(func(key, value interface{}) bool {
-			job := value.(*BatchJobProgress)
-			if job.LastUpdated.Before(threshold) {
-				s.progressStore.Delete(key)
-			}
-			return true
-		})
 	}
+
+	// Clean up old jobs from map
+	s.progressStore.Range(func(key, value interface{}) bool {
+		job := value.(*BatchJobProgress)
+		if job.LastUpdated.Before(threshold) {
+			if job.cancel != nil {
+				job.cancel()
+			}
+			s.progressStore.Delete(key)
+		}
+		return true
+	})
 }
 
 func (s *Server) Start() error {
@@ -339,27 +351,31 @@ func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
 		picoCSSPath = "https://cdn.jsdelivr.net/npm/@picocss/pico@2.1.1/css/pico.min.css"
 	}
 
+	// Conversions stop if the client goes away
+	ctx := r.Context()
 	switch outputType {
 	case "html", "html5":
-		output, err = lib.ConvertToHTML(bytes.NewReader([]byte(req.AsciiDoc)), false, usePicoCSS, picoCSSPath, "")
+		output, err = convertToHTML(ctx, req.AsciiDoc, false, usePicoCSS, picoCSSPath)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Conversion failed: %v", err), http.StatusInternalServerError)
 			return
 		}
 		contentType = "text/html; charset=utf-8"
 	case "xhtml", "xhtml5":
-		output, err = lib.ConvertToHTML(bytes.NewReader([]byte(req.AsciiDoc)), true, usePicoCSS, picoCSSPath, "")
+		output, err = convertToHTML(ctx, req.AsciiDoc, true, usePicoCSS, picoCSSPath)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Conversion failed: %v", err), http.StatusInternalServerError)
 			return
 		}
 		contentType = "application/xhtml+xml; charset=utf-8"
 	case "xml":
-		output, err = lib.ConvertToXML(bytes.NewReader([]byte(req.AsciiDoc)))
+		var doc *lib.Node
+		doc, err = lib.ParseWithOptions(ctx, strings.NewReader(req.AsciiDoc), lib.ParseOptions{})
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Conversion failed: %v", err), http.StatusInternalServerError)
 			return
 		}
+		output = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + lib.ToXML(doc)
 		contentType = "application/xml; charset=utf-8"
 	case "md2adoc":
 		output, err = lib.ConvertMarkdownToAsciiDoc(bytes.NewReader([]byte(req.AsciiDoc)))
@@ -416,6 +432,21 @@ func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// convertToHTML converts AsciiDoc to a standalone HTML or XHTML page,
+// stopping once ctx is done
+func convertToHTML(ctx context.Context, asciidoc string, xhtml, usePicoCSS bool, picoCSSPath string) (string, error) {
+	result, err := lib.ConvertContext(ctx, strings.NewReader(asciidoc), lib.ConvertOptions{
+		UsePicoCSS:  usePicoCSS,
+		Standalone:  true,
+		XHTML:       xhtml,
+		PicoCSSPath: picoCSSPath,
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.HTML, nil
+}
+
 func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -433,13 +464,26 @@ func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-	err = lib.Validate(strings.NewReader(req.AsciiDoc))
+	diagnostics, err := lib.ValidateContext(r.Context(), strings.NewReader(req.AsciiDoc), lib.ParseOptions{})
 	w.Header().Set("Content-Type", "application/json")
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{"valid": false, "error": err.Error()})
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]interface{}{"valid": true})
+	var errs []lib.Diagnostic
+	for _, d := range diagnostics {
+		if d.Severity == lib.SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	if diagnostics == nil {
+		diagnostics = []lib.Diagnostic{}
+	}
+	resp := map[string]interface{}{"valid": len(errs) == 0, "diagnostics": diagnostics}
+	if len(errs) > 0 {
+		resp["error"] = (&lib.ValidationError{Diagnostics: errs}).Error()
+	}
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (s *Server) handleXSLT(w http.ResponseWriter, r *http.Request) {
@@ -914,13 +958,19 @@ func (s *Server) handleBatchProcessFolder(w http.ResponseWriter, r *http.Request
 		return
 	}
 	
+	// The job outlives the request, so it keeps the request's values but not
+	// its cancellation. Cleanup cancels it if it stalls.
+	jobCtx, cancelJob := context.WithCancel(context.WithoutCancel(ctx))
+
 	// Start background processing
 	go func() {
+		defer cancelJob()
 		job := &BatchJobProgress{
 			JobID:       jobID,
 			TotalFiles:  totalFiles,
 			Status:      "processing",
 			LastUpdated: time.Now(),
+			cancel:      cancelJob,
 		}
 		s.progressStore.Store(jobID, job)
 
@@ -996,12 +1046,15 @@ func (s *Server) handleBatchProcessFolder(w http.ResponseWriter, r *http.Request
 		}
 
 		// Process
-		
-		results := lib.ProcessFilesParallel(files, func(file string) error {
+		links := lib.NewLinkChecker()
+		results := lib.ProcessFilesParallelContext(jobCtx, files, func(ctx context.Context, file string) error {
+			// One bad file must not hold a worker for the rest of the job
+			ctx, cancel := context.WithTimeout(ctx, batchFileTimeout)
+			defer cancel()
 			if outputType == "md2adoc" {
 				return s.processMarkdownFile(file, outputType)
 			}
-			return s.processAdocFile(file, outputType)
+			return s.convertAdocFile(ctx, file, outputType, links)
 		}, batchConfig, limits, func(current, total int, file string, err error) {
 			// Update progress
 			if j, ok := s.progressStore.Load(jobID); ok {
@@ -1038,6 +1091,9 @@ func (s *Server) handleBatchProcessFolder(w http.ResponseWriter, r *http.Request
 				job.Status = "failed"
 			}
 			job.ResultPath = archivePath
+			for _, link := range links.BrokenLinks() {
+				job.BrokenLinks = append(job.BrokenLinks, link.String())
+			}
 			job.LastUpdated = time.Now()
 			s.progressStore.Store(jobID, job)
 		}
@@ -1134,6 +1190,12 @@ func (s *Server) processMarkdownFile(mdFile string, outputType string) error {
 }
 
 func (s *Server) processAdocFile(adocFile string, outputType string) error {
+	return s.convertAdocFile(context.Background(), adocFile, outputType, nil)
+}
+
+// convertAdocFile converts adocFile next to itself, adding the document to
+// links if it isn't nil
+func (s *Server) convertAdocFile(ctx context.Context, adocFile string, outputType string, links *lib.LinkChecker) error {
 	if s.logger != nil {
 		s.logger.Debug(nil, "Processing AsciiDoc file",
 			"file", adocFile,
@@ -1158,15 +1220,40 @@ func (s *Server) processAdocFile(adocFile string, outputType string) error {
 	usePico := true
 	picoPath := "https://cdn.jsdelivr.net/npm/@picocss/pico@2.1.1/css/pico.min.css"
 
+	xhtml := false
 	switch outputType {
 	case "html", "html5":
-		output, err = lib.ConvertToHTML(bytes.NewReader(content), false, usePico, picoPath, "")
 		ext = ".html"
 	case "xhtml", "xhtml5":
-		output, err = lib.ConvertToHTML(bytes.NewReader(content), true, usePico, picoPath, "")
 		ext = ".xhtml"
-	default:
-		output, err = lib.ConvertToXML(bytes.NewReader(content))
+		xhtml = true
+	}
+
+	// Inter-document cross references point at the files the batch writes
+	parseOpts := lib.ParseOptions{
+		DocumentName: adocFile,
+		Attributes:   map[string]string{"outfilesuffix": ext + "@"},
+		LinkChecker:  links,
+	}
+	if info, err := os.Stat(adocFile); err == nil {
+		parseOpts.ModTime = info.ModTime()
+	}
+	if ext == ".xml" {
+		var doc *lib.Node
+		doc, err = lib.ParseWithOptions(ctx, bytes.NewReader(content), parseOpts)
+		if err == nil {
+			output = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + lib.ToXML(doc)
+		}
+	} else {
+		var result lib.Result
+		result, err = lib.ConvertContext(ctx, bytes.NewReader(content), lib.ConvertOptions{
+			UsePicoCSS:   usePico,
+			Standalone:   true,
+			XHTML:        xhtml,
+			PicoCSSPath:  picoPath,
+			ParseOptions: parseOpts,
+		})
+		output = result.HTML
 	}
 	
 	if err != nil {
@@ -1245,7 +1332,7 @@ func (s *Server) handleBatchDownloadArchive(w http.ResponseWriter, r *http.Reque
 
 func (s *Server) handleBatchCleanup(w http.ResponseWriter, r *http.Request) {
 	// Manual cleanup trigger
-	s.runCleanupTask()
+	s.cleanup()
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "cleaned"})
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -14,6 +15,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ndx-video/asciidoc-xml/lib"
 )
@@ -151,6 +153,21 @@ func TestServer_handleConvert_MethodNotAllowed(t *testing.T) {
 	}
 }
 
+func TestServer_handleConvert_Cancelled(t *testing.T) {
+	server := NewServer(8005)
+	jsonBody, _ := json.Marshal(map[string]string{"asciidoc": "= Title\n\nText.", "output": "html"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/api/convert", bytes.NewReader(jsonBody)).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	server.handleConvert(w, req)
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "context canceled") {
+		t.Errorf("Expected the conversion to stop with the request, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestServer_handleValidate(t *testing.T) {
 	server := NewServer(8005)
 
@@ -198,6 +215,48 @@ func TestServer_handleValidate(t *testing.T) {
 	}
 }
 
+func TestServer_handleValidate_Diagnostics(t *testing.T) {
+	server := NewServer(8005)
+
+	body := map[string]string{"asciidoc": "= Test\n\n----\nunclosed listing"}
+	jsonBody, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/validate", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	server.handleValidate(w, req)
+
+	var result struct {
+		Valid       bool   `json:"valid"`
+		Error       string `json:"error"`
+		Diagnostics []struct {
+			Severity string `json:"severity"`
+			Code     string `json:"code"`
+			Start    struct {
+				Line int `json:"line"`
+			} `json:"start"`
+		} `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	if result.Valid {
+		t.Error("Expected document with unclosed block to be invalid")
+	}
+	if result.Error == "" {
+		t.Error("Expected error message for invalid document")
+	}
+	if len(result.Diagnostics) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %d", len(result.Diagnostics))
+	}
+	d := result.Diagnostics[0]
+	if d.Severity != "error" || d.Code != "unclosed-delimiter" || d.Start.Line != 3 {
+		t.Errorf("Unexpected diagnostic: %+v", d)
+	}
+}
+
 func TestServer_handleValidate_MethodNotAllowed(t *testing.T) {
 	server := NewServer(8005)
 	req := httptest.NewRequest(http.MethodGet, "/api/validate", nil)
@@ -927,6 +986,12 @@ func TestServer_failJob(t *testing.T) {
 func TestServer_handleBatchCleanup(t *testing.T) {
 	server := NewServer(8005)
 
+	// A job untouched for a day is stopped and dropped; a recent one stays
+	staleCtx, cancelStale := context.WithCancel(context.Background())
+	defer cancelStale()
+	server.progressStore.Store("stale", &BatchJobProgress{JobID: "stale", Status: "processing", LastUpdated: time.Now().Add(-25 * time.Hour), cancel: cancelStale})
+	server.progressStore.Store("recent", &BatchJobProgress{JobID: "recent", Status: "processing", LastUpdated: time.Now()})
+
 	req := httptest.NewRequest(http.MethodPost, "/api/batch/cleanup", nil)
 	w := httptest.NewRecorder()
 
@@ -944,6 +1009,16 @@ func TestServer_handleBatchCleanup(t *testing.T) {
 	if result["status"] != "cleaned" {
 		t.Errorf("Expected status 'cleaned', got '%s'", result["status"])
 	}
+
+	if _, ok := server.progressStore.Load("stale"); ok {
+		t.Error("Expected the stale job to be removed")
+	}
+	if staleCtx.Err() == nil {
+		t.Error("Expected the stale job's context to be cancelled")
+	}
+	if _, ok := server.progressStore.Load("recent"); !ok {
+		t.Error("Expected the recent job to be kept")
+	}
 }
 
 func TestServer_processMarkdownFile(t *testing.T) {
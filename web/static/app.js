@@ -214,7 +214,11 @@ async function validateAsciiDoc() {
         });
 
         const result = await response.json();
-        if (result.valid) {
+        const warnings = (result.diagnostics || []).filter(d => d.severity !== 'error');
+        if (result.valid && warnings.length > 0) {
+            const first = warnings[0];
+            showStatus(`✓ Valid AsciiDoc (${warnings.length} warning${warnings.length === 1 ? '' : 's'}; line ${first.start.line}: ${first.message})`, 'success');
+        } else if (result.valid) {
             showStatus('✓ Valid AsciiDoc', 'success');
         } else {
             showStatus('✗ Invalid: ' + result.error, 'error');
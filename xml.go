@@ -24,8 +24,12 @@ type Header struct {
 
 // Author represents document author
 type Author struct {
-	Name  string `xml:"name"`
-	Email string `xml:"email,omitempty"`
+	Name       string `xml:"name"`
+	FirstName  string `xml:"firstname,omitempty"`
+	MiddleName string `xml:"middlename,omitempty"`
+	LastName   string `xml:"lastname,omitempty"`
+	Initials   string `xml:"initials,omitempty"`
+	Email      string `xml:"email,omitempty"`
 }
 
 // Revision represents document revision info
@@ -67,6 +71,7 @@ type ContentItem struct {
 	ThematicBreak *ThematicBreak `xml:"thematicbreak,omitempty"`
 	Admonition   *Admonition   `xml:"admonition,omitempty"`
 	Passthrough  *Passthrough  `xml:"passthrough,omitempty"`
+	Comment      *Comment      `xml:"comment,omitempty"`
 }
 
 // Section represents a document section
@@ -161,13 +166,25 @@ type Table struct {
 	Role    string    `xml:"role,attr,omitempty"`
 	ID      string    `xml:"id,attr,omitempty"`
 	Cols    string    `xml:"cols,attr,omitempty"`
+	Format  string    `xml:"format,attr,omitempty"` // psv, csv, tsv or dsv
+	Separator string  `xml:"separator,attr,omitempty"`
 	Title   string    `xml:"title,omitempty"`
+	Columns []Column  `xml:"colgroup>col,omitempty"`
 	Header  *TableRow `xml:"header,omitempty"`
 	Rows    []TableRow `xml:"row"`
 }
 
+// Column describes a table column from the cols attribute
+type Column struct {
+	Width  string `xml:"width,attr,omitempty"` // Percentage of the table width
+	Align  string `xml:"align,attr,omitempty"`
+	VAlign string `xml:"valign,attr,omitempty"`
+	Style  string `xml:"style,attr,omitempty"`
+}
+
 // TableRow represents a table row
 type TableRow struct {
+	Role  string      `xml:"role,attr,omitempty"` // header or footer
 	Cells []TableCell `xml:"cell"`
 }
 
@@ -187,6 +204,7 @@ type List struct {
 	Style  string     `xml:"style,attr"`
 	Marker string     `xml:"marker,attr,omitempty"`
 	Start  *int       `xml:"start,attr,omitempty"`
+	Options string    `xml:"options,attr,omitempty"` // e.g. reversed, checklist
 	Role   string     `xml:"role,attr,omitempty"`
 	ID     string     `xml:"id,attr,omitempty"`
 	Items  []ListItem `xml:"item"`
@@ -195,6 +213,8 @@ type List struct {
 // ListItem represents a list item
 type ListItem struct {
 	Marker string        `xml:"marker,attr,omitempty"`
+	Checkbox string      `xml:"checkbox,attr,omitempty"`
+	Checked  string      `xml:"checked,attr,omitempty"`
 	Term   InlineContent `xml:"term,omitempty"`
 	Items  []ListItemContentItem `xml:",any"`
 }
@@ -266,6 +286,12 @@ type Passthrough struct {
 	Content string `xml:",chardata"`
 }
 
+// Comment represents a comment kept by the parser's KeepComments option
+type Comment struct {
+	Type    string `xml:"type,attr,omitempty"` // line or block
+	Content string `xml:",chardata"`
+}
+
 // InlineContent represents inline content (mixed content)
 type InlineContent struct {
 	Items []InlineItem `xml:",any"`
@@ -282,15 +282,21 @@
                     <xsl:if test="@title">
                         <h1 class="document-title"><xsl:value-of select="@title"/></h1>
                     </xsl:if>
-                    <xsl:if test="@author">
+                    <xsl:for-each select="ad:header/ad:author">
                         <div class="document-author">
-                            <xsl:value-of select="@author"/>
-                            <xsl:if test="@email">
+                            <xsl:value-of select="ad:name"/>
+                            <xsl:if test="ad:email">
                                 <xsl:text> </xsl:text>
-                                <a href="mailto:{@email}"><xsl:value-of select="@email"/></a>
+                                <a>
+                                    <xsl:attribute name="href">
+                                        <xsl:if test="not(contains(ad:email, '://'))">mailto:</xsl:if>
+                                        <xsl:value-of select="ad:email"/>
+                                    </xsl:attribute>
+                                    <xsl:value-of select="ad:email"/>
+                                </a>
                             </xsl:if>
                         </div>
-                    </xsl:if>
+                    </xsl:for-each>
                     <xsl:if test="@revnumber or @revdate or @revremark">
                         <div class="document-revision">
                             <xsl:if test="@revnumber">
@@ -313,6 +319,9 @@
         </article>
     </xsl:template>
 
+    <!-- The header is rendered with the document title -->
+    <xsl:template match="ad:header"/>
+
     <!-- Preamble -->
     <xsl:template match="ad:preamble">
         <div class="preamble">
@@ -326,6 +335,10 @@
             <xsl:attribute name="class">
                 <xsl:text>section level-</xsl:text>
                 <xsl:value-of select="@level"/>
+                <xsl:if test="@style">
+                    <xsl:text> </xsl:text>
+                    <xsl:value-of select="@style"/>
+                </xsl:if>
                 <xsl:if test="@role">
                     <xsl:text> </xsl:text>
                     <xsl:value-of select="@role"/>
@@ -340,22 +353,22 @@
                 <xsl:variable name="level" select="number(@level) + 1"/>
                 <xsl:choose>
                     <xsl:when test="$level = 1">
-                        <h1><xsl:value-of select="@title"/></h1>
+                        <h1><xsl:call-template name="section-label"/><xsl:value-of select="@title"/></h1>
                     </xsl:when>
                     <xsl:when test="$level = 2">
-                        <h2><xsl:value-of select="@title"/></h2>
+                        <h2><xsl:call-template name="section-label"/><xsl:value-of select="@title"/></h2>
                     </xsl:when>
                     <xsl:when test="$level = 3">
-                        <h3><xsl:value-of select="@title"/></h3>
+                        <h3><xsl:call-template name="section-label"/><xsl:value-of select="@title"/></h3>
                     </xsl:when>
                     <xsl:when test="$level = 4">
-                        <h4><xsl:value-of select="@title"/></h4>
+                        <h4><xsl:call-template name="section-label"/><xsl:value-of select="@title"/></h4>
                     </xsl:when>
                     <xsl:when test="$level = 5">
-                        <h5><xsl:value-of select="@title"/></h5>
+                        <h5><xsl:call-template name="section-label"/><xsl:value-of select="@title"/></h5>
                     </xsl:when>
                     <xsl:otherwise>
-                        <h6><xsl:value-of select="@title"/></h6>
+                        <h6><xsl:call-template name="section-label"/><xsl:value-of select="@title"/></h6>
                     </xsl:otherwise>
                 </xsl:choose>
             </xsl:if>
@@ -364,8 +377,64 @@
         </section>
     </xsl:template>
 
+    <!-- Section number shown before the title: "1.2. " or "Appendix A: " -->
+    <xsl:template name="section-label">
+        <xsl:if test="@number">
+            <span class="sectnum">
+                <xsl:choose>
+                    <xsl:when test="@caption">
+                        <xsl:value-of select="concat(@caption, ' ', @number, ':')"/>
+                    </xsl:when>
+                    <xsl:otherwise>
+                        <xsl:value-of select="concat(@number, '.')"/>
+                    </xsl:otherwise>
+                </xsl:choose>
+            </span>
+            <xsl:text> </xsl:text>
+        </xsl:if>
+    </xsl:template>
+
+    <!-- Block title with its caption: "Table 1. Title", or an explicit caption as written -->
+    <xsl:template name="block-title">
+        <xsl:choose>
+            <xsl:when test="@caption and @number">
+                <xsl:value-of select="concat(@caption, ' ', @number, '. ')"/>
+            </xsl:when>
+            <xsl:otherwise>
+                <xsl:value-of select="@caption"/>
+            </xsl:otherwise>
+        </xsl:choose>
+        <xsl:value-of select="@title"/>
+    </xsl:template>
+
+    <!-- Discrete headings are headings without a section -->
+    <xsl:template match="ad:section[@discrete = 'true']" priority="1">
+        <xsl:variable name="level">
+            <xsl:choose>
+                <xsl:when test="number(@level) + 1 &gt; 6">6</xsl:when>
+                <xsl:otherwise><xsl:value-of select="number(@level) + 1"/></xsl:otherwise>
+            </xsl:choose>
+        </xsl:variable>
+        <xsl:element name="h{$level}">
+            <xsl:attribute name="class">
+                <xsl:text>discrete</xsl:text>
+                <xsl:if test="@role">
+                    <xsl:text> </xsl:text>
+                    <xsl:value-of select="@role"/>
+                </xsl:if>
+            </xsl:attribute>
+            <xsl:if test="@id">
+                <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
+            </xsl:if>
+            <xsl:value-of select="@title"/>
+        </xsl:element>
+    </xsl:template>
+
     <!-- Paragraphs -->
     <xsl:template match="ad:paragraph">
+        <xsl:if test="@title">
+            <div class="paragraph-title"><xsl:value-of select="@title"/></div>
+        </xsl:if>
         <p>
             <xsl:if test="@id">
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
@@ -384,24 +453,51 @@
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
             </xsl:if>
             <xsl:if test="@title">
-                <div class="codeblock-title"><xsl:value-of select="@title"/></div>
+                <div class="codeblock-title"><xsl:call-template name="block-title"/></div>
             </xsl:if>
             <pre>
                 <xsl:if test="@language">
                     <xsl:attribute name="class">language-<xsl:value-of select="@language"/></xsl:attribute>
                 </xsl:if>
-                <code><xsl:value-of select="."/></code>
+                <!-- Line numbers and highlighted lines are left to client-side scripts -->
+                <xsl:if test="@linenums = 'true'">
+                    <xsl:attribute name="data-linenums">
+                        <xsl:choose>
+                            <xsl:when test="@firstlinenum"><xsl:value-of select="@firstlinenum"/></xsl:when>
+                            <xsl:otherwise>1</xsl:otherwise>
+                        </xsl:choose>
+                    </xsl:attribute>
+                </xsl:if>
+                <xsl:if test="@highlight">
+                    <xsl:attribute name="data-highlight"><xsl:value-of select="@highlight"/></xsl:attribute>
+                </xsl:if>
+                <code><xsl:apply-templates/></code>
             </pre>
         </div>
     </xsl:template>
 
+    <!-- Callout markers in code link to their callout list item -->
+    <xsl:template match="ad:callout">
+        <xsl:choose>
+            <xsl:when test="@target">
+                <a class="conum" id="{@id}" href="#{@target}"><xsl:value-of select="@number"/></a>
+            </xsl:when>
+            <xsl:otherwise>
+                <span class="conum" id="{@id}"><xsl:value-of select="@number"/></span>
+            </xsl:otherwise>
+        </xsl:choose>
+    </xsl:template>
+
     <!-- Literal blocks -->
     <xsl:template match="ad:literalblock">
+        <xsl:if test="@title">
+            <div class="literal-title"><xsl:value-of select="@title"/></div>
+        </xsl:if>
         <pre class="literal">
             <xsl:if test="@id">
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
             </xsl:if>
-            <xsl:value-of select="."/>
+            <xsl:apply-templates/>
         </pre>
     </xsl:template>
 
@@ -412,7 +508,7 @@
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
             </xsl:if>
             <xsl:if test="@title">
-                <div class="example-title"><xsl:value-of select="@title"/></div>
+                <div class="example-title"><xsl:call-template name="block-title"/></div>
             </xsl:if>
             <div class="example-content">
                 <xsl:apply-templates/>
@@ -437,6 +533,9 @@
 
     <!-- Quote blocks -->
     <xsl:template match="ad:quote">
+        <xsl:if test="@title">
+            <div class="quote-title"><xsl:value-of select="@title"/></div>
+        </xsl:if>
         <blockquote>
             <xsl:if test="@id">
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
@@ -460,6 +559,9 @@
             <xsl:if test="@id">
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
             </xsl:if>
+            <xsl:if test="@title">
+                <div class="verseblock-title"><xsl:value-of select="@title"/></div>
+            </xsl:if>
             <xsl:apply-templates/>
             <xsl:if test="@attribution">
                 <footer class="quote-attribution">
@@ -478,6 +580,9 @@
             <xsl:if test="@role">
                 <xsl:attribute name="class">openblock <xsl:value-of select="@role"/></xsl:attribute>
             </xsl:if>
+            <xsl:if test="@title">
+                <div class="openblock-title"><xsl:value-of select="@title"/></div>
+            </xsl:if>
             <xsl:apply-templates/>
         </div>
     </xsl:template>
@@ -489,27 +594,26 @@
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
             </xsl:if>
             <xsl:if test="@title">
-                <div class="table-title"><xsl:value-of select="@title"/></div>
+                <div class="table-title"><xsl:call-template name="block-title"/></div>
             </xsl:if>
             <table>
                 <xsl:if test="@role">
                     <xsl:attribute name="class"><xsl:value-of select="@role"/></xsl:attribute>
                 </xsl:if>
-                <xsl:choose>
-                    <xsl:when test="ad:row[1]/@role = 'header'">
-                        <thead>
-                            <xsl:apply-templates select="ad:row[1]" mode="header"/>
-                        </thead>
-                        <tbody>
-                            <xsl:apply-templates select="ad:row[position() > 1]"/>
-                        </tbody>
-                    </xsl:when>
-                    <xsl:otherwise>
-                        <tbody>
-                            <xsl:apply-templates select="ad:row"/>
-                        </tbody>
-                    </xsl:otherwise>
-                </xsl:choose>
+                <xsl:apply-templates select="ad:colgroup"/>
+                <xsl:if test="ad:row[@role = 'header']">
+                    <thead>
+                        <xsl:apply-templates select="ad:row[@role = 'header']" mode="header"/>
+                    </thead>
+                </xsl:if>
+                <tbody>
+                    <xsl:apply-templates select="ad:row[not(@role = 'header') and not(@role = 'footer')]"/>
+                </tbody>
+                <xsl:if test="ad:row[@role = 'footer']">
+                    <tfoot>
+                        <xsl:apply-templates select="ad:row[@role = 'footer']"/>
+                    </tfoot>
+                </xsl:if>
             </table>
         </div>
     </xsl:template>
@@ -527,39 +631,64 @@
         </tr>
     </xsl:template>
 
+    <!-- Table columns -->
+    <xsl:template match="ad:colgroup">
+        <colgroup>
+            <xsl:for-each select="ad:col">
+                <col>
+                    <xsl:if test="@width">
+                        <xsl:attribute name="style">width: <xsl:value-of select="@width"/>;</xsl:attribute>
+                    </xsl:if>
+                </col>
+            </xsl:for-each>
+        </colgroup>
+    </xsl:template>
+
     <!-- Table cells -->
     <xsl:template match="ad:cell">
-        <td>
-            <xsl:if test="@align">
-                <xsl:attribute name="style">text-align: <xsl:value-of select="@align"/>;</xsl:attribute>
-            </xsl:if>
-            <xsl:if test="@colspan">
-                <xsl:attribute name="colspan"><xsl:value-of select="@colspan"/></xsl:attribute>
-            </xsl:if>
-            <xsl:if test="@rowspan">
-                <xsl:attribute name="rowspan"><xsl:value-of select="@rowspan"/></xsl:attribute>
-            </xsl:if>
-            <xsl:apply-templates/>
-        </td>
+        <xsl:variable name="tag">
+            <xsl:choose>
+                <xsl:when test="@style = 'header'">th</xsl:when>
+                <xsl:otherwise>td</xsl:otherwise>
+            </xsl:choose>
+        </xsl:variable>
+        <xsl:element name="{$tag}">
+            <xsl:call-template name="cell-attributes"/>
+            <xsl:choose>
+                <xsl:when test="@style = 'emphasis'"><em><xsl:apply-templates/></em></xsl:when>
+                <xsl:when test="@style = 'strong'"><strong><xsl:apply-templates/></strong></xsl:when>
+                <xsl:when test="@style = 'monospace'"><code><xsl:apply-templates/></code></xsl:when>
+                <xsl:otherwise><xsl:apply-templates/></xsl:otherwise>
+            </xsl:choose>
+        </xsl:element>
     </xsl:template>
 
     <xsl:template match="ad:cell" mode="header">
         <th>
-            <xsl:if test="@align">
-                <xsl:attribute name="style">text-align: <xsl:value-of select="@align"/>;</xsl:attribute>
-            </xsl:if>
-            <xsl:if test="@colspan">
-                <xsl:attribute name="colspan"><xsl:value-of select="@colspan"/></xsl:attribute>
-            </xsl:if>
-            <xsl:if test="@rowspan">
-                <xsl:attribute name="rowspan"><xsl:value-of select="@rowspan"/></xsl:attribute>
-            </xsl:if>
+            <xsl:call-template name="cell-attributes"/>
             <xsl:apply-templates/>
         </th>
     </xsl:template>
 
+    <xsl:template name="cell-attributes">
+        <xsl:if test="@align or @valign">
+            <xsl:attribute name="style">
+                <xsl:if test="@align">text-align: <xsl:value-of select="@align"/>;</xsl:if>
+                <xsl:if test="@align and @valign"><xsl:text> </xsl:text></xsl:if>
+                <xsl:if test="@valign">vertical-align: <xsl:value-of select="@valign"/>;</xsl:if>
+            </xsl:attribute>
+        </xsl:if>
+        <xsl:if test="@colspan">
+            <xsl:attribute name="colspan"><xsl:value-of select="@colspan"/></xsl:attribute>
+        </xsl:if>
+        <xsl:if test="@rowspan">
+            <xsl:attribute name="rowspan"><xsl:value-of select="@rowspan"/></xsl:attribute>
+        </xsl:if>
+    </xsl:template>
+
     <!-- Lists -->
     <xsl:template match="ad:list[@style='unordered']">
+        <xsl:call-template name="list-title"/>
         <ul>
             <xsl:if test="@id">
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
@@ -572,10 +701,17 @@
     </xsl:template>
 
     <xsl:template match="ad:list[@style='ordered']">
+        <xsl:call-template name="list-title"/>
         <ol>
             <xsl:if test="@id">
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
             </xsl:if>
+            <xsl:if test="@start">
+                <xsl:attribute name="start"><xsl:value-of select="@start"/></xsl:attribute>
+            </xsl:if>
+            <xsl:if test="contains(concat(',', @options, ','), ',reversed,')">
+                <xsl:attribute name="reversed">reversed</xsl:attribute>
+            </xsl:if>
             <xsl:if test="@role">
                 <xsl:attribute name="class"><xsl:value-of select="@role"/></xsl:attribute>
             </xsl:if>
@@ -584,6 +720,7 @@
     </xsl:template>
 
     <xsl:template match="ad:list[@style='labeled']">
+        <xsl:call-template name="list-title"/>
         <dl>
             <xsl:if test="@id">
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
@@ -591,7 +728,7 @@
             <xsl:if test="@role">
                 <xsl:attribute name="class"><xsl:value-of select="@role"/></xsl:attribute>
             </xsl:if>
-            <xsl:apply-templates select="ad:item" mode="labeled"/>
+            <xsl:apply-templates select="ad:listitem" mode="labeled"/>
         </dl>
     </xsl:template>
 
@@ -604,22 +741,50 @@
         </ol>
     </xsl:template>
 
+    <xsl:template name="list-title">
+        <xsl:if test="@title">
+            <div class="list-title"><xsl:value-of select="@title"/></div>
+        </xsl:if>
+    </xsl:template>
+
     <!-- List items -->
     <xsl:template match="ad:listitem">
         <li>
+            <xsl:if test="@id">
+                <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
+            </xsl:if>
             <xsl:if test="@callout">
                 <xsl:attribute name="data-callout"><xsl:value-of select="@callout"/></xsl:attribute>
             </xsl:if>
+            <xsl:if test="@coids">
+                <xsl:variable name="coid">
+                    <xsl:choose>
+                        <xsl:when test="contains(@coids, ' ')"><xsl:value-of select="substring-before(@coids, ' ')"/></xsl:when>
+                        <xsl:otherwise><xsl:value-of select="@coids"/></xsl:otherwise>
+                    </xsl:choose>
+                </xsl:variable>
+                <a class="conum" href="#{$coid}"><xsl:value-of select="@callout"/></a>
+                <xsl:text> </xsl:text>
+            </xsl:if>
+            <xsl:if test="@checkbox">
+                <input type="checkbox" disabled="disabled">
+                    <xsl:if test="@checked">
+                        <xsl:attribute name="checked">checked</xsl:attribute>
+                    </xsl:if>
+                </input>
+                <xsl:text> </xsl:text>
+            </xsl:if>
             <xsl:apply-templates/>
         </li>
     </xsl:template>
 
+    <!-- Labeled items hold the term paragraph, the description paragraph, then any attached blocks -->
     <xsl:template match="ad:listitem" mode="labeled">
         <xsl:if test="@term">
-            <dt><xsl:value-of select="@term"/></dt>
+            <dt><xsl:apply-templates select="ad:paragraph[1]/node()"/></dt>
         </xsl:if>
         <dd>
-            <xsl:apply-templates/>
+            <xsl:apply-templates select="*[position() &gt; 1]"/>
         </dd>
     </xsl:template>
 
@@ -637,24 +802,21 @@
             <xsl:if test="@id">
                 <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
             </xsl:if>
-            <xsl:if test="@title">
-                <div class="admonition-title">
-                    <xsl:value-of select="@title"/>
-                </div>
-            </xsl:if>
-            <xsl:if test="not(@title)">
-                <div class="admonition-title">
-                    <xsl:choose>
-                        <xsl:when test="@type='note'">Note</xsl:when>
-                        <xsl:when test="@type='tip'">Tip</xsl:when>
-                        <xsl:when test="@type='important'">Important</xsl:when>
-                        <xsl:when test="@type='warning'">Warning</xsl:when>
-                        <xsl:when test="@type='caution'">Caution</xsl:when>
-                        <xsl:otherwise><xsl:value-of select="@type"/></xsl:otherwise>
-                    </xsl:choose>
-                </div>
-            </xsl:if>
+            <div class="admonition-title">
+                <xsl:choose>
+                    <xsl:when test="@type='note'">Note</xsl:when>
+                    <xsl:when test="@type='tip'">Tip</xsl:when>
+                    <xsl:when test="@type='important'">Important</xsl:when>
+                    <xsl:when test="@type='warning'">Warning</xsl:when>
+                    <xsl:when test="@type='caution'">Caution</xsl:when>
+                    <xsl:otherwise><xsl:value-of select="@type"/></xsl:otherwise>
+                </xsl:choose>
+            </div>
+            <!-- Any number of blocks, from NOTE: text, [NOTE] or a [NOTE] ==== block -->
             <div class="admonition-content">
+                <xsl:if test="@title">
+                    <div class="admonition-block-title"><xsl:call-template name="block-title"/></div>
+                </xsl:if>
                 <xsl:apply-templates/>
             </div>
         </div>
@@ -678,7 +840,7 @@
                 </xsl:if>
             </img>
             <xsl:if test="@title">
-                <figcaption><xsl:value-of select="@title"/></figcaption>
+                <figcaption><xsl:call-template name="block-title"/></figcaption>
             </xsl:if>
         </figure>
     </xsl:template>
@@ -693,6 +855,15 @@
         <div class="pagebreak"></div>
     </xsl:template>
 
+    <!-- Comment -->
+    <xsl:template match="ad:comment">
+        <xsl:comment>
+            <xsl:text> </xsl:text>
+            <xsl:value-of select="."/>
+            <xsl:text> </xsl:text>
+        </xsl:comment>
+    </xsl:template>
+
     <!-- Macros -->
     <xsl:template match="ad:macro[@type='block' and @name='image']">
         <figure class="image">
@@ -702,7 +873,7 @@
                 </xsl:if>
             </img>
             <xsl:if test="@title">
-                <figcaption><xsl:value-of select="@title"/></figcaption>
+                <figcaption><xsl:call-template name="block-title"/></figcaption>
             </xsl:if>
         </figure>
     </xsl:template>
@@ -714,6 +885,81 @@
         </div>
     </xsl:template>
 
+    <!-- Cross references -->
+    <xsl:template match="ad:macro[@type='inline' and @name='xref']">
+        <a>
+            <xsl:attribute name="href">
+                <xsl:choose>
+                    <xsl:when test="@href"><xsl:value-of select="@href"/></xsl:when>
+                    <xsl:otherwise>#<xsl:value-of select="@target"/></xsl:otherwise>
+                </xsl:choose>
+            </xsl:attribute>
+            <xsl:apply-templates/>
+        </a>
+    </xsl:template>
+
+    <!-- Inline images, linked if they have a link -->
+    <xsl:template match="ad:macro[@type='inline' and @name='image']">
+        <span class="image {@role}">
+            <xsl:choose>
+                <xsl:when test="@link">
+                    <a href="{@link}"><xsl:call-template name="inline-image"/></a>
+                </xsl:when>
+                <xsl:otherwise>
+                    <xsl:call-template name="inline-image"/>
+                </xsl:otherwise>
+            </xsl:choose>
+        </span>
+    </xsl:template>
+
+    <xsl:template name="inline-image">
+        <img src="{@src}" alt="{@alt}">
+            <xsl:for-each select="@width | @height | @title">
+                <xsl:attribute name="{name()}"><xsl:value-of select="."/></xsl:attribute>
+            </xsl:for-each>
+        </img>
+    </xsl:template>
+
+    <!-- Icons: an icon font glyph, an image or the name in brackets, by mode -->
+    <xsl:template match="ad:macro[@type='inline' and @name='icon']">
+        <span class="icon {@role}">
+            <xsl:choose>
+                <xsl:when test="@mode = 'font'">
+                    <i class="fa fa-{@target}">
+                        <xsl:if test="@size">
+                            <xsl:attribute name="class">fa fa-<xsl:value-of select="@target"/> fa-<xsl:value-of select="@size"/></xsl:attribute>
+                        </xsl:if>
+                        <xsl:if test="@title">
+                            <xsl:attribute name="title"><xsl:value-of select="@title"/></xsl:attribute>
+                        </xsl:if>
+                    </i>
+                </xsl:when>
+                <xsl:when test="@mode = 'image'">
+                    <img src="{@src}" alt="{@target}"/>
+                </xsl:when>
+                <xsl:otherwise>[<xsl:value-of select="@target"/>]</xsl:otherwise>
+            </xsl:choose>
+        </span>
+    </xsl:template>
+
+    <!-- Inline STEM: math is delimited for MathJax or KaTeX, which read the decoded text -->
+    <xsl:template match="ad:stem">
+        <span class="stem" data-notation="{@notation}">
+            <xsl:choose>
+                <xsl:when test="@notation = 'latexmath'">
+                    <xsl:text>\(</xsl:text>
+                    <xsl:value-of select="."/>
+                    <xsl:text>\)</xsl:text>
+                </xsl:when>
+                <xsl:otherwise>
+                    <xsl:text>\$</xsl:text>
+                    <xsl:value-of select="."/>
+                    <xsl:text>\$</xsl:text>
+                </xsl:otherwise>
+            </xsl:choose>
+        </span>
+    </xsl:template>
+
     <!-- Generic inline macros -->
     <xsl:template match="ad:macro[@type='inline']">
         <span class="macro macro-{@name}">
@@ -723,27 +969,75 @@
 
     <!-- Inline elements -->
     <xsl:template match="ad:strong">
-        <strong><xsl:apply-templates/></strong>
+        <strong>
+            <xsl:if test="@id">
+                <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
+            </xsl:if>
+            <xsl:if test="@role">
+                <xsl:attribute name="class"><xsl:value-of select="@role"/></xsl:attribute>
+            </xsl:if>
+            <xsl:apply-templates/>
+        </strong>
     </xsl:template>
 
     <xsl:template match="ad:emphasis">
-        <em><xsl:apply-templates/></em>
+        <em>
+            <xsl:if test="@id">
+                <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
+            </xsl:if>
+            <xsl:if test="@role">
+                <xsl:attribute name="class"><xsl:value-of select="@role"/></xsl:attribute>
+            </xsl:if>
+            <xsl:apply-templates/>
+        </em>
     </xsl:template>
 
     <xsl:template match="ad:monospace">
-        <code><xsl:apply-templates/></code>
+        <code>
+            <xsl:if test="@id">
+                <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
+            </xsl:if>
+            <xsl:if test="@role">
+                <xsl:attribute name="class"><xsl:value-of select="@role"/></xsl:attribute>
+            </xsl:if>
+            <xsl:apply-templates/>
+        </code>
     </xsl:template>
 
     <xsl:template match="ad:superscript">
-        <sup><xsl:apply-templates/></sup>
+        <sup>
+            <xsl:if test="@id">
+                <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
+            </xsl:if>
+            <xsl:if test="@role">
+                <xsl:attribute name="class"><xsl:value-of select="@role"/></xsl:attribute>
+            </xsl:if>
+            <xsl:apply-templates/>
+        </sup>
     </xsl:template>
 
     <xsl:template match="ad:subscript">
-        <sub><xsl:apply-templates/></sub>
+        <sub>
+            <xsl:if test="@id">
+                <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
+            </xsl:if>
+            <xsl:if test="@role">
+                <xsl:attribute name="class"><xsl:value-of select="@role"/></xsl:attribute>
+            </xsl:if>
+            <xsl:apply-templates/>
+        </sub>
     </xsl:template>
 
     <xsl:template match="ad:highlight">
-        <mark><xsl:apply-templates/></mark>
+        <mark>
+            <xsl:if test="@id">
+                <xsl:attribute name="id"><xsl:value-of select="@id"/></xsl:attribute>
+            </xsl:if>
+            <xsl:if test="@role">
+                <xsl:attribute name="class"><xsl:value-of select="@role"/></xsl:attribute>
+            </xsl:if>
+            <xsl:apply-templates/>
+        </mark>
     </xsl:template>
 
     <!-- Links -->
@@ -772,6 +1066,26 @@
         <a id="{@id}"></a>
     </xsl:template>
 
+    <!-- Index terms: the generated index links to their IDs -->
+    <xsl:template match="ad:indexterm">
+        <xsl:choose>
+            <xsl:when test="node()">
+                <span class="indexterm" id="{@id}"><xsl:apply-templates/></span>
+            </xsl:when>
+            <xsl:otherwise>
+                <a class="indexterm" id="{@id}"></a>
+            </xsl:otherwise>
+        </xsl:choose>
+    </xsl:template>
+
+    <!-- Bibliography entries show their label -->
+    <xsl:template match="ad:bibliographyanchor">
+        <a class="bibliography-anchor" id="{@id}"></a>
+        <xsl:text>[</xsl:text>
+        <xsl:value-of select="@label"/>
+        <xsl:text>]</xsl:text>
+    </xsl:template>
+
     <!-- Footnotes -->
     <xsl:template match="ad:footnote">
         <sup class="footnote">
@@ -786,11 +1100,34 @@
         </sup>
     </xsl:template>
 
+    <!-- Hard line breaks -->
+    <xsl:template match="ad:linebreak">
+        <br/>
+    </xsl:template>
+
     <!-- Passthrough -->
     <xsl:template match="ad:passthrough">
         <xsl:value-of select="." disable-output-escaping="yes"/>
     </xsl:template>
 
+    <!-- Stem block: math is delimited for MathJax or KaTeX, which read the decoded text -->
+    <xsl:template match="ad:passthrough[@style]">
+        <div class="stem" data-notation="{@style}">
+            <xsl:choose>
+                <xsl:when test="@style = 'latexmath'">
+                    <xsl:text>\[</xsl:text>
+                    <xsl:value-of select="."/>
+                    <xsl:text>\]</xsl:text>
+                </xsl:when>
+                <xsl:otherwise>
+                    <xsl:text>\$</xsl:text>
+                    <xsl:value-of select="."/>
+                    <xsl:text>\$</xsl:text>
+                </xsl:otherwise>
+            </xsl:choose>
+        </div>
+    </xsl:template>
+
     <!-- Text nodes -->
     <xsl:template match="text()">
         <xsl:value-of select="."/>